@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ACMETokenStore provides the key authorizations of pending ACME
+// HTTP-01 challenges, as specified in RFC 8555 section 8.3.
+type ACMETokenStore interface {
+	// KeyAuthorization returns the key authorization for token. It
+	// returns NotFoundError if there is no such challenge.
+	KeyAuthorization(ctx context.Context, token string) (string, error)
+}
+
+// ACMEChallengeHandler returns a handler that answers ACME HTTP-01
+// challenges at /.well-known/acme-challenge/{token} with the key
+// authorizations of store, for services that terminate TLS themselves.
+// It can be mounted directly, or registered with a WellKnown registry:
+//
+//	wk.Handle("acme-challenge", httputil.ACMEChallengeHandler(store))
+func ACMEChallengeHandler(store ACMETokenStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGetOrHead(r) {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, r, InvalidMethodError{})
+			return
+		}
+		const prefix = "/acme-challenge/"
+		i := strings.LastIndex(r.URL.Path, prefix)
+		if i < 0 {
+			writeJSONError(w, r, NotFoundError{})
+			return
+		}
+		token := r.URL.Path[i+len(prefix):]
+		if !isACMEToken(token) {
+			writeJSONError(w, r, NotFoundError{})
+			return
+		}
+		keyAuth, err := store.KeyAuthorization(r.Context(), token)
+		if err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		if r.Method != "HEAD" {
+			w.Write([]byte(keyAuth))
+		}
+	})
+}
+
+// isACMEToken returns true if token only consists of characters of the
+// base64url alphabet, as required by RFC 8555.
+func isACMEToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MemoryACMETokenStore is an ACMETokenStore in memory. It is safe for
+// concurrent use.
+type MemoryACMETokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// NewMemoryACMETokenStore returns an empty store.
+func NewMemoryACMETokenStore() *MemoryACMETokenStore {
+	return &MemoryACMETokenStore{tokens: make(map[string]string)}
+}
+
+// Put adds the key authorization for token, e.g. from the Present
+// callback of an ACME client.
+func (s *MemoryACMETokenStore) Put(token, keyAuth string) {
+	s.mu.Lock()
+	s.tokens[token] = keyAuth
+	s.mu.Unlock()
+}
+
+// Delete removes token, e.g. once the challenge is completed.
+func (s *MemoryACMETokenStore) Delete(token string) {
+	s.mu.Lock()
+	delete(s.tokens, token)
+	s.mu.Unlock()
+}
+
+// KeyAuthorization returns the key authorization for token.
+func (s *MemoryACMETokenStore) KeyAuthorization(ctx context.Context, token string) (string, error) {
+	s.mu.RLock()
+	keyAuth, found := s.tokens[token]
+	s.mu.RUnlock()
+	if !found {
+		return "", NotFoundError{}
+	}
+	return keyAuth, nil
+}
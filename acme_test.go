@@ -0,0 +1,63 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestACMEChallengeHandler(t *testing.T) {
+	store := NewMemoryACMETokenStore()
+	store.Put("evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA", "evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA.9jg46WB3rR_AHD-EBXdN7cBkH1WOu0tA3M9fm21mqTI")
+	store.Put("deleted", "deleted.thumbprint")
+	store.Delete("deleted")
+
+	wk := NewWellKnown()
+	wk.Handle("acme-challenge", ACMEChallengeHandler(store))
+
+	tests := []struct {
+		Handler http.Handler
+		Method  string
+		Path    string
+		Code    int
+		Body    string
+	}{
+		{
+			Handler: ACMEChallengeHandler(store),
+			Method:  "GET",
+			Path:    "/.well-known/acme-challenge/evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA",
+			Code:    http.StatusOK,
+			Body:    "evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA.9jg46WB3rR_AHD-EBXdN7cBkH1WOu0tA3M9fm21mqTI",
+		},
+		{
+			Handler: wk,
+			Method:  "GET",
+			Path:    "/.well-known/acme-challenge/evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA",
+			Code:    http.StatusOK,
+			Body:    "evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA.9jg46WB3rR_AHD-EBXdN7cBkH1WOu0tA3M9fm21mqTI",
+		},
+		{Handler: wk, Method: "GET", Path: "/.well-known/acme-challenge/deleted", Code: http.StatusNotFound},
+		{Handler: wk, Method: "GET", Path: "/.well-known/acme-challenge/", Code: http.StatusNotFound},
+		{Handler: wk, Method: "GET", Path: "/.well-known/acme-challenge/a.b", Code: http.StatusNotFound},
+		{Handler: wk, Method: "POST", Path: "/.well-known/acme-challenge/deleted", Code: http.StatusMethodNotAllowed},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.Handler.ServeHTTP(w, httptest.NewRequest(tt.Method, tt.Path, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want %q, have %q", i, want, have)
+			}
+			if want, have := "text/plain", w.Header().Get("Content-Type"); want != have {
+				t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// maxDigestBodySize is the maximum size of a body read by BodyDigest.
+const maxDigestBodySize = 8 << 20
+
+// BodyDigest returns the hex-encoded SHA-256 hash of the JSON body of r
+// in canonical form: Insignificant white space is removed and object keys
+// are sorted, so semantically equal payloads like `{"a":1, "b":2}` and
+// `{"b":2,"a":1}` have the same digest. Numbers are kept as written.
+// The body is restored afterwards, so handlers can still read it.
+//
+// BodyDigest can be used to derive idempotency and cache keys, or to detect
+// duplicate submits, see PreventDuplicateSubmits. An empty body results in
+// the digest of "null". Invalid JSON results in InvalidJSONError.
+func BodyDigest(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxDigestBodySize+1))
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		if len(data) > maxDigestBodySize {
+			return "", RequestEntityTooLargeError{}
+		}
+		body = data
+	}
+	canonical, err := canonicalJSON(body)
+	if err != nil {
+		return "", InvalidJSONError{fmt.Errorf("invalid JSON data: %v", err)}
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON returns data in canonical form. It relies on
+// encoding/json sorting map keys.
+func canonicalJSON(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte("null"), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// DuplicateSubmitConfig configures the PreventDuplicateSubmits middleware.
+type DuplicateSubmitConfig struct {
+	// Store records the submits. It is required.
+	Store NonceStore
+	// Window is the time in which an identical submit is rejected.
+	// It defaults to 10 seconds.
+	Window time.Duration
+	// Client identifies the submitter, e.g. by user ID, so that identical
+	// payloads of different clients don't conflict. It defaults to ClientIP.
+	Client func(r *http.Request) string
+}
+
+// PreventDuplicateSubmits returns a middleware that rejects POST, PUT,
+// and PATCH requests with ConflictError if the same client has sent the
+// same JSON payload (see BodyDigest) to the same URL within the window,
+// e.g. because of a double click on a submit button.
+//
+// If the handler fails with a 5xx status code or panics, and Store
+// implements NonceReleaser, the submit is forgotten, so the client can
+// retry it right away.
+func PreventDuplicateSubmits(cfg DuplicateSubmitConfig) func(http.Handler) http.Handler {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = ClientIP
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			digest, err := BodyDigest(r)
+			if err != nil {
+				writeJSONError(w, r, err)
+				return
+			}
+			key := cfg.Client(r) + " " + r.Method + " " + r.URL.RequestURI() + " " + digest
+			ok, err := cfg.Store.UseNonce(r.Context(), key, time.Now().Add(cfg.Window))
+			if err != nil {
+				writeJSONError(w, r, ServerError("Unable to check for duplicate submits"))
+				return
+			}
+			if !ok {
+				writeJSONError(w, r, ConflictError{})
+				return
+			}
+			releaser, _ := cfg.Store.(NonceReleaser)
+			if releaser == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			release := func() {
+				releaser.ReleaseNonce(context.WithoutCancel(r.Context()), key)
+			}
+			defer func() {
+				if err := recover(); err != nil {
+					release()
+					panic(err)
+				}
+			}()
+			sw := &statusResponseWriter{responseWriter: responseWriter{w}}
+			next.ServeHTTP(wrapResponseWriter(sw), r)
+			if sw.status() >= 500 {
+				release()
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyDigest(t *testing.T) {
+	digest := func(body string) string {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		d, err := BodyDigest(req)
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		// The body must still be readable
+		data, _ := ioutil.ReadAll(req.Body)
+		if want, have := body, string(data); want != have {
+			t.Fatalf("want body %q, have %q", want, have)
+		}
+		return d
+	}
+
+	a := digest(`{"name":"Oliver","tags":["a","b"],"n":1}`)
+	b := digest(` { "n": 1, "tags": [ "a", "b" ], "name": "Oliver" } `)
+	if a != b {
+		t.Errorf("want equal digests, have %s and %s", a, b)
+	}
+	if c := digest(`{"name":"Oliver","tags":["b","a"],"n":1}`); a == c {
+		t.Error("want different digests for different array order")
+	}
+	if want, have := 64, len(a); want != have {
+		t.Errorf("want digest of length %d, have %d", want, have)
+	}
+	if want, have := digest(""), digest("null"); want != have {
+		t.Errorf("want empty body to equal null, have %s and %s", want, have)
+	}
+
+	for _, body := range []string{`{`, `{"a":1} {"b":2}`} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if _, err := BodyDigest(req); err == nil {
+			t.Errorf("%s: want error", body)
+		} else if _, ok := err.(InvalidJSONError); !ok {
+			t.Errorf("%s: want InvalidJSONError, have %T", body, err)
+		}
+	}
+}
+
+func TestPreventDuplicateSubmits(t *testing.T) {
+	var calls int
+	h := PreventDuplicateSubmits(DuplicateSubmitConfig{
+		Store:  &MemoryNonceStore{},
+		Window: time.Minute,
+		Client: func(r *http.Request) string { return r.Header.Get("X-User") },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Write(data)
+	}))
+
+	tests := []struct {
+		Method string
+		User   string
+		Body   string
+		Code   int
+	}{
+		{Method: "POST", User: "1", Body: `{"amount":10,"to":"bob"}`, Code: http.StatusOK},
+		{Method: "POST", User: "1", Body: `{"to":"bob","amount":10}`, Code: http.StatusConflict},
+		{Method: "POST", User: "2", Body: `{"to":"bob","amount":10}`, Code: http.StatusOK},
+		{Method: "POST", User: "1", Body: `{"to":"bob","amount":11}`, Code: http.StatusOK},
+		{Method: "GET", User: "1", Body: ``, Code: http.StatusOK},
+		{Method: "GET", User: "1", Body: ``, Code: http.StatusOK},
+		{Method: "POST", User: "1", Body: `{`, Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.Method, "/payments", strings.NewReader(tt.Body))
+		req.Header.Set("X-User", tt.User)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		}
+	}
+	if want, have := 5, calls; want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestPreventDuplicateSubmitsFailure(t *testing.T) {
+	var calls int
+	h := PreventDuplicateSubmits(DuplicateSubmitConfig{
+		Store:  &MemoryNonceStore{},
+		Window: time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		calls++
+		switch calls {
+		case 1:
+			WriteJSONError(w, ServiceUnavailableError{})
+		case 2:
+			panic(ServerError("boom"))
+		case 3:
+			WriteJSONError(w, UnprocessableEntityError{})
+		}
+	}))
+
+	// Failures with 5xx are forgotten, others are not
+	codes := []int{
+		http.StatusServiceUnavailable,
+		http.StatusInternalServerError,
+		http.StatusUnprocessableEntity,
+		http.StatusConflict,
+	}
+	for i, code := range codes {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/payments", strings.NewReader(`{"amount":10}`)))
+		if want, have := code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+	if want, have := 3, calls; want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// BudgetLimit is the maximum cost of a request in one dimension,
+// e.g. the number of database queries.
+type BudgetLimit struct {
+	// Max is the maximum total cost.
+	Max float64
+	// Code is the HTTP status code of BudgetExceededError. It defaults
+	// to 429 Too Many Requests. Use 503 Service Unavailable for costs
+	// that depend on the load of the service rather than the request.
+	Code int
+}
+
+// BudgetExceededError indicates that a request has exceeded its budget
+// in a dimension.
+type BudgetExceededError struct {
+	Dimension string
+	Max       float64
+	Used      float64
+	Code      int
+}
+
+// Error returns the error in text form.
+func (e BudgetExceededError) Error() string {
+	return fmt.Sprintf("Request budget for %q exceeded", e.Dimension)
+}
+
+// HTTPCode returns the HTTP status code of the error.
+func (e BudgetExceededError) HTTPCode() int {
+	if e.Code == 0 {
+		return http.StatusTooManyRequests
+	}
+	return e.Code
+}
+
+// ErrorDetails returns the exceeded dimension and its usage.
+func (e BudgetExceededError) ErrorDetails() []string {
+	return []string{fmt.Sprintf("Cost of %s is %g, which exceeds the maximum of %g", e.Dimension, e.Used, e.Max)}
+}
+
+// ErrorReason returns "BUDGET_EXCEEDED".
+func (BudgetExceededError) ErrorReason() string { return "BUDGET_EXCEEDED" }
+
+// Budget limits the cost of a request in several dimensions, e.g.
+// database queries or upstream calls, so pathological combinations of
+// parameters can't bring down aggregate endpoints. Handlers and helpers
+// charge against the Budget of the request context, see ChargeBudget.
+// It is safe for concurrent use.
+type Budget struct {
+	limits map[string]BudgetLimit
+	cancel context.CancelCauseFunc
+
+	mu   sync.Mutex
+	used map[string]float64
+}
+
+// NewBudget returns a Budget with the given limits by dimension.
+// Dimensions without limit are tracked, but not limited.
+func NewBudget(limits map[string]BudgetLimit) *Budget {
+	return &Budget{limits: limits, used: make(map[string]float64)}
+}
+
+// Charge adds cost to the dimension. It returns BudgetExceededError if
+// that exceeds the limit of the dimension. In EnforceBudget, the request
+// context is also canceled with that error as cause.
+func (b *Budget) Charge(dimension string, cost float64) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	b.used[dimension] += cost
+	used := b.used[dimension]
+	b.mu.Unlock()
+
+	limit, found := b.limits[dimension]
+	if !found || used <= limit.Max {
+		return nil
+	}
+	err := BudgetExceededError{Dimension: dimension, Max: limit.Max, Used: used, Code: limit.Code}
+	if b.cancel != nil {
+		b.cancel(err)
+	}
+	return err
+}
+
+// Used returns the total cost charged to the dimension.
+func (b *Budget) Used(dimension string) float64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used[dimension]
+}
+
+// String returns the costs of all dimensions, e.g. "db=3 upstream=1".
+func (b *Budget) String() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.used))
+	for name := range b.used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := ""
+	for i, name := range names {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%s=%g", name, b.used[name])
+	}
+	return s
+}
+
+type budgetContextKey struct{}
+
+// WithBudget returns a copy of ctx with b.
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetContextKey{}, b)
+}
+
+// BudgetFromContext returns the Budget of ctx, or nil if there is none.
+// Charging a nil Budget has no effect.
+func BudgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetContextKey{}).(*Budget)
+	return b
+}
+
+// ChargeBudget charges cost to the dimension of the Budget of ctx.
+//
+// Example:
+//
+//	for _, id := range ids {
+//	  if err := httputil.ChargeBudget(r.Context(), "db", 1); err != nil {
+//	    httputil.ServeJSONError(w, r, err)
+//	    return
+//	  }
+//	  ...
+//	}
+func ChargeBudget(ctx context.Context, dimension string, cost float64) error {
+	return BudgetFromContext(ctx).Charge(dimension, cost)
+}
+
+// EnforceBudget returns a middleware that attaches a new Budget with the
+// given limits to each request. Once the budget is exceeded, the request
+// context is canceled, so pending queries and upstream calls are aborted,
+// and BudgetExceededError is returned to the client unless the handler
+// has already responded.
+func EnforceBudget(limits map[string]BudgetLimit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancelCause(r.Context())
+			defer cancel(nil)
+			b := NewBudget(limits)
+			b.cancel = cancel
+			r = r.WithContext(WithBudget(ctx, b))
+
+			sw := &statusResponseWriter{responseWriter: responseWriter{w}}
+			next.ServeHTTP(wrapResponseWriter(sw), r)
+			if sw.code == 0 {
+				if err, ok := context.Cause(ctx).(BudgetExceededError); ok {
+					writeJSONError(w, r, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnforceBudget(t *testing.T) {
+	limits := map[string]BudgetLimit{
+		"db":       {Max: 3},
+		"upstream": {Max: 1, Code: http.StatusServiceUnavailable},
+	}
+	h := EnforceBudget(limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		for i := 0; i < QueryInt(r, "queries", 0); i++ {
+			if err := ChargeBudget(ctx, "db", 1); err != nil {
+				WriteJSONError(w, err)
+				return
+			}
+		}
+		for i := 0; i < QueryInt(r, "calls", 0); i++ {
+			// Ignores the error, but the context is canceled
+			ChargeBudget(ctx, "upstream", 1)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		ChargeBudget(ctx, "cpu", 100)
+		w.Write([]byte(BudgetFromContext(ctx).String()))
+	}))
+
+	tests := []struct {
+		URL    string
+		Code   int
+		Body   string
+		Detail string
+	}{
+		{URL: "/?queries=3&calls=1", Code: http.StatusOK, Body: "cpu=100 db=3 upstream=1"},
+		{URL: "/?queries=4", Code: http.StatusTooManyRequests, Detail: "Cost of db is 4, which exceeds the maximum of 3"},
+		{URL: "/?calls=2", Code: http.StatusServiceUnavailable, Detail: "Cost of upstream is 2, which exceeds the maximum of 1"},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.URL, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want %q, have %q", i, want, have)
+			}
+			continue
+		}
+		e := ErrorEnvelopeOf(t, w).Error
+		if want, have := "BUDGET_EXCEEDED", e.Reason; want != have {
+			t.Errorf("#%d: want reason %q, have %q", i, want, have)
+		}
+		if len(e.Details) != 1 || e.Details[0] != tt.Detail {
+			t.Errorf("#%d: want detail %q, have %v", i, tt.Detail, e.Details)
+		}
+	}
+}
+
+func TestBudgetWithoutContext(t *testing.T) {
+	if err := ChargeBudget(context.Background(), "db", 1000); err != nil {
+		t.Errorf("want no error without budget, have %v", err)
+	}
+	if have := BudgetFromContext(context.Background()).Used("db"); have != 0 {
+		t.Errorf("want no usage, have %v", have)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// BufferResponses returns a middleware that buffers the output of the
+// handler up to max bytes before sending it to the client. This allows
+// to still return a clean JSON error if the handler panics or writes an
+// error after it has partially written its response:
+//
+//   - If the handler panics, the buffered output is discarded and the
+//     panic is written with WriteJSONError.
+//   - If the handler sets a HTTP status code after having written parts
+//     of the body, e.g. by calling WriteJSONError, the buffered output
+//     is discarded in favor of the new response.
+//
+// In both cases, the headers are reset to their state at the time
+// buffering began, so e.g. the ETag of a discarded response doesn't leak
+// into the error. Headers that are set before BufferResponses, e.g. by
+// outer middlewares, are kept.
+//
+// Once the output exceeds max bytes, or the handler calls Flush or
+// DisableBuffering, the response is streamed to the client as usual.
+// Use the latter in streaming handlers, e.g. for Server-Sent Events.
+func BufferResponses(max int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw := &bufferedResponseWriter{
+				responseWriter: responseWriter{w},
+				max:            max,
+				code:           http.StatusOK,
+				header:         w.Header().Clone(),
+			}
+			defer func() {
+				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler || bw.streaming {
+						// Too late to write an error: abort the response
+						panic(http.ErrAbortHandler)
+					}
+					bw.discard()
+					writeJSONError(w, r, err)
+					return
+				}
+				bw.commit()
+			}()
+			next.ServeHTTP(wrapResponseWriter(bw), r)
+		})
+	}
+}
+
+// DisableBuffering makes sure that the response written to w gets
+// streamed to the client, even if BufferResponses is in use.
+func DisableBuffering(w http.ResponseWriter) {
+	for {
+		switch x := w.(type) {
+		case *bufferedResponseWriter:
+			x.DisableBuffering()
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// discardBufferedOutput discards the output that BufferResponses has
+// buffered for w so far, if any, including the headers. It is called by
+// the error writers, which replace the response.
+func discardBufferedOutput(w http.ResponseWriter) {
+	for {
+		switch x := w.(type) {
+		case *bufferedResponseWriter:
+			x.discard()
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// bufferedResponseWriter is the http.ResponseWriter used by BufferResponses.
+type bufferedResponseWriter struct {
+	responseWriter
+	max         int64
+	buf         bytes.Buffer
+	code        int
+	header      http.Header // at the time buffering began
+	sent        http.Header // at the time of the last WriteHeader
+	wroteHeader bool
+	streaming   bool
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(code int) {
+	if bw.streaming {
+		return
+	}
+	if bw.wroteHeader {
+		// Late status: discard what has been written so far, but keep
+		// the headers that have been changed for the new status
+		changed := changedHeaders(bw.Header(), bw.sent)
+		bw.discard()
+		for name, values := range changed {
+			bw.Header()[name] = values
+		}
+	}
+	bw.code = code
+	bw.wroteHeader = true
+	bw.sent = bw.Header().Clone()
+}
+
+func (bw *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	if bw.streaming {
+		return bw.ResponseWriter.Write(p)
+	}
+	if int64(bw.buf.Len()+len(p)) > bw.max {
+		bw.DisableBuffering()
+		return bw.ResponseWriter.Write(p)
+	}
+	return bw.buf.Write(p)
+}
+
+// discard drops the buffered output and status code, and resets the
+// headers to their state at the time buffering began.
+func (bw *bufferedResponseWriter) discard() {
+	if bw.streaming || !bw.wroteHeader {
+		return
+	}
+	bw.buf.Reset()
+	bw.code = http.StatusOK
+	bw.wroteHeader = false
+	h := bw.Header()
+	for name := range h {
+		delete(h, name)
+	}
+	for name, values := range bw.header {
+		h[name] = append([]string(nil), values...)
+	}
+}
+
+// DisableBuffering sends the buffered output and streams all further
+// output to the client.
+func (bw *bufferedResponseWriter) DisableBuffering() {
+	if bw.streaming {
+		return
+	}
+	bw.commit()
+	bw.streaming = true
+}
+
+// Flush implements http.Flusher. It disables buffering.
+func (bw *bufferedResponseWriter) Flush() {
+	bw.DisableBuffering()
+	bw.responseWriter.Flush()
+}
+
+// commit writes the status code and buffered output to the client.
+func (bw *bufferedResponseWriter) commit() {
+	if bw.streaming {
+		return
+	}
+	bw.streaming = true
+	bw.ResponseWriter.WriteHeader(bw.code)
+	if bw.buf.Len() > 0 {
+		bw.ResponseWriter.Write(bw.buf.Bytes())
+		bw.buf.Reset()
+	}
+}
+
+// changedHeaders returns the headers in h that have been added or
+// changed since the snapshot old was taken.
+func changedHeaders(h, old http.Header) http.Header {
+	changed := make(http.Header)
+	for name, values := range h {
+		if !equalStrings(values, old[name]) {
+			changed[name] = values
+		}
+	}
+	return changed
+}
+
+// equalStrings returns true if a and b contain the same strings in the
+// same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
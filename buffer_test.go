@@ -0,0 +1,194 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferResponses(t *testing.T) {
+	tests := []struct {
+		Handler http.HandlerFunc
+		Code    int
+		Body    string
+	}{
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "ok")
+			},
+			Code: http.StatusOK,
+			Body: "ok",
+		},
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, "created")
+			},
+			Code: http.StatusCreated,
+			Body: "created",
+		},
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"items":[`)
+				panic(NotFoundError{})
+			},
+			Code: http.StatusNotFound,
+			Body: `"message": "Record not found"`,
+		},
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"items":[`)
+				WriteJSONError(w, InvalidParameterError("page"))
+			},
+			Code: http.StatusBadRequest,
+			Body: `"message": "Invalid parameter \"page\""`,
+		},
+		{
+			// Exceeds the buffer
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, strings.Repeat("a", 100))
+				WriteJSONError(w, InvalidParameterError("page"))
+			},
+			Code: http.StatusOK,
+			Body: strings.Repeat("a", 100),
+		},
+		{
+			// Streaming
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				DisableBuffering(w)
+				fmt.Fprint(w, "data: 1\n\n")
+				WriteJSONError(w, InvalidParameterError("page"))
+			},
+			Code: http.StatusOK,
+			Body: "data: 1\n\n",
+		},
+	}
+	for i, tt := range tests {
+		h := BufferResponses(64)(tt.Handler)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Body, w.Body.String(); !strings.Contains(have, want) {
+			t.Errorf("#%d: want body to contain %q, have %q", i, want, have)
+		}
+		if tt.Code >= 400 && strings.Contains(w.Body.String(), "items") {
+			t.Errorf("#%d: expected partial output to be discarded, have %q", i, w.Body.String())
+		}
+	}
+}
+
+func TestBufferResponsesResetsHeaders(t *testing.T) {
+	tests := []struct {
+		Handler     http.HandlerFunc
+		Code        int
+		ContentType string
+	}{
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/csv")
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, "id,name\n")
+				WriteJSONError(w, InvalidParameterError("page"))
+			},
+			Code:        http.StatusBadRequest,
+			ContentType: "application/json",
+		},
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, `{"items":[`)
+				WriteJSONError(w, InvalidParameterError("page"))
+			},
+			Code:        http.StatusBadRequest,
+			ContentType: "application/json",
+		},
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, `{"items":[`)
+				http.Error(w, "boom", http.StatusInternalServerError)
+			},
+			Code:        http.StatusInternalServerError,
+			ContentType: "text/plain; charset=utf-8",
+		},
+		{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, `{"items":[`)
+				panic(NotFoundError{})
+			},
+			Code:        http.StatusNotFound,
+			ContentType: "application/json",
+		},
+	}
+	for i, tt := range tests {
+		h := BufferResponses(64)(tt.Handler)
+		w := httptest.NewRecorder()
+		w.Header().Set("X-Request-Id", "42")
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.ContentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if have := w.Header().Get("ETag"); have != "" {
+			t.Errorf("#%d: want ETag of the discarded response to be removed, have %q", i, have)
+		}
+		if want, have := "42", w.Header().Get("X-Request-Id"); want != have {
+			t.Errorf("#%d: want X-Request-Id %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestBufferResponsesUnwrap(t *testing.T) {
+	rec := httptest.NewRecorder()
+	h := BufferResponses(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uw, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			t.Fatal("want response writer to implement Unwrap")
+		}
+		if uw.Unwrap() != rec {
+			t.Error("want Unwrap to return the underlying response writer")
+		}
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("want response writer to implement http.Flusher")
+		}
+	}))
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+}
+
+func TestBufferResponsesHijacker(t *testing.T) {
+	srv := httptest.NewServer(BufferResponses(64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Error("want response writer to implement http.Hijacker")
+		}
+		DisableBuffering(w)
+		fmt.Fprint(w, "data: 1\n\n")
+		WriteJSONError(w, InvalidParameterError("page"))
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	if want, have := "data: 1\n\n", string(body); !strings.HasPrefix(have, want) {
+		t.Errorf("want body to start with %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// BulkItem is the outcome of a single operation in a batch request.
+// See WriteBulkResult.
+type BulkItem struct {
+	// Index is the position of the operation in the request.
+	Index int
+	// ID optionally identifies the record the operation refers to.
+	ID string
+	// Status is the HTTP status code of the operation. If it is 0,
+	// the status code is derived from Err, or 200 if Err is nil.
+	Status int
+	// Result is optional data to return for a successful operation,
+	// e.g. the created record.
+	Result interface{}
+	// Err is the error of a failed operation. It is serialized the
+	// same way as WriteJSONError does.
+	Err error
+}
+
+// StatusCode returns the HTTP status code of the item.
+func (item BulkItem) StatusCode() int {
+	if item.Status != 0 {
+		return item.Status
+	}
+	if item.Err != nil {
+		return NewErrorBody(item.Err).Code
+	}
+	return http.StatusOK
+}
+
+// MarshalJSON serializes the item as in WriteBulkResult.
+func (item BulkItem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(item.bulkItemFor(nil))
+}
+
+// bulkItem is the serialized form of a BulkItem.
+type bulkItem struct {
+	Index  int         `json:"index"`
+	ID     string      `json:"id,omitempty"`
+	Status int         `json:"status"`
+	Result interface{} `json:"result,omitempty"`
+	Error  *ErrorBody  `json:"error,omitempty"`
+}
+
+// bulkItemFor returns the serialized form of item, with the error
+// written as per the ErrorVerbosity of the Config in effect for r.
+func (item BulkItem) bulkItemFor(r *http.Request) bulkItem {
+	v := bulkItem{
+		Index:  item.Index,
+		ID:     item.ID,
+		Status: item.StatusCode(),
+		Result: item.Result,
+	}
+	if item.Err != nil {
+		body := configFor(r).ErrorVerbosity.apply(NewErrorBody(item.Err))
+		v.Error = &body
+	}
+	return v
+}
+
+// WriteBulkResult writes the outcome of a batch request as JSON.
+// If all items succeeded, i.e. have a 2xx status code, the HTTP status
+// is 200; otherwise it is 207 (Multi-Status) and "errors" is true, so
+// clients can tell partial failures apart without inspecting each item.
+// Example:
+//
+//	{
+//	  "errors": true,
+//	  "items": [
+//	    {"index": 0, "id": "1", "status": 201},
+//	    {"index": 1, "status": 400, "error": {"code": 400, "message": "Missing parameter \"name\""}}
+//	  ]
+//	}
+//
+// WriteBulkResult uses the global Config. Use ServeBulkResult for the
+// Config set by WithConfig to take effect.
+func WriteBulkResult(w http.ResponseWriter, results []BulkItem) {
+	writeBulkResult(w, nil, results)
+}
+
+// ServeBulkResult is like WriteBulkResult, but uses the JSONCodec and
+// ErrorVerbosity of the Config in effect for r.
+func ServeBulkResult(w http.ResponseWriter, r *http.Request, results []BulkItem) {
+	writeBulkResult(w, r, results)
+}
+
+func writeBulkResult(w http.ResponseWriter, r *http.Request, results []BulkItem) {
+	code, failed := http.StatusOK, false
+	items := make([]bulkItem, len(results))
+	for i, item := range results {
+		if status := item.StatusCode(); status < 200 || status > 299 {
+			code, failed = http.StatusMultiStatus, true
+		}
+		items[i] = item.bulkItemFor(r)
+	}
+	writeJSONCode(w, r, code, struct {
+		Errors bool       `json:"errors"`
+		Items  []bulkItem `json:"items"`
+	}{
+		Errors: failed,
+		Items:  items,
+	})
+}
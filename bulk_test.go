@@ -0,0 +1,91 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteBulkResult(t *testing.T) {
+	tests := []struct {
+		Items  []BulkItem
+		Code   int
+		Errors bool
+		Status []int
+	}{
+		{
+			Items:  nil,
+			Code:   http.StatusOK,
+			Status: []int{},
+		},
+		{
+			Items: []BulkItem{
+				{Index: 0, ID: "1", Status: http.StatusCreated},
+				{Index: 1, ID: "2"},
+			},
+			Code:   http.StatusOK,
+			Status: []int{http.StatusCreated, http.StatusOK},
+		},
+		{
+			Items: []BulkItem{
+				{Index: 0, ID: "1", Status: http.StatusCreated},
+				{Index: 1, Err: MissingParameterError("name")},
+				{Index: 2, Err: errors.New("kaboom")},
+				{Index: 3, Status: http.StatusConflict, Err: ConflictError{}},
+			},
+			Code:   http.StatusMultiStatus,
+			Errors: true,
+			Status: []int{http.StatusCreated, http.StatusBadRequest, http.StatusInternalServerError, http.StatusConflict},
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteBulkResult(w, tt.Items)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		var resp struct {
+			Errors bool `json:"errors"`
+			Items  []struct {
+				Index  int    `json:"index"`
+				ID     string `json:"id"`
+				Status int    `json:"status"`
+				Error  *struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"items"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Errors, resp.Errors; want != have {
+			t.Errorf("#%d: want errors=%v, have %v", i, want, have)
+		}
+		if resp.Items == nil {
+			t.Fatalf("#%d: want items to be an array", i)
+		}
+		if want, have := len(tt.Status), len(resp.Items); want != have {
+			t.Fatalf("#%d: want %d items, have %d", i, want, have)
+		}
+		for j, item := range resp.Items {
+			if want, have := tt.Status[j], item.Status; want != have {
+				t.Errorf("#%d.%d: want status %d, have %d", i, j, want, have)
+			}
+			if want, have := tt.Items[j].Err != nil, item.Error != nil; want != have {
+				t.Errorf("#%d.%d: want error %v, have %v", i, j, want, have)
+			}
+			if item.Error != nil {
+				if want, have := tt.Items[j].Err.Error(), item.Error.Message; want != have {
+					t.Errorf("#%d.%d: want message %q, have %q", i, j, want, have)
+				}
+			}
+		}
+	}
+}
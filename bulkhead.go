@@ -0,0 +1,170 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Bulkhead is a http.RoundTripper that limits the number of concurrent
+// requests per upstream, so one slow dependency can't exhaust the
+// connections and goroutines of the process. Requests beyond the limit
+// wait in a queue; if the queue is full, or a request has waited for
+// QueueTimeout, it fails with ServiceUnavailableError.
+//
+// A slot is held until the response body is read to the end or closed,
+// so always close it, e.g. with DrainAndClose. If the underlying
+// transport fails, the slot is released right away.
+//
+// Example:
+//
+//	client := httputil.NewHTTPClient(httputil.ThirdParty.With(
+//	  func(next http.RoundTripper) http.RoundTripper {
+//	    return &httputil.Bulkhead{Base: next, MaxConcurrent: 20, MaxQueue: 50}
+//	  }))
+type Bulkhead struct {
+	// Base is the underlying transport. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// MaxConcurrent is the maximum number of concurrent requests per
+	// upstream. It defaults to 10.
+	MaxConcurrent int
+	// MaxQueue is the maximum number of requests per upstream waiting
+	// for a slot. Zero means requests fail immediately when all slots
+	// are taken.
+	MaxQueue int
+	// QueueTimeout is the maximum time a request waits for a slot.
+	// Zero means it waits until its context is done.
+	QueueTimeout time.Duration
+	// Key returns the upstream of a request. It defaults to the host
+	// of the request URL.
+	Key func(*http.Request) string
+
+	mu           sync.Mutex
+	compartments map[string]*bulkheadCompartment
+}
+
+type bulkheadCompartment struct {
+	slots   chan struct{}
+	waiting int
+}
+
+// RoundTrip passes r to the underlying transport once a slot for its
+// upstream is available.
+func (b *Bulkhead) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := b.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	key := r.URL.Host
+	if b.Key != nil {
+		key = b.Key(r)
+	}
+	c := b.compartment(key)
+
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		if err := b.wait(r, c); err != nil {
+			if r.Body != nil {
+				r.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	release := func() { <-c.slots }
+
+	res, err := base.RoundTrip(r)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = &releaseOnCloseBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// InFlight returns the number of requests in flight and waiting for
+// the upstream key.
+func (b *Bulkhead) InFlight(key string) (active, waiting int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.compartments[key]; c != nil {
+		return len(c.slots), c.waiting
+	}
+	return 0, 0
+}
+
+// wait queues r until a slot of c is available.
+func (b *Bulkhead) wait(r *http.Request, c *bulkheadCompartment) error {
+	b.mu.Lock()
+	if c.waiting >= b.MaxQueue {
+		b.mu.Unlock()
+		return ServiceUnavailableError{RetryAfter: time.Second}
+	}
+	c.waiting++
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		c.waiting--
+		b.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if b.QueueTimeout > 0 {
+		timer := time.NewTimer(b.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ServiceUnavailableError{RetryAfter: time.Second}
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
+
+func (b *Bulkhead) compartment(key string) *bulkheadCompartment {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.compartments == nil {
+		b.compartments = make(map[string]*bulkheadCompartment)
+	}
+	c := b.compartments[key]
+	if c == nil {
+		n := b.MaxConcurrent
+		if n <= 0 {
+			n = 10
+		}
+		c = &bulkheadCompartment{slots: make(chan struct{}, n)}
+		b.compartments[key] = c
+	}
+	return c
+}
+
+// releaseOnCloseBody calls release once when the body is read to the
+// end, fails, or is closed.
+type releaseOnCloseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseOnCloseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releaseOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
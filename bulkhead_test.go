@@ -0,0 +1,141 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	b := &Bulkhead{MaxConcurrent: 1, MaxQueue: 1}
+	client := &http.Client{Transport: b}
+	slowHost := mustParseURL(t, slow.URL).Host
+
+	errs := make(chan error, 2)
+	get := func() {
+		res, err := client.Get(slow.URL)
+		if err == nil {
+			DrainAndClose(res)
+		}
+		errs <- err
+	}
+	go get()
+	waitFor(t, func() bool { active, _ := b.InFlight(slowHost); return active == 1 })
+	go get()
+	waitFor(t, func() bool { _, waiting := b.InFlight(slowHost); return waiting == 1 })
+
+	// The bulkhead of the slow upstream is saturated
+	_, err := client.Get(slow.URL)
+	if _, ok := err.(*url.Error).Err.(ServiceUnavailableError); !ok {
+		t.Fatalf("want ServiceUnavailableError, have %v", err)
+	}
+	// Other upstreams are not affected
+	res, err := client.Get(fast.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrainAndClose(res)
+
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("want queued requests to succeed, have %v", err)
+		}
+	}
+	if active, waiting := b.InFlight(slowHost); active != 0 || waiting != 0 {
+		t.Errorf("want no requests in flight, have %d active and %d waiting", active, waiting)
+	}
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	b := &Bulkhead{MaxConcurrent: 1, MaxQueue: 10, QueueTimeout: 10 * time.Millisecond}
+	client := &http.Client{Transport: b}
+	go client.Get(srv.URL)
+	waitFor(t, func() bool { active, _ := b.InFlight(mustParseURL(t, srv.URL).Host); return active == 1 })
+
+	_, err := client.Get(srv.URL)
+	if _, ok := err.(*url.Error).Err.(ServiceUnavailableError); !ok {
+		t.Fatalf("want ServiceUnavailableError, have %v", err)
+	}
+}
+
+func TestBulkheadRelease(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	host := mustParseURL(t, srv.URL).Host
+
+	// Read to the end without closing the body
+	b := &Bulkhead{MaxConcurrent: 1}
+	res, err := (&http.Client{Transport: b}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := b.InFlight(host); active != 0 {
+		t.Errorf("want slot to be released at EOF, have %d active", active)
+	}
+	res.Body.Close()
+
+	// Transport errors
+	b = &Bulkhead{
+		Base: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("boom")
+		}),
+		MaxConcurrent: 1,
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := (&http.Client{Transport: b}).Get(srv.URL); err == nil {
+			t.Fatalf("#%d: want error", i)
+		}
+		if active, _ := b.InFlight(host); active != 0 {
+			t.Errorf("#%d: want slot to be released on error, have %d active", i, active)
+		}
+	}
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// waitFor polls cond until it returns true or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met in time")
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// VariantPrimary and VariantCanary are the values of the variant
+	// header set by WeightedHandler.
+	VariantPrimary = "primary"
+	VariantCanary  = "canary"
+
+	// DefaultCanaryCookie is the default of CanaryConfig.Cookie.
+	DefaultCanaryCookie = "canary_bucket"
+	// DefaultVariantHeader is the default of CanaryConfig.VariantHeader.
+	DefaultVariantHeader = "X-Variant"
+)
+
+// CanaryConfig configures WeightedHandlerWithConfig.
+type CanaryConfig struct {
+	// Weight returns the fraction of requests, between 0 and 1, that are
+	// passed to the canary, e.g. a value that is increased over time.
+	Weight func(r *http.Request) float64
+	// Header is a request header with a stable client identifier, e.g.
+	// "X-User-Id". If set and present, its hash assigns the variant.
+	Header string
+	// Cookie is the name of the cookie that keeps the assignment of
+	// clients without Header. It defaults to DefaultCanaryCookie.
+	Cookie string
+	// Secret signs the cookie, so clients can't pick their variant.
+	// If empty, the cookie is not signed.
+	Secret []byte
+	// CookieMaxAge is the lifetime of the cookie. It defaults to 30 days.
+	CookieMaxAge time.Duration
+	// VariantHeader is the response header that is set to VariantPrimary
+	// or VariantCanary. It defaults to DefaultVariantHeader.
+	VariantHeader string
+}
+
+// WeightedHandler returns a handler that passes a fraction of requests,
+// given by weight between 0 and 1, to canary, and all others to primary,
+// for gradual rollouts without a service mesh. Assignments are sticky:
+// each client gets a random bucket, kept in a cookie, and stays on the
+// canary as the weight increases. The X-Variant response header reports
+// the variant. See WeightedHandlerWithConfig for more options, e.g.
+// signed cookies.
+func WeightedHandler(primary, canary http.Handler, weight func(r *http.Request) float64) http.Handler {
+	return WeightedHandlerWithConfig(primary, canary, CanaryConfig{Weight: weight})
+}
+
+// WeightedHandlerWithConfig is like WeightedHandler, with more options.
+func WeightedHandlerWithConfig(primary, canary http.Handler, cfg CanaryConfig) http.Handler {
+	if cfg.Cookie == "" {
+		cfg.Cookie = DefaultCanaryCookie
+	}
+	if cfg.CookieMaxAge <= 0 {
+		cfg.CookieMaxAge = 30 * 24 * time.Hour
+	}
+	if cfg.VariantHeader == "" {
+		cfg.VariantHeader = DefaultVariantHeader
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var key string
+		if cfg.Header != "" {
+			key = r.Header.Get(cfg.Header)
+		}
+		if key == "" {
+			key = stickyCookieValue(w, r, cfg.Cookie, cfg.Secret, cfg.CookieMaxAge)
+		}
+		weight := 0.0
+		if cfg.Weight != nil {
+			weight = cfg.Weight(r)
+		}
+		if bucketOf(key) < weight {
+			w.Header().Set(cfg.VariantHeader, VariantCanary)
+			canary.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(cfg.VariantHeader, VariantPrimary)
+		primary.ServeHTTP(w, r)
+	})
+}
+
+// bucketOf maps key to a stable value in [0,1).
+func bucketOf(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// stickyCookieValue returns the value of the cookie name, if it exists
+// and has a valid signature. Otherwise, it sets the cookie with a new
+// random value and returns that.
+func stickyCookieValue(w http.ResponseWriter, r *http.Request, name string, secret []byte, maxAge time.Duration) string {
+	if c, err := r.Cookie(name); err == nil {
+		if v, ok := verifyCookieValue(c.Value, secret); ok {
+			return v
+		}
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	v := hex.EncodeToString(b[:])
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    signCookieValue(v, secret),
+		Path:     "/",
+		MaxAge:   int(maxAge / time.Second),
+		HttpOnly: true,
+		Secure:   RequestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return v
+}
+
+// signCookieValue appends a HMAC SHA-256 signature to v, unless
+// secret is empty.
+func signCookieValue(v string, secret []byte) string {
+	if len(secret) == 0 {
+		return v
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(v))
+	return v + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCookieValue returns the value of a cookie signed with
+// signCookieValue, and false if the signature is invalid.
+func verifyCookieValue(signed string, secret []byte) (string, bool) {
+	if len(secret) == 0 {
+		return signed, signed != ""
+	}
+	i := strings.LastIndexByte(signed, '.')
+	if i <= 0 {
+		return "", false
+	}
+	v := signed[:i]
+	if !hmac.Equal([]byte(signed), []byte(signCookieValue(v, secret))) {
+		return "", false
+	}
+	return v, true
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testVariantHandler(variant string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(variant))
+	})
+}
+
+func TestWeightedHandler(t *testing.T) {
+	weight := 0.0
+	h := WeightedHandler(testVariantHandler(VariantPrimary), testVariantHandler(VariantCanary), func(r *http.Request) float64 {
+		return weight
+	})
+
+	// First request gets a bucket cookie
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if want, have := VariantPrimary, w.Body.String(); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if want, have := VariantPrimary, w.Header().Get(DefaultVariantHeader); want != have {
+		t.Errorf("want %s %q, have %q", DefaultVariantHeader, want, have)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCanaryCookie {
+		t.Fatalf("want bucket cookie, have %v", cookies)
+	}
+	bucket := bucketOf(cookies[0].Value)
+
+	// The client moves to the canary once the weight exceeds its bucket
+	for _, tt := range []struct {
+		Weight  float64
+		Variant string
+	}{
+		{Weight: bucket, Variant: VariantPrimary},
+		{Weight: bucket + 0.0001, Variant: VariantCanary},
+		{Weight: 1, Variant: VariantCanary},
+	} {
+		weight = tt.Weight
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookies[0])
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Variant, w.Body.String(); want != have {
+			t.Errorf("weight %v: want %q, have %q", tt.Weight, want, have)
+		}
+		if have := w.Result().Cookies(); len(have) != 0 {
+			t.Errorf("want no new cookie, have %v", have)
+		}
+	}
+}
+
+func TestWeightedHandlerWithConfig(t *testing.T) {
+	h := WeightedHandlerWithConfig(testVariantHandler(VariantPrimary), testVariantHandler(VariantCanary), CanaryConfig{
+		Weight: func(r *http.Request) float64 { return 0.3 },
+		Header: "X-User-Id",
+		Secret: []byte("secret"),
+	})
+
+	// Assignment by header is deterministic and matches the weight
+	var canaries int
+	for i := 0; i < 2000; i++ {
+		var variant string
+		for j := 0; j < 2; j++ {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-User-Id", fmt.Sprint(i))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if j == 1 && variant != w.Body.String() {
+				t.Fatalf("user %d: want sticky variant %q, have %q", i, variant, w.Body.String())
+			}
+			variant = w.Body.String()
+		}
+		if variant == VariantCanary {
+			canaries++
+		}
+	}
+	if canaries < 500 || canaries > 700 {
+		t.Errorf("want about 600 canaries, have %d", canaries)
+	}
+
+	// Tampered cookies are replaced
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: DefaultCanaryCookie, Value: "0000.forged"})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("want new cookie, have %v", cookies)
+	}
+	if _, ok := verifyCookieValue(cookies[0].Value, []byte("secret")); !ok {
+		t.Errorf("want signed cookie, have %q", cookies[0].Value)
+	}
+}
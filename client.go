@@ -0,0 +1,144 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Profile configures an outbound HTTP client, see NewHTTPClient.
+// Start from one of the presets and adjust, e.g.:
+//
+//	p := httputil.ThirdParty
+//	p.Timeout = time.Minute
+//	client := httputil.NewHTTPClient(p.With(signingTransport))
+type Profile struct {
+	// Timeout is the overall time limit of a request, including reading
+	// the response body. Zero means no limit; use contexts then.
+	Timeout time.Duration
+	// DialTimeout is the time limit to establish a connection.
+	DialTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout is the time limit of the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout is the time limit to wait for the response
+	// headers after the request has been sent. Zero means no limit.
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is the time after which idle connections are closed.
+	IdleConnTimeout time.Duration
+	// MaxIdleConns and MaxIdleConnsPerHost limit the pool of idle
+	// connections. The default of net/http, 2 per host, is far too low
+	// for services that talk to a few hosts a lot.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per host. Zero means no limit.
+	MaxConnsPerHost int
+	// ForceHTTP2 enables HTTP/2 even with a custom dialer or TLS config.
+	ForceHTTP2 bool
+	// TLSConfig is the TLS configuration. It defaults to a minimum
+	// version of TLS 1.2.
+	TLSConfig *tls.Config
+	// Transports wrap the transport, in order, e.g. to add retries,
+	// circuit breaking, request signing, or debug logging. The first
+	// one is the outermost.
+	Transports []func(http.RoundTripper) http.RoundTripper
+}
+
+var (
+	// InternalService is for calls to services in the same
+	// network: short timeouts, a large connection pool, and HTTP/2.
+	InternalService = Profile{
+		Timeout:               10 * time.Second,
+		DialTimeout:           2 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   100,
+		ForceHTTP2:            true,
+	}
+
+	// ThirdParty is for calls to external APIs over the internet:
+	// generous timeouts, and a limited number of connections per host, so
+	// a slow provider can't exhaust file descriptors.
+	ThirdParty = Profile{
+		Timeout:               30 * time.Second,
+		DialTimeout:           5 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+		IdleConnTimeout:       60 * time.Second,
+		MaxIdleConns:          50,
+		MaxIdleConnsPerHost:   10,
+		MaxConnsPerHost:       50,
+		ForceHTTP2:            true,
+	}
+
+	// LongPoll is for long polling and streaming, e.g. Server-Sent
+	// Events: no overall timeout and no response header timeout, so
+	// requests must be bounded by their context.
+	LongPoll = Profile{
+		DialTimeout:         5 * time.Second,
+		KeepAlive:           15 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     5 * time.Minute,
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 20,
+		ForceHTTP2:          true,
+	}
+)
+
+// With returns a copy of p with the given transports appended to
+// p.Transports.
+func (p Profile) With(transports ...func(http.RoundTripper) http.RoundTripper) Profile {
+	p.Transports = append(append([]func(http.RoundTripper) http.RoundTripper(nil), p.Transports...), transports...)
+	return p
+}
+
+// NewHTTPClient returns a client configured by p. Each client has its
+// own connection pool, so create one per upstream and reuse it.
+func NewHTTPClient(p Profile) *http.Client {
+	return &http.Client{
+		Timeout:   p.Timeout,
+		Transport: NewHTTPTransport(p),
+	}
+}
+
+// NewHTTPTransport returns the transport of NewHTTPClient, wrapped by
+// p.Transports.
+func NewHTTPTransport(p Profile) http.RoundTripper {
+	tlsConfig := p.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	dialer := &net.Dialer{
+		Timeout:   p.DialTimeout,
+		KeepAlive: p.KeepAlive,
+	}
+	var rt http.RoundTripper = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   p.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: p.ResponseHeaderTimeout,
+		IdleConnTimeout:       p.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          p.MaxIdleConns,
+		MaxIdleConnsPerHost:   p.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       p.MaxConnsPerHost,
+		ForceAttemptHTTP2:     p.ForceHTTP2,
+	}
+	for i := len(p.Transports) - 1; i >= 0; i-- {
+		rt = p.Transports[i](rt)
+	}
+	return rt
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"sync"
+)
+
+// maxDrainSize is the maximum number of bytes read by DrainAndClose.
+// Larger bodies are cheaper to discard along with their connection.
+const maxDrainSize = 256 << 10
+
+// DrainAndClose reads the remaining body of res, up to a limit, and
+// closes it. Only connections of bodies that are read to the end are
+// reused by http.Transport; closing a body early closes the connection,
+// which causes connection churn under load.
+//
+// Example:
+//
+//	res, err := client.Do(req)
+//	if err != nil {
+//	  return err
+//	}
+//	defer httputil.DrainAndClose(res)
+func DrainAndClose(res *http.Response) error {
+	if res == nil || res.Body == nil {
+		return nil
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxDrainSize))
+	return res.Body.Close()
+}
+
+// DetectBodyLeaks wraps a transport to find response bodies that are not
+// drained and closed, e.g. for use in Profile.Transports. It only tracks
+// requests in debug mode (see IsDebug), and logs leaks to the logger of
+// the request context (see LoggerFromContext). Bodies that are never
+// closed are reported when they are garbage collected.
+func DetectBodyLeaks(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		res, err := next.RoundTrip(r)
+		if err != nil || res.Body == nil || res.Body == http.NoBody || !IsDebug(r.Context()) {
+			return res, err
+		}
+		b := &leakTrackingBody{
+			ReadCloser: res.Body,
+			logger:     LoggerFromContext(r.Context()),
+			method:     r.Method,
+			url:        redactorFor(r).RedactURL(r.URL),
+		}
+		runtime.SetFinalizer(b, (*leakTrackingBody).finalize)
+		res.Body = b
+		return res, nil
+	})
+}
+
+// roundTripperFunc is an adapter to use a function as http.RoundTripper.
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r).
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// leakTrackingBody is a response body that logs if it is not drained
+// before being closed, or not closed at all.
+type leakTrackingBody struct {
+	io.ReadCloser
+	logger *slog.Logger
+	method string
+	url    string
+
+	mu     sync.Mutex
+	eof    bool
+	closed bool
+}
+
+func (b *leakTrackingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.mu.Lock()
+		b.eof = true
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+func (b *leakTrackingBody) Close() error {
+	b.mu.Lock()
+	first, eof := !b.closed, b.eof
+	b.closed = true
+	b.mu.Unlock()
+	if first {
+		runtime.SetFinalizer(b, nil)
+		if !eof {
+			b.logger.Warn("Response body closed without being drained", "method", b.method, "url", b.url)
+		}
+	}
+	return b.ReadCloser.Close()
+}
+
+func (b *leakTrackingBody) finalize() {
+	b.logger.Warn("Response body leaked without being closed", "method", b.method, "url", b.url)
+	b.ReadCloser.Close()
+}
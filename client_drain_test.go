@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrainAndClose(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 32<<10))
+	}))
+	srv.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	for i := 0; i < 5; i++ {
+		res, err := srv.Client().Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := DrainAndClose(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := int32(1), conns.Load(); want != have {
+		t.Errorf("want %d connection, have %d", want, have)
+	}
+	if err := DrainAndClose(nil); err != nil {
+		t.Errorf("want no error for nil response, have %v", err)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDetectBodyLeaks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	var logs syncBuffer
+	ctx := context.WithValue(context.Background(), loggerContextKey{}, slog.New(slog.NewTextHandler(&logs, nil)))
+	debugCtx := context.WithValue(ctx, debugContextKey{}, true)
+	client := &http.Client{Transport: DetectBodyLeaks(nil)}
+
+	get := func(ctx context.Context, path string) *http.Response {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+path, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// Drained and closed
+	DrainAndClose(get(debugCtx, "/drained"))
+	// Not in debug mode
+	get(ctx, "/ignored").Body.Close()
+	// Closed without being drained
+	get(debugCtx, "/undrained").Body.Close()
+	if have := logs.String(); !strings.Contains(have, "without being drained") || !strings.Contains(have, "/undrained") {
+		t.Errorf("want undrained body to be logged, have %q", have)
+	}
+	if have := logs.String(); strings.Contains(have, "/drained") || strings.Contains(have, "/ignored") {
+		t.Errorf("want only undrained body to be logged, have %q", have)
+	}
+
+	// Never closed
+	ioutil.ReadAll(get(debugCtx, "/leaked").Body)
+	for i := 0; i < 50 && !strings.Contains(logs.String(), "/leaked"); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if have := logs.String(); !strings.Contains(have, "leaked without being closed") {
+		t.Errorf("want leaked body to be logged, have %q", have)
+	}
+}
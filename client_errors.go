@@ -0,0 +1,88 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ClassifyTransportError maps an error returned by an HTTP client to a
+// typed error, so gateways respond consistently and metrics can be
+// labeled by their reason:
+//
+//   - context.Canceled becomes ClientClosedRequestError.
+//   - DNS failures become BadGatewayError with reason "DNS_FAILURE", or
+//     GatewayTimeoutError with reason "DNS_TIMEOUT" if the lookup timed out.
+//   - Refused and reset connections become BadGatewayError with reason
+//     "CONNECTION_REFUSED" and "CONNECTION_RESET".
+//   - TLS and certificate errors become BadGatewayError with reason "TLS_ERROR".
+//   - Timeouts become GatewayTimeoutError with reason "UPSTREAM_TIMEOUT".
+//   - Any other error becomes BadGatewayError with reason "UPSTREAM_ERROR".
+//
+// Errors that already have an HTTP code, and nil, are returned as is.
+//
+// Example:
+//
+//	res, err := client.Do(req)
+//	if err != nil {
+//	  httputil.ServeJSONError(w, r, httputil.ClassifyTransportError(err))
+//	  return
+//	}
+func ClassifyTransportError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(httpCoder); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return ClientClosedRequestError{Err: err}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return GatewayTimeoutError{Reason: "DNS_TIMEOUT", Err: err}
+		}
+		return BadGatewayError{Reason: "DNS_FAILURE", Err: err}
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return BadGatewayError{Reason: "CONNECTION_REFUSED", Err: err}
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return BadGatewayError{Reason: "CONNECTION_RESET", Err: err}
+	}
+	if isTLSError(err) {
+		return BadGatewayError{Reason: "TLS_ERROR", Err: err}
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return GatewayTimeoutError{Reason: "UPSTREAM_TIMEOUT", Err: err}
+	}
+	return BadGatewayError{Reason: "UPSTREAM_ERROR", Err: err}
+}
+
+// isTLSError returns true if err is caused by the TLS handshake or
+// certificate verification.
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyTransportError(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+
+	// A port that nobody listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		URL     string
+		Ctx     context.Context
+		Timeout time.Duration
+		Code    int
+		Reason  string
+	}{
+		{URL: refused, Code: http.StatusBadGateway, Reason: "CONNECTION_REFUSED"},
+		{URL: secure.URL, Code: http.StatusBadGateway, Reason: "TLS_ERROR"},
+		{URL: slow.URL, Timeout: 50 * time.Millisecond, Code: http.StatusGatewayTimeout, Reason: "UPSTREAM_TIMEOUT"},
+		{URL: slow.URL, Ctx: canceled, Code: 499, Reason: "CLIENT_CLOSED_REQUEST"},
+	}
+	for i, tt := range tests {
+		ctx := tt.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		req, _ := http.NewRequestWithContext(ctx, "GET", tt.URL, nil)
+		client := &http.Client{Timeout: tt.Timeout}
+		_, err := client.Do(req)
+		if err == nil {
+			t.Fatalf("#%d: want error", i)
+		}
+		err = ClassifyTransportError(err)
+		if want, have := tt.Code, err.(httpCoder).HTTPCode(); want != have {
+			t.Errorf("#%d: want status %d, have %d (%v)", i, want, have, errors.Unwrap(err))
+		}
+		if want, have := tt.Reason, err.(httpErrorReason).ErrorReason(); want != have {
+			t.Errorf("#%d: want reason %q, have %q (%v)", i, want, have, errors.Unwrap(err))
+		}
+	}
+}
+
+func TestClassifyTransportErrorDNS(t *testing.T) {
+	tests := []struct {
+		Err    error
+		Code   int
+		Reason string
+	}{
+		{Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, Code: http.StatusBadGateway, Reason: "DNS_FAILURE"},
+		{Err: &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, Code: http.StatusGatewayTimeout, Reason: "DNS_TIMEOUT"},
+		{Err: errors.New("unexpected EOF"), Code: http.StatusBadGateway, Reason: "UPSTREAM_ERROR"},
+		{Err: NotFoundError{}, Code: http.StatusNotFound},
+	}
+	for i, tt := range tests {
+		err := ClassifyTransportError(tt.Err)
+		if want, have := tt.Code, err.(httpCoder).HTTPCode(); want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Reason != "" {
+			if want, have := tt.Reason, err.(httpErrorReason).ErrorReason(); want != have {
+				t.Errorf("#%d: want reason %q, have %q", i, want, have)
+			}
+		}
+		if !errors.Is(err, tt.Err) {
+			t.Errorf("#%d: want error to wrap %v", i, tt.Err)
+		}
+	}
+	if ClassifyTransportError(nil) != nil {
+		t.Error("want nil for nil")
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// FailoverTransport is a http.RoundTripper that sends requests to the
+// first healthy of several base URLs, e.g. the regional endpoints of an
+// upstream, and fails over to the next one on errors. A base URL becomes
+// unhealthy after MaxFailures consecutive failures, and is tried again
+// after Cooldown, so traffic returns to the primary once it recovers.
+//
+// Requests are sent to the scheme and host of the base URL; the path and
+// query of the request are kept. Failures are transport errors and the
+// status codes 502, 503, and 504. Requests that have not reached the
+// upstream, e.g. because of DNS failures or refused connections, fail over
+// regardless of their method; others only if they are idempotent and
+// their body can be replayed via GetBody.
+//
+// Example:
+//
+//	client := httputil.NewHTTPClient(httputil.InternalService.With(
+//	  func(next http.RoundTripper) http.RoundTripper {
+//	    return &httputil.FailoverTransport{
+//	      Base:     next,
+//	      BaseURLs: []string{"https://eu.example.com", "https://us.example.com"},
+//	    }
+//	  }))
+//	res, err := client.Get("https://eu.example.com/v1/users")
+type FailoverTransport struct {
+	// Base is the underlying transport. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// BaseURLs are the base URLs in order of preference.
+	BaseURLs []string
+	// MaxFailures is the number of consecutive failures after which a
+	// base URL becomes unhealthy. It defaults to 3.
+	MaxFailures int
+	// Cooldown is the time after which an unhealthy base URL is tried
+	// again. It defaults to 30 seconds.
+	Cooldown time.Duration
+
+	mu     sync.Mutex
+	health map[string]*failoverHealth
+	now    func() time.Time
+}
+
+type failoverHealth struct {
+	failures       int
+	unhealthyUntil time.Time
+}
+
+// Healthy returns true if baseURL is currently considered healthy.
+func (t *FailoverTransport) Healthy(baseURL string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.health[baseURL]
+	return h == nil || !t.clock().Before(h.unhealthyUntil)
+}
+
+var errNoBaseURLs = errors.New("httputil: FailoverTransport.BaseURLs is empty")
+
+// RoundTrip sends r to the first healthy base URL and fails over to the
+// next one on errors. If all base URLs are unhealthy, they are tried in
+// order anyway. It returns the response or error of the last attempt.
+// It returns an error if BaseURLs is empty.
+func (t *FailoverTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if len(t.BaseURLs) == 0 {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, errNoBaseURLs
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	replayable := r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+	idempotent := replayable && isIdempotent(r)
+
+	var (
+		res *http.Response
+		err error
+	)
+	for i, baseURL := range t.candidates() {
+		if i > 0 {
+			if err := r.Context().Err(); err != nil {
+				return nil, err
+			}
+		}
+		u, perr := url.Parse(baseURL)
+		if perr != nil {
+			return nil, perr
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Scheme, r2.URL.Host, r2.Host = u.Scheme, u.Host, ""
+		if i > 0 && r.GetBody != nil {
+			if r2.Body, err = r.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		res, err = base.RoundTrip(r2)
+
+		var retry bool
+		switch {
+		case err != nil:
+			if r.Context().Err() != nil {
+				return nil, err
+			}
+			t.record(baseURL, false)
+			retry = idempotent || (replayable && !reachedUpstream(err))
+		case res.StatusCode == http.StatusBadGateway ||
+			res.StatusCode == http.StatusServiceUnavailable ||
+			res.StatusCode == http.StatusGatewayTimeout:
+			t.record(baseURL, false)
+			retry = idempotent
+		default:
+			t.record(baseURL, true)
+			return res, nil
+		}
+		if !retry || i == len(t.BaseURLs)-1 {
+			break
+		}
+		DrainAndClose(res)
+	}
+	return res, err
+}
+
+// candidates returns the base URLs with the healthy ones first.
+func (t *FailoverTransport) candidates() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock()
+	healthy := make([]string, 0, len(t.BaseURLs))
+	var unhealthy []string
+	for _, baseURL := range t.BaseURLs {
+		if h := t.health[baseURL]; h != nil && now.Before(h.unhealthyUntil) {
+			unhealthy = append(unhealthy, baseURL)
+		} else {
+			healthy = append(healthy, baseURL)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// record tracks the outcome of a request to baseURL.
+func (t *FailoverTransport) record(baseURL string, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.health == nil {
+		t.health = make(map[string]*failoverHealth)
+	}
+	h := t.health[baseURL]
+	if h == nil {
+		h = new(failoverHealth)
+		t.health[baseURL] = h
+	}
+	if ok {
+		h.failures = 0
+		h.unhealthyUntil = time.Time{}
+		return
+	}
+	h.failures++
+	maxFailures := t.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	if h.failures >= maxFailures {
+		cooldown := t.Cooldown
+		if cooldown <= 0 {
+			cooldown = 30 * time.Second
+		}
+		h.unhealthyUntil = t.clock().Add(cooldown)
+	}
+}
+
+func (t *FailoverTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// isIdempotent returns true if r may safely be sent more than once.
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// reachedUpstream returns false if err indicates that a request has not
+// been sent to the upstream at all.
+func reachedUpstream(err error) bool {
+	if e, ok := ClassifyTransportError(err).(BadGatewayError); ok {
+		switch e.Reason {
+		case "DNS_FAILURE", "CONNECTION_REFUSED", "TLS_ERROR":
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailoverTransport(t *testing.T) {
+	var down atomic.Bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("primary " + r.URL.Path))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secondary " + r.URL.Path))
+	}))
+	defer secondary.Close()
+
+	now := time.Now()
+	tr := &FailoverTransport{
+		BaseURLs:    []string{primary.URL, secondary.URL},
+		MaxFailures: 2,
+		Cooldown:    time.Minute,
+		now:         func() time.Time { return now },
+	}
+	client := &http.Client{Transport: tr}
+
+	tests := []struct {
+		Down    bool
+		Method  string
+		Advance time.Duration
+		Code    int
+		Body    string
+		Healthy bool
+	}{
+		{Method: "GET", Code: http.StatusOK, Body: "primary /users", Healthy: true},
+		{Down: true, Method: "GET", Code: http.StatusOK, Body: "secondary /users", Healthy: true},
+		{Down: true, Method: "POST", Code: http.StatusServiceUnavailable, Healthy: false},
+		// Primary is unhealthy now, so it is skipped
+		{Down: false, Method: "GET", Code: http.StatusOK, Body: "secondary /users", Healthy: false},
+		// Primary is tried again after the cooldown
+		{Down: false, Method: "GET", Advance: time.Minute, Code: http.StatusOK, Body: "primary /users", Healthy: true},
+	}
+	for i, tt := range tests {
+		down.Store(tt.Down)
+		now = now.Add(tt.Advance)
+		req, _ := http.NewRequest(tt.Method, primary.URL+"/users", strings.NewReader("{}"))
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if want, have := tt.Code, res.StatusCode; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Body != "" {
+			if want, have := tt.Body, string(body); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		}
+		if want, have := tt.Healthy, tr.Healthy(primary.URL); want != have {
+			t.Errorf("#%d: want primary healthy=%v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestFailoverTransportConnectionRefused(t *testing.T) {
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer secondary.Close()
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	client := &http.Client{Transport: &FailoverTransport{BaseURLs: []string{primary.URL, secondary.URL}}}
+	// Not idempotent, but it has never reached the primary
+	res, err := client.Post(primary.URL, "application/json", strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	if want, have := `{"a":1}`, string(body); want != have {
+		t.Errorf("want body %q, have %q", want, have)
+	}
+}
+
+func TestFailoverTransportNoBaseURLs(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://example.com/v1/users", nil)
+	res, err := (&FailoverTransport{}).RoundTrip(r)
+	if err == nil {
+		t.Fatal("want error, have nil")
+	}
+	if res != nil {
+		t.Errorf("want no response, have %v", res)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	tests := []struct {
+		Profile Profile
+		Timeout time.Duration
+		PerHost int
+	}{
+		{Profile: InternalService, Timeout: 10 * time.Second, PerHost: 100},
+		{Profile: ThirdParty, Timeout: 30 * time.Second, PerHost: 10},
+		{Profile: LongPoll, Timeout: 0, PerHost: 20},
+	}
+	for i, tt := range tests {
+		c := NewHTTPClient(tt.Profile)
+		if want, have := tt.Timeout, c.Timeout; want != have {
+			t.Errorf("#%d: want timeout %v, have %v", i, want, have)
+		}
+		tr, ok := c.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("#%d: want *http.Transport, have %T", i, c.Transport)
+		}
+		if want, have := tt.PerHost, tr.MaxIdleConnsPerHost; want != have {
+			t.Errorf("#%d: want MaxIdleConnsPerHost %d, have %d", i, want, have)
+		}
+		if want, have := uint16(tls.VersionTLS12), tr.TLSClientConfig.MinVersion; want != have {
+			t.Errorf("#%d: want TLS MinVersion %x, have %x", i, want, have)
+		}
+	}
+}
+
+func TestNewHTTPClientTransports(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace")))
+	}))
+	defer srv.Close()
+
+	trace := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				r.Header.Add("X-Trace", name)
+				return next.RoundTrip(r)
+			})
+		}
+	}
+	p := InternalService.With(trace("outer"), trace("inner"))
+	if len(InternalService.Transports) != 0 {
+		t.Fatal("want preset to be unchanged")
+	}
+	res, err := NewHTTPClient(p).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "outer", string(body); want != have {
+		t.Errorf("want first X-Trace %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Config holds the package-wide settings. The zero value uses the
+// defaults for all settings.
+//
+// The global Config is set with SetConfig, or one setting at a time
+// with e.g. SetRedactor or SetProxyConfig. WithConfig overrides it for
+// the requests passing through a middleware chain, which also allows
+// tests to run in parallel with different settings instead of mutating
+// the global Config. WithProxyConfig and WithDuplicateParameterPolicy
+// override a single setting.
+//
+// Functions without access to the request, e.g. WriteJSONError, use
+// the global Config. Use their counterparts that accept the request,
+// e.g. ServeJSONError, for WithConfig to take effect.
+//
+// Example:
+//
+//	cfg := httputil.CurrentConfig()
+//	cfg.ErrorEncoder = httputil.JSONAPIErrorEncoder
+//	api := httputil.WithConfig(cfg)(router)
+type Config struct {
+	// ErrorEncoder writes errors. If nil, errors are written as
+	// ErrorEnvelope. See SetErrorEncoder.
+	ErrorEncoder ErrorEncoder
+	// OnErrorWritten is called for each error written. See OnErrorWritten.
+	OnErrorWritten func(r *http.Request, code int, err error)
+	// Redactor removes credentials from dumps and logs. If nil, the
+	// Redactor of NewDefaultRedactor is used. See SetRedactor.
+	Redactor *Redactor
+	// Proxy configures the trusted proxies. See SetProxyConfig.
+	Proxy ProxyConfig
+	// DuplicateParameters is the policy for parameters that are passed
+	// more than once. See SetDuplicateParameterPolicy.
+	DuplicateParameters DuplicateParameterPolicy
+	// ErrorVerbosity controls how much of an error is written to the
+	// client. The zero value writes messages and details of all errors.
+	// Hooks and logs always get the complete error.
+	ErrorVerbosity ErrorVerbosity
+	// JSONCodec serializes JSON in ReadJSON, WriteJSON, and the error
+	// writers. If nil, encoding/json is used, and JSON is written
+	// indented. WriteJSON and WriteJSONCode use the global Config, as
+	// they have no access to the request.
+	JSONCodec JSONCodec
+	// FormLimits restricts the forms parsed by the Form* helpers. Zero
+	// fields use DefaultFormLimits. See ParseFormWithLimits.
+	FormLimits Limits
+	// PhoneNormalizer is used by MustFormPhone. If nil,
+	// NormalizePhoneE164 is used. See SetPhoneNormalizer.
+	PhoneNormalizer PhoneNormalizer
+	// CursorCodec is used by EncodeCursor and DecodeCursor. If nil, a
+	// codec with a random key is used. See SetCursorCodec. It is only
+	// used from the global Config, as EncodeCursor has no access to
+	// the request.
+	CursorCodec *CursorCodec
+	// JSONTimeLayout is the layout of JSONTime. If empty,
+	// DefaultJSONTimeLayout is used. See SetJSONTimeLayout. It is only
+	// used from the global Config, as JSONTime has no access to the
+	// request.
+	JSONTimeLayout string
+}
+
+var (
+	configMu     sync.RWMutex
+	globalConfig Config
+)
+
+// CurrentConfig returns the global Config.
+func CurrentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return globalConfig
+}
+
+// SetConfig sets the global Config. SetConfig(Config{}) restores the
+// defaults.
+func SetConfig(cfg Config) {
+	configMu.Lock()
+	globalConfig = cfg
+	configMu.Unlock()
+}
+
+// updateConfig changes a single setting of the global Config.
+func updateConfig(fn func(cfg *Config)) {
+	configMu.Lock()
+	fn(&globalConfig)
+	configMu.Unlock()
+}
+
+type configContextKey struct{}
+
+// WithConfig returns a middleware that makes the package use cfg
+// instead of the global Config for all requests passing through.
+// Settings only used from the global Config are ignored, see Config.
+func WithConfig(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), configContextKey{}, cfg)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ConfigFromContext returns the Config set by WithConfig, or the global
+// Config if there is none.
+func ConfigFromContext(ctx context.Context) Config {
+	if cfg, ok := ctx.Value(configContextKey{}).(Config); ok {
+		return cfg
+	}
+	return CurrentConfig()
+}
+
+// overrideConfig returns a middleware that changes a single setting of
+// the Config in effect for the requests passing through.
+func overrideConfig(fn func(cfg *Config)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := ConfigFromContext(r.Context())
+			fn(&cfg)
+			ctx := context.WithValue(r.Context(), configContextKey{}, cfg)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// configFor returns the Config in effect for r, which may be nil.
+func configFor(r *http.Request) Config {
+	if r == nil {
+		return CurrentConfig()
+	}
+	return ConfigFromContext(r.Context())
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetConfig(t *testing.T) {
+	defer SetConfig(CurrentConfig())
+
+	SetRedactor(&Redactor{Headers: []string{"X-Secret"}})
+	SetJSONTimeLayout(time.RFC1123)
+	SetDuplicateParameterPolicy(DuplicatesReject)
+	cfg := CurrentConfig()
+	if cfg.Redactor == nil || cfg.Redactor.Headers[0] != "X-Secret" {
+		t.Errorf("want Redactor of SetRedactor, have %+v", cfg.Redactor)
+	}
+	if want, have := time.RFC1123, cfg.JSONTimeLayout; want != have {
+		t.Errorf("want JSONTimeLayout %q, have %q", want, have)
+	}
+	if want, have := DuplicatesReject, cfg.DuplicateParameters; want != have {
+		t.Errorf("want DuplicateParameters %v, have %v", want, have)
+	}
+
+	SetConfig(Config{})
+	if want, have := DefaultJSONTimeLayout, currentJSONTimeLayout(); want != have {
+		t.Errorf("want default JSONTimeLayout %q, have %q", want, have)
+	}
+	if want, have := defaultRedactor, redactorFor(nil); want != have {
+		t.Errorf("want default Redactor, have %+v", have)
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+	cfg := CurrentConfig()
+	cfg.ErrorEncoder = JSONAPIErrorEncoder
+	cfg.Redactor = &Redactor{Headers: []string{"X-Secret"}}
+	cfg.DuplicateParameters = DuplicatesReject
+	var written atomic.Int64
+	cfg.OnErrorWritten = func(r *http.Request, code int, err error) {
+		written.Add(1)
+	}
+
+	h := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		if have := redactorFor(r).RedactHeader(r.Header).Get("X-Secret"); strings.Contains(have, "s3cr3t") {
+			t.Errorf("want X-Secret to be redacted, have %q", have)
+		}
+		MustQueryString(r, "q")
+	}))
+
+	for i := 0; i < 2; i++ {
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/?q=a&q=b", nil)
+			r.Header.Set("X-Secret", "s3cr3t")
+			h.ServeHTTP(w, r)
+			if want, have := http.StatusBadRequest, w.Code; want != have {
+				t.Errorf("want status %d, have %d", want, have)
+			}
+			if want, have := "application/vnd.api+json", w.Header().Get("Content-Type"); want != have {
+				t.Errorf("want Content-Type %q, have %q", want, have)
+			}
+		})
+	}
+	t.Cleanup(func() {
+		if want, have := int64(2), written.Load(); want != have {
+			t.Errorf("want OnErrorWritten to be called %d times, have %d", want, have)
+		}
+	})
+
+	// The global Config is unchanged
+	w := httptest.NewRecorder()
+	WriteJSONError(w, NotFoundError{})
+	if want, have := "application/json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("want Content-Type %q, have %q", want, have)
+	}
+}
+
+// upperJSONCodec marshals JSON without indentation and uppercases it.
+type upperJSONCodec struct{}
+
+func (upperJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	js, err := json.Marshal(v)
+	return bytes.ToUpper(js), err
+}
+
+func (upperJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(bytes.ToUpper(data), v)
+}
+
+func TestWithConfigErrorWriters(t *testing.T) {
+	cfg := CurrentConfig()
+	cfg.ErrorVerbosity = ErrorsHideInternal
+	cfg.JSONCodec = upperJSONCodec{}
+	cfg.OnErrorWritten = func(r *http.Request, code int, err error) {
+		if r == nil {
+			t.Error("want OnErrorWritten to get the request")
+		}
+	}
+	h := WithConfig(cfg)(WithDuplicateParameterPolicy(DuplicatesLastWins)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, have := "b", QueryString(r, "q", ""); want != have {
+			t.Errorf("want q=%q, have %q", want, have)
+		}
+		var v map[string]string
+		if err := ReadJSON(r, &v); err != nil || v["NAME"] != "OLIVER" {
+			t.Errorf("want JSONCodec to be used by ReadJSON, have %v and %v", v, err)
+		}
+		if r.URL.Path == "/internal" {
+			ServeJSONError(w, r, errors.New("pq: connection refused"))
+		} else {
+			ServeJSONError(w, r, InvalidParameterError("q"))
+		}
+	})))
+
+	tests := []struct {
+		Path string
+		Code int
+		Body string
+	}{
+		{Path: "/internal", Code: http.StatusInternalServerError, Body: `{"ERROR":{"CODE":500,"MESSAGE":"INTERNAL SERVER ERROR"}}`},
+		{Path: "/invalid", Code: http.StatusBadRequest, Body: `{"ERROR":{"CODE":400,"MESSAGE":"INVALID PARAMETER \"Q\""}}`},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", tt.Path+"?q=a&q=b", strings.NewReader(`{"name":"oliver"}`))
+		h.ServeHTTP(w, r)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Body, w.Body.String(); want != have {
+			t.Errorf("#%d: want body %s, have %s", i, want, have)
+		}
+	}
+
+	// WriteJSONError uses the global Config
+	w := httptest.NewRecorder()
+	WriteJSONError(w, errors.New("pq: connection refused"))
+	if want, have := "pq: connection refused", ErrorEnvelopeOf(t, w).Error.Message; want != have {
+		t.Errorf("want message %q, have %q", want, have)
+	}
+}
+
+func TestWithConfigOtherWriters(t *testing.T) {
+	cfg := CurrentConfig()
+	cfg.ErrorVerbosity = ErrorsHideInternal
+	cfg.JSONCodec = upperJSONCodec{}
+	var r *http.Request
+	WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r = req
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// Bulk results
+	w := httptest.NewRecorder()
+	ServeBulkResult(w, r, []BulkItem{
+		{Index: 0, Err: errors.New("pq: connection refused")},
+		{Index: 1, Err: InvalidParameterError("q")},
+	})
+	if body := w.Body.String(); !strings.Contains(body, `"MESSAGE":"INTERNAL SERVER ERROR"`) || strings.Contains(body, "PQ:") {
+		t.Errorf("want internal bulk error to be hidden, have %s", body)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"MESSAGE":"INVALID PARAMETER \"Q\""`) {
+		t.Errorf("want client bulk error to be written, have %s", body)
+	}
+
+	// Protocol Buffers
+	w = httptest.NewRecorder()
+	WriteProtoError(w, r, errors.New("pq: connection refused"))
+	if body := w.Body.String(); !strings.Contains(body, "Internal Server Error") || strings.Contains(body, "pq:") {
+		t.Errorf("want internal proto error to be hidden, have %s", body)
+	}
+
+	// Multipart responses
+	w = httptest.NewRecorder()
+	mr := NewMultipartResponseFor(w, r)
+	if err := mr.WriteJSON(map[string]string{"name": "oliver"}); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+	if body := w.Body.String(); !strings.Contains(body, `{"NAME":"OLIVER"}`) {
+		t.Errorf("want JSONCodec to be used by MultipartResponse, have %s", body)
+	}
+
+	// Encrypted JSON
+	keys := StaticJWEKey{ID: "partner-1", Key: []byte("0123456789abcdef0123456789abcdef")}
+	w = httptest.NewRecorder()
+	WriteEncryptedJSON(w, r, http.StatusOK, map[string]string{"name": "oliver"}, keys)
+	req := r.Clone(r.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(w.Body.Bytes()))
+	req.Header.Set("Content-Type", ContentTypeJOSE)
+	var v map[string]string
+	if err := ReadEncryptedJSON(req, keys, &v); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "OLIVER", v["NAME"]; want != have {
+		t.Errorf("want JSONCodec to be used by the encrypted JSON helpers, have %v", v)
+	}
+}
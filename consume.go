@@ -0,0 +1,284 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is an event of a Server-Sent Events stream.
+type SSEEvent struct {
+	// ID is the last event ID of the stream, which is sent in the
+	// Last-Event-ID header on reconnection.
+	ID string
+	// Event is the event type. It defaults to "message".
+	Event string
+	// Data is the payload, with multiple data lines joined by "\n".
+	Data string
+}
+
+// SSEConsumer consumes Server-Sent Events and reconnects if the stream
+// breaks, as specified in the HTML Living Standard, Section 9.2.
+// The zero value is ready to use.
+type SSEConsumer struct {
+	// Client is used to reconnect. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// RetryDelay is the time to wait before reconnecting, unless the
+	// server specifies it with a "retry" field. It defaults to 3 seconds.
+	RetryDelay time.Duration
+	// MaxRetries is the number of consecutive reconnections without
+	// receiving an event, after which Consume gives up. Zero uses the
+	// default of 3. Use a negative value, e.g. -1, to disable
+	// reconnection, so Consume returns when the stream breaks.
+	MaxRetries int
+}
+
+// ConsumeSSE calls fn for each event of the Server-Sent Events stream
+// of res, and reconnects with the Last-Event-ID header if the stream
+// breaks. See SSEConsumer for details.
+//
+// Example:
+//
+//	req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/events", nil)
+//	req.Header.Set("Accept", "text/event-stream")
+//	res, err := client.Do(req)
+//	if err != nil {
+//	  return err
+//	}
+//	err = httputil.ConsumeSSE(ctx, res, func(e httputil.SSEEvent) error {
+//	  log.Printf("%s: %s", e.Event, e.Data)
+//	  return nil
+//	})
+func ConsumeSSE(ctx context.Context, res *http.Response, fn func(SSEEvent) error) error {
+	return (&SSEConsumer{}).Consume(ctx, res, fn)
+}
+
+// Consume calls fn for each event of the stream of res and closes its
+// body. It returns the first error of fn, or when ctx is done. If the
+// stream breaks, Consume reconnects by re-sending res.Request with the
+// ID of the last event in the Last-Event-ID header. It returns nil when
+// the server responds to a reconnection with 204 No Content, which tells
+// clients to stop, and an error for other unexpected responses.
+func (c *SSEConsumer) Consume(ctx context.Context, res *http.Response, fn func(SSEEvent) error) error {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	delay := c.RetryDelay
+	if delay <= 0 {
+		delay = 3 * time.Second
+	}
+	maxRetries := c.MaxRetries
+	switch {
+	case maxRetries == 0:
+		maxRetries = 3
+	case maxRetries < 0:
+		maxRetries = 0
+	}
+
+	req := res.Request
+	var (
+		lastID string
+		err    error // of the last attempt
+	)
+	for retries := 0; ; retries++ {
+		if res != nil {
+			received, rerr := readSSE(ctx, res, &lastID, &delay, fn)
+			if e, ok := rerr.(sseReadError); ok {
+				rerr = e.err
+			} else if rerr != nil {
+				return rerr
+			}
+			if received {
+				retries = 0
+			}
+			err = rerr
+		}
+		if req == nil || retries >= maxRetries || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		r2 := req.Clone(ctx)
+		if req.GetBody != nil {
+			if r2.Body, err = req.GetBody(); err != nil {
+				return err
+			}
+		}
+		if lastID != "" {
+			r2.Header.Set("Last-Event-ID", lastID)
+		}
+		if res, err = client.Do(r2); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			res = nil
+			continue
+		}
+		switch {
+		case res.StatusCode == http.StatusNoContent:
+			DrainAndClose(res)
+			return nil
+		case res.StatusCode != http.StatusOK:
+			DrainAndClose(res)
+			return fmt.Errorf("%s %s: unexpected status %d", r2.Method, r2.URL.Redacted(), res.StatusCode)
+		}
+	}
+}
+
+// sseReadError is an error reading the stream, after which Consume
+// may reconnect.
+type sseReadError struct{ err error }
+
+func (e sseReadError) Error() string { return e.err.Error() }
+
+// readSSE calls fn for each event of res, and closes its body. It
+// returns whether it has received any events.
+func readSSE(ctx context.Context, res *http.Response, lastID *string, delay *time.Duration, fn func(SSEEvent) error) (bool, error) {
+	defer res.Body.Close()
+	stop := context.AfterFunc(ctx, func() { res.Body.Close() })
+	defer stop()
+
+	var (
+		received  bool
+		eventType string
+		data      strings.Builder
+		hasData   bool
+	)
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			// Dispatch the event
+			if hasData {
+				received = true
+				e := SSEEvent{ID: *lastID, Event: eventType, Data: data.String()}
+				if e.Event == "" {
+					e.Event = "message"
+				}
+				if err := fn(e); err != nil {
+					return received, err
+				}
+			}
+			eventType, hasData = "", false
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Comment
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				*lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+				*delay = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return received, ctx.Err()
+	}
+	if err := sc.Err(); err != nil {
+		return received, sseReadError{err}
+	}
+	return received, nil
+}
+
+// DefaultMaxNDJSONLineSize is the default of NDJSONConsumer.MaxLineSize.
+const DefaultMaxNDJSONLineSize = 1 << 20
+
+// NDJSONConsumer consumes newline-delimited JSON streams. The zero value
+// is ready to use.
+type NDJSONConsumer struct {
+	// MaxLineSize is the maximum size of a line in bytes. Consume returns
+	// an error for longer lines, so a stream without newlines cannot
+	// exhaust memory. It defaults to DefaultMaxNDJSONLineSize.
+	MaxLineSize int
+}
+
+// ConsumeNDJSON calls fn for each line of the newline-delimited JSON
+// stream of res, e.g. with content type "application/x-ndjson", and
+// closes its body. See NDJSONConsumer for details.
+//
+// Example:
+//
+//	err := httputil.ConsumeNDJSON(ctx, res, func(line json.RawMessage) error {
+//	  var item Item
+//	  if err := json.Unmarshal(line, &item); err != nil {
+//	    return err
+//	  }
+//	  ...
+//	})
+func ConsumeNDJSON(ctx context.Context, res *http.Response, fn func(json.RawMessage) error) error {
+	return (&NDJSONConsumer{}).Consume(ctx, res, fn)
+}
+
+// Consume calls fn for each line of the stream of res and closes its
+// body. Empty lines are skipped. It returns the first error of fn, an
+// error for lines that are not valid JSON or exceed MaxLineSize, or when
+// ctx is done.
+func (c *NDJSONConsumer) Consume(ctx context.Context, res *http.Response, fn func(json.RawMessage) error) error {
+	defer res.Body.Close()
+	stop := context.AfterFunc(ctx, func() { res.Body.Close() })
+	defer stop()
+
+	maxLineSize := c.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxNDJSONLineSize
+	}
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 0, min(64<<10, maxLineSize)), maxLineSize)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return fmt.Errorf("invalid JSON in line %d", n)
+		}
+		// The buffer of sc is reused, so pass a copy
+		if err := fn(json.RawMessage(bytes.Clone(line))); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := sc.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return fmt.Errorf("line %d exceeds the maximum size of %d bytes", n+1, maxLineSize)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConsumeSSE(t *testing.T) {
+	var lastIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch r.Header.Get("Last-Event-ID") {
+		case "":
+			fmt.Fprint(w, "retry: 1\n: comment\n\nid: 1\ndata: hello\n\nid: 2\nevent: update\ndata: line 1\r\ndata: line 2\n\n")
+		case "2":
+			fmt.Fprint(w, "id: 3\ndata: {\"a\":1}\n\nevent: ignored\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []SSEEvent
+	err = ConsumeSSE(context.Background(), res, func(e SSEEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SSEEvent{
+		{ID: "1", Event: "message", Data: "hello"},
+		{ID: "2", Event: "update", Data: "line 1\nline 2"},
+		{ID: "3", Event: "message", Data: `{"a":1}`},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("want %d events, have %d: %+v", len(want), len(events), events)
+	}
+	for i := range want {
+		if want, have := want[i], events[i]; want != have {
+			t.Errorf("#%d: want %+v, have %+v", i, want, have)
+		}
+	}
+	if want, have := ",2,3", strings.Join(lastIDs, ","); want != have {
+		t.Errorf("want Last-Event-IDs %q, have %q", want, have)
+	}
+}
+
+func TestConsumeSSEStop(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "retry: 1\ndata: 1\n\ndata: 2\n\n")
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	err = ConsumeSSE(context.Background(), res, func(e SSEEvent) error {
+		if e.Data == "2" {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("want error %v, have %v", errStop, err)
+	}
+	if want, have := 1, requests; want != have {
+		t.Errorf("want %d request, have %d", want, have)
+	}
+}
+
+func TestConsumeSSENoRetries(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "retry: 1\ndata: 1\n\n")
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events int
+	err = (&SSEConsumer{MaxRetries: -1}).Consume(context.Background(), res, func(e SSEEvent) error {
+		events++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, requests; want != have {
+		t.Errorf("want %d request, have %d", want, have)
+	}
+	if want, have := 1, events; want != have {
+		t.Errorf("want %d event, have %d", want, have)
+	}
+}
+
+func TestConsumeNDJSON(t *testing.T) {
+	tests := []struct {
+		Body  string
+		Lines []string
+		Err   bool
+	}{
+		{Body: "", Lines: nil},
+		{Body: "{\"a\":1}\n\n[1,2]\r\n\"x\"", Lines: []string{`{"a":1}`, `[1,2]`, `"x"`}},
+		{Body: "{\"a\":1}\n{\"a\":\n", Lines: []string{`{"a":1}`}, Err: true},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteString(tt.Body)
+		var lines []string
+		err := ConsumeNDJSON(context.Background(), w.Result(), func(line json.RawMessage) error {
+			lines = append(lines, string(line))
+			return nil
+		})
+		if tt.Err != (err != nil) {
+			t.Errorf("#%d: want error=%v, have %v", i, tt.Err, err)
+		}
+		if want, have := strings.Join(tt.Lines, " "), strings.Join(lines, " "); want != have {
+			t.Errorf("#%d: want lines %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestConsumeNDJSONMaxLineSize(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.WriteString("[1]\n\"" + strings.Repeat("x", 100) + "\"\n[2]\n")
+	var lines []string
+	err := (&NDJSONConsumer{MaxLineSize: 64}).Consume(context.Background(), w.Result(), func(line json.RawMessage) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err == nil {
+		t.Fatal("want error, have nil")
+	}
+	if want, have := "[1]", strings.Join(lines, " "); want != have {
+		t.Errorf("want lines %q, have %q", want, have)
+	}
+}
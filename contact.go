@@ -0,0 +1,197 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	emailHint = "Expected an e-mail address like name@example.com"
+	phoneHint = "Expected a phone number in international format like +14155552671"
+)
+
+// NormalizeEmail validates s as an e-mail address of the form
+// local@domain and returns it with surrounding whitespace removed and
+// the domain in lower case. It supports the dot-atom form of RFC 5322
+// only, i.e. no quoted local parts, comments, or display names.
+func NormalizeEmail(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 254 {
+		return "", false
+	}
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return "", false
+	}
+	local, domain := s[:at], strings.ToLower(s[at+1:])
+	if len(local) > 64 || !isDotAtom(local) || !isHostname(domain) {
+		return "", false
+	}
+	if !strings.Contains(domain, ".") {
+		return "", false
+	}
+	return local + "@" + domain, true
+}
+
+// isDotAtom returns true if s is a dot-atom as in RFC 5322 section 3.2.3.
+func isDotAtom(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' || strings.Contains(s, "..") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte(".!#$%&'*+/=?^_`{|}~-", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isHostname returns true if s is a sequence of dot-separated DNS labels.
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MustFormEmail checks if the request r has a Form value with
+// the specified key that is an e-mail address. The address is
+// normalized as in NormalizeEmail. If is doesn't, it will panic.
+func MustFormEmail(r *http.Request, key string) string {
+	v := mustFormValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	email, ok := NormalizeEmail(v)
+	if !ok {
+		panic(InvalidParameterHintError{Parameter: key, Hint: emailHint})
+	}
+	return email
+}
+
+// PhoneNormalizer converts phone numbers to E.164 format, e.g. "+14155552671".
+// Numbers without an international prefix are interpreted relative to
+// defaultRegion, an ISO 3166-1 alpha-2 country code like "US".
+//
+// Use SetPhoneNormalizer to plug in a full implementation, e.g. one
+// based on libphonenumber.
+type PhoneNormalizer interface {
+	NormalizePhone(number, defaultRegion string) (string, error)
+}
+
+// PhoneNormalizerFunc is an adapter to use ordinary funcs as PhoneNormalizer.
+type PhoneNormalizerFunc func(number, defaultRegion string) (string, error)
+
+// NormalizePhone calls f(number, defaultRegion).
+func (f PhoneNormalizerFunc) NormalizePhone(number, defaultRegion string) (string, error) {
+	return f(number, defaultRegion)
+}
+
+// SetPhoneNormalizer sets the PhoneNormalizer used by MustFormPhone.
+// Passing nil restores NormalizePhoneE164.
+func SetPhoneNormalizer(n PhoneNormalizer) {
+	updateConfig(func(cfg *Config) { cfg.PhoneNormalizer = n })
+}
+
+// errInvalidPhone is returned by NormalizePhoneE164 for invalid numbers.
+var errInvalidPhone = errors.New("httputil: invalid phone number")
+
+// callingCodes maps ISO 3166-1 alpha-2 country codes to their
+// international calling codes.
+var callingCodes = map[string]string{
+	"AR": "54", "AT": "43", "AU": "61", "BE": "32", "BG": "359", "BR": "55",
+	"CA": "1", "CH": "41", "CL": "56", "CN": "86", "CO": "57", "CY": "357",
+	"CZ": "420", "DE": "49", "DK": "45", "EE": "372", "EG": "20", "ES": "34",
+	"FI": "358", "FR": "33", "GB": "44", "GR": "30", "HK": "852", "HR": "385",
+	"HU": "36", "ID": "62", "IE": "353", "IL": "972", "IN": "91", "IS": "354",
+	"IT": "39", "JP": "81", "KR": "82", "LT": "370", "LU": "352", "LV": "371",
+	"MT": "356", "MX": "52", "MY": "60", "NG": "234", "NL": "31", "NO": "47",
+	"NZ": "64", "PE": "51", "PH": "63", "PK": "92", "PL": "48", "PT": "351",
+	"RO": "40", "RS": "381", "RU": "7", "SA": "966", "SE": "46", "SG": "65",
+	"SI": "386", "SK": "421", "TH": "66", "TR": "90", "TW": "886", "UA": "380",
+	"US": "1", "VN": "84", "ZA": "27",
+}
+
+// NormalizePhoneE164 is the default PhoneNormalizer. It removes
+// common separators and accepts numbers in international format
+// ("+49 89 1234567" or "0049 89 1234567"). National numbers are
+// prefixed with the calling code of defaultRegion after stripping a
+// leading trunk prefix "0" (or "1" in North America). It only checks
+// the length of the result, not whether the number is assigned.
+func NormalizePhoneE164(number, defaultRegion string) (string, error) {
+	var digits strings.Builder
+	for i, c := range strings.TrimSpace(number) {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == '+' && i == 0:
+			digits.WriteRune(c)
+		case c == ' ', c == '-', c == '.', c == '(', c == ')', c == '/':
+		default:
+			return "", errInvalidPhone
+		}
+	}
+	s := digits.String()
+	switch {
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	case strings.HasPrefix(s, "00"):
+		s = s[2:]
+	default:
+		code, found := callingCodes[strings.ToUpper(defaultRegion)]
+		if !found {
+			return "", errInvalidPhone
+		}
+		if code == "1" {
+			s = strings.TrimPrefix(s, "1")
+		} else if code != "39" {
+			// Italian numbers keep their leading zero
+			s = strings.TrimPrefix(s, "0")
+		}
+		s = code + s
+	}
+	// E.164 numbers have at most 15 digits
+	if len(s) < 7 || len(s) > 15 || s[0] == '0' || strings.Contains(s, "+") {
+		return "", errInvalidPhone
+	}
+	return "+" + s, nil
+}
+
+// MustFormPhone checks if the request r has a Form value with
+// the specified key that is a phone number. The number is returned in
+// E.164 format, see SetPhoneNormalizer. If is doesn't, it will panic.
+func MustFormPhone(r *http.Request, key string, defaultRegion string) string {
+	v := mustFormValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	n := configFor(r).PhoneNormalizer
+	if n == nil {
+		n = PhoneNormalizerFunc(NormalizePhoneE164)
+	}
+	phone, err := n.NormalizePhone(v, defaultRegion)
+	if err != nil {
+		panic(InvalidParameterHintError{Parameter: key, Hint: phoneHint})
+	}
+	return phone
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		Email string
+		Want  string
+		OK    bool
+	}{
+		{Email: "oliver@example.com", Want: "oliver@example.com", OK: true},
+		{Email: "  Oliver.Eilhard+news@Example.COM ", Want: "Oliver.Eilhard+news@example.com", OK: true},
+		{Email: "a@b.co", Want: "a@b.co", OK: true},
+		{Email: "", OK: false},
+		{Email: "oliver", OK: false},
+		{Email: "@example.com", OK: false},
+		{Email: "oliver@", OK: false},
+		{Email: "oliver@localhost", OK: false},
+		{Email: "oli..ver@example.com", OK: false},
+		{Email: ".oliver@example.com", OK: false},
+		{Email: "oliver@-example.com", OK: false},
+		{Email: "oliver@exa_mple.com", OK: false},
+		{Email: "Oliver <oliver@example.com>", OK: false},
+		{Email: strings.Repeat("a", 65) + "@example.com", OK: false},
+	}
+	for i, tt := range tests {
+		have, ok := NormalizeEmail(tt.Email)
+		if ok != tt.OK || have != tt.Want {
+			t.Errorf("#%d: want %q/%v, have %q/%v", i, tt.Want, tt.OK, have, ok)
+		}
+	}
+}
+
+func TestNormalizePhoneE164(t *testing.T) {
+	tests := []struct {
+		Number string
+		Region string
+		Want   string
+		OK     bool
+	}{
+		{Number: "+1 (415) 555-2671", Want: "+14155552671", OK: true},
+		{Number: "0049 89 1234567", Want: "+49891234567", OK: true},
+		{Number: "089/1234567", Region: "DE", Want: "+49891234567", OK: true},
+		{Number: "415.555.2671", Region: "us", Want: "+14155552671", OK: true},
+		{Number: "1-415-555-2671", Region: "US", Want: "+14155552671", OK: true},
+		{Number: "06 12345678", Region: "IT", Want: "+390612345678", OK: true},
+		{Number: "089 1234567", Region: "", OK: false},
+		{Number: "+49 89 12a4567", OK: false},
+		{Number: "49+891234567", OK: false},
+		{Number: "+123", OK: false},
+		{Number: "+1234567890123456", OK: false},
+	}
+	for i, tt := range tests {
+		have, err := NormalizePhoneE164(tt.Number, tt.Region)
+		if ok := err == nil; ok != tt.OK || have != tt.Want {
+			t.Errorf("#%d: want %q/%v, have %q/%v", i, tt.Want, tt.OK, have, err)
+		}
+	}
+}
+
+func TestMustFormEmailAndPhone(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		email := MustFormEmail(r, "email")
+		phone := MustFormPhone(r, "phone", "DE")
+		w.Write([]byte(email + " " + phone))
+	}
+
+	tests := []struct {
+		Form    url.Values
+		Code    int
+		Body    string
+		Details []string
+	}{
+		{
+			Form: url.Values{"email": {"Oliver@Example.com"}, "phone": {"089 1234567"}},
+			Code: http.StatusOK,
+			Body: "Oliver@example.com +49891234567",
+		},
+		{
+			Form: url.Values{"phone": {"089 1234567"}},
+			Code: http.StatusBadRequest,
+		},
+		{
+			Form:    url.Values{"email": {"oliver"}, "phone": {"089 1234567"}},
+			Code:    http.StatusBadRequest,
+			Details: []string{emailHint},
+		},
+		{
+			Form:    url.Values{"email": {"oliver@example.com"}, "phone": {"call me"}},
+			Code:    http.StatusBadRequest,
+			Details: []string{phoneHint},
+		},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.Form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+			continue
+		}
+		resp := ErrorEnvelopeOf(t, w)
+		if want, have := len(tt.Details), len(resp.Error.Details); want != have {
+			t.Fatalf("#%d: want %d details, have %d", i, want, have)
+		}
+		for j := range tt.Details {
+			if want, have := tt.Details[j], resp.Error.Details[j]; want != have {
+				t.Errorf("#%d: want detail %q, have %q", i, want, have)
+			}
+		}
+	}
+}
+
+func TestSetPhoneNormalizer(t *testing.T) {
+	SetPhoneNormalizer(PhoneNormalizerFunc(func(number, region string) (string, error) {
+		return "+" + region + number, nil
+	}))
+	defer SetPhoneNormalizer(nil)
+
+	req := httptest.NewRequest("GET", "/?phone=123", nil)
+	if want, have := "+XX123", MustFormPhone(req, "phone", "XX"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestInvalidParameterHintErrorUnwrap(t *testing.T) {
+	var err error = InvalidParameterHintError{Parameter: "email", Hint: emailHint}
+	var perr InvalidParameterError
+	if !errors.As(err, &perr) {
+		t.Fatal("want InvalidParameterHintError to unwrap to InvalidParameterError")
+	}
+	if want, have := "email", string(perr); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := `Invalid parameter "email"`, err.Error(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// AcceptContinue runs precheck on the request headers before the body of r
+// is being read. If precheck returns an error, the error is written with
+// WriteJSONError and AcceptContinue returns false. In that case, the
+// handler must return without reading the body.
+//
+// If the client has sent "Expect: 100-continue", it waits for the server
+// before sending the body. The HTTP server only sends "100 Continue" once
+// the handler starts reading the body, so a rejected upload never gets
+// transmitted, which saves bandwidth on large uploads.
+//
+// Example:
+//
+//	func Upload(w http.ResponseWriter, r *http.Request) {
+//	  if !httputil.AcceptContinue(w, r, httputil.MaxContentLength(100<<20)) {
+//	    return
+//	  }
+//	  // Read r.Body ...
+//	}
+func AcceptContinue(w http.ResponseWriter, r *http.Request, precheck ...func(r *http.Request) error) bool {
+	for _, check := range precheck {
+		if err := check(r); err != nil {
+			if strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+				// Don't wait for a body that will never be read
+				w.Header().Set("Connection", "close")
+			}
+			writeJSONError(w, r, err)
+			return false
+		}
+	}
+	return true
+}
+
+// MaxContentLength returns a precheck for AcceptContinue that rejects
+// requests without a Content-Length or with a Content-Length that is
+// larger than max with RequestEntityTooLargeError.
+func MaxContentLength(max int64) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		if r.ContentLength < 0 || r.ContentLength > max {
+			return RequestEntityTooLargeError{}
+		}
+		return nil
+	}
+}
+
+// RequireContentType returns a precheck for AcceptContinue that rejects
+// requests with a content type not in types with UnsupportedMediaTypeError.
+// Types may use wildcards like "image/*".
+func RequireContentType(types ...string) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return UnsupportedMediaTypeError{}
+		}
+		for _, t := range types {
+			if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(t, "*"))) {
+				return nil
+			}
+		}
+		return UnsupportedMediaTypeError{}
+	}
+}
+
+// RequireBearerToken returns a precheck for AcceptContinue that rejects
+// requests without a bearer token, or with a token for which valid
+// returns false, with UnauthorizedError.
+func RequireBearerToken(valid func(token string) bool) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		token, ok := BearerToken(r)
+		if !ok || !valid(token) {
+			return UnauthorizedError{}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcceptContinue(t *testing.T) {
+	var bodiesRead int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !AcceptContinue(w, r,
+			RequireBearerToken(func(token string) bool { return token == "secret" }),
+			MaxContentLength(1024),
+			RequireContentType("image/*"),
+		) {
+			return
+		}
+		ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&bodiesRead, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+	tests := []struct {
+		Token       string
+		Size        int
+		ContentType string
+		Want        int
+	}{
+		{Token: "secret", Size: 512, ContentType: "image/png", Want: http.StatusCreated},
+		{Token: "wrong", Size: 512, ContentType: "image/png", Want: http.StatusUnauthorized},
+		{Token: "secret", Size: 2048, ContentType: "image/png", Want: http.StatusRequestEntityTooLarge},
+		{Token: "secret", Size: 512, ContentType: "text/plain", Want: http.StatusUnsupportedMediaType},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest("PUT", srv.URL, bytes.NewReader(make([]byte, tt.Size)))
+		req.Header.Set("Authorization", "Bearer "+tt.Token)
+		req.Header.Set("Content-Type", tt.ContentType)
+		req.Header.Set("Expect", "100-continue")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		resp.Body.Close()
+		if want, have := tt.Want, resp.StatusCode; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+	if want, have := int32(1), atomic.LoadInt32(&bodiesRead); want != have {
+		t.Errorf("want %d bodies read, have %d", want, have)
+	}
+}
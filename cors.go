@@ -0,0 +1,119 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures CORS.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to send cross-origin
+	// requests, e.g. "https://app.example.com", or "*" for all.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, HEAD, POST, PUT, PATCH, and DELETE.
+	AllowedMethods []string
+	// AllowedHeaders defaults to Accept, Authorization, and Content-Type.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers that scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration
+	// Routes, if set, restricts the methods allowed in preflight
+	// responses to those of the routes matching the URL. Preflight
+	// requests for URLs without routes are passed to the next handler.
+	// See Options.
+	Routes RouteLister
+}
+
+// CORS returns a middleware that implements Cross-Origin Resource
+// Sharing. Preflight requests from allowed origins are answered with
+// 204 No Content; requests from other origins are passed through without
+// CORS headers, so browsers block them.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Accept", "Authorization", "Content-Type"}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if !cfg.allows(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if containsString(cfg.AllowedOrigins, "*") && !cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				methods := cfg.AllowedMethods
+				if cfg.Routes != nil {
+					if methods = cfg.routeMethods(r); len(methods) == 0 {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+				h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+				h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+				if cfg.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if len(cfg.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cfg CORSConfig) allows(origin string) bool {
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods returns the allowed methods of the routes matching the
+// URL of r. Routes that accept all methods, or handle OPTIONS
+// themselves, allow all AllowedMethods.
+func (cfg CORSConfig) routeMethods(r *http.Request) []string {
+	routeMethods := cfg.Routes.Methods(r)
+	if containsString(routeMethods, http.MethodOptions) {
+		return cfg.AllowedMethods
+	}
+	var methods []string
+	for _, method := range cfg.AllowedMethods {
+		if containsString(routeMethods, method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	h := CORS(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		Method        string
+		Origin        string
+		RequestMethod string
+		Code          int
+		AllowOrigin   string
+		AllowMethods  string
+		MaxAge        string
+		Expose        string
+	}{
+		{Method: "GET", Code: http.StatusOK},
+		{Method: "GET", Origin: "https://evil.example.com", Code: http.StatusOK},
+		{Method: "GET", Origin: "https://app.example.com", Code: http.StatusOK, AllowOrigin: "https://app.example.com", Expose: "X-Request-Id"},
+		{
+			Method:        "OPTIONS",
+			Origin:        "https://app.example.com",
+			RequestMethod: "PUT",
+			Code:          http.StatusNoContent,
+			AllowOrigin:   "https://app.example.com",
+			AllowMethods:  "GET, HEAD, POST, PUT, PATCH, DELETE",
+			MaxAge:        "600",
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.Method, "/", nil)
+		if tt.Origin != "" {
+			r.Header.Set("Origin", tt.Origin)
+		}
+		if tt.RequestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.RequestMethod)
+		}
+		h.ServeHTTP(w, r)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		for name, want := range map[string]string{
+			"Access-Control-Allow-Origin":   tt.AllowOrigin,
+			"Access-Control-Allow-Methods":  tt.AllowMethods,
+			"Access-Control-Max-Age":        tt.MaxAge,
+			"Access-Control-Expose-Headers": tt.Expose,
+		} {
+			if have := w.Header().Get(name); want != have {
+				t.Errorf("#%d: want %s %q, have %q", i, name, want, have)
+			}
+		}
+		if tt.AllowOrigin != "" {
+			if want, have := "true", w.Header().Get("Access-Control-Allow-Credentials"); want != have {
+				t.Errorf("#%d: want credentials %q, have %q", i, want, have)
+			}
+		}
+	}
+
+	// Wildcard without credentials
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://any.example.com")
+	CORS(CORSConfig{AllowedOrigins: []string{"*"}})(http.NotFoundHandler()).ServeHTTP(w, r)
+	if want, have := "*", w.Header().Get("Access-Control-Allow-Origin"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// utf8BOM is the byte order mark that makes Excel recognize UTF-8 in CSV files.
+const utf8BOM = "\xef\xbb\xbf"
+
+// WriteCSV writes a CSV file into w with the given HTTP status code.
+// The header is written as the first record, followed by the records
+// passed to yield by rows. Records are streamed to the client as they
+// are produced.
+//
+// Example:
+//
+//	httputil.WriteCSV(w, http.StatusOK, []string{"id", "name"}, func(yield func([]string)) {
+//	  for _, u := range users {
+//	    yield([]string{u.ID, u.Name})
+//	  }
+//	})
+func WriteCSV(w http.ResponseWriter, code int, header []string, rows func(yield func([]string))) {
+	writeCSV(w, code, false, header, rows)
+}
+
+// WriteExcelCSV is like WriteCSV, but prefixes the output with a UTF-8
+// byte order mark so that Excel detects the encoding correctly.
+func WriteExcelCSV(w http.ResponseWriter, code int, header []string, rows func(yield func([]string))) {
+	writeCSV(w, code, true, header, rows)
+}
+
+func writeCSV(w http.ResponseWriter, code int, bom bool, header []string, rows func(yield func([]string))) {
+	cw := CSVWriter{BOM: bom}
+	w.Header().Set("Content-Type", cw.ContentType())
+	w.WriteHeader(code)
+	cw.WriteTable(w, header, rows)
+}
+
+// ReadCSV deserializes the CSV body of the request into dst, which must
+// be a pointer to a slice of structs. The first record is the header.
+// Columns are mapped to struct fields via the "csv" struct tag, e.g.
+// `csv:"name"`, or by the field name if there is no tag. Columns without
+// a matching field are ignored; use `csv:"-"` to skip a field.
+//
+// Supported field types are strings, bools, integers, floats, and types
+// that implement encoding.TextUnmarshaler. A maximum size of 32 MB is
+// permitted. Malformed CSV data are returned as InvalidCSVError, and
+// conversion errors as UnprocessableEntityError with one detail per
+// failed value.
+func ReadCSV(r *http.Request, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice || rv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.New("ReadCSV: dst must be a pointer to a slice of structs")
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+
+	br := bufio.NewReader(io.LimitReader(r.Body, 32<<20))
+	if b, err := br.Peek(len(utf8BOM)); err == nil && string(b) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return InvalidCSVError{fmt.Errorf("invalid CSV data: %w", err)}
+	}
+	fields := csvFieldIndexes(elemType, header)
+
+	var details []string
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return InvalidCSVError{fmt.Errorf("invalid CSV data: %w", err)}
+		}
+		elem := reflect.New(elemType).Elem()
+		for col, value := range record {
+			if col >= len(fields) || fields[col] == nil {
+				continue
+			}
+			if err := setCSVField(elem.FieldByIndex(fields[col]), value); err != nil {
+				details = append(details, fmt.Sprintf("line %d: invalid value for column %q", line, header[col]))
+			}
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	if len(details) > 0 {
+		return UnprocessableEntityError{Errors: details}
+	}
+	return nil
+}
+
+// csvFieldIndexes returns the index of the struct field for each column
+// of header, or nil if there is no matching field.
+func csvFieldIndexes(t reflect.Type, header []string) [][]int {
+	byName := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("csv"); tag != "" {
+			name = strings.Split(tag, ",")[0]
+		}
+		if name == "-" {
+			continue
+		}
+		byName[name] = f.Index
+	}
+	indexes := make([][]int, len(header))
+	for i, h := range header {
+		indexes[i] = byName[strings.TrimSpace(h)]
+	}
+	return indexes
+}
+
+func setCSVField(f reflect.Value, s string) error {
+	if s == "" {
+		return nil
+	}
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported type %v", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCSV(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteExcelCSV(w, 200, []string{"id", "name"}, func(yield func([]string)) {
+		yield([]string{"1", "Oliver"})
+		yield([]string{"2", `Smith, "Jr."`})
+	})
+
+	if w.Code != 200 {
+		t.Fatalf("expected status = %d; got: %d", 200, w.Code)
+	}
+	if want, have := "text/csv; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("want Content-Type %q, have %q", want, have)
+	}
+	want := "\xef\xbb\xbfid,name\n1,Oliver\n2,\"Smith, \"\"Jr.\"\"\"\n"
+	if have := w.Body.String(); want != have {
+		t.Errorf("want body %q, have %q", want, have)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	type user struct {
+		ID      int64     `csv:"id"`
+		Name    string    `csv:"name"`
+		Active  bool      `csv:"active"`
+		Born    time.Time `csv:"born"`
+		Skipped string    `csv:"-"`
+	}
+
+	body := "\xef\xbb\xbfname,id,active,unknown,born\n" +
+		"Oliver,1,true,x,2000-01-02T00:00:00Z\n" +
+		"Sandra,2,false,y,2001-02-03T00:00:00Z\n"
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+
+	var users []user
+	if err := ReadCSV(req, &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users; got: %d", len(users))
+	}
+	if users[0].ID != 1 || users[0].Name != "Oliver" || !users[0].Active || users[0].Born.Year() != 2000 {
+		t.Errorf("unexpected users[0]: %+v", users[0])
+	}
+	if users[1].ID != 2 || users[1].Name != "Sandra" || users[1].Active || users[1].Born.Year() != 2001 {
+		t.Errorf("unexpected users[1]: %+v", users[1])
+	}
+}
+
+func TestReadCSVFailure(t *testing.T) {
+	type user struct {
+		ID   int    `csv:"id"`
+		Name string `csv:"name"`
+	}
+
+	body := "id,name\nA,Oliver\n2,Sandra\n"
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+
+	var users []user
+	err := ReadCSV(req, &users)
+	uerr, ok := err.(UnprocessableEntityError)
+	if !ok {
+		t.Fatalf("expected UnprocessableEntityError; got: %v", err)
+	}
+	if len(uerr.Errors) != 1 || uerr.Errors[0] != `line 2: invalid value for column "id"` {
+		t.Errorf("unexpected error details: %v", uerr.Errors)
+	}
+}
+
+func TestReadCSVInvalid(t *testing.T) {
+	type user struct {
+		ID   int    `csv:"id"`
+		Name string `csv:"name"`
+	}
+
+	tests := []string{
+		"id,\"name\n1,Oliver\n",
+		"id,name\n1,\"Oli\"ver\n",
+	}
+	for i, body := range tests {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		var users []user
+		err := ReadCSV(req, &users)
+		var cerr InvalidCSVError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("#%d: want InvalidCSVError, have %v", i, err)
+		}
+		if want, have := http.StatusBadRequest, cerr.HTTPCode(); want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		var perr *csv.ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("#%d: want to unwrap *csv.ParseError, have %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// CurlConfig configures CurlCommandWithConfig.
+type CurlConfig struct {
+	// Redactor redacts headers, query string, and body. It defaults to
+	// the Redactor set by SetRedactor.
+	Redactor *Redactor
+	// Unredacted disables redaction altogether, e.g. to replay a
+	// request locally.
+	Unredacted bool
+}
+
+// CurlCommand returns a curl command line equivalent to r, e.g. to paste
+// into bug reports, redacted by the Redactor set by SetRedactor. It works
+// for outgoing requests as well as for requests received by a handler.
+// The body of r is restored, so r can still be sent or served.
+//
+// Example output:
+//
+//	curl -X POST 'https://example.com/orders?limit=10' -H 'Authorization: [REDACTED]' -H 'Content-Type: application/json' --data-binary '{"id":1}'
+func CurlCommand(r *http.Request) string {
+	return CurlCommandWithConfig(r, CurlConfig{})
+}
+
+// CurlCommandWithConfig is like CurlCommand, but with custom redaction.
+func CurlCommandWithConfig(r *http.Request, cfg CurlConfig) string {
+	rd := cfg.Redactor
+	if rd == nil {
+		rd = redactorFor(r)
+	}
+
+	u := *r.URL
+	if u.Host == "" {
+		// Incoming request
+		u.Scheme, u.Host = RequestScheme(r), RequestHost(r)
+	}
+	u.User = nil
+	rawURL := u.String()
+	if !cfg.Unredacted {
+		rawURL = rd.RedactURL(&u)
+	}
+
+	args := []string{"curl"}
+	if r.Method != "" && r.Method != http.MethodGet {
+		args = append(args, "-X", shellQuote(r.Method))
+	}
+	args = append(args, shellQuote(rawURL))
+
+	header := r.Header
+	if !cfg.Unredacted {
+		header = rd.RedactHeader(header)
+	}
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "Content-Length" {
+			continue
+		}
+		for _, value := range header[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+
+	if body := curlBody(r); len(body) > 0 {
+		if !cfg.Unredacted {
+			body = []byte(rd.RedactText(string(body)))
+		}
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+	return strings.Join(args, " ")
+}
+
+// curlBody returns the beginning of the body of r and restores it.
+func curlBody(r *http.Request) []byte {
+	if r.GetBody != nil {
+		// Outgoing request: don't consume the body to be sent
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil
+		}
+		defer rc.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(rc, maxDebugDumpSize))
+		return body
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxDebugDumpSize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
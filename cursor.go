@@ -0,0 +1,164 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Versions of the cursor format.
+const (
+	cursorVersionSigned    byte = 1
+	cursorVersionEncrypted byte = 2
+)
+
+// CursorCodec serializes pagination cursors into opaque, URL-safe tokens.
+// Tokens are signed with HMAC SHA-256 so that clients cannot tamper with
+// them. If EncryptionKey is set, tokens are encrypted with AES-GCM as
+// well, hiding their contents from clients.
+type CursorCodec struct {
+	// Key is the secret used to sign cursors.
+	Key []byte
+	// EncryptionKey is optional. If set, it must be 16, 24, or 32 bytes.
+	EncryptionKey []byte
+}
+
+// Encode serializes v as JSON and returns it as an opaque token.
+func (c *CursorCodec) Encode(v interface{}) (string, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var token []byte
+	if len(c.EncryptionKey) > 0 {
+		aead, err := c.aead()
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		token = append([]byte{cursorVersionEncrypted}, nonce...)
+		token = aead.Seal(token, nonce, js, token[:1])
+	} else {
+		token = append([]byte{cursorVersionSigned}, js...)
+	}
+	token = append(token, c.sign(token)...)
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// Decode deserializes the token s, created by Encode, into dst.
+func (c *CursorCodec) Decode(s string, dst interface{}) error {
+	token, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(token) < 1+sha256.Size {
+		return errors.New("cursor too short")
+	}
+	body, mac := token[:len(token)-sha256.Size], token[len(token)-sha256.Size:]
+	if !hmac.Equal(mac, c.sign(body)) {
+		return errors.New("invalid cursor signature")
+	}
+	var js []byte
+	switch body[0] {
+	case cursorVersionSigned:
+		js = body[1:]
+	case cursorVersionEncrypted:
+		aead, err := c.aead()
+		if err != nil {
+			return err
+		}
+		if len(body) < 1+aead.NonceSize() {
+			return errors.New("cursor too short")
+		}
+		nonce := body[1 : 1+aead.NonceSize()]
+		js, err = aead.Open(nil, nonce, body[1+aead.NonceSize():], body[:1])
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported cursor version %d", body[0])
+	}
+	return json.Unmarshal(js, dst)
+}
+
+func (c *CursorCodec) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (c *CursorCodec) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	randomCursorCodecOnce sync.Once
+	randomCursorCodec     *CursorCodec
+)
+
+// SetCursorCodec sets the CursorCodec used by EncodeCursor and DecodeCursor.
+// By default, a codec with a random key is used, so cursors are only valid
+// for the lifetime of the process. Services with more than one instance
+// must set a codec with a shared key.
+func SetCursorCodec(c *CursorCodec) {
+	updateConfig(func(cfg *Config) { cfg.CursorCodec = c })
+}
+
+func cursorCodec() *CursorCodec {
+	if c := CurrentConfig().CursorCodec; c != nil {
+		return c
+	}
+	randomCursorCodecOnce.Do(func() {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		randomCursorCodec = &CursorCodec{Key: key}
+	})
+	return randomCursorCodec
+}
+
+// EncodeCursor serializes v, e.g. the keyset of the last record on a page,
+// into an opaque token to be passed to clients. It panics with ServerError
+// if v cannot be serialized.
+func EncodeCursor(v interface{}) string {
+	s, err := cursorCodec().Encode(v)
+	if err != nil {
+		panic(ServerError(fmt.Sprintf("Unable to encode cursor: %v", err)))
+	}
+	return s
+}
+
+// DecodeCursor deserializes the cursor in the query string parameter key
+// of r into dst. If r has no such parameter, dst is left unchanged and
+// nil is returned. If the cursor is invalid, e.g. because it has been
+// tampered with, InvalidParameterError is returned.
+func DecodeCursor(r *http.Request, key string, dst interface{}) error {
+	v, err := lookupQuery(r, key)
+	if err != nil || v == "" {
+		return err
+	}
+	if err := cursorCodec().Decode(v, dst); err != nil {
+		return InvalidParameterError(key)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCursorCodec(t *testing.T) {
+	type keyset struct {
+		CreatedAt int64  `json:"c"`
+		ID        string `json:"i"`
+	}
+
+	codecs := []*CursorCodec{
+		{Key: []byte("secret")},
+		{Key: []byte("secret"), EncryptionKey: []byte("0123456789abcdef")},
+	}
+	for i, c := range codecs {
+		s, err := c.Encode(keyset{CreatedAt: 1500000000, ID: "order-42"})
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if strings.ContainsAny(s, "+/=") {
+			t.Errorf("#%d: expected URL-safe cursor; got: %q", i, s)
+		}
+		raw, _ := base64.RawURLEncoding.DecodeString(s)
+		if encrypted := len(c.EncryptionKey) > 0; encrypted == strings.Contains(string(raw), "order-42") {
+			t.Errorf("#%d: unexpected cursor contents: %q", i, raw)
+		}
+		var ks keyset
+		if err := c.Decode(s, &ks); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if ks.CreatedAt != 1500000000 || ks.ID != "order-42" {
+			t.Errorf("#%d: unexpected cursor: %+v", i, ks)
+		}
+
+		// Tampering
+		tampered := []byte(s)
+		tampered[3] ^= 1
+		if err := c.Decode(string(tampered), &ks); err == nil {
+			t.Errorf("#%d: expected tampered cursor to fail", i)
+		}
+		// Different key
+		other := &CursorCodec{Key: []byte("other"), EncryptionKey: c.EncryptionKey}
+		if err := other.Decode(s, &ks); err == nil {
+			t.Errorf("#%d: expected cursor with different key to fail", i)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	type keyset struct {
+		ID int `json:"id"`
+	}
+
+	cursor := EncodeCursor(keyset{ID: 42})
+
+	var ks keyset
+	if err := DecodeCursor(httptest.NewRequest("GET", "/orders?after="+cursor, nil), "after", &ks); err != nil {
+		t.Fatal(err)
+	}
+	if ks.ID != 42 {
+		t.Fatalf("want ID %d, have %d", 42, ks.ID)
+	}
+
+	ks = keyset{}
+	if err := DecodeCursor(httptest.NewRequest("GET", "/orders", nil), "after", &ks); err != nil || ks.ID != 0 {
+		t.Fatalf("expected missing cursor to be ignored; got: %v, %+v", err, ks)
+	}
+
+	err := DecodeCursor(httptest.NewRequest("GET", "/orders?after=eyJpZCI6NDJ9", nil), "after", &ks)
+	if err != InvalidParameterError("after") {
+		t.Fatalf("expected InvalidParameterError; got: %v", err)
+	}
+}
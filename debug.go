@@ -5,14 +5,254 @@
 package httputil
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	stdhttputil "net/http/httputil"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// DumpRequestOut prints the request to the given io.Writer.
+// DumpRequestOut prints the request to the given io.Writer, redacted
+// by the Redactor set by SetRedactor.
 func DumpRequestOut(w io.Writer, r *http.Request) {
-	data, _ := stdhttputil.DumpRequestOut(r, true)
-	fmt.Fprint(w, string(data))
+	rd := redactorFor(r)
+	dump := r.Clone(r.Context())
+	dump.Header = rd.RedactHeader(r.Header)
+	if u, err := url.Parse(rd.RedactURL(r.URL)); err == nil {
+		dump.URL = u
+	}
+	data, _ := stdhttputil.DumpRequestOut(dump, true)
+	r.Body = dump.Body
+	fmt.Fprint(w, rd.RedactText(string(data)))
+}
+
+const (
+	// DefaultDebugHeader is the default header of debug tokens.
+	DefaultDebugHeader = "X-Debug"
+	// DefaultDebugParam is the default query string parameter
+	// of debug tokens.
+	DefaultDebugParam = "_debug"
+
+	// maxDebugDumpSize is the maximum number of body bytes dumped
+	// in debug mode.
+	maxDebugDumpSize = 64 << 10
+)
+
+type debugContextKey struct{}
+
+// NewDebugToken returns a token that enables debug mode for requests
+// until ttl expires, see DebugMode. Hand it out to engineers triaging
+// production issues, e.g. via an internal tool.
+func NewDebugToken(secret []byte, ttl time.Duration) string {
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return expires + "." + signDebugToken(expires, secret)
+}
+
+// VerifyDebugToken returns true if token has been created by
+// NewDebugToken with the same secret and has not yet expired.
+func VerifyDebugToken(token string, secret []byte) bool {
+	expires, sig, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(sig), []byte(signDebugToken(expires, secret))) {
+		return false
+	}
+	t, err := strconv.ParseInt(expires, 10, 64)
+	return err == nil && time.Now().Unix() <= t
+}
+
+func signDebugToken(expires string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("debug:"))
+	mac.Write([]byte(expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DebugConfig configures the DebugMode middleware.
+type DebugConfig struct {
+	// Secret verifies debug tokens. It is required.
+	Secret []byte
+	// Header is the request header with the debug token.
+	// It defaults to DefaultDebugHeader.
+	Header string
+	// Param is the query string parameter with the debug token, e.g.
+	// for browsers. It defaults to DefaultDebugParam.
+	Param string
+}
+
+// DebugMode returns a middleware that enables verbose behavior for single
+// requests with a valid debug token (see NewDebugToken) in a header or
+// query string parameter, e.g. for triaging production issues:
+//
+//   - A Server-Timing header reports the time spent in the handler and
+//     the measurements of the Timing collector.
+//   - JSON errors get a "debug" field with the Go type, the chain of
+//     wrapped errors, and the stack trace.
+//   - Request and response are dumped to the logger of the request (see
+//     LoggerFromContext), redacted by the Redactor set by SetRedactor.
+//
+// Requests with a missing or invalid token are served as usual. Use
+// IsDebug to enable more verbose behavior in handlers.
+func DebugMode(cfg DebugConfig) func(http.Handler) http.Handler {
+	if cfg.Header == "" {
+		cfg.Header = DefaultDebugHeader
+	}
+	if cfg.Param == "" {
+		cfg.Param = DefaultDebugParam
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(cfg.Header)
+			if token == "" {
+				// Rejected duplicates are treated as no token
+				token, _ = lookupQuery(r, cfg.Param)
+			}
+			if token == "" || !VerifyDebugToken(token, cfg.Secret) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			ctx := context.WithValue(r.Context(), debugContextKey{}, true)
+			tw := w
+			if _, found := ctx.Value(timingContextKey{}).(*ServerTiming); !found {
+				tm := &ServerTiming{}
+				ctx = context.WithValue(ctx, timingContextKey{}, tm)
+				tw = wrapResponseWriter(&timingResponseWriter{responseWriter: responseWriter{w}, tm: tm, start: start, total: true})
+			}
+			r = r.WithContext(ctx)
+			requestDump := dumpDebugRequest(r)
+			dw := &debugResponseWriter{responseWriter: responseWriter{tw}}
+			next.ServeHTTP(wrapResponseWriter(dw), r)
+
+			logger := LoggerFromContext(r.Context())
+			logger.InfoContext(r.Context(), "Debug request",
+				"request", requestDump,
+				"status", dw.status(),
+				"response_header", redactorFor(r).RedactHeader(w.Header()),
+				"response_body", redactorFor(r).RedactText(dw.body.String()),
+				"duration", time.Since(start),
+			)
+		})
+	}
+}
+
+// IsDebug returns true if debug mode has been enabled for the request
+// with ctx by DebugMode.
+func IsDebug(ctx context.Context) bool {
+	on, _ := ctx.Value(debugContextKey{}).(bool)
+	return on
+}
+
+// dumpDebugRequest returns the request line, redacted headers, and the
+// beginning of the body of r. The body of r is restored.
+func dumpDebugRequest(r *http.Request) string {
+	dump := r.Clone(r.Context())
+	dump.Header = redactorFor(r).RedactHeader(r.Header)
+	if u, err := url.Parse(redactorFor(r).RedactURL(r.URL)); err == nil {
+		dump.URL, dump.RequestURI = u, ""
+	}
+	dump.Body = nil
+	data, _ := stdhttputil.DumpRequest(dump, false)
+	if r.Body != nil {
+		body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxDebugDumpSize))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		data = append(data, body...)
+	}
+	return redactorFor(r).RedactText(string(data))
+}
+
+// debugResponseWriter records the status code and the beginning of
+// the response body.
+type debugResponseWriter struct {
+	responseWriter
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (dw *debugResponseWriter) WriteHeader(code int) {
+	if dw.wroteHeader {
+		return
+	}
+	dw.wroteHeader = true
+	dw.code = code
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+func (dw *debugResponseWriter) Write(p []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	if n := maxDebugDumpSize - dw.body.Len(); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		dw.body.Write(p[:n])
+	}
+	return dw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (dw *debugResponseWriter) Flush() {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	dw.responseWriter.Flush()
+}
+
+func (dw *debugResponseWriter) status() int {
+	if !dw.wroteHeader {
+		return http.StatusOK
+	}
+	return dw.code
+}
+
+// isDebugResponse returns true if debug mode has been enabled for
+// the request or the response writer.
+func isDebugResponse(w http.ResponseWriter, r *http.Request) bool {
+	if r != nil && IsDebug(r.Context()) {
+		return true
+	}
+	for {
+		switch x := w.(type) {
+		case *debugResponseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// debugErrorInfo returns verbose information about err for
+// the "debug" field of JSON errors.
+func debugErrorInfo(err interface{}) map[string]interface{} {
+	info := map[string]interface{}{
+		"type":  fmt.Sprintf("%T", err),
+		"stack": strings.Split(strings.TrimSpace(string(debug.Stack())), "\n"),
+	}
+	if e, ok := err.(error); ok {
+		var chain []string
+		for e = errors.Unwrap(e); e != nil; e = errors.Unwrap(e) {
+			chain = append(chain, fmt.Sprintf("%T: %v", e, e))
+		}
+		if len(chain) > 0 {
+			info["causes"] = chain
+		}
+	}
+	return info
 }
@@ -8,9 +8,13 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func chunk(s string) string {
@@ -42,7 +46,7 @@ func TestDumpRequestOut(t *testing.T) {
 		"Host: www.alt-f4.de\r\n" +
 		"User-Agent: Go-http-client/1.1\r\n" +
 		"Transfer-Encoding: chunked\r\n" +
-		"Authorization: Bearer secret\r\n" +
+		"Authorization: [REDACTED]\r\n" +
 		"Accept-Encoding: gzip\r\n" +
 		"\r\n" +
 		chunk(string(body)) +
@@ -51,3 +55,88 @@ func TestDumpRequestOut(t *testing.T) {
 		t.Fatalf("have:\n%q\nwant:\n%q", have, want)
 	}
 }
+
+func TestDebugToken(t *testing.T) {
+	secret := []byte("secret")
+	tests := []struct {
+		Token string
+		Valid bool
+	}{
+		{Token: NewDebugToken(secret, time.Minute), Valid: true},
+		{Token: NewDebugToken(secret, -time.Minute), Valid: false},
+		{Token: NewDebugToken([]byte("other"), time.Minute), Valid: false},
+		{Token: "", Valid: false},
+		{Token: "9999999999.invalid", Valid: false},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Valid, VerifyDebugToken(tt.Token, secret); want != have {
+			t.Errorf("#%d: want %v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestDebugMode(t *testing.T) {
+	secret := []byte("secret")
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	h := SlogMiddleware(logger)(DebugMode(DebugConfig{Secret: secret})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if want, have := `{"name":"Oliver"}`, string(body); want != have {
+			t.Errorf("want body %q, have %q", want, have)
+		}
+		WriteJSONError(w, fmt.Errorf("loading user: %w", ServerError("connection refused")))
+	})))
+
+	tests := []struct {
+		Header string
+		Query  string
+		Debug  bool
+	}{
+		{Debug: false},
+		{Header: NewDebugToken(secret, time.Minute), Debug: true},
+		{Query: "?_debug=" + url.QueryEscape(NewDebugToken(secret, time.Minute)), Debug: true},
+		{Header: NewDebugToken(secret, -time.Minute), Debug: false},
+	}
+	for i, tt := range tests {
+		logs.Reset()
+		req := httptest.NewRequest("POST", "/users"+tt.Query, strings.NewReader(`{"name":"Oliver"}`))
+		req.Header.Set("Authorization", "Bearer top-secret")
+		if tt.Header != "" {
+			req.Header.Set(DefaultDebugHeader, tt.Header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if want, have := tt.Debug, strings.HasPrefix(w.Header().Get("Server-Timing"), "total;dur="); want != have {
+			t.Errorf("#%d: want Server-Timing %v, have %q", i, want, w.Header().Get("Server-Timing"))
+		}
+		env := ErrorEnvelopeOf(t, w)
+		debug, _ := env.Error.Extensions["debug"].(map[string]interface{})
+		if want, have := tt.Debug, debug != nil; want != have {
+			t.Fatalf("#%d: want debug info %v, have %v", i, want, env.Error.Extensions)
+		}
+		if !tt.Debug {
+			if strings.Contains(logs.String(), "Debug request") {
+				t.Errorf("#%d: want no dump, have %s", i, logs.String())
+			}
+			continue
+		}
+		if want, have := "*fmt.wrapError", debug["type"]; want != have {
+			t.Errorf("#%d: want type %q, have %v", i, want, have)
+		}
+		if causes, _ := debug["causes"].([]interface{}); len(causes) != 1 {
+			t.Errorf("#%d: want 1 cause, have %v", i, debug["causes"])
+		}
+		if _, ok := debug["stack"].([]interface{}); !ok {
+			t.Errorf("#%d: want stack, have %v", i, debug["stack"])
+		}
+		dump := logs.String()
+		if !strings.Contains(dump, "Debug request") || !strings.Contains(dump, `{\"name\":\"Oliver\"}`) {
+			t.Errorf("#%d: want request dump, have %s", i, dump)
+		}
+		if strings.Contains(dump, "top-secret") {
+			t.Errorf("#%d: want credentials to be redacted, have %s", i, dump)
+		}
+	}
+}
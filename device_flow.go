@@ -0,0 +1,450 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DeviceCodeGrantType is the grant_type of token requests in the
+	// device authorization grant.
+	DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// defaultDeviceInterval is the default of DeviceFlowConfig.Interval.
+	defaultDeviceInterval = 5 * time.Second
+	// defaultDeviceExpiresIn is the default of DeviceFlowConfig.ExpiresIn.
+	defaultDeviceExpiresIn = 10 * time.Minute
+	// userCodeAlphabet has no vowels, to avoid words, and no ambiguous
+	// characters, as recommended by RFC 8628 section 6.1.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// DeviceAuthorization is a pending device authorization request.
+type DeviceAuthorization struct {
+	DeviceCode string
+	UserCode   string
+	ClientID   string
+	Scope      string
+	ExpiresAt  time.Time
+	// Interval is the minimum time between two token requests.
+	Interval time.Duration
+	// LastPolledAt is the time of the last token request.
+	LastPolledAt time.Time
+	// Subject is the user that approved the request, if any.
+	Subject string
+	// Denied is true if the user denied the request.
+	Denied bool
+}
+
+// DeviceAuthorizationStore keeps device authorization requests.
+// Get and GetByUserCode return NotFoundError for unknown codes.
+type DeviceAuthorizationStore interface {
+	Create(ctx context.Context, a DeviceAuthorization) error
+	Get(ctx context.Context, deviceCode string) (DeviceAuthorization, error)
+	GetByUserCode(ctx context.Context, userCode string) (DeviceAuthorization, error)
+	Update(ctx context.Context, a DeviceAuthorization) error
+	Delete(ctx context.Context, deviceCode string) error
+}
+
+// DeviceFlowConfig configures NewDeviceFlow.
+type DeviceFlowConfig struct {
+	// Store keeps the requests. It defaults to a MemoryDeviceAuthorizationStore.
+	Store DeviceAuthorizationStore
+	// VerificationURI is the absolute URL of the page where users enter
+	// the user code.
+	VerificationURI string
+	// Interval is the minimum time between two token requests. It
+	// defaults to 5 seconds.
+	Interval time.Duration
+	// ExpiresIn is the lifetime of a request. It defaults to 10 minutes.
+	ExpiresIn time.Duration
+	// Subject returns the authenticated user of a request to the Verify
+	// handler, or an empty string if there is none.
+	Subject func(r *http.Request) string
+	// Secret signs the CSRF tokens of the Verify handler. It is required
+	// if Subject is set.
+	Secret []byte
+	// IssueToken issues the token for an approved request.
+	IssueToken func(ctx context.Context, a DeviceAuthorization) (*OAuth2Token, error)
+}
+
+// DeviceFlow implements the server side of the OAuth 2.0 Device
+// Authorization Grant (RFC 8628), for command line tools that can't
+// open a browser themselves. Create it with NewDeviceFlow.
+//
+// The CLI calls the Authorize handler and asks the user to enter the
+// user code at the verification URI. The user approves the request
+// on that page, which calls the Verify handler (or Approve). Meanwhile,
+// the CLI polls the Token handler until the token is issued.
+//
+// The Token handler returns errors in the format of RFC 6749 section 5.2,
+// e.g. {"error":"authorization_pending"}, so standard OAuth 2.0 clients
+// understand them. Clients that poll too fast get "slow_down" and a
+// Retry-After header. The other handlers return errors as ErrorEnvelope.
+type DeviceFlow struct {
+	cfg DeviceFlowConfig
+}
+
+// NewDeviceFlow returns a DeviceFlow for cfg. It panics if cfg has a
+// Subject, i.e. is used with the Verify handler, but no Secret.
+func NewDeviceFlow(cfg DeviceFlowConfig) *DeviceFlow {
+	if cfg.Subject != nil && len(cfg.Secret) == 0 {
+		panic(errors.New("httputil: DeviceFlowConfig.Secret is required with Subject"))
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryDeviceAuthorizationStore()
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultDeviceInterval
+	}
+	if cfg.ExpiresIn <= 0 {
+		cfg.ExpiresIn = defaultDeviceExpiresIn
+	}
+	return &DeviceFlow{cfg: cfg}
+}
+
+// Authorize is the device authorization endpoint. It expects a POST
+// form with client_id and an optional scope.
+func (f *DeviceFlow) Authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, r, InvalidMethodError{})
+		return
+	}
+	clientID := r.PostFormValue("client_id")
+	if clientID == "" {
+		writeJSONError(w, r, MissingParameterError("client_id"))
+		return
+	}
+	a := DeviceAuthorization{
+		DeviceCode: randomToken(),
+		UserCode:   newUserCode(),
+		ClientID:   clientID,
+		Scope:      r.PostFormValue("scope"),
+		ExpiresAt:  time.Now().Add(f.cfg.ExpiresIn),
+		Interval:   f.cfg.Interval,
+	}
+	if err := f.cfg.Store.Create(r.Context(), a); err != nil {
+		writeJSONError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, map[string]interface{}{
+		"device_code":               a.DeviceCode,
+		"user_code":                 a.UserCode,
+		"verification_uri":          f.cfg.VerificationURI,
+		"verification_uri_complete": appendQuery(f.cfg.VerificationURI, url.Values{"user_code": {a.UserCode}}),
+		"expires_in":                int(f.cfg.ExpiresIn / time.Second),
+		"interval":                  int(a.Interval / time.Second),
+	})
+}
+
+// Verify is the endpoint behind the verification page. GET returns the
+// client_id and scope of the request with the user_code parameter, so
+// the page can ask the user for consent, and a csrf_token. POST with
+// user_code, csrf_token, and an action of "approve" or "deny" completes
+// the request for the user returned by DeviceFlowConfig.Subject. The
+// csrf_token is bound to the user and the user code, so other sites
+// can't make the browser of a signed-in user approve a request.
+func (f *DeviceFlow) Verify(w http.ResponseWriter, r *http.Request) {
+	subject := ""
+	if f.cfg.Subject != nil {
+		subject = f.cfg.Subject(r)
+	}
+	if subject == "" {
+		writeJSONError(w, r, UnauthorizedError{})
+		return
+	}
+	userCode := r.FormValue("user_code")
+	if userCode == "" {
+		writeJSONError(w, r, MissingParameterError("user_code"))
+		return
+	}
+
+	switch r.Method {
+	case "GET", "HEAD":
+		a, err := f.pending(r.Context(), userCode)
+		if err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		WriteJSON(w, map[string]interface{}{
+			"client_id":  a.ClientID,
+			"scope":      a.Scope,
+			"csrf_token": f.csrfToken(subject, userCode),
+		})
+	case "POST":
+		token := r.PostFormValue("csrf_token")
+		if !hmac.Equal([]byte(token), []byte(f.csrfToken(subject, userCode))) {
+			writeJSONError(w, r, InvalidXSRFToken{})
+			return
+		}
+		var err error
+		switch action := r.PostFormValue("action"); action {
+		case "approve":
+			err = f.Approve(r.Context(), userCode, subject)
+		case "deny":
+			err = f.Deny(r.Context(), userCode)
+		default:
+			err = InvalidParameterError("action")
+		}
+		if err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeJSONError(w, r, InvalidMethodError{})
+	}
+}
+
+// csrfToken returns the CSRF token of the Verify handler for subject
+// and userCode.
+func (f *DeviceFlow) csrfToken(subject, userCode string) string {
+	mac := hmac.New(sha256.New, f.cfg.Secret)
+	mac.Write([]byte(subject))
+	mac.Write([]byte{0})
+	mac.Write([]byte(normalizeUserCode(userCode)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Approve approves the request with the given user code for subject.
+func (f *DeviceFlow) Approve(ctx context.Context, userCode, subject string) error {
+	a, err := f.pending(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	a.Subject = subject
+	return f.cfg.Store.Update(ctx, a)
+}
+
+// Deny denies the request with the given user code.
+func (f *DeviceFlow) Deny(ctx context.Context, userCode string) error {
+	a, err := f.pending(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	a.Denied = true
+	return f.cfg.Store.Update(ctx, a)
+}
+
+// pending returns the request with the given user code that has been
+// neither approved nor denied.
+func (f *DeviceFlow) pending(ctx context.Context, userCode string) (DeviceAuthorization, error) {
+	a, err := f.cfg.Store.GetByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		return a, err
+	}
+	if a.Subject != "" || a.Denied || time.Now().After(a.ExpiresAt) {
+		return a, NotFoundError{}
+	}
+	return a, nil
+}
+
+// Token is the token endpoint for the device_code grant type. It expects
+// a POST form with grant_type, device_code, and client_id.
+func (f *DeviceFlow) Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, r, InvalidMethodError{})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if r.PostFormValue("grant_type") != DeviceCodeGrantType {
+		writeTokenError(w, r, "unsupported_grant_type", "Unsupported grant type")
+		return
+	}
+	ctx := r.Context()
+	a, err := f.cfg.Store.Get(ctx, r.PostFormValue("device_code"))
+	if _, notFound := err.(NotFoundError); notFound || (err == nil && a.ClientID != r.PostFormValue("client_id")) {
+		writeTokenError(w, r, "invalid_grant", "Invalid device code")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case now.After(a.ExpiresAt):
+		f.cfg.Store.Delete(ctx, a.DeviceCode)
+		writeTokenError(w, r, "expired_token", "Device code expired")
+		return
+	case a.Denied:
+		f.cfg.Store.Delete(ctx, a.DeviceCode)
+		writeTokenError(w, r, "access_denied", "Access denied")
+		return
+	case now.Sub(a.LastPolledAt) < a.Interval:
+		// Polling too fast increases the interval by 5 seconds (RFC 8628 section 3.5)
+		a.Interval += 5 * time.Second
+		a.LastPolledAt = now
+		if err := f.cfg.Store.Update(ctx, a); err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(a.Interval/time.Second)))
+		writeTokenError(w, r, "slow_down", "Polling too fast")
+		return
+	case a.Subject == "":
+		a.LastPolledAt = now
+		if err := f.cfg.Store.Update(ctx, a); err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+		writeTokenError(w, r, "authorization_pending", "Authorization pending")
+		return
+	}
+
+	if f.cfg.IssueToken == nil {
+		writeJSONError(w, r, NotImplementedError{})
+		return
+	}
+	token, err := f.cfg.IssueToken(ctx, a)
+	if err != nil {
+		writeJSONError(w, r, err)
+		return
+	}
+	// Device codes are used only once
+	f.cfg.Store.Delete(ctx, a.DeviceCode)
+	WriteJSON(w, token)
+}
+
+// writeTokenError writes a 400 Bad Request error of the Token handler in
+// the format of RFC 6749 section 5.2, with the RFC 8628 error code, e.g.
+// {"error":"authorization_pending","error_description":"..."}.
+func writeTokenError(w http.ResponseWriter, r *http.Request, code, description string) {
+	err := ErrorBody{Code: http.StatusBadRequest, Message: description, Reason: code}
+	notifyErrorWritten(r, err.Code, err)
+	logErrorWritten(w, r, err.Code, description)
+	writeJSONCode(w, r, err.Code, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// newUserCode returns a random user code like "WDJB-MJHT".
+func newUserCode() string {
+	// Bytes above the largest multiple of the alphabet size are
+	// rejected, so all characters are equally likely
+	max := 256 - 256%len(userCodeAlphabet)
+	code := make([]byte, 0, 9)
+	var b [16]byte
+	for len(code) < 9 {
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(errors.New("httputil: unable to read random bytes"))
+		}
+		for _, c := range b {
+			if int(c) >= max || len(code) == 9 {
+				continue
+			}
+			if len(code) == 4 {
+				code = append(code, '-')
+			}
+			code = append(code, userCodeAlphabet[int(c)%len(userCodeAlphabet)])
+		}
+	}
+	return string(code)
+}
+
+// normalizeUserCode returns userCode in the form of newUserCode, so users
+// can enter it in lower case and without dash.
+func normalizeUserCode(userCode string) string {
+	s := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+	if len(s) != 8 {
+		return s
+	}
+	return s[:4] + "-" + s[4:]
+}
+
+// MemoryDeviceAuthorizationStore is a DeviceAuthorizationStore in memory.
+// Expired requests are removed when new ones are created. It is safe for
+// concurrent use.
+type MemoryDeviceAuthorizationStore struct {
+	mu       sync.Mutex
+	requests map[string]DeviceAuthorization // by device code
+}
+
+// NewMemoryDeviceAuthorizationStore returns an empty store.
+func NewMemoryDeviceAuthorizationStore() *MemoryDeviceAuthorizationStore {
+	return &MemoryDeviceAuthorizationStore{requests: make(map[string]DeviceAuthorization)}
+}
+
+// Create adds a.
+func (s *MemoryDeviceAuthorizationStore) Create(ctx context.Context, a DeviceAuthorization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for code, other := range s.requests {
+		if now.After(other.ExpiresAt) {
+			delete(s.requests, code)
+		} else if other.UserCode == a.UserCode {
+			return ConflictError{}
+		}
+	}
+	s.requests[a.DeviceCode] = a
+	return nil
+}
+
+// Get returns the request with the given device code.
+func (s *MemoryDeviceAuthorizationStore) Get(ctx context.Context, deviceCode string) (DeviceAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, found := s.requests[deviceCode]
+	if !found {
+		return a, NotFoundError{}
+	}
+	return a, nil
+}
+
+// GetByUserCode returns the request with the given user code.
+func (s *MemoryDeviceAuthorizationStore) GetByUserCode(ctx context.Context, userCode string) (DeviceAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.requests {
+		if a.UserCode == userCode {
+			return a, nil
+		}
+	}
+	return DeviceAuthorization{}, NotFoundError{}
+}
+
+// Update replaces the request with the device code of a.
+func (s *MemoryDeviceAuthorizationStore) Update(ctx context.Context, a DeviceAuthorization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.requests[a.DeviceCode]; !found {
+		return NotFoundError{}
+	}
+	s.requests[a.DeviceCode] = a
+	return nil
+}
+
+// Delete removes the request with the given device code.
+func (s *MemoryDeviceAuthorizationStore) Delete(ctx context.Context, deviceCode string) error {
+	s.mu.Lock()
+	delete(s.requests, deviceCode)
+	s.mu.Unlock()
+	return nil
+}
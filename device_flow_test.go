@@ -0,0 +1,183 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	flow := NewDeviceFlow(DeviceFlowConfig{
+		VerificationURI: "https://example.com/device",
+		Interval:        20 * time.Millisecond,
+		Secret:          []byte("secret"),
+		Subject: func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+		IssueToken: func(ctx context.Context, a DeviceAuthorization) (*OAuth2Token, error) {
+			return &OAuth2Token{AccessToken: "token-for-" + a.Subject, TokenType: "Bearer"}, nil
+		},
+	})
+	post := func(h http.HandlerFunc, user string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+	poll := func(deviceCode string) *httptest.ResponseRecorder {
+		return post(flow.Token, "", url.Values{"grant_type": {DeviceCodeGrantType}, "device_code": {deviceCode}, "client_id": {"cli"}})
+	}
+
+	// The CLI starts the flow
+	w := post(flow.Authorize, "", url.Values{"client_id": {"cli"}, "scope": {"orders:read"}})
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	auth := DecodeAs[struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+	}](t, w)
+	if len(auth.UserCode) != 9 || auth.DeviceCode == "" {
+		t.Fatalf("want device and user code, have %+v", auth)
+	}
+	if want, have := "https://example.com/device?user_code="+auth.UserCode, auth.VerificationURIComplete; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// Polling before approval
+	w = poll(auth.DeviceCode)
+	if want, have := "authorization_pending", tokenErrorOf(t, w); want != have {
+		t.Errorf("want error %q, have %q", want, have)
+	}
+
+	// The user looks up and approves the request, entering the code sloppily
+	sloppy := strings.ToLower(strings.Replace(auth.UserCode, "-", "", 1))
+	req := httptest.NewRequest("GET", "/device?user_code="+sloppy, nil)
+	req.Header.Set("X-User", "alice")
+	w = httptest.NewRecorder()
+	flow.Verify(w, req)
+	consent := DecodeAs[struct {
+		ClientID  string `json:"client_id"`
+		Scope     string `json:"scope"`
+		CSRFToken string `json:"csrf_token"`
+	}](t, w)
+	if consent.ClientID != "cli" || consent.Scope != "orders:read" || consent.CSRFToken == "" {
+		t.Errorf("want client_id, scope, and csrf_token, have %+v", consent)
+	}
+	w = post(flow.Verify, "", url.Values{"user_code": {sloppy}, "action": {"approve"}, "csrf_token": {consent.CSRFToken}})
+	if want, have := http.StatusUnauthorized, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	for _, token := range []string{"", "forged", flow.csrfToken("mallory", sloppy)} {
+		w = post(flow.Verify, "alice", url.Values{"user_code": {sloppy}, "action": {"approve"}, "csrf_token": {token}})
+		if want, have := http.StatusBadRequest, w.Code; want != have {
+			t.Errorf("want status %d for CSRF token %q, have %d", want, token, have)
+		}
+	}
+	w = post(flow.Verify, "alice", url.Values{"user_code": {sloppy}, "action": {"approve"}, "csrf_token": {consent.CSRFToken}})
+	if want, have := http.StatusNoContent, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body.String())
+	}
+
+	// The CLI gets the token, once
+	time.Sleep(30 * time.Millisecond)
+	w = poll(auth.DeviceCode)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body.String())
+	}
+	if want, have := "token-for-alice", DecodeAs[OAuth2Token](t, w).AccessToken; want != have {
+		t.Errorf("want access token %q, have %q", want, have)
+	}
+	w = poll(auth.DeviceCode)
+	if want, have := "invalid_grant", tokenErrorOf(t, w); want != have {
+		t.Errorf("want error %q, have %q", want, have)
+	}
+}
+
+// tokenErrorOf returns the RFC 6749 error code written by DeviceFlow.Token.
+func tokenErrorOf(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code == http.StatusOK {
+		return ""
+	}
+	return DecodeAs[struct {
+		Error string `json:"error"`
+	}](t, w).Error
+}
+
+func TestDeviceFlowSlowDown(t *testing.T) {
+	flow := NewDeviceFlow(DeviceFlowConfig{VerificationURI: "https://example.com/device"})
+	post := func(h http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+	deviceCode := DecodeAs[struct {
+		DeviceCode string `json:"device_code"`
+	}](t, post(flow.Authorize, url.Values{"client_id": {"cli"}})).DeviceCode
+
+	tests := []struct {
+		ClientID   string
+		Code       int
+		Reason     string
+		RetryAfter string
+	}{
+		{ClientID: "cli", Code: http.StatusBadRequest, Reason: "authorization_pending"},
+		{ClientID: "cli", Code: http.StatusBadRequest, Reason: "slow_down", RetryAfter: "10"},
+		{ClientID: "cli", Code: http.StatusBadRequest, Reason: "slow_down", RetryAfter: "15"},
+		{ClientID: "other", Code: http.StatusBadRequest, Reason: "invalid_grant"},
+	}
+	for i, tt := range tests {
+		w := post(flow.Token, url.Values{"grant_type": {DeviceCodeGrantType}, "device_code": {deviceCode}, "client_id": {tt.ClientID}})
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Reason, tokenErrorOf(t, w); want != have {
+			t.Errorf("#%d: want error %q, have %q", i, want, have)
+		}
+		if want, have := tt.RetryAfter, w.Header().Get("Retry-After"); want != have {
+			t.Errorf("#%d: want Retry-After %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestNewUserCode(t *testing.T) {
+	counts := make(map[rune]int)
+	for i := 0; i < 1000; i++ {
+		code := newUserCode()
+		if len(code) != 9 || code[4] != '-' {
+			t.Fatalf("want a code like WDJB-MJHT, have %q", code)
+		}
+		for _, c := range strings.Replace(code, "-", "", 1) {
+			if !strings.ContainsRune(userCodeAlphabet, c) {
+				t.Fatalf("want only characters of %q, have %q", userCodeAlphabet, code)
+			}
+			counts[c]++
+		}
+	}
+	if want, have := len(userCodeAlphabet), len(counts); want != have {
+		t.Errorf("want all %d characters to be used, have %d", want, have)
+	}
+}
+
+func TestNewDeviceFlowRequiresSecret(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic without Secret")
+		}
+	}()
+	NewDeviceFlow(DeviceFlowConfig{Subject: func(r *http.Request) string { return "" }})
+}
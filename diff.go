@@ -0,0 +1,197 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiffOptions configures DiffResponses.
+type DiffOptions struct {
+	// IgnoreHeaders are headers that are expected to differ. It defaults
+	// to Date, Content-Length, X-Request-Id, and Server-Timing.
+	IgnoreHeaders []string
+	// IgnoreFields are JSON paths that are expected to differ, e.g.
+	// "$.updated_at" or "$.items[0].id". A path also ignores all fields
+	// below it.
+	IgnoreFields []string
+	// MaxBodySize is the maximum number of body bytes compared.
+	// It defaults to 1 MB.
+	MaxBodySize int64
+}
+
+// ResponseDiff is a difference between two responses, see DiffResponses.
+type ResponseDiff struct {
+	// Field is "status", a header like "header Content-Type", "body"
+	// for non-JSON bodies, or a JSON path like "body $.items[0].name".
+	Field string `json:"field"`
+	// Kind is "added", "removed", or "changed".
+	Kind string `json:"kind"`
+	// A and B are the values of the first and second response.
+	A string `json:"a,omitempty"`
+	B string `json:"b,omitempty"`
+}
+
+// String returns the difference in text form.
+func (d ResponseDiff) String() string {
+	switch d.Kind {
+	case "added":
+		return fmt.Sprintf("%s added: %s", d.Field, d.B)
+	case "removed":
+		return fmt.Sprintf("%s removed: %s", d.Field, d.A)
+	}
+	return fmt.Sprintf("%s changed from %s to %s", d.Field, d.A, d.B)
+}
+
+// DiffResponses compares the responses a and b, e.g. of the old and new
+// implementation of a service receiving shadow traffic (see Mirror). It
+// compares status codes, headers, and bodies. JSON bodies are compared
+// structurally, so the order of object fields and white space don't
+// matter. The differences are sorted by field. The bodies of a and b
+// are restored.
+func DiffResponses(a, b *http.Response, opts DiffOptions) ([]ResponseDiff, error) {
+	if opts.IgnoreHeaders == nil {
+		opts.IgnoreHeaders = []string{"Date", "Content-Length", "X-Request-Id", "Server-Timing"}
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+
+	var diffs []ResponseDiff
+	if a.StatusCode != b.StatusCode {
+		diffs = append(diffs, ResponseDiff{Field: "status", Kind: "changed", A: strconv.Itoa(a.StatusCode), B: strconv.Itoa(b.StatusCode)})
+	}
+	diffs = appendHeaderDiffs(diffs, a.Header, b.Header, opts.IgnoreHeaders)
+
+	bodyA, err := peekBody(a, opts.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	bodyB, err := peekBody(b, opts.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	var va, vb interface{}
+	if json.Unmarshal(bodyA, &va) == nil && json.Unmarshal(bodyB, &vb) == nil {
+		diffs = appendJSONDiffs(diffs, "$", va, vb, opts.IgnoreFields)
+	} else if !bytes.Equal(bodyA, bodyB) {
+		diffs = append(diffs, ResponseDiff{Field: "body", Kind: "changed", A: string(bodyA), B: string(bodyB)})
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs, nil
+}
+
+// peekBody returns the beginning of the body of res and restores it.
+func peekBody(res *http.Response, max int64) ([]byte, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, max))
+	if err != nil {
+		return nil, err
+	}
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+	return body, nil
+}
+
+func appendHeaderDiffs(diffs []ResponseDiff, a, b http.Header, ignore []string) []ResponseDiff {
+	ignored := func(key string) bool {
+		for _, name := range ignore {
+			if strings.EqualFold(name, key) {
+				return true
+			}
+		}
+		return false
+	}
+	for key, values := range a {
+		if ignored(key) {
+			continue
+		}
+		va := strings.Join(values, ", ")
+		if other, found := b[key]; !found {
+			diffs = append(diffs, ResponseDiff{Field: "header " + key, Kind: "removed", A: va})
+		} else if vb := strings.Join(other, ", "); va != vb {
+			diffs = append(diffs, ResponseDiff{Field: "header " + key, Kind: "changed", A: va, B: vb})
+		}
+	}
+	for key, values := range b {
+		if _, found := a[key]; !found && !ignored(key) {
+			diffs = append(diffs, ResponseDiff{Field: "header " + key, Kind: "added", B: strings.Join(values, ", ")})
+		}
+	}
+	return diffs
+}
+
+// appendJSONDiffs compares the decoded JSON values a and b at path.
+func appendJSONDiffs(diffs []ResponseDiff, path string, a, b interface{}, ignore []string) []ResponseDiff {
+	if isIgnoredPath(path, ignore) {
+		return diffs
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			for key, va := range a {
+				if vb, found := b[key]; found {
+					diffs = appendJSONDiffs(diffs, path+"."+key, va, vb, ignore)
+				} else if !isIgnoredPath(path+"."+key, ignore) {
+					diffs = append(diffs, ResponseDiff{Field: "body " + path + "." + key, Kind: "removed", A: jsonString(va)})
+				}
+			}
+			for key, vb := range b {
+				if _, found := a[key]; !found && !isIgnoredPath(path+"."+key, ignore) {
+					diffs = append(diffs, ResponseDiff{Field: "body " + path + "." + key, Kind: "added", B: jsonString(vb)})
+				}
+			}
+			return diffs
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			for i := 0; i < len(a) || i < len(b); i++ {
+				elem := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(b):
+					diffs = append(diffs, ResponseDiff{Field: "body " + elem, Kind: "removed", A: jsonString(a[i])})
+				case i >= len(a):
+					diffs = append(diffs, ResponseDiff{Field: "body " + elem, Kind: "added", B: jsonString(b[i])})
+				default:
+					diffs = appendJSONDiffs(diffs, elem, a[i], b[i], ignore)
+				}
+			}
+			return diffs
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		diffs = append(diffs, ResponseDiff{Field: "body " + path, Kind: "changed", A: jsonString(a), B: jsonString(b)})
+	}
+	return diffs
+}
+
+func isIgnoredPath(path string, ignore []string) bool {
+	for _, p := range ignore {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonString returns v in compact JSON form.
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
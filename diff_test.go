@@ -0,0 +1,75 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiffResponses(t *testing.T) {
+	response := func(code int, header http.Header, body string) *http.Response {
+		w := httptest.NewRecorder()
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(code)
+		w.WriteString(body)
+		return w.Result()
+	}
+
+	tests := []struct {
+		A, B *http.Response
+		Opts DiffOptions
+		Want []string
+	}{
+		{
+			A:    response(200, http.Header{"Date": {"Mon"}}, `{"a":1,"b":[1,2]}`),
+			B:    response(200, http.Header{"Date": {"Tue"}}, "{\n  \"b\": [1, 2],\n  \"a\": 1\n}"),
+			Want: nil,
+		},
+		{
+			A:    response(200, http.Header{"Content-Type": {"application/json"}, "X-Old": {"1"}}, `{"id":1,"name":"a","items":[{"n":1},{"n":2}],"at":"1"}`),
+			B:    response(201, http.Header{"Content-Type": {"application/json; charset=utf-8"}}, `{"id":1,"name":"b","items":[{"n":1}],"at":"2","new":true}`),
+			Opts: DiffOptions{IgnoreFields: []string{"$.at"}},
+			Want: []string{
+				`body $.items[1] removed: {"n":2}`,
+				`body $.name changed from "a" to "b"`,
+				`body $.new added: true`,
+				`header Content-Type changed from application/json to application/json; charset=utf-8`,
+				`header X-Old removed: 1`,
+				`status changed from 200 to 201`,
+			},
+		},
+		{
+			A:    response(200, nil, "hello"),
+			B:    response(200, nil, "world"),
+			Want: []string{"body changed from hello to world"},
+		},
+	}
+	for i, tt := range tests {
+		diffs, err := DiffResponses(tt.A, tt.B, tt.Opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := make([]string, len(diffs))
+		for j, d := range diffs {
+			have[j] = d.String()
+		}
+		if want, have := strings.Join(tt.Want, "\n"), strings.Join(have, "\n"); want != have {
+			t.Errorf("#%d: want\n%s\nhave\n%s", i, want, have)
+		}
+	}
+
+	// Bodies are restored
+	a := response(200, nil, "hello")
+	DiffResponses(a, response(200, nil, "hello"), DiffOptions{})
+	if body, _ := ioutil.ReadAll(a.Body); string(body) != "hello" {
+		t.Errorf("want body to be restored, have %q", body)
+	}
+}
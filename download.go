@@ -0,0 +1,291 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DownloadOptions configures DownloadFile.
+type DownloadOptions struct {
+	// SHA256 is the expected hex-encoded SHA-256 checksum of the file.
+	// If empty, the checksum is not verified.
+	SHA256 string
+	// Resume continues a previous, incomplete download via a Range
+	// request instead of starting over. Incomplete downloads are kept
+	// in dst + ".part", and the ETag or Last-Modified header of the
+	// response in dst + ".part.validator". The Range request is sent
+	// with If-Range, so a file that has changed in the meantime is
+	// downloaded from the start. Downloads without a strong ETag or a
+	// Last-Modified header are not resumed.
+	Resume bool
+	// Retries is the number of times a failed download is retried.
+	// With Resume, retries continue where the failed attempt stopped.
+	// Responses with status 4xx other than 408 and 429 are not retried.
+	Retries int
+	// MaxSize is the maximum size of the file in bytes. Zero means no limit.
+	MaxSize int64
+	// Progress is called after each chunk written to disk, with the
+	// number of bytes written so far, and the total size or -1 if the
+	// size is unknown.
+	Progress func(written, total int64)
+}
+
+// ChecksumError indicates that a downloaded file has an unexpected checksum.
+type ChecksumError struct {
+	Want string
+	Have string
+}
+
+// Error returns the error in text form.
+func (e ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch: want %s, have %s", e.Want, e.Have)
+}
+
+// DownloadFile downloads url to the file dst. The body is streamed to
+// dst + ".part", which is renamed to dst once it is complete and its
+// checksum is verified, so dst never contains a partial or corrupt file.
+// If client is nil, http.DefaultClient is used.
+//
+// Example:
+//
+//	err := httputil.DownloadFile(ctx, client, "https://peer/artifacts/model.bin", "/data/model.bin", httputil.DownloadOptions{
+//	  SHA256:  "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+//	  Resume:  true,
+//	  Retries: 3,
+//	})
+func DownloadFile(ctx context.Context, client *http.Client, url, dst string, opts DownloadOptions) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	part := dst + ".part"
+	if !opts.Resume {
+		if err := removePart(part); err != nil {
+			return err
+		}
+	}
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if err = download(ctx, client, url, part, opts); err == nil {
+			break
+		}
+		if isPermanentDownloadError(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		if isPermanentDownloadError(err) || !opts.Resume {
+			removePart(part)
+		}
+		return err
+	}
+	if err := os.Rename(part, dst); err != nil {
+		return err
+	}
+	if err := os.Remove(part + ".validator"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removePart removes the incomplete download part and its validator.
+func removePart(part string) error {
+	for _, name := range []string{part, part + ".validator"} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// download continues downloading url to the file part, and verifies
+// the checksum once it is complete.
+func download(ctx context.Context, client *http.Client, url, part string, opts DownloadOptions) error {
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Hash the existing part, so the checksum covers the whole file
+	h := sha256.New()
+	offset, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	validator := ""
+	if offset > 0 {
+		if b, err := os.ReadFile(part + ".validator"); err == nil {
+			validator = string(b)
+		}
+	}
+	if validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer DrainAndClose(res)
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		// Range not supported, file changed, or no part yet: start over
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		offset = 0
+		h.Reset()
+		if err := writeValidator(part, res); err != nil {
+			return err
+		}
+	case http.StatusPartialContent:
+		if validator == "" || !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return fmt.Errorf("GET %s: unexpected Content-Range %q", url, res.Header.Get("Content-Range"))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if validator == "" {
+			return downloadStatusError{URL: url, Code: res.StatusCode}
+		}
+		// The part is complete already if it has the size of the file,
+		// and the file has not changed
+		size := strings.TrimPrefix(res.Header.Get("Content-Range"), "bytes */")
+		etag := res.Header.Get("ETag")
+		if size != strconv.FormatInt(offset, 10) || (etag != "" && strings.HasPrefix(validator, `"`) && etag != validator) {
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+			return fmt.Errorf("GET %s: part does not match the file, starting over", url)
+		}
+		return verifyChecksum(h, opts.SHA256)
+	default:
+		return downloadStatusError{URL: url, Code: res.StatusCode}
+	}
+
+	total := int64(-1)
+	if res.ContentLength >= 0 {
+		total = offset + res.ContentLength
+	}
+	if opts.MaxSize > 0 && total > opts.MaxSize {
+		return downloadSizeError(opts.MaxSize)
+	}
+
+	body := io.Reader(res.Body)
+	if opts.MaxSize > 0 {
+		body = io.LimitReader(body, opts.MaxSize-offset+1)
+	}
+	written := offset
+	buf := make([]byte, 32<<10)
+	for {
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				return err
+			}
+			h.Write(buf[:n])
+			written += int64(n)
+			if opts.MaxSize > 0 && written > opts.MaxSize {
+				return downloadSizeError(opts.MaxSize)
+			}
+			if opts.Progress != nil {
+				opts.Progress(written, total)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	if total >= 0 && written != total {
+		return fmt.Errorf("GET %s: got %d of %d bytes", url, written, total)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return verifyChecksum(h, opts.SHA256)
+}
+
+// writeValidator saves the strong ETag or the Last-Modified header of
+// res, to resume the download with If-Range. Without either, any
+// validator of an earlier response is removed, so the download is not
+// resumed.
+func writeValidator(part string, res *http.Response) error {
+	validator := res.Header.Get("ETag")
+	if !strings.HasPrefix(validator, `"`) {
+		validator = res.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(part + ".validator"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(part+".validator", []byte(validator), 0644)
+}
+
+// downloadStatusError indicates that a download failed with an
+// unexpected status code.
+type downloadStatusError struct {
+	URL  string
+	Code int
+}
+
+func (e downloadStatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %d", e.URL, e.Code)
+}
+
+// downloadSizeError indicates that a download exceeds DownloadOptions.MaxSize.
+type downloadSizeError int64
+
+func (e downloadSizeError) Error() string {
+	return fmt.Sprintf("download exceeds the maximum size of %d bytes", int64(e))
+}
+
+// isPermanentDownloadError returns true if retrying the download
+// won't help.
+func isPermanentDownloadError(err error) bool {
+	switch e := err.(type) {
+	case ChecksumError, downloadSizeError:
+		return true
+	case downloadStatusError:
+		// Client errors won't go away, except for timeouts and rate limits
+		return e.Code >= 400 && e.Code < 500 &&
+			e.Code != http.StatusRequestTimeout && e.Code != http.StatusTooManyRequests
+	}
+	return false
+}
+
+// verifyChecksum returns ChecksumError if the hex-encoded sum of h
+// doesn't match want. An empty want matches any sum.
+func verifyChecksum(h hash.Hash, want string) error {
+	if want == "" {
+		return nil
+	}
+	have := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(have, want) {
+		return ChecksumError{Want: want, Have: have}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/flaky" && n == 1 {
+			// Abort the first response halfway
+			w.Header().Set("Content-Length", "100000")
+			w.Write(content[:50000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Path     string
+		Opts     DownloadOptions
+		Requests int32
+		Err      bool
+	}{
+		{Path: "/file", Opts: DownloadOptions{SHA256: checksum}, Requests: 1},
+		{Path: "/flaky", Opts: DownloadOptions{SHA256: checksum, Resume: true, Retries: 1}, Requests: 2},
+		{Path: "/flaky", Opts: DownloadOptions{Resume: true}, Requests: 1, Err: true},
+		{Path: "/file", Opts: DownloadOptions{SHA256: strings.Repeat("0", 64)}, Requests: 1, Err: true},
+		{Path: "/file", Opts: DownloadOptions{MaxSize: 1000, Retries: 3}, Requests: 1, Err: true},
+	}
+	for i, tt := range tests {
+		requests.Store(0)
+		dst := filepath.Join(t.TempDir(), "file.bin")
+		var progress int64
+		tt.Opts.Progress = func(written, total int64) {
+			if total != int64(len(content)) {
+				t.Errorf("#%d: want total %d, have %d", i, len(content), total)
+			}
+			progress = written
+		}
+		err := DownloadFile(context.Background(), nil, srv.URL+tt.Path, dst, tt.Opts)
+		if want, have := tt.Requests, requests.Load(); want != have {
+			t.Errorf("#%d: want %d requests, have %d", i, want, have)
+		}
+		if tt.Err {
+			if err == nil {
+				t.Errorf("#%d: want error", i)
+			}
+			if _, err := os.Stat(dst); !os.IsNotExist(err) {
+				t.Errorf("#%d: want no file on error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		data, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Errorf("#%d: want content of %d bytes, have %d bytes", i, len(content), len(data))
+		}
+		if want, have := int64(len(content)), progress; want != have {
+			t.Errorf("#%d: want progress %d, have %d", i, want, have)
+		}
+		if _, err := os.Stat(dst + ".part"); !os.IsNotExist(err) {
+			t.Errorf("#%d: want part file to be removed", i)
+		}
+	}
+}
+
+func TestDownloadFileChecksumError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	err := DownloadFile(context.Background(), nil, srv.URL, filepath.Join(t.TempDir(), "x"), DownloadOptions{SHA256: "00"})
+	if _, ok := err.(ChecksumError); !ok {
+		t.Fatalf("want ChecksumError, have %v", err)
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Part      []byte
+		Validator string
+	}{
+		// Unchanged file: resumed
+		{Part: content[:4000], Validator: `"v2"`},
+		// Changed file: If-Range makes the server send all of it
+		{Part: []byte("stale"), Validator: `"v1"`},
+		// Complete part of an unchanged file: 416 with matching size
+		{Part: content, Validator: `"v2"`},
+		// Part of the same size of a changed file: 416 does not match
+		{Part: bytes.Repeat([]byte("x"), len(content)), Validator: `"v1"`},
+		// No validator: not resumed
+		{Part: []byte("stale")},
+	}
+	for i, tt := range tests {
+		dst := filepath.Join(t.TempDir(), "file.bin")
+		if err := os.WriteFile(dst+".part", tt.Part, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if tt.Validator != "" {
+			if err := os.WriteFile(dst+".part.validator", []byte(tt.Validator), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := DownloadFile(context.Background(), nil, srv.URL, dst, DownloadOptions{Resume: true, Retries: 1})
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		data, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, data) {
+			t.Errorf("#%d: want content of %d bytes, have %q", i, len(content), data[:min(len(data), 20)])
+		}
+		if _, err := os.Stat(dst + ".part.validator"); !os.IsNotExist(err) {
+			t.Errorf("#%d: want validator to be removed", i)
+		}
+	}
+}
+
+func TestDownloadFileRetries(t *testing.T) {
+	tests := []struct {
+		Status   int
+		Requests int32
+	}{
+		{http.StatusNotFound, 1},
+		{http.StatusForbidden, 1},
+		{http.StatusRequestTimeout, 3},
+		{http.StatusTooManyRequests, 3},
+		{http.StatusServiceUnavailable, 3},
+	}
+	for i, tt := range tests {
+		var requests atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(tt.Status)
+		}))
+		err := DownloadFile(context.Background(), nil, srv.URL, filepath.Join(t.TempDir(), "x"), DownloadOptions{Retries: 2})
+		srv.Close()
+		if err == nil {
+			t.Errorf("#%d: want error", i)
+		}
+		if want, have := tt.Requests, requests.Load(); want != have {
+			t.Errorf("#%d: want %d requests, have %d", i, want, have)
+		}
+	}
+}
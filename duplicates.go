@@ -0,0 +1,118 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+)
+
+// DuplicateParameterPolicy specifies how the Query* and Form* helpers
+// handle parameters that are passed more than once, e.g. "?id=1&id=2".
+type DuplicateParameterPolicy int32
+
+const (
+	// DuplicatesFirstWins uses the first value. This is the default.
+	DuplicatesFirstWins DuplicateParameterPolicy = iota
+	// DuplicatesLastWins uses the last value.
+	DuplicatesLastWins
+	// DuplicatesReject makes the Query* and Form* helpers panic with
+	// DuplicateParameterError, which RecoverJSON writes as 400 Bad
+	// Request. The helpers don't fall back to their default value.
+	DuplicatesReject
+)
+
+// SetDuplicateParameterPolicy sets the global DuplicateParameterPolicy.
+// Use WithDuplicateParameterPolicy to override it for some handlers only.
+func SetDuplicateParameterPolicy(p DuplicateParameterPolicy) {
+	updateConfig(func(cfg *Config) { cfg.DuplicateParameters = p })
+}
+
+// WithDuplicateParameterPolicy returns a middleware that makes the
+// Query* and Form* helpers use p instead of the policy of the Config
+// in effect for all requests passing through. It is a shortcut for
+// setting Config.DuplicateParameters with WithConfig.
+func WithDuplicateParameterPolicy(p DuplicateParameterPolicy) func(http.Handler) http.Handler {
+	return overrideConfig(func(cfg *Config) { cfg.DuplicateParameters = p })
+}
+
+// lookupQuery returns the value of the query string parameter key,
+// applying the DuplicateParameterPolicy. It returns DuplicateParameterError
+// if the policy rejects the parameter.
+func lookupQuery(r *http.Request, key string) (string, error) {
+	v, _, err := QueryRaw(r, key)
+	return v, err
+}
+
+// mustQueryValue is like lookupQuery, but panics on errors. It is used
+// by the Must* and the other Query* helpers alike, so rejected
+// duplicates never result in the default value.
+func mustQueryValue(r *http.Request, key string) string {
+	v, err := lookupQuery(r, key)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// lookupForm returns the value of the form parameter key, applying the
+// DuplicateParameterPolicy. Like http.Request.FormValue, it parses the
+// form if necessary and includes the query string parameters. It
+// returns FormLimitError if the form exceeds Config.FormLimits, and
+// DuplicateParameterError if the policy rejects the parameter.
+func lookupForm(r *http.Request, key string) (string, error) {
+	if r.Form == nil {
+		if err := ParseFormWithLimits(r, configFor(r).FormLimits); err != nil {
+			if e, ok := err.(FormLimitError); ok {
+				return "", e
+			}
+		}
+	}
+	return pickValue(r, key, r.Form[key])
+}
+
+// formValue is like lookupForm, but returns an empty string if the form
+// exceeds the limits, so the Form* helpers return their default value.
+// It panics with DuplicateParameterError if the policy rejects the
+// parameter.
+func formValue(r *http.Request, key string) string {
+	v, err := lookupForm(r, key)
+	if _, ok := err.(DuplicateParameterError); ok {
+		panic(err)
+	}
+	return v
+}
+
+// mustFormValue is like lookupForm, but panics on errors.
+func mustFormValue(r *http.Request, key string) string {
+	v, err := lookupForm(r, key)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func pickValue(r *http.Request, key string, values []string) (string, error) {
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		return values[0], nil
+	}
+	return pickDuplicate(r, key, values[0], values[len(values)-1])
+}
+
+// pickDuplicate returns the first or last value of a parameter that has
+// been passed more than once, or DuplicateParameterError, as per
+// DuplicateParameterPolicy.
+func pickDuplicate(r *http.Request, key, first, last string) (string, error) {
+	switch configFor(r).DuplicateParameters {
+	case DuplicatesLastWins:
+		return last, nil
+	case DuplicatesReject:
+		return "", DuplicateParameterError(key)
+	default:
+		return first, nil
+	}
+}
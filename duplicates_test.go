@@ -0,0 +1,86 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateParameterPolicy(t *testing.T) {
+	tests := []struct {
+		Policy DuplicateParameterPolicy
+		Code   int
+		Body   string
+	}{
+		{Policy: DuplicatesFirstWins, Code: http.StatusOK, Body: "1 a"},
+		{Policy: DuplicatesLastWins, Code: http.StatusOK, Body: "2 c"},
+		{Policy: DuplicatesReject, Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		h := WithDuplicateParameterPolicy(tt.Policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer RecoverJSON(w, r)
+			id := MustQueryInt(r, "id")
+			name := MustFormString(r, "name")
+			fmt.Fprintf(w, "%d %s", id, name)
+		}))
+
+		values := url.Values{"name": {"a", "b"}}
+		req := httptest.NewRequest("POST", "/?id=1&id=2&name=c", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		} else if !strings.Contains(w.Body.String(), `Duplicate parameter \"id\"`) {
+			t.Errorf("#%d: unexpected body %s", i, w.Body.String())
+		}
+	}
+}
+
+func TestDuplicatesRejectNonMust(t *testing.T) {
+	tests := []struct {
+		Handler func(r *http.Request) interface{}
+		Code    int
+		Param   string
+	}{
+		{Handler: func(r *http.Request) interface{} { return QueryInt(r, "limit", -1) }, Code: http.StatusOK},
+		{Handler: func(r *http.Request) interface{} { return QueryInt(r, "id", -1) }, Code: http.StatusBadRequest, Param: "id"},
+		{Handler: func(r *http.Request) interface{} { return QueryString(r, "id", "default") }, Code: http.StatusBadRequest, Param: "id"},
+		{Handler: func(r *http.Request) interface{} { return FormString(r, "name", "default") }, Code: http.StatusBadRequest, Param: "name"},
+		{Handler: func(r *http.Request) interface{} { _, err := ParseODataQuery(r); return err }, Code: http.StatusOK},
+	}
+	for i, tt := range tests {
+		var v interface{}
+		h := WithDuplicateParameterPolicy(DuplicatesReject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer RecoverJSON(w, r)
+			v = tt.Handler(r)
+		}))
+		req := httptest.NewRequest("GET", "/?id=1&id=2&limit=10&name=a&name=b&$top=1&$top=2", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Param != "" && !strings.Contains(w.Body.String(), `Duplicate parameter \"`+tt.Param+`\"`) {
+			t.Errorf("#%d: unexpected body %s", i, w.Body.String())
+		}
+		if i == len(tests)-1 {
+			if _, ok := v.(DuplicateParameterError); !ok {
+				t.Errorf("#%d: want DuplicateParameterError from ParseODataQuery, have %v", i, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// APIClient calls the JSON endpoints of a service written with this
+// package, see NewEndpoint.
+type APIClient struct {
+	// BaseURL is the URL that endpoint paths are relative to,
+	// e.g. "https://orders.internal/v1".
+	BaseURL string
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Header is added to each request, e.g. for authorization.
+	Header http.Header
+}
+
+// Endpoint is a typed client method of an APIClient, built at runtime
+// from its route, so internal services need no hand-written client SDKs.
+// In is the type of the parameters, and Out the type of the response.
+type Endpoint[In, Out any] struct {
+	client *APIClient
+	method string
+	path   string
+}
+
+// NewEndpoint returns the endpoint with the given method and route, e.g.
+// "/orders/{id}". If In is a struct, its fields with a "path" tag fill
+// the route parameters of the same name, fields with a "query" tag are
+// added to the query string unless they are zero, and the field with a
+// "body" tag is sent as JSON. Otherwise, In itself fills the only route
+// parameter.
+//
+// Responses with a 2xx status code are decoded into Out. Other responses
+// return an ErrorBody decoded from the ErrorEnvelope written by
+// WriteJSONError, so services can relay errors of their upstreams as is.
+// Transport errors are returned as is; see ClassifyTransportError.
+//
+// Example:
+//
+//	type ListOrders struct {
+//	  Customer string `path:"customer"`
+//	  Limit    int    `query:"limit"`
+//	}
+//
+//	api := &httputil.APIClient{BaseURL: "https://orders.internal/v1"}
+//	getOrder := httputil.NewEndpoint[string, Order](api, "GET", "/orders/{id}")
+//	listOrders := httputil.NewEndpoint[ListOrders, []Order](api, "GET", "/customers/{customer}/orders")
+//
+//	order, err := getOrder.Call(ctx, "42")
+//	orders, err := listOrders.Call(ctx, ListOrders{Customer: "7", Limit: 10})
+func NewEndpoint[In, Out any](c *APIClient, method, path string) *Endpoint[In, Out] {
+	return &Endpoint[In, Out]{client: c, method: method, path: path}
+}
+
+// Call calls the endpoint with the parameters in. It returns nil and no
+// error for responses without a body, e.g. 204 No Content.
+func (e *Endpoint[In, Out]) Call(ctx context.Context, in In) (*Out, error) {
+	req, err := e.newRequest(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	client := e.client.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer DrainAndClose(res)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, decodeErrorResponse(res)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	out := new(Out)
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("%s %s: invalid JSON response: %v", e.method, e.path, err)
+	}
+	return out, nil
+}
+
+// newRequest builds the request for the parameters in.
+func (e *Endpoint[In, Out]) newRequest(ctx context.Context, in In) (*http.Request, error) {
+	path := e.path
+	query := make(url.Values)
+	var body io.Reader
+
+	v := reflect.ValueOf(in)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			if name := f.Tag.Get("path"); name != "" {
+				path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(formatParam(fv)))
+			}
+			if name := f.Tag.Get("query"); name != "" && !fv.IsZero() {
+				if fv.Kind() == reflect.Slice {
+					for j := 0; j < fv.Len(); j++ {
+						query.Add(name, formatParam(fv.Index(j)))
+					}
+				} else {
+					query.Set(name, formatParam(fv))
+				}
+			}
+			if _, found := f.Tag.Lookup("body"); found {
+				data, err := json.Marshal(fv.Interface())
+				if err != nil {
+					return nil, err
+				}
+				body = bytes.NewReader(data)
+			}
+		}
+	} else if start := strings.IndexByte(path, '{'); start >= 0 {
+		if end := strings.IndexByte(path[start:], '}'); end >= 0 {
+			path = path[:start] + url.PathEscape(formatParam(v)) + path[start+end+1:]
+		}
+	}
+	if strings.Contains(path, "{") {
+		return nil, fmt.Errorf("%s %s: missing route parameters", e.method, path)
+	}
+
+	rawURL := strings.TrimSuffix(e.client.BaseURL, "/") + path
+	if len(query) > 0 {
+		rawURL = appendQuery(rawURL, query)
+	}
+	req, err := http.NewRequestWithContext(ctx, e.method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range e.client.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// formatParam returns v in text form for a route or query parameter.
+func formatParam(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// decodeErrorResponse returns the ErrorBody of an error response, or an
+// ErrorBody with the status code and text if it has none.
+func decodeErrorResponse(res *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	var env ErrorEnvelope
+	if err := json.Unmarshal(data, &env); err == nil && env.Error.Code != 0 {
+		return env.Error
+	}
+	return ErrorBody{Code: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpoint(t *testing.T) {
+	type Order struct {
+		ID       string `json:"id"`
+		Customer string `json:"customer,omitempty"`
+		Note     string `json:"note,omitempty"`
+	}
+	type ListOrders struct {
+		Customer string   `path:"customer"`
+		Limit    int      `query:"limit"`
+		Status   []string `query:"status"`
+	}
+	type CreateOrder struct {
+		Customer string `path:"customer"`
+		Order    Order  `body:""`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, have := "secret", r.Header.Get("X-Api-Key"); want != have {
+			WriteJSONError(w, UnauthorizedError{})
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/orders/a b":
+			WriteJSON(w, Order{ID: "a b"})
+		case r.Method == "GET" && r.URL.Path == "/v1/orders/404":
+			WriteJSONError(w, NotFoundError{})
+		case r.Method == "GET" && r.URL.Path == "/v1/customers/7/orders":
+			WriteJSON(w, []Order{{ID: r.URL.RawQuery}})
+		case r.Method == "POST" && r.URL.Path == "/v1/customers/7/orders":
+			var o Order
+			json.NewDecoder(r.Body).Decode(&o)
+			o.ID, o.Customer = "1", "7"
+			WriteJSONCode(w, http.StatusCreated, o)
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	api := &APIClient{BaseURL: srv.URL + "/v1/", Header: http.Header{"X-Api-Key": {"secret"}}}
+	ctx := context.Background()
+
+	getOrder := NewEndpoint[string, Order](api, "GET", "/orders/{id}")
+	order, err := getOrder.Call(ctx, "a b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "a b", order.ID; want != have {
+		t.Errorf("want id %q, have %q", want, have)
+	}
+
+	_, err = getOrder.Call(ctx, "404")
+	if e, ok := err.(ErrorBody); !ok || e.Code != http.StatusNotFound || e.Message != "Record not found" {
+		t.Errorf("want ErrorBody with status 404, have %#v", err)
+	}
+
+	listOrders := NewEndpoint[ListOrders, []Order](api, "GET", "/customers/{customer}/orders")
+	orders, err := listOrders.Call(ctx, ListOrders{Customer: "7", Limit: 10, Status: []string{"open", "paid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*orders) != 1 || (*orders)[0].ID != "limit=10&status=open&status=paid" {
+		t.Errorf("want query string in response, have %+v", *orders)
+	}
+
+	createOrder := NewEndpoint[CreateOrder, Order](api, "POST", "/customers/{customer}/orders")
+	order, err = createOrder.Call(ctx, CreateOrder{Customer: "7", Order: Order{Note: "asap"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := (Order{ID: "1", Customer: "7", Note: "asap"}), *order; want != have {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+
+	deleteOrder := NewEndpoint[string, struct{}](api, "DELETE", "/orders/{id}")
+	if out, err := deleteOrder.Call(ctx, "1"); err != nil || out != nil {
+		t.Errorf("want no response and no error, have %v and %v", out, err)
+	}
+
+	unauthorized := NewEndpoint[string, Order](&APIClient{BaseURL: srv.URL + "/v1"}, "GET", "/orders/{id}")
+	if _, err := unauthorized.Call(ctx, "1"); err == nil || err.(ErrorBody).Code != http.StatusUnauthorized {
+		t.Errorf("want status 401, have %v", err)
+	}
+}
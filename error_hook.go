@@ -0,0 +1,61 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// OnErrorWritten registers fn to be called by all error writers of this
+// package, e.g. WriteJSONError, RecoverJSON, or WriteProtoError, right
+// before the error is written. Use it to increment metrics, sample logs,
+// or classify errors in one place. Use RouteTemplate to group errors by
+// route instead of by URL.
+//
+// The request r is nil for writers that don't have access to it, i.e.
+// WriteError, WriteJSONError, BadRequestError, ForbiddenError, and the
+// writers like WriteJSONCode, WriteProto, or WriteHAL when serialization
+// fails. Handlers should use ServeError and ServeJSONError, which pass
+// the request through.
+// Panics with values other than errors are passed as ServerError.
+// Passing nil removes the callback.
+func OnErrorWritten(fn func(r *http.Request, code int, err error)) {
+	updateConfig(func(cfg *Config) { cfg.OnErrorWritten = fn })
+}
+
+// notifyErrorWritten calls the callback registered with OnErrorWritten,
+// or set by WithConfig.
+func notifyErrorWritten(r *http.Request, code int, err interface{}) {
+	fn := configFor(r).OnErrorWritten
+	if fn == nil {
+		return
+	}
+	e, ok := err.(error)
+	if !ok {
+		e = ServerError(fmt.Sprint(err))
+	}
+	fn(r, code, e)
+}
+
+// RouteTemplate returns the path template of the gorilla/mux route
+// matched by r, e.g. "/users/{id}". It returns an empty string if r is
+// nil or has not been routed by gorilla/mux.
+func RouteTemplate(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return tpl
+}
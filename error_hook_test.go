@@ -0,0 +1,82 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOnErrorWritten(t *testing.T) {
+	type event struct {
+		Route string
+		Code  int
+		Err   error
+	}
+	var events []event
+	OnErrorWritten(func(r *http.Request, code int, err error) {
+		events = append(events, event{Route: RouteTemplate(r), Code: code, Err: err})
+	})
+	defer OnErrorWritten(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		panic(NotFoundError{})
+	})
+	router.HandleFunc("/kaboom", func(w http.ResponseWriter, r *http.Request) {
+		defer Recover(w, r)
+		panic("kaboom")
+	})
+	router.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		ServeJSONError(w, r, GoneError{})
+	})
+	router.HandleFunc("/pages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		ServeError(w, r, AccessDeniedError{})
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/kaboom", nil))
+	WriteJSONError(httptest.NewRecorder(), InvalidParameterError("id"))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/42", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/pages/42", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if want, have := 5, len(events); want != have {
+		t.Fatalf("want %d events, have %d", want, have)
+	}
+	tests := []event{
+		{Route: "/users/{id}", Code: http.StatusNotFound, Err: NotFoundError{}},
+		{Route: "/kaboom", Code: http.StatusInternalServerError, Err: ServerError("kaboom")},
+		{Route: "", Code: http.StatusBadRequest, Err: InvalidParameterError("id")},
+		{Route: "/orders/{id}", Code: http.StatusGone, Err: GoneError{}},
+		{Route: "/pages/{id}", Code: http.StatusForbidden, Err: AccessDeniedError{}},
+	}
+	for i, want := range tests {
+		have := events[i]
+		if want.Route != have.Route {
+			t.Errorf("#%d: want route %q, have %q", i, want.Route, have.Route)
+		}
+		if want.Code != have.Code {
+			t.Errorf("#%d: want code %d, have %d", i, want.Code, have.Code)
+		}
+		if !errors.Is(have.Err, want.Err) {
+			t.Errorf("#%d: want error %v, have %v", i, want.Err, have.Err)
+		}
+	}
+}
+
+func TestRouteTemplateWithoutRouter(t *testing.T) {
+	if want, have := "", RouteTemplate(nil); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "", RouteTemplate(httptest.NewRequest("GET", "/", nil)); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
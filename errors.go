@@ -5,38 +5,72 @@
 package httputil
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 // BadRequestError returns HTTP status 400 and an error message as HTML.
+// The callback registered with OnErrorWritten gets no request; use
+// ServeError with an HTTPError instead if it needs one.
 func BadRequestError(w http.ResponseWriter, errorMessage string, args ...interface{}) {
+	badRequestError(w, nil, errorMessage, args...)
+}
+
+func badRequestError(w http.ResponseWriter, r *http.Request, errorMessage string, args ...interface{}) {
+	notifyErrorWritten(r, http.StatusBadRequest, fmt.Errorf(errorMessage, args...))
 	w.WriteHeader(http.StatusBadRequest)
 	fmt.Fprintf(w, "<h1>Bad request</h1>")
 }
 
 // ForbiddenError returns HTTP status 403 and an error message as HTML.
+// The callback registered with OnErrorWritten gets no request; use
+// ServeError with AccessDeniedError instead if it needs one.
 func ForbiddenError(w http.ResponseWriter, errorMessage string, args ...interface{}) {
+	notifyErrorWritten(nil, http.StatusForbidden, fmt.Errorf(errorMessage, args...))
 	w.WriteHeader(http.StatusForbidden)
 	fmt.Fprintf(w, "<h1>Forbidden</h1>")
 }
 
 // InternalServerError returns HTTP status 500 and an error message as HTML.
 func InternalServerError(w http.ResponseWriter, r *http.Request, err interface{}) {
+	notifyErrorWritten(r, http.StatusInternalServerError, err)
 	w.WriteHeader(http.StatusInternalServerError)
 	fmt.Fprintf(w, "<h1>Server error</h1>")
 }
 
 // WriteError writes an error message for display in a HTML page.
+// It uses the global Config; use ServeError for the Config set by
+// WithConfig to take effect.
 func WriteError(w http.ResponseWriter, err interface{}) {
+	writeError(w, nil, err)
+}
+
+// ServeError is like WriteError, but uses the Config in effect for r,
+// and passes r to the callback registered with OnErrorWritten.
+func ServeError(w http.ResponseWriter, r *http.Request, err interface{}) {
+	writeError(w, r, err)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err interface{}) {
 	code := 500
 	if i, ok := err.(httpCoder); ok {
 		code = i.HTTPCode()
 	}
 	msg := fmt.Sprint(err)
+	notifyErrorWritten(r, code, err)
+	logErrorWritten(w, r, code, msg)
+	discardBufferedOutput(w)
+	writeErrorHeaders(w, err)
+	if configFor(r).ErrorVerbosity.hides(code) {
+		msg = http.StatusText(code)
+	}
 	w.WriteHeader(code)
 	fmt.Fprintf(w, "<h1>%s</h1>", msg)
 }
@@ -55,28 +89,254 @@ func WriteError(w http.ResponseWriter, err interface{}) {
 // If err implements the httpCoder interface, it can specify the HTTP code
 // to return. If err implements the httpErrorDetails interface, its
 // ErrorDetails func is used to collect the error details; otherwise,
-// the "details" field is missing in the error returned.
+// the "details" field is missing in the error returned. If err implements
+// the httpErrorReason interface, a machine-readable "reason" field is added.
+// Use SetErrorEncoder to write errors in a different format.
+//
+// WriteJSONError uses the global Config. Use ServeJSONError for the
+// Config set by WithConfig to take effect.
 func WriteJSONError(w http.ResponseWriter, err interface{}) {
-	code := 500
+	writeJSONError(w, nil, err)
+}
+
+// ServeJSONError is like WriteJSONError, but uses the Config in effect
+// for r, and passes r to the ErrorEncoder and to the callback registered
+// with OnErrorWritten. Handlers should prefer it over WriteJSONError.
+func ServeJSONError(w http.ResponseWriter, r *http.Request, err interface{}) {
+	writeJSONError(w, r, err)
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, err interface{}) {
+	cfg := configFor(r)
+	body := NewErrorBody(err)
+	msg := body.Message
+	body = cfg.ErrorVerbosity.apply(body)
+	if isDebugResponse(w, r) {
+		ext := make(map[string]interface{}, len(body.Extensions)+1)
+		for k, v := range body.Extensions {
+			ext[k] = v
+		}
+		ext["debug"] = debugErrorInfo(err)
+		body.Extensions = ext
+	}
+	notifyErrorWritten(r, body.Code, err)
+	logErrorWritten(w, r, body.Code, msg)
+	discardBufferedOutput(w)
+	writeErrorHeaders(w, err)
+	if encode := cfg.ErrorEncoder; encode != nil {
+		encode(w, r, body.Code, body.Message, body.Details)
+		return
+	}
+	writeJSONCode(w, r, body.Code, ErrorEnvelope{Error: body})
+}
+
+// ErrorVerbosity controls how much of an error is written to the client.
+// See Config.ErrorVerbosity.
+type ErrorVerbosity int
+
+const (
+	// ErrorsVerbose writes the messages and details of all errors. This
+	// is the default.
+	ErrorsVerbose ErrorVerbosity = iota
+	// ErrorsHideInternal writes only the status text, e.g. "Internal
+	// Server Error", for errors with a 5xx status code, so internals
+	// like database errors don't leak to clients.
+	ErrorsHideInternal
+	// ErrorsTerse writes only the status text for all errors.
+	ErrorsTerse
+)
+
+// hides returns true if the message and details of an error with the
+// given HTTP status code must not be written.
+func (v ErrorVerbosity) hides(code int) bool {
+	switch v {
+	case ErrorsHideInternal:
+		return code >= 500
+	case ErrorsTerse:
+		return true
+	}
+	return false
+}
+
+// apply returns body with the message replaced by the status text and
+// without details, if v hides them for the status code of body.
+func (v ErrorVerbosity) apply(body ErrorBody) ErrorBody {
+	if v.hides(body.Code) {
+		body.Message = http.StatusText(body.Code)
+		body.Details = nil
+	}
+	return body
+}
+
+// ErrorEncoder writes an error with the HTTP status code, message, and
+// details into w. The request r is nil when called via WriteJSONError,
+// WriteGone without request, or other writers without access to it.
+type ErrorEncoder func(w http.ResponseWriter, r *http.Request, code int, msg string, details []string)
+
+// SetErrorEncoder replaces the format of errors written by WriteJSONError,
+// RecoverJSON, and the middlewares of this package, e.g. to comply with
+// an organization-wide error schema. See JSONAPIErrorEncoder for an
+// example. Passing nil restores the default ErrorEnvelope format.
+func SetErrorEncoder(enc ErrorEncoder) {
+	updateConfig(func(cfg *Config) { cfg.ErrorEncoder = enc })
+}
+
+// JSONAPIErrorEncoder is an ErrorEncoder that writes errors as JSON:API
+// error objects, with one error object per detail. Example:
+//
+//	{
+//	  "errors": [
+//	    {"status": "422", "title": "Record has semantic errors", "detail": "Name is blank"}
+//	  ]
+//	}
+func JSONAPIErrorEncoder(w http.ResponseWriter, r *http.Request, code int, msg string, details []string) {
+	type errorObject struct {
+		Status string `json:"status"`
+		Title  string `json:"title"`
+		Detail string `json:"detail,omitempty"`
+	}
+	status := strconv.Itoa(code)
+	errs := []errorObject{{Status: status, Title: msg}}
+	if len(details) > 0 {
+		errs = errs[:0]
+		for _, detail := range details {
+			errs = append(errs, errorObject{Status: status, Title: msg, Detail: detail})
+		}
+	}
+	js, err := marshalJSONFor(r, map[string]interface{}{"errors": errs})
+	if err != nil {
+		badRequestError(w, r, "JSON serialization error: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(code)
+	w.Write(js)
+}
+
+// ErrorEnvelope is the JSON structure written by WriteJSONError.
+// Clients can use it to decode error responses.
+type ErrorEnvelope struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody is the "error" field of an ErrorEnvelope. It implements the
+// error interface, so a decoded ErrorBody can be relayed as is, e.g. with
+// WriteJSONError or by panicking in a handler protected by RecoverJSON.
+type ErrorBody struct {
+	// Code is the HTTP status code.
+	Code int
+	// Message is the error message.
+	Message string
+	// Details is an optional list of additional information.
+	Details []string
+	// Reason is an optional machine-readable reason, e.g. "QUOTA_EXCEEDED".
+	Reason string
+	// Extensions holds any additional fields of the error. They are
+	// serialized next to the standard fields, which take precedence.
+	Extensions map[string]interface{}
+}
+
+// NewErrorBody returns the ErrorBody that WriteJSONError writes for err.
+func NewErrorBody(err interface{}) ErrorBody {
+	if e, ok := err.(ErrorBody); ok {
+		return e
+	}
+	body := ErrorBody{
+		Code:    500,
+		Message: fmt.Sprint(err),
+	}
 	if i, ok := err.(httpCoder); ok {
-		code = i.HTTPCode()
+		body.Code = i.HTTPCode()
 	}
-	var details []string
 	if i, ok := err.(httpErrorDetails); ok {
-		details = i.ErrorDetails()
+		body.Details = i.ErrorDetails()
 	}
-	msg := fmt.Sprint(err)
-	innerErr := map[string]interface{}{
-		"code":    code,
-		"message": msg,
+	if i, ok := err.(httpErrorReason); ok {
+		body.Reason = i.ErrorReason()
 	}
-	if len(details) > 0 {
-		innerErr["details"] = details
+	return body
+}
+
+// Error returns the error message.
+func (e ErrorBody) Error() string { return e.Message }
+
+// HTTPCode returns the HTTP status code of the error.
+func (e ErrorBody) HTTPCode() int { return e.Code }
+
+// ErrorDetails returns additional information about the error.
+func (e ErrorBody) ErrorDetails() []string { return e.Details }
+
+// ErrorReason returns the machine-readable reason of the error.
+func (e ErrorBody) ErrorReason() string { return e.Reason }
+
+// MarshalJSON serializes the error, omitting details and reason if empty.
+func (e ErrorBody) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(e.Extensions)+4)
+	for k, v := range e.Extensions {
+		m[k] = v
+	}
+	m["code"] = e.Code
+	m["message"] = e.Message
+	if len(e.Details) > 0 {
+		m["details"] = e.Details
+	} else {
+		delete(m, "details")
+	}
+	if e.Reason != "" {
+		m["reason"] = e.Reason
+	} else {
+		delete(m, "reason")
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON deserializes the error. Fields other than code, message,
+// details, and reason are collected in Extensions.
+func (e *ErrorBody) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*e = ErrorBody{}
+	for k, raw := range m {
+		var err error
+		switch k {
+		case "code":
+			err = json.Unmarshal(raw, &e.Code)
+		case "message":
+			err = json.Unmarshal(raw, &e.Message)
+		case "details":
+			err = json.Unmarshal(raw, &e.Details)
+		case "reason":
+			err = json.Unmarshal(raw, &e.Reason)
+		default:
+			var v interface{}
+			if err = json.Unmarshal(raw, &v); err == nil {
+				if e.Extensions == nil {
+					e.Extensions = make(map[string]interface{})
+				}
+				e.Extensions[k] = v
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("httputil: invalid error field %q: %v", k, err)
+		}
 	}
+	return nil
+}
 
-	WriteJSONCode(w, code, map[string]interface{}{
-		"error": innerErr,
-	})
+// writeErrorHeaders adds the response headers of err to w if it
+// implements the httpHeaderer interface.
+func writeErrorHeaders(w http.ResponseWriter, err interface{}) {
+	i, ok := err.(httpHeaderer)
+	if !ok {
+		return
+	}
+	for name, values := range i.ErrorHeaders() {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
 }
 
 // httpCoder provides an interface to return the HTTP status code
@@ -91,6 +351,57 @@ type httpErrorDetails interface {
 	ErrorDetails() []string
 }
 
+// httpErrorReason provides an interface to return a machine-readable
+// reason for an error, e.g. "QUOTA_EXCEEDED". See GrpcError for an example.
+type httpErrorReason interface {
+	ErrorReason() string
+}
+
+// httpHeaderer provides an interface to add response headers for an
+// error, e.g. Retry-After or WWW-Authenticate. See TooManyRequestsError
+// for an example.
+type httpHeaderer interface {
+	ErrorHeaders() http.Header
+}
+
+// HTTPError is an error with an arbitrary HTTP status code, message,
+// details, and additional response headers. Use it e.g. in gateways to
+// relay the status and headers of an upstream (like X-RateLimit-*)
+// without defining a named type per status code.
+type HTTPError struct {
+	Code    int
+	Message string
+	Details []string
+	Header  http.Header
+}
+
+// Error returns the error in text form. If Message is blank,
+// it returns the standard text of the status code.
+func (e HTTPError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if text := http.StatusText(e.HTTPCode()); text != "" {
+		return text
+	}
+	return fmt.Sprintf("HTTP status %d", e.Code)
+}
+
+// HTTPCode returns the HTTP status code of the error. It returns 500
+// if Code is not a valid status code.
+func (e HTTPError) HTTPCode() int {
+	if e.Code < 100 || e.Code > 999 {
+		return http.StatusInternalServerError
+	}
+	return e.Code
+}
+
+// ErrorDetails returns additional information about the error.
+func (e HTTPError) ErrorDetails() []string { return e.Details }
+
+// ErrorHeaders returns the additional response headers.
+func (e HTTPError) ErrorHeaders() http.Header { return e.Header }
+
 // InvalidMethodError indicates that an invalid HTTP method is being used.
 type InvalidMethodError struct{}
 
@@ -101,7 +412,11 @@ func (InvalidMethodError) Error() string { return "Invalid HTTP method" }
 func (InvalidMethodError) HTTPCode() int { return http.StatusMethodNotAllowed }
 
 // UnauthorizedError indicates that credentials are either missing or invalid.
-type UnauthorizedError struct{}
+type UnauthorizedError struct {
+	// Challenge is returned in the WWW-Authenticate header if not blank,
+	// e.g. `Bearer realm="example"`.
+	Challenge string
+}
 
 // Error returns the error in text form.
 func (UnauthorizedError) Error() string { return "Missing or invalid credentials" }
@@ -109,6 +424,24 @@ func (UnauthorizedError) Error() string { return "Missing or invalid credentials
 // HTTPCode returns the HTTP status code of the error.
 func (UnauthorizedError) HTTPCode() int { return http.StatusUnauthorized }
 
+// ErrorHeaders returns the WWW-Authenticate header.
+func (e UnauthorizedError) ErrorHeaders() http.Header {
+	if e.Challenge == "" {
+		return nil
+	}
+	return http.Header{"Www-Authenticate": {e.Challenge}}
+}
+
+// AccessDeniedError indicates that the client is not permitted to
+// access a resource, e.g. because of an invalid signature.
+type AccessDeniedError struct{}
+
+// Error returns the error in text form.
+func (AccessDeniedError) Error() string { return "Access denied" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (AccessDeniedError) HTTPCode() int { return http.StatusForbidden }
+
 // NotFoundError indicates that a record or resource does not exist.
 type NotFoundError struct{}
 
@@ -118,6 +451,26 @@ func (NotFoundError) Error() string { return "Record not found" }
 // HTTPCode returns the HTTP status code of the error.
 func (NotFoundError) HTTPCode() int { return http.StatusNotFound }
 
+// GoneError indicates that a resource is no longer available,
+// e.g. because it has been deleted or a link has expired.
+type GoneError struct{}
+
+// Error returns the error in text form.
+func (GoneError) Error() string { return "Resource is gone" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (GoneError) HTTPCode() int { return http.StatusGone }
+
+// ConflictError indicates that the request conflicts with the current
+// state of a resource, e.g. because it has already been processed.
+type ConflictError struct{}
+
+// Error returns the error in text form.
+func (ConflictError) Error() string { return "Conflict" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (ConflictError) HTTPCode() int { return http.StatusConflict }
+
 // InvalidJSONError indicates that the JSON data are invalid.
 type InvalidJSONError struct {
 	error
@@ -126,6 +479,17 @@ type InvalidJSONError struct {
 // HTTPCode returns the HTTP status code of the error.
 func (InvalidJSONError) HTTPCode() int { return http.StatusBadRequest }
 
+// InvalidCSVError indicates that the CSV data are invalid.
+type InvalidCSVError struct {
+	error
+}
+
+// HTTPCode returns the HTTP status code of the error.
+func (InvalidCSVError) HTTPCode() int { return http.StatusBadRequest }
+
+// Unwrap returns the underlying error, e.g. a *csv.ParseError.
+func (e InvalidCSVError) Unwrap() error { return e.error }
+
 // MissingParameterError indicates that a required parameter is missing or blank.
 type MissingParameterError string
 
@@ -144,6 +508,59 @@ func (p InvalidParameterError) Error() string { return fmt.Sprintf("Invalid para
 // HTTPCode returns the HTTP status code of the error.
 func (InvalidParameterError) HTTPCode() int { return http.StatusBadRequest }
 
+// InvalidParameterHintError indicates that a parameter is invalid and
+// tells the client what a valid value looks like. It unwraps to an
+// InvalidParameterError.
+type InvalidParameterHintError struct {
+	Parameter string
+	Hint      string
+}
+
+// Error returns the error in text form.
+func (p InvalidParameterHintError) Error() string {
+	return InvalidParameterError(p.Parameter).Error()
+}
+
+// HTTPCode returns the HTTP status code of the error.
+func (InvalidParameterHintError) HTTPCode() int { return http.StatusBadRequest }
+
+// ErrorDetails returns the hint.
+func (p InvalidParameterHintError) ErrorDetails() []string { return []string{p.Hint} }
+
+// Unwrap returns the underlying InvalidParameterError.
+func (p InvalidParameterHintError) Unwrap() error { return InvalidParameterError(p.Parameter) }
+
+// DuplicateParameterError indicates that a parameter has been passed
+// more than once, while only a single value is permitted.
+type DuplicateParameterError string
+
+// Error returns the error in text form.
+func (p DuplicateParameterError) Error() string {
+	return fmt.Sprintf("Duplicate parameter %q", string(p))
+}
+
+// HTTPCode returns the HTTP status code of the error.
+func (DuplicateParameterError) HTTPCode() int { return http.StatusBadRequest }
+
+// UnexpectedParametersError indicates that the request contains
+// parameters that are not supported, e.g. because of a typo.
+type UnexpectedParametersError []string
+
+// Error returns the error in text form.
+func (UnexpectedParametersError) Error() string { return "Unexpected parameters" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (UnexpectedParametersError) HTTPCode() int { return http.StatusBadRequest }
+
+// ErrorDetails returns the names of the unexpected parameters.
+func (p UnexpectedParametersError) ErrorDetails() []string {
+	details := make([]string, len(p))
+	for i, name := range p {
+		details[i] = fmt.Sprintf("Unexpected parameter %q", name)
+	}
+	return details
+}
+
 // InvalidXSRFToken indicates that the user has not provided a valid XSRF token.
 type InvalidXSRFToken struct{}
 
@@ -168,6 +585,397 @@ func (UnprocessableEntityError) HTTPCode() int { return 422 }
 // ErrorDetails returns additional information about the error.
 func (p UnprocessableEntityError) ErrorDetails() []string { return p.Errors }
 
+// RequestEntityTooLargeError indicates that the request payload is
+// larger than permitted.
+type RequestEntityTooLargeError struct{}
+
+// Error returns the error in text form.
+func (RequestEntityTooLargeError) Error() string { return "Request entity too large" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (RequestEntityTooLargeError) HTTPCode() int { return http.StatusRequestEntityTooLarge }
+
+// UnsupportedMediaTypeError indicates that the request payload is
+// in a format that is not supported.
+type UnsupportedMediaTypeError struct{}
+
+// Error returns the error in text form.
+func (UnsupportedMediaTypeError) Error() string { return "Unsupported media type" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (UnsupportedMediaTypeError) HTTPCode() int { return http.StatusUnsupportedMediaType }
+
+// LockedError indicates that a resource is locked, e.g. by a WebDAV LOCK.
+type LockedError struct{}
+
+// Error returns the error in text form.
+func (LockedError) Error() string { return "Resource is locked" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (LockedError) HTTPCode() int { return http.StatusLocked }
+
+// FailedDependencyError indicates that an operation failed because
+// another operation it depends on failed.
+type FailedDependencyError struct{}
+
+// Error returns the error in text form.
+func (FailedDependencyError) Error() string { return "Failed dependency" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (FailedDependencyError) HTTPCode() int { return http.StatusFailedDependency }
+
+// PaymentRequiredError indicates that the client has to upgrade its
+// plan or pay to proceed, e.g. because its monthly quota is exhausted.
+type PaymentRequiredError struct {
+	// Reason is an optional machine-readable reason, e.g. "MONTHLY_QUOTA_EXCEEDED".
+	Reason string
+}
+
+// Error returns the error in text form.
+func (PaymentRequiredError) Error() string { return "Payment required" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (PaymentRequiredError) HTTPCode() int { return http.StatusPaymentRequired }
+
+// ErrorReason returns the machine-readable reason of the error.
+func (e PaymentRequiredError) ErrorReason() string { return e.Reason }
+
+// ProxyAuthenticationRequiredError indicates that the client has to
+// authenticate with a proxy, e.g. when relaying the response of an
+// upstream proxy.
+type ProxyAuthenticationRequiredError struct {
+	// Challenge is returned in the Proxy-Authenticate header if not
+	// blank, e.g. `Basic realm="proxy"`.
+	Challenge string
+}
+
+// Error returns the error in text form.
+func (ProxyAuthenticationRequiredError) Error() string { return "Proxy authentication required" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (ProxyAuthenticationRequiredError) HTTPCode() int {
+	return http.StatusProxyAuthRequired
+}
+
+// ErrorHeaders returns the Proxy-Authenticate header.
+func (e ProxyAuthenticationRequiredError) ErrorHeaders() http.Header {
+	if e.Challenge == "" {
+		return nil
+	}
+	return http.Header{"Proxy-Authenticate": {e.Challenge}}
+}
+
+// ExpectationFailedError indicates that the server cannot meet the
+// Expect header of the request, e.g. "Expect: 100-continue" for a body
+// that would be rejected anyway.
+type ExpectationFailedError struct{}
+
+// Error returns the error in text form.
+func (ExpectationFailedError) Error() string { return "Expectation failed" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (ExpectationFailedError) HTTPCode() int { return http.StatusExpectationFailed }
+
+// MisdirectedRequestError indicates that the request was sent to a
+// server that is not able to respond for its host, e.g. over a reused
+// HTTP/2 connection. Clients may retry on a new connection.
+type MisdirectedRequestError struct{}
+
+// Error returns the error in text form.
+func (MisdirectedRequestError) Error() string { return "Misdirected request" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (MisdirectedRequestError) HTTPCode() int { return http.StatusMisdirectedRequest }
+
+// UpgradeRequiredError indicates that the client has to switch to
+// another protocol to proceed.
+type UpgradeRequiredError struct {
+	// Upgrade lists the required protocols, e.g. "TLS/1.3". It is
+	// returned in the Upgrade header if not blank.
+	Upgrade string
+}
+
+// Error returns the error in text form.
+func (UpgradeRequiredError) Error() string { return "Upgrade required" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (UpgradeRequiredError) HTTPCode() int { return http.StatusUpgradeRequired }
+
+// ErrorHeaders returns the Upgrade and Connection headers.
+func (e UpgradeRequiredError) ErrorHeaders() http.Header {
+	if e.Upgrade == "" {
+		return nil
+	}
+	return http.Header{"Upgrade": {e.Upgrade}, "Connection": {"Upgrade"}}
+}
+
+// UnavailableForLegalReasonsError indicates that the resource is not
+// available because of a legal demand, as in RFC 7725.
+type UnavailableForLegalReasonsError struct {
+	// Blocker is the URL of the entity implementing the block. It is
+	// returned in a Link header with relation "blocked-by" if not blank.
+	Blocker string
+}
+
+// Error returns the error in text form.
+func (UnavailableForLegalReasonsError) Error() string { return "Unavailable for legal reasons" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (UnavailableForLegalReasonsError) HTTPCode() int {
+	return http.StatusUnavailableForLegalReasons
+}
+
+// ErrorHeaders returns the Link header to the blocker.
+func (e UnavailableForLegalReasonsError) ErrorHeaders() http.Header {
+	if e.Blocker == "" {
+		return nil
+	}
+	return http.Header{"Link": {"<" + e.Blocker + `>; rel="blocked-by"`}}
+}
+
+// NotAcceptableError indicates that the server cannot produce a
+// representation that matches the Accept headers of the request.
+type NotAcceptableError struct{}
+
+// Error returns the error in text form.
+func (NotAcceptableError) Error() string { return "Not acceptable" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (NotAcceptableError) HTTPCode() int { return http.StatusNotAcceptable }
+
+// RequestTimeoutError indicates that the client did not send the
+// complete request in time. The connection is closed afterwards.
+type RequestTimeoutError struct{}
+
+// Error returns the error in text form.
+func (RequestTimeoutError) Error() string { return "Request timeout" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (RequestTimeoutError) HTTPCode() int { return http.StatusRequestTimeout }
+
+// ErrorHeaders returns the Connection header.
+func (RequestTimeoutError) ErrorHeaders() http.Header {
+	return http.Header{"Connection": {"close"}}
+}
+
+// LengthRequiredError indicates that the request has to specify a
+// Content-Length, e.g. for uploads of a known size.
+type LengthRequiredError struct{}
+
+// Error returns the error in text form.
+func (LengthRequiredError) Error() string { return "Length required" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (LengthRequiredError) HTTPCode() int { return http.StatusLengthRequired }
+
+// PreconditionFailedError indicates that a conditional request failed,
+// e.g. because the If-Match header doesn't match the current ETag.
+type PreconditionFailedError struct{}
+
+// Error returns the error in text form.
+func (PreconditionFailedError) Error() string { return "Precondition failed" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (PreconditionFailedError) HTTPCode() int { return http.StatusPreconditionFailed }
+
+// URITooLongError indicates that the request target is longer than
+// the server is willing to interpret.
+type URITooLongError struct{}
+
+// Error returns the error in text form.
+func (URITooLongError) Error() string { return "URI too long" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (URITooLongError) HTTPCode() int { return http.StatusRequestURITooLong }
+
+// RangeNotSatisfiableError indicates that none of the ranges in the
+// Range header overlap the current extent of the resource.
+type RangeNotSatisfiableError struct {
+	// Size is the current size of the resource. It is returned in the
+	// Content-Range header if not negative, e.g. "bytes */1234".
+	Size int64
+}
+
+// Error returns the error in text form.
+func (RangeNotSatisfiableError) Error() string { return "Range not satisfiable" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (RangeNotSatisfiableError) HTTPCode() int {
+	return http.StatusRequestedRangeNotSatisfiable
+}
+
+// ErrorHeaders returns the Content-Range header.
+func (e RangeNotSatisfiableError) ErrorHeaders() http.Header {
+	if e.Size < 0 {
+		return nil
+	}
+	return http.Header{"Content-Range": {fmt.Sprintf("bytes */%d", e.Size)}}
+}
+
+// PreconditionRequiredError indicates that the request has to be
+// conditional, e.g. send an If-Match header to prevent lost updates.
+type PreconditionRequiredError struct{}
+
+// Error returns the error in text form.
+func (PreconditionRequiredError) Error() string { return "Precondition required" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (PreconditionRequiredError) HTTPCode() int { return http.StatusPreconditionRequired }
+
+// RequestHeaderFieldsTooLargeError indicates that a single header or
+// all headers of the request together are too large.
+type RequestHeaderFieldsTooLargeError struct{}
+
+// Error returns the error in text form.
+func (RequestHeaderFieldsTooLargeError) Error() string { return "Request header fields too large" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (RequestHeaderFieldsTooLargeError) HTTPCode() int {
+	return http.StatusRequestHeaderFieldsTooLarge
+}
+
+// TooManyRequestsError indicates that the client has sent too many
+// requests or exhausted its quota.
+type TooManyRequestsError struct {
+	// RetryAfter is returned in the Retry-After header if positive.
+	RetryAfter time.Duration
+	// Reason is an optional machine-readable reason, e.g. "DAILY_QUOTA_EXCEEDED".
+	Reason string
+}
+
+// Error returns the error in text form.
+func (TooManyRequestsError) Error() string { return "Too many requests" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (TooManyRequestsError) HTTPCode() int { return http.StatusTooManyRequests }
+
+// ErrorReason returns the machine-readable reason of the error.
+func (e TooManyRequestsError) ErrorReason() string { return e.Reason }
+
+// ErrorHeaders returns the Retry-After header.
+func (e TooManyRequestsError) ErrorHeaders() http.Header { return retryAfterHeader(e.RetryAfter) }
+
+// ServiceUnavailableError indicates that the server or an upstream
+// service is temporarily unable to handle the request.
+type ServiceUnavailableError struct {
+	// RetryAfter is returned in the Retry-After header if positive.
+	RetryAfter time.Duration
+}
+
+// Error returns the error in text form.
+func (ServiceUnavailableError) Error() string { return "Service unavailable" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (ServiceUnavailableError) HTTPCode() int { return http.StatusServiceUnavailable }
+
+// ErrorHeaders returns the Retry-After header.
+func (e ServiceUnavailableError) ErrorHeaders() http.Header { return retryAfterHeader(e.RetryAfter) }
+
+// retryAfterHeader returns a Retry-After header with d rounded up to
+// whole seconds, or nil if d is not positive.
+func retryAfterHeader(d time.Duration) http.Header {
+	if d <= 0 {
+		return nil
+	}
+	h := make(http.Header)
+	SetHeaderDuration(h, "Retry-After", (d + time.Second - 1).Truncate(time.Second))
+	return h
+}
+
+// InsufficientStorageError indicates that the server is unable to
+// store the representation needed to complete the request.
+type InsufficientStorageError struct{}
+
+// Error returns the error in text form.
+func (InsufficientStorageError) Error() string { return "Insufficient storage" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (InsufficientStorageError) HTTPCode() int { return http.StatusInsufficientStorage }
+
+// LoopDetectedError indicates that the server detected an infinite
+// loop while processing the request, e.g. a WebDAV "Depth: infinity" request.
+type LoopDetectedError struct{}
+
+// Error returns the error in text form.
+func (LoopDetectedError) Error() string { return "Loop detected" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (LoopDetectedError) HTTPCode() int { return http.StatusLoopDetected }
+
+// NetworkAuthenticationRequiredError indicates that the client has to
+// authenticate to gain network access, e.g. at a captive portal.
+type NetworkAuthenticationRequiredError struct{}
+
+// Error returns the error in text form.
+func (NetworkAuthenticationRequiredError) Error() string { return "Network authentication required" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (NetworkAuthenticationRequiredError) HTTPCode() int {
+	return http.StatusNetworkAuthenticationRequired
+}
+
+// BadGatewayError indicates that an upstream service could not be
+// reached or returned an invalid response, see ClassifyTransportError.
+type BadGatewayError struct {
+	// Reason is an optional machine-readable reason, e.g. "DNS_FAILURE".
+	Reason string
+	// Err is the underlying error. It is not returned to the client.
+	Err error
+}
+
+// Error returns the error in text form.
+func (BadGatewayError) Error() string { return "Bad gateway" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (BadGatewayError) HTTPCode() int { return http.StatusBadGateway }
+
+// ErrorReason returns the machine-readable reason of the error.
+func (e BadGatewayError) ErrorReason() string { return e.Reason }
+
+// Unwrap returns the underlying error.
+func (e BadGatewayError) Unwrap() error { return e.Err }
+
+// GatewayTimeoutError indicates that an upstream service did not
+// respond in time, see ClassifyTransportError.
+type GatewayTimeoutError struct {
+	// Reason is an optional machine-readable reason, e.g. "UPSTREAM_TIMEOUT".
+	Reason string
+	// Err is the underlying error. It is not returned to the client.
+	Err error
+}
+
+// Error returns the error in text form.
+func (GatewayTimeoutError) Error() string { return "Gateway timeout" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (GatewayTimeoutError) HTTPCode() int { return http.StatusGatewayTimeout }
+
+// ErrorReason returns the machine-readable reason of the error.
+func (e GatewayTimeoutError) ErrorReason() string { return e.Reason }
+
+// Unwrap returns the underlying error.
+func (e GatewayTimeoutError) Unwrap() error { return e.Err }
+
+// ClientClosedRequestError indicates that the client has gone away
+// before the response was complete. Its status code 499 is non-standard,
+// but widely used in access logs and metrics.
+type ClientClosedRequestError struct {
+	// Err is the underlying error. It is not returned to the client.
+	Err error
+}
+
+// Error returns the error in text form.
+func (ClientClosedRequestError) Error() string { return "Client closed request" }
+
+// HTTPCode returns the HTTP status code of the error.
+func (ClientClosedRequestError) HTTPCode() int { return 499 }
+
+// ErrorReason returns "CLIENT_CLOSED_REQUEST".
+func (ClientClosedRequestError) ErrorReason() string { return "CLIENT_CLOSED_REQUEST" }
+
+// Unwrap returns the underlying error.
+func (e ClientClosedRequestError) Unwrap() error { return e.Err }
+
 // TimeoutError indicates that the request has timed out.
 type TimeoutError struct{}
 
@@ -196,6 +1004,11 @@ func (NotImplementedError) Error() string { return "Not implemented" }
 func (NotImplementedError) HTTPCode() int { return http.StatusNotImplemented }
 
 // GrpcError is a placeholder for a gRPC error, and will turn it into a HTTP error.
+// Error details attached to the gRPC status are passed on: Field violations
+// of errdetails.BadRequest and errdetails.PreconditionFailure as well as
+// errdetails.QuotaFailure violations become error details, errdetails.RetryInfo
+// becomes a Retry-After header, and errdetails.ErrorInfo or the presence of
+// errdetails.QuotaFailure set the error reason.
 type GrpcError struct {
 	Err error
 }
@@ -213,13 +1026,100 @@ func (e GrpcError) HTTPCode() int {
 	switch status.Code(e.Err) {
 	case codes.OK:
 		return http.StatusOK
+	case codes.Canceled:
+		// Non-standard, but widely used for "Client Closed Request"
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
 	case codes.NotFound:
 		return http.StatusNotFound
-	case codes.AlreadyExists:
+	case codes.AlreadyExists, codes.Aborted:
 		return http.StatusConflict
 	case codes.FailedPrecondition:
 		return http.StatusBadRequest
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
 	default:
 		return http.StatusInternalServerError
 	}
 }
+
+// ErrorDetails returns the violations found in the details of the gRPC status.
+func (e GrpcError) ErrorDetails() []string {
+	s, ok := status.FromError(e.Err)
+	if !ok {
+		return nil
+	}
+	var details []string
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				details = append(details, violation(v.GetField(), v.GetDescription()))
+			}
+		case *errdetails.PreconditionFailure:
+			for _, v := range d.GetViolations() {
+				details = append(details, violation(v.GetSubject(), v.GetDescription()))
+			}
+		case *errdetails.QuotaFailure:
+			for _, v := range d.GetViolations() {
+				details = append(details, violation(v.GetSubject(), v.GetDescription()))
+			}
+		}
+	}
+	return details
+}
+
+// ErrorReason returns the reason of an errdetails.ErrorInfo attached to the
+// gRPC status, or "QUOTA_EXCEEDED" if it has an errdetails.QuotaFailure.
+func (e GrpcError) ErrorReason() string {
+	s, ok := status.FromError(e.Err)
+	if !ok {
+		return ""
+	}
+	var reason string
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetReason() != "" {
+				return d.GetReason()
+			}
+		case *errdetails.QuotaFailure:
+			reason = "QUOTA_EXCEEDED"
+		}
+	}
+	return reason
+}
+
+// ErrorHeaders returns a Retry-After header if the gRPC status carries
+// an errdetails.RetryInfo.
+func (e GrpcError) ErrorHeaders() http.Header {
+	s, ok := status.FromError(e.Err)
+	if !ok {
+		return nil
+	}
+	for _, d := range s.Details() {
+		if d, ok := d.(*errdetails.RetryInfo); ok && d.GetRetryDelay() != nil {
+			return retryAfterHeader(d.GetRetryDelay().AsDuration())
+		}
+	}
+	return nil
+}
+
+// violation formats a violation of subject as an error detail.
+func violation(subject, description string) string {
+	if subject == "" {
+		return description
+	}
+	return subject + ": " + description
+}
@@ -8,7 +8,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func TestWriteJSONError(t *testing.T) {
@@ -28,18 +35,7 @@ func TestWriteJSONError(t *testing.T) {
 	if got != "application/json" {
 		t.Errorf("expected Content-Type = %q; got: %q", "application/json", got)
 	}
-	type failure struct {
-		Error struct {
-			Code    int      `json:"code"`
-			Message string   `json:"message"`
-			Details []string `json:"details"`
-		} `json:"error"`
-	}
-	var fail failure
-	err = json.NewDecoder(w.Body).Decode(&fail)
-	if err != nil {
-		t.Fatal(err)
-	}
+	fail := ErrorEnvelopeOf(t, w)
 	if fail.Error.Code != 500 {
 		t.Errorf("expected error code = %d; got: %d", 500, fail.Error.Code)
 	}
@@ -65,18 +61,7 @@ func TestWriteJSONErrorWithCoder(t *testing.T) {
 	if got != "application/json" {
 		t.Errorf("expected Content-Type = %q; got: %q", "application/json", got)
 	}
-	type failure struct {
-		Error struct {
-			Code    int      `json:"code"`
-			Message string   `json:"message"`
-			Details []string `json:"details"`
-		} `json:"error"`
-	}
-	var fail failure
-	err = json.NewDecoder(w.Body).Decode(&fail)
-	if err != nil {
-		t.Fatal(err)
-	}
+	fail := ErrorEnvelopeOf(t, w)
 	if fail.Error.Code != http.StatusBadRequest {
 		t.Errorf("expected error code = %d; got: %d", http.StatusBadRequest, fail.Error.Code)
 	}
@@ -104,18 +89,7 @@ func TestWriteJSONErrorWithDetails(t *testing.T) {
 	if got != "application/json" {
 		t.Errorf("expected Content-Type = %q; got: %q", "application/json", got)
 	}
-	type failure struct {
-		Error struct {
-			Code    int      `json:"code"`
-			Message string   `json:"message"`
-			Details []string `json:"details"`
-		} `json:"error"`
-	}
-	var fail failure
-	err = json.NewDecoder(w.Body).Decode(&fail)
-	if err != nil {
-		t.Fatal(err)
-	}
+	fail := ErrorEnvelopeOf(t, w)
 	if fail.Error.Code != 422 {
 		t.Errorf("expected error code = %d; got: %d", 422, fail.Error.Code)
 	}
@@ -132,3 +106,332 @@ func TestWriteJSONErrorWithDetails(t *testing.T) {
 		t.Errorf("expected error details[1] = %q; got: %q", `B is invalid`, fail.Error.Details[1])
 	}
 }
+
+func TestErrorHTTPCodes(t *testing.T) {
+	tests := []struct {
+		Err    httpCoder
+		Code   int
+		Header string
+		Value  string
+	}{
+		{Err: LockedError{}, Code: http.StatusLocked},
+		{Err: FailedDependencyError{}, Code: http.StatusFailedDependency},
+		{Err: PaymentRequiredError{}, Code: http.StatusPaymentRequired},
+		{Err: TooManyRequestsError{}, Code: http.StatusTooManyRequests},
+		{Err: ServiceUnavailableError{}, Code: http.StatusServiceUnavailable},
+		{Err: InsufficientStorageError{}, Code: http.StatusInsufficientStorage},
+		{Err: LoopDetectedError{}, Code: http.StatusLoopDetected},
+		{Err: BadGatewayError{}, Code: http.StatusBadGateway},
+		{Err: GatewayTimeoutError{}, Code: http.StatusGatewayTimeout},
+		{Err: ClientClosedRequestError{}, Code: 499},
+		{Err: ProxyAuthenticationRequiredError{}, Code: http.StatusProxyAuthRequired},
+		{Err: ProxyAuthenticationRequiredError{Challenge: `Basic realm="proxy"`}, Code: http.StatusProxyAuthRequired, Header: "Proxy-Authenticate", Value: `Basic realm="proxy"`},
+		{Err: ExpectationFailedError{}, Code: http.StatusExpectationFailed},
+		{Err: MisdirectedRequestError{}, Code: http.StatusMisdirectedRequest},
+		{Err: UpgradeRequiredError{Upgrade: "TLS/1.3"}, Code: http.StatusUpgradeRequired, Header: "Upgrade", Value: "TLS/1.3"},
+		{Err: UnavailableForLegalReasonsError{}, Code: http.StatusUnavailableForLegalReasons},
+		{Err: UnavailableForLegalReasonsError{Blocker: "https://authority.example.org/"}, Code: http.StatusUnavailableForLegalReasons, Header: "Link", Value: `<https://authority.example.org/>; rel="blocked-by"`},
+		{Err: NotAcceptableError{}, Code: http.StatusNotAcceptable},
+		{Err: RequestTimeoutError{}, Code: http.StatusRequestTimeout, Header: "Connection", Value: "close"},
+		{Err: LengthRequiredError{}, Code: http.StatusLengthRequired},
+		{Err: PreconditionFailedError{}, Code: http.StatusPreconditionFailed},
+		{Err: URITooLongError{}, Code: http.StatusRequestURITooLong},
+		{Err: RangeNotSatisfiableError{Size: 1234}, Code: http.StatusRequestedRangeNotSatisfiable, Header: "Content-Range", Value: "bytes */1234"},
+		{Err: RangeNotSatisfiableError{Size: -1}, Code: http.StatusRequestedRangeNotSatisfiable, Header: "Content-Range", Value: ""},
+		{Err: PreconditionRequiredError{}, Code: http.StatusPreconditionRequired},
+		{Err: RequestHeaderFieldsTooLargeError{}, Code: http.StatusRequestHeaderFieldsTooLarge},
+		{Err: NetworkAuthenticationRequiredError{}, Code: http.StatusNetworkAuthenticationRequired},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteJSONError(w, tt.Err)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Header != "" {
+			if want, have := tt.Value, w.Header().Get(tt.Header); want != have {
+				t.Errorf("#%d: want %s %q, have %q", i, tt.Header, want, have)
+			}
+		}
+	}
+}
+
+func TestGrpcErrorHTTPCode(t *testing.T) {
+	tests := []struct {
+		Code codes.Code
+		Want int
+	}{
+		{Code: codes.OK, Want: http.StatusOK},
+		{Code: codes.Canceled, Want: 499},
+		{Code: codes.Unknown, Want: http.StatusInternalServerError},
+		{Code: codes.InvalidArgument, Want: http.StatusBadRequest},
+		{Code: codes.DeadlineExceeded, Want: http.StatusGatewayTimeout},
+		{Code: codes.NotFound, Want: http.StatusNotFound},
+		{Code: codes.AlreadyExists, Want: http.StatusConflict},
+		{Code: codes.PermissionDenied, Want: http.StatusForbidden},
+		{Code: codes.ResourceExhausted, Want: http.StatusTooManyRequests},
+		{Code: codes.FailedPrecondition, Want: http.StatusBadRequest},
+		{Code: codes.Aborted, Want: http.StatusConflict},
+		{Code: codes.OutOfRange, Want: http.StatusBadRequest},
+		{Code: codes.Unimplemented, Want: http.StatusNotImplemented},
+		{Code: codes.Internal, Want: http.StatusInternalServerError},
+		{Code: codes.Unavailable, Want: http.StatusServiceUnavailable},
+		{Code: codes.DataLoss, Want: http.StatusInternalServerError},
+		{Code: codes.Unauthenticated, Want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		err := GrpcError{Err: status.Error(tt.Code, "upstream")}
+		if want, have := tt.Want, err.HTTPCode(); want != have {
+			t.Errorf("%v: want %d, have %d", tt.Code, want, have)
+		}
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		Err     error
+		Code    int
+		Message string
+		Details []string
+		Header  http.Header
+	}{
+		{
+			Err:     HTTPError{Code: http.StatusTooManyRequests},
+			Code:    http.StatusTooManyRequests,
+			Message: "Too Many Requests",
+		},
+		{
+			Err: &HTTPError{
+				Code:    http.StatusTooManyRequests,
+				Message: "Slow down",
+				Details: []string{"Limit is 100 requests per minute"},
+				Header:  http.Header{"X-Ratelimit-Remaining": {"0"}, "Retry-After": {"30"}},
+			},
+			Code:    http.StatusTooManyRequests,
+			Message: "Slow down",
+			Details: []string{"Limit is 100 requests per minute"},
+			Header:  http.Header{"X-Ratelimit-Remaining": {"0"}, "Retry-After": {"30"}},
+		},
+		{
+			Err:     HTTPError{Code: 599},
+			Code:    599,
+			Message: "HTTP status 599",
+		},
+		{
+			Err:     HTTPError{Code: 42, Message: "Invalid"},
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid",
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteJSONError(w, tt.Err)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		for name := range tt.Header {
+			if want, have := tt.Header.Get(name), w.Header().Get(name); want != have {
+				t.Errorf("#%d: want header %s=%q, have %q", i, name, want, have)
+			}
+		}
+		resp := ErrorEnvelopeOf(t, w)
+		if want, have := tt.Message, resp.Error.Message; want != have {
+			t.Errorf("#%d: want message %q, have %q", i, want, have)
+		}
+		if want, have := len(tt.Details), len(resp.Error.Details); want != have {
+			t.Errorf("#%d: want %d details, have %d", i, want, have)
+		}
+	}
+}
+
+func TestWriteErrorHeaders(t *testing.T) {
+	tests := []struct {
+		Err    interface{}
+		Name   string
+		Header string
+	}{
+		{Err: TooManyRequestsError{RetryAfter: 1500 * time.Millisecond}, Name: "Retry-After", Header: "2"},
+		{Err: TooManyRequestsError{}, Name: "Retry-After", Header: ""},
+		{Err: ServiceUnavailableError{RetryAfter: time.Minute}, Name: "Retry-After", Header: "60"},
+		{Err: UnauthorizedError{Challenge: `Bearer realm="api"`}, Name: "WWW-Authenticate", Header: `Bearer realm="api"`},
+		{Err: UnauthorizedError{}, Name: "WWW-Authenticate", Header: ""},
+		{Err: HTTPError{Code: 405, Header: http.Header{"Allow": {"GET, HEAD"}}}, Name: "Allow", Header: "GET, HEAD"},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteJSONError(w, tt.Err)
+		if want, have := tt.Header, w.Header().Get(tt.Name); want != have {
+			t.Errorf("#%d: want %s=%q, have %q", i, tt.Name, want, have)
+		}
+		w = httptest.NewRecorder()
+		WriteError(w, tt.Err)
+		if want, have := tt.Header, w.Header().Get(tt.Name); want != have {
+			t.Errorf("#%d: WriteError: want %s=%q, have %q", i, tt.Name, want, have)
+		}
+	}
+}
+
+func TestGrpcErrorDetails(t *testing.T) {
+	st, err := status.New(codes.ResourceExhausted, "Quota exceeded").WithDetails(
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{
+			{Subject: "project:42", Description: "Daily limit reached"},
+		}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(90 * time.Second)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	WriteJSONError(w, GrpcError{Err: st.Err()})
+	if want, have := http.StatusTooManyRequests, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "90", w.Header().Get("Retry-After"); want != have {
+		t.Errorf("want Retry-After %q, have %q", want, have)
+	}
+	resp := ErrorEnvelopeOf(t, w)
+	if want, have := "QUOTA_EXCEEDED", resp.Error.Reason; want != have {
+		t.Errorf("want reason %q, have %q", want, have)
+	}
+	if want, have := 1, len(resp.Error.Details); want != have {
+		t.Fatalf("want %d details, have %d", want, have)
+	}
+	if want, have := "project:42: Daily limit reached", resp.Error.Details[0]; want != have {
+		t.Errorf("want detail %q, have %q", want, have)
+	}
+}
+
+func TestGrpcErrorFieldViolations(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "Invalid request").WithDetails(
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "name", Description: "must not be blank"},
+			{Field: "age", Description: "must be positive"},
+		}},
+		&errdetails.ErrorInfo{Reason: "INVALID_USER", Domain: "example.com"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := GrpcError{Err: st.Err()}
+	if want, have := http.StatusBadRequest, e.HTTPCode(); want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	if want, have := []string{"name: must not be blank", "age: must be positive"}, e.ErrorDetails(); !reflect.DeepEqual(want, have) {
+		t.Errorf("want details %v, have %v", want, have)
+	}
+	if want, have := "INVALID_USER", e.ErrorReason(); want != have {
+		t.Errorf("want reason %q, have %q", want, have)
+	}
+	if have := e.ErrorHeaders(); have != nil {
+		t.Errorf("want no headers, have %v", have)
+	}
+}
+
+func TestErrorEnvelopeRoundTrip(t *testing.T) {
+	data := []byte(`{
+  "error": {
+    "code": 402,
+    "details": ["Card declined"],
+    "message": "Payment required",
+    "reason": "CARD_DECLINED",
+    "request_id": "abc-123"
+  }
+}`)
+	var env ErrorEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	want := ErrorBody{
+		Code:       402,
+		Message:    "Payment required",
+		Details:    []string{"Card declined"},
+		Reason:     "CARD_DECLINED",
+		Extensions: map[string]interface{}{"request_id": "abc-123"},
+	}
+	if !reflect.DeepEqual(want, env.Error) {
+		t.Fatalf("want %+v, have %+v", want, env.Error)
+	}
+
+	// Relaying the decoded error must reproduce the original response
+	w := httptest.NewRecorder()
+	WriteJSONError(w, env.Error)
+	if want, have := 402, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	if !EqualJSON(data, w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", data, w.Body.String())
+	}
+}
+
+func TestErrorBodyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		Body ErrorBody
+		Want string
+	}{
+		{
+			Body: ErrorBody{Code: 404, Message: "Record not found"},
+			Want: `{"code":404,"message":"Record not found"}`,
+		},
+		{
+			Body: ErrorBody{
+				Code:       400,
+				Message:    "Invalid",
+				Extensions: map[string]interface{}{"code": "overridden", "details": "overridden", "trace": 1},
+			},
+			Want: `{"code":400,"message":"Invalid","trace":1}`,
+		},
+	}
+	for i, tt := range tests {
+		have, err := json.Marshal(tt.Body)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !EqualJSON([]byte(tt.Want), have) {
+			t.Errorf("#%d: want %s, have %s", i, tt.Want, have)
+		}
+	}
+
+	var body ErrorBody
+	if err := json.Unmarshal([]byte(`{"code":"400"}`), &body); err == nil {
+		t.Error("want error for invalid code")
+	}
+}
+
+func TestSetErrorEncoder(t *testing.T) {
+	SetErrorEncoder(JSONAPIErrorEncoder)
+	defer SetErrorEncoder(nil)
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		panic(UnprocessableEntityError{Errors: []string{"Name is blank", "Age is negative"}})
+	}
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/", nil))
+	if want, have := 422, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	if want, have := "application/vnd.api+json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("want Content-Type %q, have %q", want, have)
+	}
+	want := `{"errors":[
+		{"status":"422","title":"Record has semantic errors","detail":"Name is blank"},
+		{"status":"422","title":"Record has semantic errors","detail":"Age is negative"}
+	]}`
+	if !EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	WriteJSONError(w, NotFoundError{})
+	want = `{"errors":[{"status":"404","title":"Record not found"}]}`
+	if !EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+
+	SetErrorEncoder(nil)
+	w = httptest.NewRecorder()
+	WriteJSONError(w, NotFoundError{})
+	if want, have := 404, ErrorEnvelopeOf(t, w).Error.Code; want != have {
+		t.Errorf("want default envelope with code %d, have %d", want, have)
+	}
+}
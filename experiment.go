@@ -0,0 +1,181 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultExperimentHeader is the default of Experiment.Header.
+const DefaultExperimentHeader = "X-Experiment"
+
+// Experiment is an A/B test, see AssignExperiment.
+type Experiment struct {
+	// Name identifies the experiment, e.g. "checkout-button".
+	Name string
+	// Variants are the names of the variants, e.g. "control" and "green".
+	Variants []string
+	// Weights are the relative weights of Variants. If empty, all
+	// variants have the same weight.
+	Weights []float64
+	// UserID returns a stable identifier of the user, if known. Users
+	// are assigned by the hash of it, so they get the same variant on
+	// all devices. Other clients get a random variant.
+	UserID func(r *http.Request) string
+	// Secret signs the cookie, so clients can't pick their variant.
+	// If empty, the cookie is not signed.
+	Secret []byte
+	// Cookie is the name of the cookie that keeps the assignment.
+	// It defaults to "exp_" followed by Name.
+	Cookie string
+	// CookieMaxAge is the lifetime of the cookie. It defaults to 30 days.
+	CookieMaxAge time.Duration
+	// Header is the response header that the assignment is added to,
+	// as "name=variant". It defaults to DefaultExperimentHeader.
+	Header string
+}
+
+type experimentsContextKey struct{}
+
+// experiments are the assignments of a request.
+type experiments struct {
+	sync.Mutex
+	variants map[string]string
+}
+
+// AssignExperiment assigns the client of r to a variant of exp and
+// returns the variant, and r with the assignment in its context (see
+// ExperimentVariant and Experiments). A valid assignment cookie takes
+// precedence; otherwise users are bucketed by the hash of exp.UserID,
+// and anonymous clients randomly. The assignment is kept in a cookie and
+// added to the response header, e.g. "X-Experiment: checkout-button=green",
+// so it can be joined with analytics data.
+//
+// Example:
+//
+//	variant, r := httputil.AssignExperiment(w, r, checkoutExperiment)
+//	...
+//	httputil.WriteJSON(w, map[string]interface{}{
+//	  "data": cart,
+//	  "meta": map[string]interface{}{"experiments": httputil.Experiments(r.Context())},
+//	})
+func AssignExperiment(w http.ResponseWriter, r *http.Request, exp Experiment) (string, *http.Request) {
+	if len(exp.Variants) == 0 {
+		return "", r
+	}
+	if exp.Cookie == "" {
+		exp.Cookie = "exp_" + exp.Name
+	}
+	if exp.CookieMaxAge <= 0 {
+		exp.CookieMaxAge = 30 * 24 * time.Hour
+	}
+	if exp.Header == "" {
+		exp.Header = DefaultExperimentHeader
+	}
+
+	variant := experimentVariantFromCookie(r, exp)
+	if variant == "" {
+		var key string
+		if exp.UserID != nil {
+			key = exp.UserID(r)
+		}
+		if key == "" {
+			var b [16]byte
+			rand.Read(b[:])
+			key = hex.EncodeToString(b[:])
+		}
+		variant = pickVariant(exp, bucketOf(exp.Name+"/"+key))
+		http.SetCookie(w, &http.Cookie{
+			Name:     exp.Cookie,
+			Value:    signCookieValue(variant, exp.Secret),
+			Path:     "/",
+			MaxAge:   int(exp.CookieMaxAge / time.Second),
+			HttpOnly: true,
+			Secure:   RequestScheme(r) == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	w.Header().Add(exp.Header, exp.Name+"="+variant)
+
+	exps, ok := r.Context().Value(experimentsContextKey{}).(*experiments)
+	if !ok {
+		exps = &experiments{variants: make(map[string]string)}
+		r = r.WithContext(context.WithValue(r.Context(), experimentsContextKey{}, exps))
+	}
+	exps.Lock()
+	exps.variants[exp.Name] = variant
+	exps.Unlock()
+	return variant, r
+}
+
+// ExperimentVariant returns the variant of the experiment name assigned
+// with AssignExperiment, or an empty string if there is none.
+func ExperimentVariant(ctx context.Context, name string) string {
+	exps, ok := ctx.Value(experimentsContextKey{}).(*experiments)
+	if !ok {
+		return ""
+	}
+	exps.Lock()
+	defer exps.Unlock()
+	return exps.variants[name]
+}
+
+// Experiments returns all assignments of AssignExperiment, by the name
+// of the experiment, e.g. for the meta data of a response.
+func Experiments(ctx context.Context) map[string]string {
+	m := make(map[string]string)
+	if exps, ok := ctx.Value(experimentsContextKey{}).(*experiments); ok {
+		exps.Lock()
+		for name, variant := range exps.variants {
+			m[name] = variant
+		}
+		exps.Unlock()
+	}
+	return m
+}
+
+// experimentVariantFromCookie returns the variant of a valid assignment
+// cookie, or an empty string.
+func experimentVariantFromCookie(r *http.Request, exp Experiment) string {
+	c, err := r.Cookie(exp.Cookie)
+	if err != nil {
+		return ""
+	}
+	v, ok := verifyCookieValue(c.Value, exp.Secret)
+	if !ok || !containsString(exp.Variants, v) {
+		return ""
+	}
+	return v
+}
+
+// pickVariant returns the variant of exp for bucket in [0,1).
+func pickVariant(exp Experiment, bucket float64) string {
+	weight := func(i int) float64 {
+		if len(exp.Weights) == 0 {
+			return 1
+		}
+		if i < len(exp.Weights) && exp.Weights[i] > 0 {
+			return exp.Weights[i]
+		}
+		return 0
+	}
+	var total float64
+	for i := range exp.Variants {
+		total += weight(i)
+	}
+	x := bucket * total
+	for i, variant := range exp.Variants {
+		if x < weight(i) {
+			return variant
+		}
+		x -= weight(i)
+	}
+	return exp.Variants[len(exp.Variants)-1]
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAssignExperiment(t *testing.T) {
+	exp := Experiment{
+		Name:     "checkout",
+		Variants: []string{"control", "green"},
+		Weights:  []float64{3, 1},
+		UserID:   func(r *http.Request) string { return r.Header.Get("X-User-Id") },
+		Secret:   []byte("secret"),
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variant, r := AssignExperiment(w, r, exp)
+		if want, have := variant, ExperimentVariant(r.Context(), "checkout"); want != have {
+			t.Errorf("want variant %q in context, have %q", want, have)
+		}
+		WriteJSON(w, map[string]interface{}{
+			"meta": map[string]interface{}{"experiments": Experiments(r.Context())},
+		})
+	})
+
+	// Users are assigned by hash of their id, according to the weights
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User-Id", fmt.Sprint(i))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		have := w.Header().Get(DefaultExperimentHeader)
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User-Id", fmt.Sprint(i))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want := w.Header().Get(DefaultExperimentHeader); want != have {
+			t.Fatalf("user %d: want deterministic assignment %q, have %q", i, want, have)
+		}
+		counts[have]++
+	}
+	if n := counts["checkout=green"]; n < 400 || n > 600 {
+		t.Errorf("want about 500 users in green, have %v", counts)
+	}
+
+	// The cookie keeps the assignment and is reported in the response
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "exp_checkout" {
+		t.Fatalf("want assignment cookie, have %v", cookies)
+	}
+	variant, ok := verifyCookieValue(cookies[0].Value, exp.Secret)
+	if !ok {
+		t.Fatalf("want signed cookie, have %q", cookies[0].Value)
+	}
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User-Id", fmt.Sprint(i))
+		req.AddCookie(cookies[0])
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := "checkout="+variant, w.Header().Get(DefaultExperimentHeader); want != have {
+			t.Errorf("want %q, have %q", want, have)
+		}
+		if have := w.Result().Cookies(); len(have) != 0 {
+			t.Errorf("want no new cookie, have %v", have)
+		}
+		if want, have := `{"meta":{"experiments":{"checkout":"`+variant+`"}}}`, w.Body.String(); !EqualJSON([]byte(want), []byte(have)) {
+			t.Errorf("want %s, have %s", want, have)
+		}
+	}
+
+	// Forged cookies are ignored
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "exp_checkout", Value: "green"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if have := w.Result().Cookies(); len(have) != 1 {
+		t.Errorf("want new cookie, have %v", have)
+	}
+}
+
+func TestExperimentsWithoutAssignment(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if have := ExperimentVariant(req.Context(), "checkout"); have != "" {
+		t.Errorf("want no variant, have %q", have)
+	}
+	if have := Experiments(req.Context()); len(have) != 0 {
+		t.Errorf("want no experiments, have %v", have)
+	}
+}
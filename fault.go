@@ -0,0 +1,140 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Fault is a failure injected by the FaultInjection middleware.
+type Fault struct {
+	// Percent is the percentage of matching requests that get the
+	// fault, between 0 and 100.
+	Percent float64
+	// Match restricts the fault to requests for which it returns true,
+	// e.g. by header or route (see RouteTemplate). If nil, all requests
+	// match.
+	Match func(r *http.Request) bool
+
+	// Latency delays the request before it is handled.
+	Latency time.Duration
+	// Error is returned instead of handling the request, e.g.
+	// ServiceUnavailableError{}.
+	Error error
+	// Drop closes the connection without a response.
+	Drop bool
+	// Truncate cuts off the response body after the given number
+	// of bytes and closes the connection.
+	Truncate int
+}
+
+// FaultConfig configures the FaultInjection middleware.
+type FaultConfig struct {
+	// Enabled must be set to inject faults. Tie it to an environment
+	// variable or feature flag, so faults never reach production by
+	// accident.
+	Enabled bool
+	// Faults are checked in order. The first fault that matches the
+	// request and is selected by its Percent is injected.
+	Faults []Fault
+}
+
+// MatchHeader returns a Fault.Match func that matches requests with
+// the header key set to value, e.g. to let testers opt in with
+// "X-Chaos: on".
+func MatchHeader(key, value string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		return r.Header.Get(key) == value
+	}
+}
+
+// MatchRoute returns a Fault.Match func that matches requests by the
+// template of the gorilla/mux route, e.g. "/orders/{id}".
+func MatchRoute(templates ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		route := RouteTemplate(r)
+		for _, tpl := range templates {
+			if route == tpl {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FaultInjection returns a middleware that injects latency, errors,
+// dropped connections, or truncated responses into a percentage of
+// requests, for resilience drills in staging environments. It does
+// nothing unless cfg.Enabled is set.
+//
+// Example:
+//
+//	router.Use(httputil.FaultInjection(httputil.FaultConfig{
+//	  Enabled: os.Getenv("CHAOS") == "1",
+//	  Faults: []httputil.Fault{
+//	    {Percent: 10, Latency: 2 * time.Second},
+//	    {Percent: 5, Error: httputil.ServiceUnavailableError{RetryAfter: time.Minute}},
+//	    {Percent: 1, Match: httputil.MatchRoute("/orders/{id}"), Drop: true},
+//	  },
+//	}))
+func FaultInjection(cfg FaultConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled || len(cfg.Faults) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var fault *Fault
+			for i := range cfg.Faults {
+				f := &cfg.Faults[i]
+				if (f.Match == nil || f.Match(r)) && rand.Float64()*100 < f.Percent {
+					fault = f
+					break
+				}
+			}
+			if fault == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if fault.Latency > 0 {
+				select {
+				case <-time.After(fault.Latency):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			switch {
+			case fault.Drop:
+				// Closes the connection without a response
+				panic(http.ErrAbortHandler)
+			case fault.Error != nil:
+				writeJSONError(w, r, fault.Error)
+			case fault.Truncate > 0:
+				next.ServeHTTP(wrapResponseWriter(&truncatingResponseWriter{responseWriter: responseWriter{w}, remaining: fault.Truncate}), r)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// truncatingResponseWriter aborts the response after a number of bytes.
+type truncatingResponseWriter struct {
+	responseWriter
+	remaining int
+}
+
+func (tw *truncatingResponseWriter) Write(p []byte) (int, error) {
+	if len(p) <= tw.remaining {
+		tw.remaining -= len(p)
+		return tw.ResponseWriter.Write(p)
+	}
+	tw.ResponseWriter.Write(p[:tw.remaining])
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFaultInjection(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello, world"))
+	})
+
+	tests := []struct {
+		Config FaultConfig
+		Header string
+		Code   int
+		Delay  time.Duration
+	}{
+		{
+			Config: FaultConfig{Faults: []Fault{{Percent: 100, Error: ServiceUnavailableError{}}}},
+			Code:   http.StatusOK,
+		},
+		{
+			Config: FaultConfig{Enabled: true, Faults: []Fault{{Percent: 100, Error: ServiceUnavailableError{}}}},
+			Code:   http.StatusServiceUnavailable,
+		},
+		{
+			Config: FaultConfig{Enabled: true, Faults: []Fault{{Percent: 0, Error: ServiceUnavailableError{}}}},
+			Code:   http.StatusOK,
+		},
+		{
+			Config: FaultConfig{Enabled: true, Faults: []Fault{
+				{Percent: 100, Match: MatchHeader("X-Chaos", "on"), Error: TooManyRequestsError{}},
+			}},
+			Code: http.StatusOK,
+		},
+		{
+			Config: FaultConfig{Enabled: true, Faults: []Fault{
+				{Percent: 100, Match: MatchHeader("X-Chaos", "on"), Error: TooManyRequestsError{}},
+			}},
+			Header: "on",
+			Code:   http.StatusTooManyRequests,
+		},
+		{
+			Config: FaultConfig{Enabled: true, Faults: []Fault{{Percent: 100, Latency: 20 * time.Millisecond}}},
+			Code:   http.StatusOK,
+			Delay:  20 * time.Millisecond,
+		},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.Header != "" {
+			req.Header.Set("X-Chaos", tt.Header)
+		}
+		w := httptest.NewRecorder()
+		start := time.Now()
+		FaultInjection(tt.Config)(ok).ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if d := time.Since(start); d < tt.Delay {
+			t.Errorf("#%d: want delay of at least %v, have %v", i, tt.Delay, d)
+		}
+	}
+}
+
+func TestFaultInjectionConnection(t *testing.T) {
+	h := FaultInjection(FaultConfig{
+		Enabled: true,
+		Faults: []Fault{
+			{Percent: 100, Match: MatchHeader("X-Fault", "drop"), Drop: true},
+			{Percent: 100, Match: MatchHeader("X-Fault", "truncate"), Truncate: 5},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "12")
+		w.Write([]byte("Hello, world"))
+	}))
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.Start()
+	defer srv.Close()
+
+	for _, fault := range []string{"drop", "truncate"} {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("X-Fault", fault)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			if fault != "drop" {
+				t.Errorf("%s: %v", fault, err)
+			}
+			continue
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if fault == "drop" {
+			t.Errorf("%s: want error, have response %d", fault, res.StatusCode)
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: want error reading body, have %q", fault, body)
+		}
+		if want, have := "Hello", string(body); want != have {
+			t.Errorf("%s: want %q, have %q", fault, want, have)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FilterVisitor is implemented by translators of filter expressions.
+// See VisitFilter.
+type FilterVisitor interface {
+	VisitCompare(e *FilterCompare) error
+	VisitLogical(e *FilterLogical) error
+	VisitNot(e *FilterNot) error
+	VisitFunc(e *FilterFunc) error
+}
+
+// VisitFilter calls the method of v that matches the type of expr.
+// Visitors call VisitFilter on the children of a node to walk the tree.
+func VisitFilter(expr FilterExpr, v FilterVisitor) error {
+	switch e := expr.(type) {
+	case *FilterCompare:
+		return v.VisitCompare(e)
+	case *FilterLogical:
+		return v.VisitLogical(e)
+	case *FilterNot:
+		return v.VisitNot(e)
+	case *FilterFunc:
+		return v.VisitFunc(e)
+	}
+	return fmt.Errorf("httputil: unsupported filter expression %T", expr)
+}
+
+// FilterAllowList restricts the fields and operators of a filter
+// expression when translating it, e.g. with FilterToSQL.
+type FilterAllowList struct {
+	// Fields maps the permitted field names of the filter to the names
+	// used in the translation, e.g. "name" to "u.full_name". The names
+	// are trusted and inserted as is, so they must never be taken from
+	// the request.
+	Fields map[string]string
+	// Ops lists the permitted comparison operators (e.g. FilterEq) and
+	// functions (e.g. "contains"). If empty, all are permitted.
+	Ops []string
+}
+
+// field returns the translated name of field, or an error if the field
+// or operator is not permitted.
+func (a FilterAllowList) field(field, op string) (string, error) {
+	name, found := a.Fields[field]
+	if !found {
+		return "", InvalidParameterHintError{Parameter: "$filter", Hint: fmt.Sprintf("Filtering by %q is not supported", field)}
+	}
+	if len(a.Ops) > 0 {
+		permitted := false
+		for _, o := range a.Ops {
+			if o == op {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return "", InvalidParameterHintError{Parameter: "$filter", Hint: fmt.Sprintf("Operator %q is not supported", op)}
+		}
+	}
+	return name, nil
+}
+
+// SQLDialect specifies the placeholder syntax of a SQL database.
+type SQLDialect int
+
+const (
+	// SQLQuestion uses "?" placeholders, e.g. for MySQL and SQLite.
+	SQLQuestion SQLDialect = iota
+	// SQLDollar uses "$1", "$2", ... placeholders, e.g. for PostgreSQL.
+	SQLDollar
+	// SQLAtP uses "@p1", "@p2", ... placeholders, e.g. for SQL Server.
+	SQLAtP
+)
+
+// FilterToSQL translates expr into a fragment of a WHERE clause, with
+// all values passed as arguments for the placeholders of dialect, so
+// filters from query parameters can't be used for SQL injection.
+// Fields and operators are checked against allow. A nil expr results
+// in an empty fragment.
+//
+// Example:
+//
+//	where, args, err := httputil.FilterToSQL(q.Filter, httputil.SQLDollar, allow)
+//	if err != nil {
+//	  panic(err)
+//	}
+//	if where != "" {
+//	  rows, err = db.QueryContext(ctx, "SELECT * FROM products WHERE "+where, args...)
+//	}
+func FilterToSQL(expr FilterExpr, dialect SQLDialect, allow FilterAllowList) (string, []interface{}, error) {
+	if expr == nil {
+		return "", nil, nil
+	}
+	v := &sqlFilterVisitor{dialect: dialect, allow: allow}
+	if err := VisitFilter(expr, v); err != nil {
+		return "", nil, err
+	}
+	return v.b.String(), v.args, nil
+}
+
+type sqlFilterVisitor struct {
+	dialect SQLDialect
+	allow   FilterAllowList
+	b       strings.Builder
+	args    []interface{}
+}
+
+func (v *sqlFilterVisitor) placeholder(arg interface{}) string {
+	v.args = append(v.args, arg)
+	switch v.dialect {
+	case SQLDollar:
+		return "$" + strconv.Itoa(len(v.args))
+	case SQLAtP:
+		return "@p" + strconv.Itoa(len(v.args))
+	}
+	return "?"
+}
+
+var sqlCompareOps = map[string]string{
+	FilterEq: "=",
+	FilterNe: "<>",
+	FilterGt: ">",
+	FilterGe: ">=",
+	FilterLt: "<",
+	FilterLe: "<=",
+}
+
+func (v *sqlFilterVisitor) VisitCompare(e *FilterCompare) error {
+	col, err := v.allow.field(e.Field, e.Op)
+	if err != nil {
+		return err
+	}
+	if e.Value == nil {
+		switch e.Op {
+		case FilterEq:
+			v.b.WriteString(col + " IS NULL")
+		case FilterNe:
+			v.b.WriteString(col + " IS NOT NULL")
+		default:
+			return InvalidParameterHintError{Parameter: "$filter", Hint: fmt.Sprintf("Operator %q can't be used with null", e.Op)}
+		}
+		return nil
+	}
+	op, found := sqlCompareOps[e.Op]
+	if !found {
+		return fmt.Errorf("httputil: unsupported operator %q", e.Op)
+	}
+	v.b.WriteString(col + " " + op + " " + v.placeholder(e.Value))
+	return nil
+}
+
+func (v *sqlFilterVisitor) VisitLogical(e *FilterLogical) error {
+	var op string
+	switch e.Op {
+	case "and":
+		op = " AND "
+	case "or":
+		op = " OR "
+	default:
+		return fmt.Errorf("httputil: unsupported logical operator %q", e.Op)
+	}
+	v.b.WriteString("(")
+	if err := VisitFilter(e.Left, v); err != nil {
+		return err
+	}
+	v.b.WriteString(op)
+	if err := VisitFilter(e.Right, v); err != nil {
+		return err
+	}
+	v.b.WriteString(")")
+	return nil
+}
+
+func (v *sqlFilterVisitor) VisitNot(e *FilterNot) error {
+	v.b.WriteString("NOT (")
+	if err := VisitFilter(e.Expr, v); err != nil {
+		return err
+	}
+	v.b.WriteString(")")
+	return nil
+}
+
+// sqlLikeEscaper escapes the wildcards of LIKE patterns with "!",
+// which needs no escaping in string literals of any dialect.
+var sqlLikeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func (v *sqlFilterVisitor) VisitFunc(e *FilterFunc) error {
+	col, err := v.allow.field(e.Field, e.Name)
+	if err != nil {
+		return err
+	}
+	pattern := sqlLikeEscaper.Replace(e.Value)
+	switch e.Name {
+	case "contains":
+		pattern = "%" + pattern + "%"
+	case "startswith":
+		pattern = pattern + "%"
+	case "endswith":
+		pattern = "%" + pattern
+	default:
+		return fmt.Errorf("httputil: unsupported function %q", e.Name)
+	}
+	v.b.WriteString(col + " LIKE " + v.placeholder(pattern) + " ESCAPE '!'")
+	return nil
+}
+
+// FilterToMongo translates expr into a MongoDB-style query document,
+// e.g. {"price": {"$lt": 10}}. Fields and operators are checked against
+// allow. Function arguments are quoted, so they are matched literally.
+// A nil expr results in an empty document.
+func FilterToMongo(expr FilterExpr, allow FilterAllowList) (map[string]interface{}, error) {
+	if expr == nil {
+		return map[string]interface{}{}, nil
+	}
+	v := &mongoFilterVisitor{allow: allow}
+	if err := VisitFilter(expr, v); err != nil {
+		return nil, err
+	}
+	return v.doc, nil
+}
+
+type mongoFilterVisitor struct {
+	allow FilterAllowList
+	doc   map[string]interface{}
+}
+
+// visit translates expr into a separate document.
+func (v *mongoFilterVisitor) visit(expr FilterExpr) (map[string]interface{}, error) {
+	child := &mongoFilterVisitor{allow: v.allow}
+	if err := VisitFilter(expr, child); err != nil {
+		return nil, err
+	}
+	return child.doc, nil
+}
+
+func (v *mongoFilterVisitor) VisitCompare(e *FilterCompare) error {
+	field, err := v.allow.field(e.Field, e.Op)
+	if err != nil {
+		return err
+	}
+	if _, found := sqlCompareOps[e.Op]; !found {
+		return fmt.Errorf("httputil: unsupported operator %q", e.Op)
+	}
+	v.doc = map[string]interface{}{field: map[string]interface{}{"$" + e.Op: e.Value}}
+	return nil
+}
+
+func (v *mongoFilterVisitor) VisitLogical(e *FilterLogical) error {
+	if e.Op != "and" && e.Op != "or" {
+		return fmt.Errorf("httputil: unsupported logical operator %q", e.Op)
+	}
+	left, err := v.visit(e.Left)
+	if err != nil {
+		return err
+	}
+	right, err := v.visit(e.Right)
+	if err != nil {
+		return err
+	}
+	v.doc = map[string]interface{}{"$" + e.Op: []interface{}{left, right}}
+	return nil
+}
+
+func (v *mongoFilterVisitor) VisitNot(e *FilterNot) error {
+	doc, err := v.visit(e.Expr)
+	if err != nil {
+		return err
+	}
+	v.doc = map[string]interface{}{"$nor": []interface{}{doc}}
+	return nil
+}
+
+func (v *mongoFilterVisitor) VisitFunc(e *FilterFunc) error {
+	field, err := v.allow.field(e.Field, e.Name)
+	if err != nil {
+		return err
+	}
+	pattern := regexp.QuoteMeta(e.Value)
+	switch e.Name {
+	case "contains":
+	case "startswith":
+		pattern = "^" + pattern
+	case "endswith":
+		pattern = pattern + "$"
+	default:
+		return fmt.Errorf("httputil: unsupported function %q", e.Name)
+	}
+	v.doc = map[string]interface{}{field: map[string]interface{}{"$regex": pattern}}
+	return nil
+}
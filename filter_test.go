@@ -0,0 +1,142 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var testFilterAllowList = FilterAllowList{
+	Fields: map[string]string{
+		"name":       "p.name",
+		"price":      "p.price",
+		"deleted_at": "p.deleted_at",
+	},
+}
+
+func TestFilterToSQL(t *testing.T) {
+	tests := []struct {
+		Filter  string
+		Dialect SQLDialect
+		Where   string
+		Args    []interface{}
+	}{
+		{
+			Filter: "",
+			Where:  "",
+		},
+		{
+			Filter: "price lt 10",
+			Where:  "p.price < ?",
+			Args:   []interface{}{int64(10)},
+		},
+		{
+			Filter:  "price ge 5 and (name eq 'x'' OR 1=1 --' or deleted_at eq null)",
+			Dialect: SQLDollar,
+			Where:   "(p.price >= $1 AND (p.name = $2 OR p.deleted_at IS NULL))",
+			Args:    []interface{}{int64(5), "x' OR 1=1 --"},
+		},
+		{
+			Filter:  "not contains(name,'50%_off!') and deleted_at ne null",
+			Dialect: SQLAtP,
+			Where:   "(NOT (p.name LIKE @p1 ESCAPE '!') AND p.deleted_at IS NOT NULL)",
+			Args:    []interface{}{"%50!%!_off!!%"},
+		},
+		{
+			Filter: "startswith(name,'a') or endswith(name,'z')",
+			Where:  "(p.name LIKE ? ESCAPE '!' OR p.name LIKE ? ESCAPE '!')",
+			Args:   []interface{}{"a%", "%z"},
+		},
+	}
+	for i, tt := range tests {
+		expr, err := ParseFilter(tt.Filter)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		where, args, err := FilterToSQL(expr, tt.Dialect, testFilterAllowList)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Where, where; want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+		if want, have := tt.Args, args; !reflect.DeepEqual(want, have) {
+			t.Errorf("#%d: want args %v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestFilterAllowList(t *testing.T) {
+	allow := FilterAllowList{
+		Fields: map[string]string{"name": "name", "price": "price"},
+		Ops:    []string{FilterEq, "contains"},
+	}
+	tests := []struct {
+		Filter string
+		Err    bool
+	}{
+		{Filter: "name eq 'x'"},
+		{Filter: "contains(name,'x')"},
+		{Filter: "password eq 'secret'", Err: true},
+		{Filter: "price gt 3", Err: true},
+		{Filter: "startswith(name,'x')", Err: true},
+		{Filter: "name eq 'x' and not (secret eq 1)", Err: true},
+	}
+	for i, tt := range tests {
+		expr, err := ParseFilter(tt.Filter)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		_, _, sqlErr := FilterToSQL(expr, SQLQuestion, allow)
+		_, mongoErr := FilterToMongo(expr, allow)
+		for _, err := range []error{sqlErr, mongoErr} {
+			if want, have := tt.Err, err != nil; want != have {
+				t.Errorf("#%d: %q: want error %v, have %v", i, tt.Filter, want, err)
+			}
+			if _, ok := err.(InvalidParameterHintError); err != nil && !ok {
+				t.Errorf("#%d: want InvalidParameterHintError, have %T", i, err)
+			}
+		}
+	}
+}
+
+func TestFilterToSQLNullOperator(t *testing.T) {
+	expr, _ := ParseFilter("price gt null")
+	if _, _, err := FilterToSQL(expr, SQLQuestion, testFilterAllowList); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestFilterToMongo(t *testing.T) {
+	expr, err := ParseFilter("price lt 10 and (startswith(name,'a.b') or not deleted_at eq null)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := FilterToMongo(expr, testFilterAllowList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$and":[
+		{"p.price":{"$lt":10}},
+		{"$or":[
+			{"p.name":{"$regex":"^a\\.b"}},
+			{"$nor":[{"p.deleted_at":{"$eq":null}}]}
+		]}
+	]}`
+	if !EqualJSON([]byte(want), have) {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	doc, err = FilterToMongo(nil, testFilterAllowList)
+	if err != nil || len(doc) != 0 {
+		t.Errorf("want empty document, have %v, %v", doc, err)
+	}
+}
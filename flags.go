@@ -0,0 +1,115 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+)
+
+// FlagProvider resolves feature flags. Implementations may use the
+// FlagClaims of ctx to resolve flags per tenant or user.
+type FlagProvider interface {
+	// FlagEnabled returns true if the flag name is enabled.
+	FlagEnabled(ctx context.Context, name string) (bool, error)
+}
+
+// FlagClaims identify the tenant and user that flags are resolved for,
+// typically taken from the claims of an access token.
+type FlagClaims struct {
+	Tenant string
+	User   string
+}
+
+type (
+	flagProviderContextKey struct{}
+	flagClaimsContextKey   struct{}
+)
+
+// Flags returns a middleware that makes p available to FlagEnabled and
+// RequireFlag for all requests passing through. If claims is not nil,
+// it is called to attach the FlagClaims of each request, e.g. from the
+// verified bearer token.
+func Flags(p FlagProvider, claims func(r *http.Request) FlagClaims) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), flagProviderContextKey{}, p)
+			if claims != nil {
+				ctx = WithFlagClaims(ctx, claims(r))
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// WithFlagClaims returns a copy of ctx with the given claims.
+func WithFlagClaims(ctx context.Context, claims FlagClaims) context.Context {
+	return context.WithValue(ctx, flagClaimsContextKey{}, claims)
+}
+
+// FlagClaimsFromContext returns the claims attached with Flags or
+// WithFlagClaims, or empty claims if there are none.
+func FlagClaimsFromContext(ctx context.Context) FlagClaims {
+	claims, _ := ctx.Value(flagClaimsContextKey{}).(FlagClaims)
+	return claims
+}
+
+// FlagEnabled returns true if the flag name is enabled by the FlagProvider
+// attached with Flags. Flags are disabled if there is no provider or it
+// returns an error.
+func FlagEnabled(ctx context.Context, name string) bool {
+	p, ok := ctx.Value(flagProviderContextKey{}).(FlagProvider)
+	if !ok {
+		return false
+	}
+	on, err := p.FlagEnabled(ctx, name)
+	return err == nil && on
+}
+
+// RequireFlag returns a middleware that returns NotFoundError unless the
+// flag name is enabled (see FlagEnabled), so experimental endpoints are
+// invisible to clients without the flag.
+//
+// Example:
+//
+//	router.Use(httputil.Flags(provider, claimsFromToken))
+//	beta := router.PathPrefix("/beta").Subrouter()
+//	beta.Use(httputil.RequireFlag("beta-api"))
+func RequireFlag(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !FlagEnabled(r.Context(), name) {
+				writeJSONError(w, r, NotFoundError{})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StaticFlags is a FlagProvider with fixed flags. Flags of the user take
+// precedence over flags of the tenant, which take precedence over
+// Default.
+type StaticFlags struct {
+	Default map[string]bool
+	Tenants map[string]map[string]bool
+	Users   map[string]map[string]bool
+}
+
+// FlagEnabled resolves name for the FlagClaims of ctx.
+func (f StaticFlags) FlagEnabled(ctx context.Context, name string) (bool, error) {
+	claims := FlagClaimsFromContext(ctx)
+	if claims.User != "" {
+		if on, found := f.Users[claims.User][name]; found {
+			return on, nil
+		}
+	}
+	if claims.Tenant != "" {
+		if on, found := f.Tenants[claims.Tenant][name]; found {
+			return on, nil
+		}
+	}
+	return f.Default[name], nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type failingFlagProvider struct{}
+
+func (failingFlagProvider) FlagEnabled(ctx context.Context, name string) (bool, error) {
+	return true, errors.New("unavailable")
+}
+
+func TestRequireFlag(t *testing.T) {
+	flags := StaticFlags{
+		Default: map[string]bool{"beta": false},
+		Tenants: map[string]map[string]bool{"acme": {"beta": true}},
+		Users:   map[string]map[string]bool{"bob": {"beta": false}},
+	}
+	claims := func(r *http.Request) FlagClaims {
+		return FlagClaims{Tenant: r.Header.Get("X-Tenant"), User: r.Header.Get("X-User")}
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello, beta"))
+	})
+
+	tests := []struct {
+		Provider FlagProvider
+		Tenant   string
+		User     string
+		Code     int
+	}{
+		{Provider: nil, Code: http.StatusNotFound},
+		{Provider: flags, Code: http.StatusNotFound},
+		{Provider: flags, Tenant: "acme", Code: http.StatusOK},
+		{Provider: flags, Tenant: "acme", User: "alice", Code: http.StatusOK},
+		{Provider: flags, Tenant: "acme", User: "bob", Code: http.StatusNotFound},
+		{Provider: flags, Tenant: "other", Code: http.StatusNotFound},
+		{Provider: failingFlagProvider{}, Code: http.StatusNotFound},
+	}
+	for i, tt := range tests {
+		var h http.Handler = RequireFlag("beta")(ok)
+		if tt.Provider != nil {
+			h = Flags(tt.Provider, claims)(h)
+		}
+		req := httptest.NewRequest("GET", "/beta", nil)
+		req.Header.Set("X-Tenant", tt.Tenant)
+		req.Header.Set("X-User", tt.User)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusNotFound {
+			if want, have := http.StatusNotFound, ErrorEnvelopeOf(t, w).Error.Code; want != have {
+				t.Errorf("#%d: want error code %d, have %d", i, want, have)
+			}
+		}
+	}
+}
+
+func TestFlagEnabled(t *testing.T) {
+	flags := StaticFlags{Default: map[string]bool{"new-search": true}}
+	h := Flags(flags, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if FlagEnabled(r.Context(), "new-search") {
+			w.Write([]byte("new"))
+		} else {
+			w.Write([]byte("old"))
+		}
+		if FlagEnabled(r.Context(), "unknown") {
+			t.Error("want unknown flag to be disabled")
+		}
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if want, have := "new", w.Body.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	ctx := WithFlagClaims(context.Background(), FlagClaims{Tenant: "acme"})
+	if want, have := "acme", FlagClaimsFromContext(ctx).Tenant; want != have {
+		t.Errorf("want tenant %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Limits restricts the forms parsed by ParseFormWithLimits. Zero fields
+// use the values of DefaultFormLimits.
+type Limits struct {
+	// MaxKeys is the maximum number of parameters in the query string
+	// and the body together.
+	MaxKeys int
+	// MaxValueLen is the maximum length of a key or value in bytes.
+	MaxValueLen int
+	// MaxMemory is the maximum size of a URL-encoded body, and the
+	// memory used for a multipart body, whose files are stored on disk.
+	MaxMemory int64
+}
+
+// DefaultFormLimits are the limits of the Form* helpers, unless set
+// with Config.FormLimits.
+var DefaultFormLimits = Limits{
+	MaxKeys:     1000,
+	MaxValueLen: 1 << 20,
+	MaxMemory:   32 << 20,
+}
+
+// withDefaults returns l with zero fields set to DefaultFormLimits.
+func (l Limits) withDefaults() Limits {
+	if l.MaxKeys <= 0 {
+		l.MaxKeys = DefaultFormLimits.MaxKeys
+	}
+	if l.MaxValueLen <= 0 {
+		l.MaxValueLen = DefaultFormLimits.MaxValueLen
+	}
+	if l.MaxMemory <= 0 {
+		l.MaxMemory = DefaultFormLimits.MaxMemory
+	}
+	return l
+}
+
+// FormLimitError indicates that a form exceeds the Limits of
+// ParseFormWithLimits.
+type FormLimitError struct {
+	// Limit is the exceeded limit: "keys", "value length", or "size".
+	Limit string
+	// Max is the value of the limit.
+	Max int64
+}
+
+// Error returns the error in text form.
+func (e FormLimitError) Error() string {
+	return fmt.Sprintf("Form exceeds the maximum %s of %d", e.Limit, e.Max)
+}
+
+// HTTPCode returns the HTTP status code of the error.
+func (FormLimitError) HTTPCode() int { return http.StatusRequestEntityTooLarge }
+
+// Unwrap returns RequestEntityTooLargeError.
+func (FormLimitError) Unwrap() error { return RequestEntityTooLargeError{} }
+
+// ParseFormWithLimits is like r.ParseMultipartForm, but returns
+// FormLimitError if the query string or the body exceed the limits.
+// The number of keys and the length of keys and values are checked
+// before the query string and URL-encoded bodies are parsed, and while
+// the parts of multipart bodies are read, so adversarial requests are
+// rejected cheaply. If a URL-encoded body exceeds the limits, it is
+// restored, so it can still be read by the handler.
+//
+// It does nothing if the form of r has already been parsed. Once it has
+// returned FormLimitError, it returns the same error on later calls,
+// even if a multipart body has been consumed. The Must* helpers of the
+// Form* family use it with Config.FormLimits, or DefaultFormLimits, and
+// panic with FormLimitError; the others return their default value.
+// Call it first to handle the error yourself.
+func ParseFormWithLimits(r *http.Request, limits Limits) error {
+	if r.Form != nil {
+		return nil
+	}
+	if b, ok := r.Body.(formLimitBody); ok {
+		return b.err
+	}
+	err := parseFormWithLimits(r, limits)
+	var e FormLimitError
+	if errors.As(err, &e) {
+		body := r.Body
+		if body == nil {
+			body = http.NoBody
+		}
+		r.Body = formLimitBody{ReadCloser: body, err: e}
+	}
+	return err
+}
+
+// formLimitBody is the body of a request whose form exceeds the limits.
+// It remembers the FormLimitError for later calls of ParseFormWithLimits.
+type formLimitBody struct {
+	io.ReadCloser
+	err FormLimitError
+}
+
+func parseFormWithLimits(r *http.Request, limits Limits) error {
+	limits = limits.withDefaults()
+	keys := 0
+	if err := checkFormData(r.URL.RawQuery, limits, &keys); err != nil {
+		return err
+	}
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	hasBody := r.Body != nil && r.Body != http.NoBody &&
+		(r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch)
+	switch {
+	case hasBody && ct == "application/x-www-form-urlencoded":
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, limits.MaxMemory+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > limits.MaxMemory {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			return FormLimitError{Limit: "size", Max: limits.MaxMemory}
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err := checkFormData(string(body), limits, &keys); err != nil {
+			return err
+		}
+		return r.ParseForm()
+	case hasBody && ct == "multipart/form-data":
+		return parseMultipartWithLimits(r, limits, keys)
+	}
+	return r.ParseForm()
+}
+
+// parseMultipartWithLimits reads the parts of the multipart body of r
+// one by one and checks them against the limits, with keys being the
+// number of keys of the query string. Files are stored like by
+// r.ParseMultipartForm, in memory or on disk.
+func parseMultipartWithLimits(r *http.Request, limits Limits, keys int) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	form := &multipart.Form{Value: make(map[string][]string), File: make(map[string][]*multipart.FileHeader)}
+	fail := func(err error) error {
+		form.RemoveAll()
+		return err
+	}
+	memory := limits.MaxMemory
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if keys++; keys > limits.MaxKeys {
+			return fail(FormLimitError{Limit: "keys", Max: int64(limits.MaxKeys)})
+		}
+		if err := checkFormLen(len(name), limits); err != nil {
+			return fail(err)
+		}
+		if part.FileName() == "" {
+			value, err := ioutil.ReadAll(io.LimitReader(part, int64(limits.MaxValueLen)+1))
+			if err != nil {
+				return fail(err)
+			}
+			if err := checkFormLen(len(value), limits); err != nil {
+				return fail(err)
+			}
+			if memory -= int64(len(value)); memory < 0 {
+				return fail(FormLimitError{Limit: "size", Max: limits.MaxMemory})
+			}
+			form.Value[name] = append(form.Value[name], string(value))
+			continue
+		}
+		fh, err := readFormFile(part, memory)
+		if err != nil {
+			return fail(err)
+		}
+		if fh.Size < memory {
+			memory -= fh.Size
+		} else {
+			memory = 0
+		}
+		form.File[name] = append(form.File[name], fh)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return fail(err)
+	}
+	for k, v := range form.Value {
+		r.Form[k] = append(r.Form[k], v...)
+		r.PostForm[k] = append(r.PostForm[k], v...)
+	}
+	r.MultipartForm = form
+	return nil
+}
+
+// readFormFile stores the file of part in memory, up to maxMemory
+// bytes, or else on disk. The part is passed through a multipart
+// message of its own, so multipart.Reader.ReadForm stores it.
+func readFormFile(part *multipart.Part, maxMemory int64) (*multipart.FileHeader, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		w, err := mw.CreatePart(part.Header)
+		if err == nil {
+			_, err = io.Copy(w, part)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	form, err := multipart.NewReader(pr, mw.Boundary()).ReadForm(maxMemory)
+	if err != nil {
+		return nil, err
+	}
+	for _, files := range form.File {
+		if len(files) > 0 {
+			return files[0], nil
+		}
+	}
+	return nil, errors.New("httputil: multipart file part not found")
+}
+
+// checkFormData checks the URL-encoded data against the limits, without
+// parsing it. keys is incremented by the number of keys found.
+func checkFormData(data string, limits Limits, keys *int) error {
+	for data != "" {
+		var pair string
+		pair, data, _ = strings.Cut(data, "&")
+		if pair == "" {
+			continue
+		}
+		if *keys++; *keys > limits.MaxKeys {
+			return FormLimitError{Limit: "keys", Max: int64(limits.MaxKeys)}
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		if err := checkFormLen(unescapedLen(k), limits); err != nil {
+			return err
+		}
+		if err := checkFormLen(unescapedLen(v), limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFormLen checks the length n of a key or value.
+func checkFormLen(n int, limits Limits) error {
+	if n > limits.MaxValueLen {
+		return FormLimitError{Limit: "value length", Max: int64(limits.MaxValueLen)}
+	}
+	return nil
+}
+
+// unescapedLen returns the length of the URL-encoded s after unescaping.
+func unescapedLen(s string) int {
+	return len(s) - 2*strings.Count(s, "%")
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseFormWithLimits(t *testing.T) {
+	limits := Limits{MaxKeys: 3, MaxValueLen: 8, MaxMemory: 64}
+	tests := []struct {
+		Target string
+		Body   string
+		Limit  string
+	}{
+		{Target: "/?a=1&b=2", Body: "c=3"},
+		{Target: "/?a=1&b=2", Body: "c=3&d=4", Limit: "keys"},
+		{Target: "/?a=1&b=2&c=3&d=4", Limit: "keys"},
+		{Target: "/?a=1&&&b=2", Body: "c=3"},
+		{Target: "/?q=12345678"},
+		{Target: "/?q=123456789", Limit: "value length"},
+		{Target: "/?q=%41%42%43%44%45%46%47%48"},
+		{Body: "q=" + strings.Repeat("%41", 9), Limit: "value length"},
+		{Body: strings.Repeat("x", 9) + "=1", Limit: "value length"},
+		{Body: "q=1&" + strings.Repeat("&", 64), Limit: "size"},
+	}
+	for i, tt := range tests {
+		target := tt.Target
+		if target == "" {
+			target = "/"
+		}
+		r := httptest.NewRequest("POST", target, strings.NewReader(tt.Body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		err := ParseFormWithLimits(r, limits)
+		if tt.Limit == "" {
+			if err != nil {
+				t.Errorf("#%d: want no error, have %v", i, err)
+			}
+			continue
+		}
+		var e FormLimitError
+		if !errors.As(err, &e) {
+			t.Errorf("#%d: want FormLimitError, have %v", i, err)
+			continue
+		}
+		if want, have := tt.Limit, e.Limit; want != have {
+			t.Errorf("#%d: want limit %q, have %q", i, want, have)
+		}
+		if !errors.Is(err, RequestEntityTooLargeError{}) {
+			t.Errorf("#%d: want RequestEntityTooLargeError, have %v", i, err)
+		}
+	}
+}
+
+func TestParseFormWithLimitsBody(t *testing.T) {
+	r := httptest.NewRequest("POST", "/?a=1", strings.NewReader("b=2&a=3"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := ParseFormWithLimits(r, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"3", "1"}, r.Form["a"]; len(have) != 2 || want[0] != have[0] || want[1] != have[1] {
+		t.Errorf("want a=%v, have %v", want, have)
+	}
+	if want, have := "2", r.PostForm.Get("b"); want != have {
+		t.Errorf("want b=%q, have %q", want, have)
+	}
+}
+
+func TestParseFormWithLimitsMultipart(t *testing.T) {
+	newRequest := func(n int) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for i := 0; i < n; i++ {
+			mw.WriteField("name", "value")
+		}
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write([]byte("hello"))
+		mw.Close()
+		r := httptest.NewRequest("POST", "/?a=1", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+	limits := Limits{MaxKeys: 4}
+	r := newRequest(2)
+	if err := ParseFormWithLimits(r, limits); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "value", r.FormValue("name"); want != have {
+		t.Errorf("want name=%q, have %q", want, have)
+	}
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := ioutil.ReadAll(f); fh.Filename != "a.txt" || string(data) != "hello" {
+		t.Errorf("want file a.txt with %q, have %s with %q", "hello", fh.Filename, data)
+	}
+	if err := ParseFormWithLimits(newRequest(3), limits); !errors.As(err, &FormLimitError{}) {
+		t.Errorf("want FormLimitError, have %v", err)
+	}
+
+	// Values are checked while they are read
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", strings.Repeat("x", 9))
+	mw.Close()
+	r = httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	err = ParseFormWithLimits(r, Limits{MaxValueLen: 8})
+	if e := (FormLimitError{}); !errors.As(err, &e) || e.Limit != "value length" {
+		t.Errorf("want FormLimitError for the value length, have %v", err)
+	}
+}
+
+func TestParseFormWithLimitsRestoresBody(t *testing.T) {
+	body := "a=1&" + strings.Repeat("b", 64)
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := ParseFormWithLimits(r, Limits{MaxMemory: 16}); !errors.As(err, &FormLimitError{}) {
+		t.Fatalf("want FormLimitError, have %v", err)
+	}
+	if data, _ := ioutil.ReadAll(r.Body); string(data) != body {
+		t.Errorf("want body to be restored, have %q", data)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := ParseFormWithLimits(r, Limits{MaxValueLen: 16}); !errors.As(err, &FormLimitError{}) {
+		t.Fatalf("want FormLimitError, have %v", err)
+	}
+	if data, _ := ioutil.ReadAll(r.Body); string(data) != body {
+		t.Errorf("want body to be restored, have %q", data)
+	}
+}
+
+func TestFormHelpersWithLimits(t *testing.T) {
+	cfg := CurrentConfig()
+	cfg.FormLimits = Limits{MaxKeys: 2}
+	h := WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		WriteJSON(w, MustFormInt(r, "a"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader("a=1&b=2"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(w, r)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/", strings.NewReader("a=1&b=2&c=3"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(w, r)
+	if want, have := http.StatusRequestEntityTooLarge, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	if want, have := "Form exceeds the maximum keys of 2", ErrorEnvelopeOf(t, w).Error.Message; want != have {
+		t.Errorf("want message %q, have %q", want, have)
+	}
+
+	// The other helpers return their default value
+	r = httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a=1&", 1500)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for i := 0; i < 2; i++ {
+		if want, have := 42, FormInt(r, "a", 42); want != have {
+			t.Errorf("#%d: want %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestFormHelpersWithLimitsMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i := 0; i < 3; i++ {
+		mw.WriteField("a", "1")
+	}
+	mw.Close()
+	cfg := CurrentConfig()
+	cfg.FormLimits = Limits{MaxKeys: 2}
+	var r *http.Request
+	WithConfig(cfg)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r = req
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", &body))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	// The error is returned again after the body has been consumed
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if e := (FormLimitError{}); !errors.As(err, &e) || e.Limit != "keys" {
+					t.Errorf("#%d: want FormLimitError for the keys, have %v", i, err)
+				}
+			}()
+			MustFormInt(r, "a")
+		}()
+	}
+	if want, have := 42, FormInt(r, "a", 42); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+	if err := ParseFormWithLimits(r, Limits{}); !errors.As(err, &FormLimitError{}) {
+		t.Errorf("want FormLimitError, have %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type forwardHeadersContextKey struct{}
+
+// DefaultForwardHeaders are the headers forwarded by ForwardHeaders if
+// no names are given: the request id, the tenant, and W3C baggage.
+var DefaultForwardHeaders = []string{"X-Request-Id", "X-Tenant", "Baggage"}
+
+// ForwardHeaders returns a middleware that keeps the given headers of
+// inbound requests in the request context, so that ForwardHeadersTransport
+// adds them to outbound requests made with that context. Only the
+// headers in the allow-list are forwarded; with no names,
+// DefaultForwardHeaders are used.
+//
+// If X-Request-Id is in the allow-list but not in the request, the id
+// of SlogMiddleware is forwarded, so use ForwardHeaders after it.
+//
+// Example:
+//
+//	client := httputil.NewHTTPClient(httputil.InternalService.With(httputil.ForwardHeadersTransport))
+//	h := httputil.ForwardHeaders("X-Request-Id", "X-Tenant")(router)
+//	...
+//	req, _ := http.NewRequestWithContext(r.Context(), "GET", url, nil)
+//	res, err := client.Do(req) // with X-Request-Id and X-Tenant of r
+func ForwardHeaders(names ...string) func(http.Handler) http.Handler {
+	if len(names) == 0 {
+		names = DefaultForwardHeaders
+	}
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = http.CanonicalHeaderKey(name)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := make(http.Header)
+			for _, key := range keys {
+				if values := r.Header.Values(key); len(values) > 0 {
+					h[key] = append([]string(nil), values...)
+				}
+			}
+			if _, ok := h["X-Request-Id"]; !ok && containsString(keys, "X-Request-Id") {
+				if id := RequestIDFromContext(r.Context()); id != "" {
+					h.Set("X-Request-Id", id)
+				}
+			}
+			if len(h) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithForwardedHeaders(r.Context(), h)))
+		})
+	}
+}
+
+// WithForwardedHeaders returns a copy of ctx with headers to be
+// forwarded by ForwardHeadersTransport.
+func WithForwardedHeaders(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, forwardHeadersContextKey{}, h)
+}
+
+// ForwardedHeadersFromContext returns the headers to be forwarded,
+// as kept by ForwardHeaders, or nil if there are none.
+func ForwardedHeadersFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(forwardHeadersContextKey{}).(http.Header)
+	return h
+}
+
+// ForwardHeadersTransport wraps a transport to add the headers kept by
+// ForwardHeaders in the request context to outbound requests, e.g. for
+// use in Profile.Transports. Headers already set in the outbound request
+// are left alone.
+//
+// ForwardHeadersTransport forwards the headers to all hosts, so only use
+// it for clients of internal services. Use ForwardHeadersTransportTo if
+// the client also talks to third parties.
+func ForwardHeadersTransport(next http.RoundTripper) http.RoundTripper {
+	return forwardHeadersTransport(next, nil)
+}
+
+// ForwardHeadersTransportTo is like ForwardHeadersTransport, but only
+// forwards the headers to the given hosts, so e.g. tenants and baggage
+// don't leak to third parties. A host with a leading dot, e.g.
+// ".svc.cluster.local", matches all of its subdomains. Ports are ignored.
+//
+// Example:
+//
+//	client := httputil.NewHTTPClient(httputil.InternalService.With(
+//	  httputil.ForwardHeadersTransportTo("billing", ".svc.cluster.local")))
+func ForwardHeadersTransportTo(hosts ...string) func(http.RoundTripper) http.RoundTripper {
+	allowed := make([]string, len(hosts))
+	for i, host := range hosts {
+		allowed[i] = strings.ToLower(host)
+	}
+	allow := func(host string) bool {
+		host = strings.ToLower(host)
+		for _, h := range allowed {
+			if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return forwardHeadersTransport(next, allow)
+	}
+}
+
+// forwardHeadersTransport adds the forwarded headers to the requests
+// to hosts permitted by allow, or to all hosts if allow is nil.
+func forwardHeadersTransport(next http.RoundTripper, allow func(host string) bool) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		h := ForwardedHeadersFromContext(r.Context())
+		if len(h) == 0 || (allow != nil && !allow(r.URL.Hostname())) {
+			return next.RoundTrip(r)
+		}
+		r2 := r.Clone(r.Context())
+		for key, values := range h {
+			if _, ok := r2.Header[key]; !ok {
+				// Copy, so changes to r2 don't affect other requests
+				r2.Header[key] = append([]string(nil), values...)
+			}
+		}
+		return next.RoundTrip(r2)
+	})
+}
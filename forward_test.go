@@ -0,0 +1,106 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardHeaders(t *testing.T) {
+	var outbound http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header
+	}))
+	defer backend.Close()
+	client := NewHTTPClient(InternalService.With(ForwardHeadersTransport))
+
+	h := ForwardHeaders()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", backend.URL, nil)
+		req.Header.Set("X-Tenant", "override")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	r.Header.Set("X-Tenant", "acme")
+	r.Header.Set("Baggage", "userId=alice,serverNode=DF%2028")
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	tests := map[string]string{
+		"X-Request-Id":  "req-1",
+		"X-Tenant":      "override",
+		"Baggage":       "userId=alice,serverNode=DF%2028",
+		"Authorization": "",
+	}
+	for name, want := range tests {
+		if have := outbound.Get(name); want != have {
+			t.Errorf("want %s %q, have %q", name, want, have)
+		}
+	}
+}
+
+func TestForwardHeadersRequestID(t *testing.T) {
+	var forwarded http.Header
+	h := SlogMiddleware(slog.Default())(ForwardHeaders("x-request-id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = ForwardedHeadersFromContext(r.Context())
+	})))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if want, have := w.Header().Get("X-Request-Id"), forwarded.Get("X-Request-Id"); want == "" || want != have {
+		t.Errorf("want generated request id %q, have %q", want, have)
+	}
+
+	h = ForwardHeaders("X-Tenant")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = ForwardedHeadersFromContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if forwarded != nil {
+		t.Errorf("want no forwarded headers, have %v", forwarded)
+	}
+}
+
+func TestForwardHeadersTransportTo(t *testing.T) {
+	var tenant string
+	next := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		tenant = r.Header.Get("X-Tenant")
+		// Changes by later transports must not affect other requests
+		r.Header.Add("X-Tenant", "changed")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})
+	tr := ForwardHeadersTransportTo("billing", ".svc.cluster.local")(next)
+	ctx := WithForwardedHeaders(context.Background(), http.Header{"X-Tenant": {"acme"}})
+
+	tests := []struct {
+		URL  string
+		Want string
+	}{
+		{URL: "http://billing/invoices", Want: "acme"},
+		{URL: "http://BILLING:8080/invoices", Want: "acme"},
+		{URL: "http://orders.svc.cluster.local/orders", Want: "acme"},
+		{URL: "https://api.example.com/", Want: ""},
+		{URL: "http://evilsvc.cluster.local/", Want: ""},
+		{URL: "http://billing.example.com/", Want: ""},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequestWithContext(ctx, "GET", tt.URL, nil)
+		if _, err := tr.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := tt.Want, tenant; want != have {
+			t.Errorf("#%d: want X-Tenant %q, have %q", i, want, have)
+		}
+	}
+	if want, have := []string{"acme"}, ForwardedHeadersFromContext(ctx)["X-Tenant"]; len(have) != 1 || want[0] != have[0] {
+		t.Errorf("want forwarded headers to be unchanged, have %q", have)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// countryCodes are the officially assigned ISO 3166-1 alpha-2 codes.
+var countryCodes = makeCodeSet(`
+AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL
+BM BN BO BQ BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV
+CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR GA GB GD
+GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM
+IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK
+LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW
+MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR
+PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS
+ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG UM US UY
+UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`)
+
+// currencyCodes are the active ISO 4217 currency codes.
+var currencyCodes = makeCodeSet(`
+AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV
+BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUP CVE CZK
+DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL
+HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT
+LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR
+MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF
+SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP
+TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XAG XAU
+XBA XBB XBC XBD XCD XCG XDR XOF XPD XPF XPT XSU XTS XUA XXX YER ZAR ZMW ZWG`)
+
+func makeCodeSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, code := range strings.Fields(s) {
+		set[code] = true
+	}
+	return set
+}
+
+// languageTagRe matches well-formed BCP 47 language tags (RFC 5646),
+// excluding grandfathered tags.
+var languageTagRe = regexp.MustCompile(`(?i)^(` +
+	`[a-z]{2,3}(-[a-z]{3}){0,3}|[a-z]{4,8})` + // language
+	`(-[a-z]{4})?` + // script
+	`(-([a-z]{2}|[0-9]{3}))?` + // region
+	`(-([a-z0-9]{5,8}|[0-9][a-z0-9]{3}))*` + // variants
+	`(-[0-9a-wyz](-[a-z0-9]{2,8})+)*` + // extensions
+	`(-x(-[a-z0-9]{1,8})+)?$`) // private use
+
+// IsCountryCode returns true if s is an ISO 3166-1 alpha-2 country code,
+// e.g. "DE". Codes are case-sensitive.
+func IsCountryCode(s string) bool { return countryCodes[s] }
+
+// IsCurrencyCode returns true if s is an ISO 4217 currency code,
+// e.g. "EUR". Codes are case-sensitive.
+func IsCurrencyCode(s string) bool { return currencyCodes[s] }
+
+// CanonicalLanguageTag returns the BCP 47 language tag s in its canonical
+// casing, e.g. "zh-Hant-TW" for "ZH-hant-tw". It returns false if s is
+// not a well-formed language tag.
+func CanonicalLanguageTag(s string) (string, bool) {
+	if !languageTagRe.MatchString(s) {
+		return "", false
+	}
+	parts := strings.Split(strings.ToLower(s), "-")
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i-1]) == 1 {
+			// Everything after an extension or private use singleton is lowercase
+			break
+		}
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			if parts[i][0] >= 'a' && parts[i][0] <= 'z' {
+				parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+			}
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
+
+// parseLatLon parses latitude and longitude and checks their ranges.
+func parseLatLon(latValue, lonValue string) (float64, float64, bool) {
+	lat, ok := parseCoordinate(latValue, 90)
+	if !ok {
+		return 0, 0, false
+	}
+	lon, ok := parseCoordinate(lonValue, 180)
+	if !ok {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parseCoordinate parses a coordinate in the range [-max,max].
+// NaN and infinity, which ParseFloat accepts, are rejected explicitly,
+// as NaN fails every comparison and would pass the range check.
+func parseCoordinate(value string, max float64) (float64, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || f < -max || f > max {
+		return 0, false
+	}
+	return f, true
+}
+
+// MustQueryLatLon checks if the request r has query strings with
+// the specified keys that can be converted to a latitude in the range
+// [-90,90] and a longitude in the range [-180,180].
+// If is doesn't, it will panic.
+func MustQueryLatLon(r *http.Request, latKey, lonKey string) (lat, lon float64) {
+	latValue, lonValue := mustQueryValue(r, latKey), mustQueryValue(r, lonKey)
+	if latValue == "" {
+		panic(MissingParameterError(latKey))
+	}
+	if lonValue == "" {
+		panic(MissingParameterError(lonKey))
+	}
+	lat, ok := parseCoordinate(latValue, 90)
+	if !ok {
+		panic(InvalidParameterError(latKey))
+	}
+	lon, ok = parseCoordinate(lonValue, 180)
+	if !ok {
+		panic(InvalidParameterError(lonKey))
+	}
+	return lat, lon
+}
+
+// QueryLatLon checks if the request r has query strings with
+// the specified keys that can be converted to a latitude in the range
+// [-90,90] and a longitude in the range [-180,180].
+// If is doesn't, it will return ok = false.
+func QueryLatLon(r *http.Request, latKey, lonKey string) (lat, lon float64, ok bool) {
+	latValue, lonValue := mustQueryValue(r, latKey), mustQueryValue(r, lonKey)
+	if latValue == "" || lonValue == "" {
+		return 0, 0, false
+	}
+	return parseLatLon(latValue, lonValue)
+}
+
+// MustQueryCountryCode checks if the request r has a query string with
+// the specified key that is an ISO 3166-1 alpha-2 country code. The code
+// is returned in upper case. If is doesn't, it will panic.
+func MustQueryCountryCode(r *http.Request, key string) string {
+	v := strings.ToUpper(mustQueryValue(r, key))
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	if !IsCountryCode(v) {
+		panic(InvalidParameterError(key))
+	}
+	return v
+}
+
+// QueryCountryCode checks if the request r has a query string with
+// the specified key that is an ISO 3166-1 alpha-2 country code. The code
+// is returned in upper case. If is doesn't, it will return defaultValue.
+// It panics with InvalidParameterError if the value is not a country code.
+func QueryCountryCode(r *http.Request, key string, defaultValue string) string {
+	v := strings.ToUpper(mustQueryValue(r, key))
+	if v == "" {
+		return defaultValue
+	}
+	if !IsCountryCode(v) {
+		panic(InvalidParameterError(key))
+	}
+	return v
+}
+
+// MustQueryCurrency checks if the request r has a query string with
+// the specified key that is an ISO 4217 currency code. The code
+// is returned in upper case. If is doesn't, it will panic.
+func MustQueryCurrency(r *http.Request, key string) string {
+	v := strings.ToUpper(mustQueryValue(r, key))
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	if !IsCurrencyCode(v) {
+		panic(InvalidParameterError(key))
+	}
+	return v
+}
+
+// QueryCurrency checks if the request r has a query string with
+// the specified key that is an ISO 4217 currency code. The code
+// is returned in upper case. If is doesn't, it will return defaultValue.
+// It panics with InvalidParameterError if the value is not a currency code.
+func QueryCurrency(r *http.Request, key string, defaultValue string) string {
+	v := strings.ToUpper(mustQueryValue(r, key))
+	if v == "" {
+		return defaultValue
+	}
+	if !IsCurrencyCode(v) {
+		panic(InvalidParameterError(key))
+	}
+	return v
+}
+
+// MustQueryLanguageTag checks if the request r has a query string with
+// the specified key that is a well-formed BCP 47 language tag. The tag
+// is returned in canonical casing. If is doesn't, it will panic.
+func MustQueryLanguageTag(r *http.Request, key string) string {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	tag, ok := CanonicalLanguageTag(v)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return tag
+}
+
+// QueryLanguageTag checks if the request r has a query string with
+// the specified key that is a well-formed BCP 47 language tag. The tag
+// is returned in canonical casing. If is doesn't, it will return defaultValue.
+// It panics with InvalidParameterError if the value is malformed.
+func QueryLanguageTag(r *http.Request, key string, defaultValue string) string {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	tag, ok := CanonicalLanguageTag(v)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return tag
+}
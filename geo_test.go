@@ -0,0 +1,128 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMustQueryLatLon(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		lat, lon := MustQueryLatLon(r, "lat", "lon")
+		fmt.Fprintf(w, "%g,%g", lat, lon)
+	}
+
+	tests := []struct {
+		Query string
+		Code  int
+		Body  string
+	}{
+		{Query: "lat=48.137&lon=11.575", Code: http.StatusOK, Body: "48.137,11.575"},
+		{Query: "lat=-90&lon=180", Code: http.StatusOK, Body: "-90,180"},
+		{Query: "lat=91&lon=11.575", Code: http.StatusBadRequest},
+		{Query: "lat=48.137&lon=-181", Code: http.StatusBadRequest},
+		{Query: "lat=48.137", Code: http.StatusBadRequest},
+		{Query: "lat=abc&lon=1", Code: http.StatusBadRequest},
+		{Query: "lat=NaN&lon=11.575", Code: http.StatusBadRequest},
+		{Query: "lat=48.137&lon=NaN", Code: http.StatusBadRequest},
+		{Query: "lat=Inf&lon=11.575", Code: http.StatusBadRequest},
+		{Query: "lat=48.137&lon=-Inf", Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/?"+tt.Query, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		}
+	}
+}
+
+func TestQueryLatLon(t *testing.T) {
+	tests := []struct {
+		Query string
+		OK    bool
+	}{
+		{Query: "lat=48.137&lon=11.575", OK: true},
+		{Query: "lat=NaN&lon=11.575"},
+		{Query: "lat=48.137&lon=nan"},
+		{Query: "lat=+Inf&lon=11.575"},
+		{Query: "lat=48.137&lon=-infinity"},
+	}
+	for i, tt := range tests {
+		_, _, ok := QueryLatLon(httptest.NewRequest("GET", "/?"+tt.Query, nil), "lat", "lon")
+		if want, have := tt.OK, ok; want != have {
+			t.Errorf("#%d: want ok=%v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestQueryCountryCodeAndCurrency(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?country=de&currency=eur&bad_country=XX&bad_currency=ABC&bad_lang=en_US!", nil)
+	if want, have := "DE", QueryCountryCode(req, "country", ""); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "US", QueryCountryCode(req, "missing", "US"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "EUR", QueryCurrency(req, "currency", ""); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "USD", QueryCurrency(req, "missing", "USD"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "en", QueryLanguageTag(req, "missing", "en"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// Malformed values are rejected, like in the other non-Must helpers
+	for i, f := range []func(){
+		func() { QueryCountryCode(req, "bad_country", "US") },
+		func() { QueryCurrency(req, "bad_currency", "USD") },
+		func() { QueryLanguageTag(req, "bad_lang", "en") },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(InvalidParameterError); !ok {
+					t.Errorf("#%d: want panic with InvalidParameterError", i)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestCanonicalLanguageTag(t *testing.T) {
+	tests := []struct {
+		Tag  string
+		Want string
+		OK   bool
+	}{
+		{Tag: "en", Want: "en", OK: true},
+		{Tag: "EN-us", Want: "en-US", OK: true},
+		{Tag: "zh-hant-tw", Want: "zh-Hant-TW", OK: true},
+		{Tag: "es-419", Want: "es-419", OK: true},
+		{Tag: "de-DE-u-co-phonebk", Want: "de-DE-u-co-phonebk", OK: true},
+		{Tag: "en-x-US", Want: "en-x-us", OK: true},
+		{Tag: "", OK: false},
+		{Tag: "e", OK: false},
+		{Tag: "en_US", OK: false},
+		{Tag: "en--US", OK: false},
+	}
+	for i, tt := range tests {
+		tag, ok := CanonicalLanguageTag(tt.Tag)
+		if ok != tt.OK || tag != tt.Want {
+			t.Errorf("#%d: want %q/%v, have %q/%v", i, tt.Want, tt.OK, tag, ok)
+		}
+	}
+}
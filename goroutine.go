@@ -0,0 +1,62 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"fmt"
+)
+
+// Go runs fn in a new goroutine and returns a channel that receives the
+// error returned by fn, or nil, and is closed afterwards. Panics in fn
+// are recovered and sent as errors, see SafeFunc. Use it instead of a
+// plain go statement in handlers, as RecoverJSON only protects the
+// goroutine serving the request, and a panic in any other goroutine
+// crashes the process.
+//
+// Example:
+//
+//	func Handler(w http.ResponseWriter, r *http.Request) {
+//	  defer httputil.RecoverJSON(w, r)
+//	  errc := httputil.Go(r.Context(), func(ctx context.Context) error {
+//	    return loadUser(ctx)
+//	  })
+//	  ...
+//	  if err := <-errc; err != nil {
+//	    panic(err)
+//	  }
+//	}
+func Go(ctx context.Context, fn func(ctx context.Context) error) <-chan error {
+	errc := make(chan error, 1)
+	safe := SafeFunc(fn)
+	go func() {
+		defer close(errc)
+		errc <- safe(ctx)
+	}()
+	return errc
+}
+
+// SafeFunc returns a func that calls fn and recovers from panics in fn.
+// If fn panics with an error, e.g. with MissingParameterError from one
+// of the Must* helpers, that error is returned. Panics with other values
+// are returned as ServerError.
+func SafeFunc(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = panicError(v)
+			}
+		}()
+		return fn(ctx)
+	}
+}
+
+// panicError converts the recovered value v into an error.
+func panicError(v interface{}) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+	return ServerError(fmt.Sprint(v))
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSafeFunc(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		Func func(ctx context.Context) error
+		Err  error
+	}{
+		{
+			Func: func(ctx context.Context) error { return nil },
+		},
+		{
+			Func: func(ctx context.Context) error { return errBoom },
+			Err:  errBoom,
+		},
+		{
+			Func: func(ctx context.Context) error { panic(MissingParameterError("id")) },
+			Err:  MissingParameterError("id"),
+		},
+		{
+			Func: func(ctx context.Context) error { panic("kaboom") },
+			Err:  ServerError("kaboom"),
+		},
+	}
+	for i, tt := range tests {
+		err := SafeFunc(tt.Func)(context.Background())
+		if want, have := tt.Err, err; want != have {
+			t.Errorf("#%d: want %v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestGo(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		errc := Go(r.Context(), func(ctx context.Context) error {
+			var m map[string]int
+			m["crash"] = 1 // assignment to nil map panics
+			return nil
+		})
+		if err := <-errc; err != nil {
+			panic(err)
+		}
+	}
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/", nil))
+	if want, have := http.StatusInternalServerError, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+
+	errc := Go(context.Background(), func(ctx context.Context) error { return nil })
+	if err := <-errc; err != nil {
+		t.Errorf("want no error, have %v", err)
+	}
+	if _, ok := <-errc; ok {
+		t.Error("want channel to be closed")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// GraphQLRequest is a GraphQL request as specified in GraphQL-over-HTTP.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// ParseGraphQLRequest extracts a GraphQL request from r. It supports
+// GET requests with the query, operationName, variables, and extensions
+// in the query string, POST requests with a JSON body, and POST requests
+// with a body of type application/graphql that contains the query only.
+//
+// Mutations are rejected with InvalidMethodError in GET requests, and
+// unsupported content types are rejected with UnsupportedMediaTypeError.
+// The operation selected by operationName is checked, so neither
+// comments nor documents with several operations sneak a mutation
+// through. If the document has several operations and no operationName,
+// GET requests are rejected if any of them is a mutation.
+func ParseGraphQLRequest(r *http.Request) (*GraphQLRequest, error) {
+	var req GraphQLRequest
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return nil, InvalidParameterError("variables")
+			}
+		}
+		if v := q.Get("extensions"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+				return nil, InvalidParameterError("extensions")
+			}
+		}
+		if graphQLHasMutation(req.Query, req.OperationName) {
+			return nil, InvalidMethodError{}
+		}
+	case "POST":
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mt {
+		case "application/json":
+			if err := ReadJSON(r, &req); err != nil {
+				return nil, InvalidJSONError{err}
+			}
+		case "application/graphql":
+			data, err := ioutil.ReadAll(io.LimitReader(r.Body, 8<<20))
+			if err != nil {
+				return nil, InvalidParameterError("query")
+			}
+			req.Query = string(data)
+		default:
+			return nil, UnsupportedMediaTypeError{}
+		}
+	default:
+		return nil, InvalidMethodError{}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, MissingParameterError("query")
+	}
+	return &req, nil
+}
+
+// graphQLHasMutation returns true if the operation of the document
+// query selected by operationName is a mutation.
+func graphQLHasMutation(query, operationName string) bool {
+	ops := graphQLOperations(query)
+	if operationName == "" && len(ops) == 1 {
+		return ops[0].typ == "mutation"
+	}
+	for _, op := range ops {
+		if (operationName == "" || op.name == operationName) && op.typ == "mutation" {
+			return true
+		}
+	}
+	return false
+}
+
+type graphQLOperation struct {
+	typ  string // query, mutation, or subscription
+	name string
+}
+
+// graphQLOperations returns the operations defined in the document
+// query. It tokenizes the document as specified in the GraphQL spec,
+// Section 2.1, skipping white space, commas, comments, and strings, and
+// inspects the definitions at the top level only.
+func graphQLOperations(query string) []graphQLOperation {
+	var (
+		ops        []graphQLOperation
+		braces     int
+		parens     int
+		definition = true // the next token starts a definition
+		named      bool   // the next name is the name of the last operation
+	)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' && query[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], `"""`):
+			i += 3
+			for i < len(query) && !strings.HasPrefix(query[i:], `"""`) {
+				if strings.HasPrefix(query[i:], `\"""`) {
+					i += 4
+					continue
+				}
+				i++
+			}
+			i += 3
+			named = false
+		case c == '"':
+			for i++; i < len(query) && query[i] != '"' && query[i] != '\n'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			i++
+			named = false
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || query[j] >= '0' && query[j] <= '9' || query[j] >= 'A' && query[j] <= 'Z' || query[j] >= 'a' && query[j] <= 'z') {
+				j++
+			}
+			name := query[i:j]
+			i = j
+			switch {
+			case named:
+				ops[len(ops)-1].name = name
+				named = false
+			case definition:
+				if name == "query" || name == "mutation" || name == "subscription" {
+					ops = append(ops, graphQLOperation{typ: name})
+					named = true
+				}
+				definition = false
+			}
+		default:
+			switch c {
+			case '{':
+				if definition && braces == 0 && parens == 0 {
+					// Shorthand query
+					ops = append(ops, graphQLOperation{typ: "query"})
+				}
+				definition = false
+				braces++
+			case '}':
+				braces--
+				if braces == 0 && parens == 0 {
+					definition = true
+				}
+			case '(':
+				parens++
+			case ')':
+				parens--
+			}
+			named = false
+			i++
+		}
+	}
+	return ops
+}
+
+// GraphQLError is an entry in the errors array of a GraphQL result.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Error returns the error in text form.
+func (e *GraphQLError) Error() string { return e.Message }
+
+// WriteGraphQLResult writes a GraphQL result with data and errs into w.
+// Errors that are not a *GraphQLError are converted, with the HTTP status
+// code of the error (see WriteJSONError) turned into extensions.code,
+// e.g. "NOT_FOUND" for NotFoundError, and the error details put into
+// extensions.details. The result is always written with HTTP status 200.
+func WriteGraphQLResult(w http.ResponseWriter, data interface{}, errs []error) {
+	result := make(map[string]interface{})
+	if data != nil {
+		result["data"] = data
+	}
+	if len(errs) > 0 {
+		gqlErrs := make([]*GraphQLError, len(errs))
+		for i, err := range errs {
+			gqlErrs[i] = toGraphQLError(err)
+		}
+		result["errors"] = gqlErrs
+	}
+	WriteJSONCode(w, http.StatusOK, result)
+}
+
+func toGraphQLError(err error) *GraphQLError {
+	if e, ok := err.(*GraphQLError); ok {
+		return e
+	}
+	code := 500
+	if i, ok := err.(httpCoder); ok {
+		code = i.HTTPCode()
+	}
+	e := &GraphQLError{
+		Message: fmt.Sprint(err),
+		Extensions: map[string]interface{}{
+			"code": graphQLErrorCode(code),
+		},
+	}
+	if i, ok := err.(httpErrorDetails); ok {
+		if details := i.ErrorDetails(); len(details) > 0 {
+			e.Extensions["details"] = details
+		}
+	}
+	return e
+}
+
+// graphQLErrorCode returns the HTTP status text in upper snake case,
+// e.g. "NOT_FOUND" for 404.
+func graphQLErrorCode(code int) string {
+	text := http.StatusText(code)
+	if text == "" {
+		return "INTERNAL_SERVER_ERROR"
+	}
+	text = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+	return strings.ToUpper(text)
+}
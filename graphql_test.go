@@ -0,0 +1,179 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphQLRequest(t *testing.T) {
+	const query = `query Hero($id: ID!) { hero(id: $id) { name } }`
+	tests := []struct {
+		Method      string
+		URL         string
+		ContentType string
+		Body        string
+		Err         error
+		Variables   int
+	}{
+		{
+			Method:    "GET",
+			URL:       "/graphql?query=" + url.QueryEscape(query) + "&variables=" + url.QueryEscape(`{"id":"1"}`),
+			Variables: 1,
+		},
+		{
+			Method:      "POST",
+			URL:         "/graphql",
+			ContentType: "application/json",
+			Body:        `{"query":` + `"query Hero($id: ID!) { hero(id: $id) { name } }"` + `,"operationName":"Hero","variables":{"id":"1"}}`,
+			Variables:   1,
+		},
+		{
+			Method:      "POST",
+			URL:         "/graphql",
+			ContentType: "application/graphql",
+			Body:        query,
+		},
+		{
+			Method: "GET",
+			URL:    "/graphql?query=" + url.QueryEscape(`mutation { like(id: 1) }`),
+			Err:    InvalidMethodError{},
+		},
+		{
+			Method: "GET",
+			URL:    "/graphql?query=" + url.QueryEscape("# comment\nmutation { like(id: 1) }"),
+			Err:    InvalidMethodError{},
+		},
+		{
+			Method: "GET",
+			URL:    "/graphql?query=" + url.QueryEscape(`query A { hero { name } } mutation B { like(id: 1) }`) + "&operationName=B",
+			Err:    InvalidMethodError{},
+		},
+		{
+			Method: "GET",
+			URL:    "/graphql?query=" + url.QueryEscape(query) + "&variables=%7B",
+			Err:    InvalidParameterError("variables"),
+		},
+		{
+			Method: "GET",
+			URL:    "/graphql",
+			Err:    MissingParameterError("query"),
+		},
+		{
+			Method:      "POST",
+			URL:         "/graphql",
+			ContentType: "text/plain",
+			Body:        query,
+			Err:         UnsupportedMediaTypeError{},
+		},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.Method, tt.URL, strings.NewReader(tt.Body))
+		if tt.ContentType != "" {
+			req.Header.Set("Content-Type", tt.ContentType)
+		}
+		gql, err := ParseGraphQLRequest(req)
+		if tt.Err != nil {
+			if err != tt.Err {
+				t.Errorf("#%d: want error %v, have %v", i, tt.Err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if want, have := query, gql.Query; want != have {
+			t.Errorf("#%d: want query %q, have %q", i, want, have)
+		}
+		if want, have := tt.Variables, len(gql.Variables); want != have {
+			t.Errorf("#%d: want %d variables, have %d", i, want, have)
+		}
+	}
+}
+
+func TestGraphQLHasMutation(t *testing.T) {
+	tests := []struct {
+		Query         string
+		OperationName string
+		Want          bool
+	}{
+		{Query: `{ hero { name } }`},
+		{Query: `query { hero { name } }`},
+		{Query: `mutation { like(id: 1) }`, Want: true},
+		{Query: "# mutation\n{ hero { name } }"},
+		{Query: "# comment\nmutation Like { like(id: 1) }", Want: true},
+		{Query: "\ufeff  ,mutation { like(id: 1) }", Want: true},
+		{Query: `query A { hero { name } } mutation B { like(id: 1) }`, OperationName: "A"},
+		{Query: `query A { hero { name } } mutation B { like(id: 1) }`, OperationName: "B", Want: true},
+		{Query: `query A { hero { name } } mutation B { like(id: 1) }`, Want: true},
+		{Query: `query mutation { hero { name } }`, OperationName: "mutation"},
+		{Query: `query A($s: String = "} mutation B {") @dir(a: {b: 1}) { hero(s: """ " } """) { name } }`, OperationName: "A"},
+		{Query: `query A { hero(name: "x\"}") { name } } mutation B { like(id: 1) }`, OperationName: "B", Want: true},
+		{Query: `fragment F on Hero { name } mutation { like(id: 1) }`, Want: true},
+		{Query: `fragment F on Hero { name } query { hero { ...F } }`},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Want, graphQLHasMutation(tt.Query, tt.OperationName); want != have {
+			t.Errorf("#%d: want %v, have %v for %q", i, want, have, tt.Query)
+		}
+	}
+}
+
+func TestWriteGraphQLResult(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteGraphQLResult(w, map[string]interface{}{"hero": nil}, []error{
+		NotFoundError{},
+		UnprocessableEntityError{Errors: []string{"name is missing"}},
+		errors.New("kaboom"),
+		&GraphQLError{Message: "custom", Path: []interface{}{"hero", 0}},
+	})
+	if w.Code != 200 {
+		t.Fatalf("expected status = %d; got: %d", 200, w.Code)
+	}
+	want := `{
+  "data": {
+    "hero": null
+  },
+  "errors": [
+    {
+      "message": "Record not found",
+      "extensions": {
+        "code": "NOT_FOUND"
+      }
+    },
+    {
+      "message": "Record has semantic errors",
+      "extensions": {
+        "code": "UNPROCESSABLE_ENTITY",
+        "details": [
+          "name is missing"
+        ]
+      }
+    },
+    {
+      "message": "kaboom",
+      "extensions": {
+        "code": "INTERNAL_SERVER_ERROR"
+      }
+    },
+    {
+      "message": "custom",
+      "path": [
+        "hero",
+        0
+      ]
+    }
+  ]
+}
+`
+	if have := w.Body.String(); want != have {
+		t.Errorf("want:\n%s\nhave:\n%s", want, have)
+	}
+}
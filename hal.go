@@ -0,0 +1,143 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ContentTypeHAL is the content type of HAL documents
+// (https://datatracker.ietf.org/doc/html/draft-kelly-json-hal).
+const ContentTypeHAL = "application/hal+json"
+
+// HALResource is a resource in HAL format: The properties of Resource,
+// which must serialize to a JSON object, are extended by "_links" and
+// "_embedded".
+type HALResource struct {
+	// Resource holds the properties, typically a struct or a map.
+	Resource interface{}
+	// Links are serialized into "_links", keyed by relation type.
+	// Multiple links with the same relation type become an array.
+	Links Links
+	// Embedded holds embedded resources by relation type. Values are
+	// typically a HALResource or a []HALResource.
+	Embedded map[string]interface{}
+}
+
+// halLink is a link object of HAL.
+type halLink struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	Hreflang  string `json:"hreflang,omitempty"`
+}
+
+// MarshalJSON serializes the resource in HAL format. The target
+// attributes "templated", "name", "title", "profile", and "hreflang"
+// of each link are taken from its Params.
+func (res HALResource) MarshalJSON() ([]byte, error) {
+	props := make(map[string]json.RawMessage)
+	if res.Resource != nil {
+		data, err := json.Marshal(res.Resource)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &props); err != nil {
+			return nil, fmt.Errorf("httputil: HAL resource must be a JSON object: %v", err)
+		}
+		if props == nil {
+			props = make(map[string]json.RawMessage)
+		}
+	}
+	if len(res.Links) > 0 {
+		data, err := json.Marshal(halLinks(res.Links))
+		if err != nil {
+			return nil, err
+		}
+		props["_links"] = data
+	}
+	if len(res.Embedded) > 0 {
+		data, err := json.Marshal(res.Embedded)
+		if err != nil {
+			return nil, err
+		}
+		props["_embedded"] = data
+	}
+	return json.Marshal(props)
+}
+
+// halLinks groups links by relation type.
+func halLinks(links Links) map[string]interface{} {
+	byRel := make(map[string][]halLink)
+	for _, l := range links {
+		byRel[l.Rel] = append(byRel[l.Rel], halLink{
+			Href:      l.URL,
+			Templated: l.Params["templated"] == "true",
+			Type:      l.Type,
+			Name:      l.Params["name"],
+			Title:     l.Params["title"],
+			Profile:   l.Params["profile"],
+			Hreflang:  l.Params["hreflang"],
+		})
+	}
+	m := make(map[string]interface{}, len(byRel))
+	for rel, list := range byRel {
+		// "curies" is always an array as per the specification
+		if len(list) == 1 && rel != "curies" {
+			m[rel] = list[0]
+		} else {
+			m[rel] = list
+		}
+	}
+	return m
+}
+
+// WriteHAL writes resource in HAL format into w, with the given links
+// and embedded resources, and sets the HTTP status code.
+//
+// Example:
+//
+//	httputil.WriteHAL(w, http.StatusOK, order,
+//	  httputil.Links{}.Add("/orders/1", "self").Add("/customers/7", "customer"),
+//	  map[string]interface{}{"items": items})
+func WriteHAL(w http.ResponseWriter, code int, resource interface{}, links Links, embedded map[string]interface{}) {
+	js, err := marshalJSON(HALResource{Resource: resource, Links: links, Embedded: embedded})
+	if err != nil {
+		WriteJSONError(w, ServerError(fmt.Sprintf("JSON serialization error: %v", err)))
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeHAL)
+	w.WriteHeader(code)
+	w.Write(js)
+}
+
+// WriteHALNegotiated writes resource either in HAL format or as plain JSON,
+// depending on the Accept header of r. HAL is only written if clients
+// ask for application/hal+json explicitly, and at least with the same
+// q-value as application/json.
+func WriteHALNegotiated(w http.ResponseWriter, r *http.Request, code int, resource interface{}, links Links, embedded map[string]interface{}) {
+	w.Header().Add("Vary", "Accept")
+	if acceptsHAL(r) {
+		WriteHAL(w, code, resource, links, embedded)
+		return
+	}
+	WriteJSONCode(w, code, resource)
+}
+
+// acceptsHAL returns true if r explicitly accepts HAL over plain JSON.
+func acceptsHAL(r *http.Request) bool {
+	ranges := parseAccept(r.Header.Get("Accept"))
+	for _, mr := range ranges {
+		if mr.typ+"/"+mr.subtype == ContentTypeHAL && mr.q > 0 {
+			return mr.q >= acceptQuality(ranges, "application/json")
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type halOrder struct {
+	ID     int     `json:"id"`
+	Total  float64 `json:"total"`
+	Status string  `json:"status"`
+}
+
+func TestWriteHAL(t *testing.T) {
+	links := Links{}.
+		Add("/orders/1", "self").
+		Add("/customers/7", "customer").
+		Add("/orders/1/items/1", "item").
+		Add("/orders/1/items/2", "item")
+	links = append(links, Link{URL: "/docs/{rel}", Rel: "curies", Params: map[string]string{"name": "doc", "templated": "true"}})
+	embedded := map[string]interface{}{
+		"items": []HALResource{
+			{Resource: map[string]interface{}{"sku": "A-1"}, Links: Links{}.Add("/items/A-1", "self")},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	WriteHAL(w, http.StatusOK, halOrder{ID: 1, Total: 30, Status: "shipped"}, links, embedded)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := ContentTypeHAL, w.Header().Get("Content-Type"); want != have {
+		t.Errorf("want Content-Type %q, have %q", want, have)
+	}
+	want := `{
+		"_embedded": {"items": [{"_links": {"self": {"href": "/items/A-1"}}, "sku": "A-1"}]},
+		"_links": {
+			"curies": [{"href": "/docs/{rel}", "templated": true, "name": "doc"}],
+			"customer": {"href": "/customers/7"},
+			"item": [{"href": "/orders/1/items/1"}, {"href": "/orders/1/items/2"}],
+			"self": {"href": "/orders/1"}
+		},
+		"id": 1,
+		"status": "shipped",
+		"total": 30
+	}`
+	if !EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+}
+
+func TestWriteHALInvalidResource(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteHAL(w, http.StatusOK, []int{1, 2}, nil, nil)
+	if want, have := http.StatusInternalServerError, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
+
+func TestWriteHALNegotiated(t *testing.T) {
+	tests := []struct {
+		Accept string
+		HAL    bool
+	}{
+		{Accept: "", HAL: false},
+		{Accept: "*/*", HAL: false},
+		{Accept: "application/json", HAL: false},
+		{Accept: "application/hal+json", HAL: true},
+		{Accept: "application/hal+json, application/json", HAL: true},
+		{Accept: "application/hal+json;q=0.5, application/json", HAL: false},
+		{Accept: "application/hal+json;q=0, */*", HAL: false},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/orders/1", nil)
+		if tt.Accept != "" {
+			req.Header.Set("Accept", tt.Accept)
+		}
+		w := httptest.NewRecorder()
+		WriteHALNegotiated(w, req, http.StatusOK, halOrder{ID: 1}, Links{}.Add("/orders/1", "self"), nil)
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		_, hasLinks := body["_links"]
+		if want, have := tt.HAL, hasLinks; want != have {
+			t.Errorf("#%d: Accept %q: want HAL %v, have %v", i, tt.Accept, want, have)
+		}
+		if want, have := "Accept", w.Header().Get("Vary"); want != have {
+			t.Errorf("#%d: want Vary %q, have %q", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// -- Request headers --
+
+// MustHeaderString checks if the request r has a header with
+// the specified key. If is doesn't, it will panic.
+func MustHeaderString(r *http.Request, key string) string {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	return v
+}
+
+// MustHeaderBool checks if the request r has a header with
+// the specified key that can be converted to a bool.
+// If is doesn't, it will panic.
+func MustHeaderBool(r *http.Request, key string) bool {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	f, err := strconv.ParseBool(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return f
+}
+
+// MustHeaderInt checks if the request r has a header with
+// the specified key that can be converted to an int.
+// If is doesn't, it will panic.
+func MustHeaderInt(r *http.Request, key string) int {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return i
+}
+
+// MustHeaderInt64 checks if the request r has a header with
+// the specified key that can be converted to an int64.
+// If is doesn't, it will panic.
+func MustHeaderInt64(r *http.Request, key string) int64 {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return i
+}
+
+// MustHeaderFloat64 checks if the request r has a header with
+// the specified key that can be converted to a float64.
+// If is doesn't, it will panic.
+func MustHeaderFloat64(r *http.Request, key string) float64 {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return f
+}
+
+// MustHeaderTime checks if the request r has a header with
+// the specified key that can be converted to a time.Time, using
+// the HTTP date formats (e.g. as in If-Modified-Since).
+// If is doesn't, it will panic.
+func MustHeaderTime(r *http.Request, key string) time.Time {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return t
+}
+
+// MustHeaderDuration checks if the request r has a header with
+// the specified key that can be converted to a time.Duration.
+// If is doesn't, it will panic.
+func MustHeaderDuration(r *http.Request, key string) time.Duration {
+	v := r.Header.Get(key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return d
+}
+
+// HeaderString checks if the request r has a header with
+// the specified key. If is doesn't, it will return defaultValue.
+func HeaderString(r *http.Request, key string, defaultValue string) string {
+	if v := r.Header.Get(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+// HeaderBool checks if the request r has a header with
+// the specified key that can be converted to a bool.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the header is malformed.
+func HeaderBool(r *http.Request, key string, defaultValue bool) bool {
+	v := r.Header.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseBool(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return f
+}
+
+// HeaderInt checks if the request r has a header with
+// the specified key that can be converted to an int.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the header is malformed.
+func HeaderInt(r *http.Request, key string, defaultValue int) int {
+	v := r.Header.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return i
+}
+
+// HeaderInt64 checks if the request r has a header with
+// the specified key that can be converted to an int64.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the header is malformed.
+func HeaderInt64(r *http.Request, key string, defaultValue int64) int64 {
+	v := r.Header.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return i
+}
+
+// HeaderFloat64 checks if the request r has a header with
+// the specified key that can be converted to a float64.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the header is malformed.
+func HeaderFloat64(r *http.Request, key string, defaultValue float64) float64 {
+	v := r.Header.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return f
+}
+
+// HeaderTime checks if the request r has a header with
+// the specified key that can be converted to a time.Time, using
+// the HTTP date formats (e.g. as in If-Modified-Since).
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the header is malformed.
+func HeaderTime(r *http.Request, key string, defaultValue time.Time) time.Time {
+	v := r.Header.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return t
+}
+
+// HeaderDuration checks if the request r has a header with
+// the specified key that can be converted to a time.Duration.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the header is malformed.
+func HeaderDuration(r *http.Request, key string, defaultValue time.Duration) time.Duration {
+	v := r.Header.Get(key)
+	if v == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(InvalidParameterError(key))
+	}
+	return d
+}
+
+// -- Response headers --
+
+// SetHeaderInt sets the header with the specified key to i.
+func SetHeaderInt(h http.Header, key string, i int) {
+	h.Set(key, strconv.Itoa(i))
+}
+
+// SetHeaderInt64 sets the header with the specified key to i.
+func SetHeaderInt64(h http.Header, key string, i int64) {
+	h.Set(key, strconv.FormatInt(i, 10))
+}
+
+// SetHeaderBool sets the header with the specified key to b.
+func SetHeaderBool(h http.Header, key string, b bool) {
+	h.Set(key, strconv.FormatBool(b))
+}
+
+// SetHeaderTime sets the header with the specified key to t,
+// formatted as HTTP date (e.g. as in Last-Modified).
+func SetHeaderTime(h http.Header, key string, t time.Time) {
+	h.Set(key, t.UTC().Format(http.TimeFormat))
+}
+
+// SetHeaderDuration sets the header with the specified key to
+// the number of whole seconds in d (e.g. as in Retry-After).
+func SetHeaderDuration(h http.Header, key string, d time.Duration) {
+	h.Set(key, strconv.FormatInt(int64(d/time.Second), 10))
+}
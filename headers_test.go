@@ -0,0 +1,89 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeaderGetters(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-Modified-Since", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("X-Request-Timeout", "1.5s")
+	req.Header.Set("X-Page-Size", "25")
+	req.Header.Set("X-Invalid", "abc")
+
+	if want, have := time.Date(2021, 4, 20, 2, 7, 55, 0, time.UTC), HeaderTime(req, "If-Modified-Since", time.Time{}); !want.Equal(have) {
+		t.Errorf("HeaderTime: want %v, have %v", want, have)
+	}
+	if want, have := 1500*time.Millisecond, HeaderDuration(req, "X-Request-Timeout", 0); want != have {
+		t.Errorf("HeaderDuration: want %v, have %v", want, have)
+	}
+	if want, have := 25, HeaderInt(req, "X-Page-Size", 10); want != have {
+		t.Errorf("HeaderInt: want %v, have %v", want, have)
+	}
+	func() {
+		defer func() {
+			if _, ok := recover().(InvalidParameterError); !ok {
+				t.Error("HeaderInt: want panic with InvalidParameterError for a malformed header")
+			}
+		}()
+		HeaderInt(req, "X-Invalid", 10)
+	}()
+	if want, have := int64(10), HeaderInt64(req, "X-Missing", 10); want != have {
+		t.Errorf("HeaderInt64: want %v, have %v", want, have)
+	}
+	if want, have := "none", HeaderString(req, "X-Missing", "none"); want != have {
+		t.Errorf("HeaderString: want %v, have %v", want, have)
+	}
+}
+
+func TestMustHeaderIntFailure(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		fmt.Fprint(w, MustHeaderInt(r, "X-Page-Size"))
+	}
+
+	tests := []struct {
+		Value string
+		Code  int
+	}{
+		{Value: "25", Code: http.StatusOK},
+		{Value: "", Code: http.StatusBadRequest},
+		{Value: "abc", Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.Value != "" {
+			req.Header.Set("X-Page-Size", tt.Value)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestHeaderSetters(t *testing.T) {
+	h := make(http.Header)
+	SetHeaderTime(h, "Last-Modified", time.Date(2021, 4, 20, 4, 7, 55, 0, time.FixedZone("CEST", 2*3600)))
+	SetHeaderDuration(h, "Retry-After", 90*time.Second)
+	SetHeaderInt(h, "X-Total-Count", 42)
+
+	if want, have := "Tue, 20 Apr 2021 02:07:55 GMT", h.Get("Last-Modified"); want != have {
+		t.Errorf("Last-Modified: want %q, have %q", want, have)
+	}
+	if want, have := "90", h.Get("Retry-After"); want != have {
+		t.Errorf("Retry-After: want %q, have %q", want, have)
+	}
+	if want, have := "42", h.Get("X-Total-Count"); want != have {
+		t.Errorf("X-Total-Count: want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// HedgedTransport is a http.RoundTripper that reduces tail latency by
+// sending another attempt of a request if no response has arrived after
+// Delay, and using whichever response arrives first. The other attempts
+// are canceled. Only idempotent requests whose body can be replayed via
+// GetBody are hedged (see FailoverTransport for what is idempotent).
+//
+// Choose Delay around the 95th percentile latency of the upstream, so
+// that hedging adds only a few percent of extra load.
+type HedgedTransport struct {
+	// Base is the underlying transport. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Delay is the time to wait for a response before sending another
+	// attempt. Requests are not hedged if it is not positive.
+	Delay time.Duration
+	// MaxHedges is the maximum number of additional attempts per request.
+	// It defaults to 1.
+	MaxHedges int
+
+	requests atomic.Int64
+	hedges   atomic.Int64
+	wins     atomic.Int64
+}
+
+// HedgeStats are the counters of a HedgedTransport, e.g. for metrics.
+type HedgeStats struct {
+	// Requests is the number of hedgeable requests.
+	Requests int64
+	// Hedges is the number of additional attempts sent.
+	Hedges int64
+	// Wins is the number of requests answered by an additional attempt.
+	Wins int64
+}
+
+// Stats returns the counters of t since it was created.
+func (t *HedgedTransport) Stats() HedgeStats {
+	return HedgeStats{
+		Requests: t.requests.Load(),
+		Hedges:   t.hedges.Load(),
+		Wins:     t.wins.Load(),
+	}
+}
+
+type hedgeResult struct {
+	attempt int
+	res     *http.Response
+	err     error
+}
+
+// RoundTrip sends r and, if it is slow, additional attempts of it.
+// It returns the first response, or the last error if all attempts fail.
+func (t *HedgedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	replayable := r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+	if t.Delay <= 0 || !replayable || !isIdempotent(r) {
+		return base.RoundTrip(r)
+	}
+	maxAttempts := 1 + t.MaxHedges
+	if t.MaxHedges <= 0 {
+		maxAttempts = 2
+	}
+	t.requests.Add(1)
+
+	results := make(chan hedgeResult, maxAttempts)
+	var cancels []context.CancelFunc
+	launch := func() error {
+		ctx, cancel := context.WithCancel(r.Context())
+		r2 := r.Clone(ctx)
+		if len(cancels) > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			r2.Body = body
+		}
+		cancels = append(cancels, cancel)
+		attempt := len(cancels) - 1
+		go func() {
+			res, err := base.RoundTrip(r2)
+			results <- hedgeResult{attempt: attempt, res: res, err: err}
+		}()
+		return nil
+	}
+	if err := launch(); err != nil {
+		return nil, err
+	}
+	inFlight := 1
+
+	timer := time.NewTimer(t.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if err := launch(); err == nil {
+				inFlight++
+				t.hedges.Add(1)
+			}
+			if len(cancels) < maxAttempts {
+				timer.Reset(t.Delay)
+			}
+		case result := <-results:
+			inFlight--
+			if result.err != nil {
+				cancels[result.attempt]()
+				if inFlight == 0 {
+					return nil, result.err
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != result.attempt {
+					cancel()
+				}
+			}
+			// Close the responses of the attempts that lost
+			go func(n int) {
+				for ; n > 0; n-- {
+					if other := <-results; other.res != nil {
+						other.res.Body.Close()
+					}
+				}
+			}(inFlight)
+			if result.attempt > 0 {
+				t.wins.Add(1)
+			}
+			result.res.Body = &cancelOnCloseBody{ReadCloser: result.res.Body, cancel: cancels[result.attempt]}
+			return result.res, nil
+		}
+	}
+}
+
+// cancelOnCloseBody cancels the context of a request when its
+// response body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
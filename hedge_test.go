@@ -0,0 +1,69 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedTransport(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if r.URL.Path == "/slow" && n == 1 {
+			select {
+			case <-time.After(300 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		fmt.Fprintf(w, "attempt %d", n)
+	}))
+	defer srv.Close()
+
+	tr := &HedgedTransport{Delay: 20 * time.Millisecond}
+	client := &http.Client{Transport: tr}
+
+	tests := []struct {
+		Method   string
+		Path     string
+		Body     string
+		Requests int32
+		Stats    HedgeStats
+	}{
+		{Method: "GET", Path: "/fast", Body: "attempt 1", Requests: 1, Stats: HedgeStats{Requests: 1}},
+		{Method: "GET", Path: "/slow", Body: "attempt 2", Requests: 2, Stats: HedgeStats{Requests: 2, Hedges: 1, Wins: 1}},
+		{Method: "POST", Path: "/slow", Body: "attempt 1", Requests: 1, Stats: HedgeStats{Requests: 2, Hedges: 1, Wins: 1}},
+	}
+	for i, tt := range tests {
+		requests.Store(0)
+		req, _ := http.NewRequest(tt.Method, srv.URL+tt.Path, nil)
+		start := time.Now()
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if want, have := tt.Body, string(body); want != have {
+			t.Errorf("#%d: want body %q, have %q", i, want, have)
+		}
+		if tt.Method == "GET" && time.Since(start) > 250*time.Millisecond {
+			t.Errorf("#%d: want hedged response, took %v", i, time.Since(start))
+		}
+		if want, have := tt.Requests, requests.Load(); want != have {
+			t.Errorf("#%d: want %d requests, have %d", i, want, have)
+		}
+		if want, have := tt.Stats, tr.Stats(); want != have {
+			t.Errorf("#%d: want stats %+v, have %+v", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+)
+
+// EarlyHints sends a 103 Early Hints informational response with the
+// links in the Link header, so that clients can start preloading assets
+// while the handler prepares the final response. The links are also part
+// of the final response. EarlyHints must be called before the handler
+// writes the response; it is a no-op without links.
+func EarlyHints(w http.ResponseWriter, links ...Link) {
+	if len(links) == 0 {
+		return
+	}
+	SetLinkHeader(w, links...)
+	w.WriteHeader(http.StatusEarlyHints)
+}
+
+// Push initiates HTTP/2 server pushes for the given resources. It is a
+// no-op if the connection does not support server push. Push must be
+// called before the handler writes the response.
+func Push(w http.ResponseWriter, resources ...string) {
+	pusher, ok := w.(http.Pusher)
+	if !ok {
+		return
+	}
+	for _, res := range resources {
+		if err := pusher.Push(res, nil); err != nil {
+			// Either unsupported or disabled by the client
+			return
+		}
+	}
+}
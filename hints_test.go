@@ -0,0 +1,50 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func TestEarlyHints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		EarlyHints(w, Link{URL: "/app.js", Rel: "preload", As: "script"})
+		Push(w, "/app.js")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var hints []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header.Get("Link"))
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status = %d; got: %d", http.StatusOK, resp.StatusCode)
+	}
+	want := `</app.js>; rel="preload"; as="script"`
+	if len(hints) != 1 || hints[0] != want {
+		t.Fatalf("want early hints [%s], have %v", want, hints)
+	}
+	if have := resp.Header.Get("Link"); want != have {
+		t.Fatalf("want Link header %s in final response, have %s", want, have)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+// Package http3 serves HTTP/3 over QUIC next to httputil.RunServer.
+// It is experimental, and kept in a separate package so that services
+// not using HTTP/3 don't depend on quic-go.
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/olivere/httputil"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// AltSvcMaxAge is how long clients may remember that HTTP/3 is
+// available, in seconds.
+const AltSvcMaxAge = 24 * 60 * 60
+
+// RunServerH3 serves h like httputil.RunServer over HTTP/1.1 and HTTP/2,
+// and additionally over HTTP/3 on the UDP port of the same address.
+// Responses over TCP advertise HTTP/3 in the Alt-Svc header, so clients
+// that support it switch to HTTP/3 for subsequent requests.
+//
+// HTTP/3 requires TLS, so cfg must have TLSCertFile and TLSKeyFile, and
+// cfg.Addr must be a TCP address. HTTP/3 connections are closed right
+// away on shutdown, while HTTP/1.1 and HTTP/2 are shut down gracefully.
+func RunServerH3(ctx context.Context, cfg httputil.ServerConfig, h http.Handler) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.TLSCertFile == "" {
+		return httputil.InvalidConfigError{Details: []string{"HTTP/3 requires a TLS certificate and key file"}}
+	}
+	if strings.HasPrefix(cfg.Addr, "unix:") || strings.HasPrefix(cfg.Addr, "systemd") {
+		return httputil.InvalidConfigError{Details: []string{"HTTP/3 requires a TCP address like :443"}}
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenPacket("udp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	h3 := &http3.Server{
+		Handler:        cfg.Handler(h),
+		Port:           port,
+		TLSConfig:      &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13},
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+	h3errc := make(chan error, 1)
+	go func() {
+		h3errc <- h3.Serve(conn)
+	}()
+
+	// Serve TCP on the port chosen for UDP, e.g. for ":0"
+	if host, _, err := net.SplitHostPort(cfg.Addr); err == nil {
+		cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	err = httputil.RunServer(ctx, cfg, AltSvc(port)(h))
+	// quic-go doesn't implement a graceful shutdown of HTTP/3 yet
+	if cerr := h3.Close(); err == nil {
+		err = cerr
+	}
+	if h3err := <-h3errc; err == nil && h3err != nil && !errors.Is(h3err, http.ErrServerClosed) && !errors.Is(h3err, net.ErrClosed) {
+		err = h3err
+	}
+	return err
+}
+
+// AltSvc returns a middleware that advertises HTTP/3 on the given UDP
+// port with the Alt-Svc header.
+func AltSvc(port int) func(http.Handler) http.Handler {
+	value := fmt.Sprintf(`h3=":%d"; ma=%d`, port, AltSvcMaxAge)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor < 3 {
+				w.Header().Set("Alt-Svc", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
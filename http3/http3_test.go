@@ -0,0 +1,123 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package http3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/olivere/httputil"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1.
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestRunServerH3(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP not supported: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	cfg := httputil.DefaultServerConfig()
+	cfg.Addr = fmt.Sprintf("127.0.0.1:%d", port)
+	cfg.TLSCertFile, cfg.TLSKeyFile = writeTestCert(t)
+	cfg.ShutdownTimeout = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- RunServerH3(ctx, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}))
+	}()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	https := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	var res *http.Response
+	for i := 0; i < 50; i++ {
+		if res, err = https.Get("https://" + cfg.Addr); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := fmt.Sprintf(`h3=":%d"; ma=%d`, port, AltSvcMaxAge), res.Header.Get("Alt-Svc"); want != have {
+		t.Errorf("want Alt-Svc %q, have %q", want, have)
+	}
+
+	rt := &http3.RoundTripper{TLSClientConfig: tlsConfig}
+	defer rt.Close()
+	res, err = (&http.Client{Transport: rt, Timeout: 5 * time.Second}).Get("https://" + cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if want, have := "HTTP/3.0", string(body); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	if have := res.Header.Get("Alt-Svc"); have != "" {
+		t.Errorf("want no Alt-Svc over HTTP/3, have %q", have)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("want graceful shutdown, have %v", err)
+	}
+}
+
+func TestRunServerH3RequiresTLS(t *testing.T) {
+	cfg := httputil.DefaultServerConfig()
+	if err := RunServerH3(context.Background(), cfg, http.NotFoundHandler()); err == nil {
+		t.Fatal("want error without TLS")
+	}
+}
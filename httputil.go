@@ -46,8 +46,17 @@ func ReadJSON(r *http.Request, dst interface{}) error {
 	}()
 
 	// Limit to 8 MB of JSON
-	if err := json.NewDecoder(io.TeeReader(io.LimitReader(r.Body, 8<<20), buf)).Decode(dst); err != nil {
-		return fmt.Errorf("invalid JSON data: %v, on input: %s", err, buf.Bytes())
+	body := io.TeeReader(io.LimitReader(r.Body, 8<<20), buf)
+	var err error
+	if codec := configFor(r).JSONCodec; codec != nil {
+		if _, err = io.Copy(io.Discard, body); err == nil {
+			err = codec.Unmarshal(buf.Bytes(), dst)
+		}
+	} else {
+		err = json.NewDecoder(body).Decode(dst)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON data: %v, on input: %s", err, redactorFor(r).RedactText(buf.String()))
 	}
 	return nil
 }
@@ -59,6 +68,15 @@ func MustReadJSON(r *http.Request, dst interface{}) {
 	}
 }
 
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // CloseBody closes rc.
 func CloseBody(rc io.ReadCloser) {
 	if rc != nil {
@@ -73,27 +91,79 @@ func WriteJSON(w http.ResponseWriter, data interface{}) {
 
 // WriteJSONCode writes data as JSON into w and sets the HTTP status code.
 func WriteJSONCode(w http.ResponseWriter, code int, data interface{}) {
-	js, err := json.MarshalIndent(data, "", "  ")
+	writeJSONCode(w, nil, code, data)
+}
+
+func writeJSONCode(w http.ResponseWriter, r *http.Request, code int, data interface{}) {
+	js, err := marshalJSONFor(r, data)
 	if err != nil {
-		BadRequestError(w, "JSON serialization error: %v", err)
+		badRequestError(w, r, "JSON serialization error: %v", err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(js)
-	w.Write([]byte("\n"))
+}
+
+// JSONCodec serializes and deserializes JSON, e.g. with a faster library
+// than encoding/json. See Config.JSONCodec.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// marshalJSON serializes data the way WriteJSONCode writes it,
+// i.e. indented and with a trailing newline.
+func marshalJSON(data interface{}) ([]byte, error) {
+	return marshalJSONFor(nil, data)
+}
+
+// marshalJSONFor is like marshalJSON, but uses the JSONCodec of the
+// Config in effect for r, which may be nil.
+func marshalJSONFor(r *http.Request, data interface{}) ([]byte, error) {
+	if codec := configFor(r).JSONCodec; codec != nil {
+		return codec.Marshal(data)
+	}
+	js, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(js, '\n'), nil
+}
+
+// marshalCompactJSONFor is like marshalJSONFor, but doesn't indent the
+// output if no JSONCodec is configured, e.g. for embedded documents.
+func marshalCompactJSONFor(r *http.Request, data interface{}) ([]byte, error) {
+	if codec := configFor(r).JSONCodec; codec != nil {
+		return codec.Marshal(data)
+	}
+	return json.Marshal(data)
+}
+
+// unmarshalJSONFor deserializes data into dst, using the JSONCodec of
+// the Config in effect for r, which may be nil.
+func unmarshalJSONFor(r *http.Request, data []byte, dst interface{}) error {
+	if codec := configFor(r).JSONCodec; codec != nil {
+		return codec.Unmarshal(data, dst)
+	}
+	return json.Unmarshal(data, dst)
 }
 
 // Recover can be used as a deferred func to catch panics in an HTTP handler.
+// Panics with http.ErrAbortHandler are passed through to the HTTP server.
 func Recover(w http.ResponseWriter, r *http.Request) {
 	err := recover()
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
 	if err != nil {
-		WriteError(w, err)
+		writeError(w, r, err)
 	}
 }
 
 // RecoverJSON can be used as a deferred func to catch panics in an HTTP handler
-// and print a JSON error.
+// and print a JSON error. Panics with http.ErrAbortHandler are passed
+// through to the HTTP server.
 //
 // Example:
 //
@@ -104,7 +174,36 @@ func Recover(w http.ResponseWriter, r *http.Request) {
 //	}
 func RecoverJSON(w http.ResponseWriter, r *http.Request) {
 	err := recover()
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
 	if err != nil {
-		WriteJSONError(w, err)
+		writeJSONError(w, r, err)
 	}
 }
+
+// Abort stops the handler by panicking with an error with the given
+// HTTP status code, message, and details. Use it together with
+// Recover or RecoverJSON.
+//
+// Example:
+//
+//	func Handler(w http.ResponseWriter, r *http.Request) {
+//	  defer httputil.RecoverJSON(w, r)
+//	  ...
+//	  httputil.Abort(http.StatusConflict, "Order already shipped")
+//	}
+func Abort(code int, msg string, details ...string) {
+	panic(abortError{code: code, msg: msg, details: details})
+}
+
+// abortError is the error passed to panic by Abort.
+type abortError struct {
+	code    int
+	msg     string
+	details []string
+}
+
+func (e abortError) Error() string          { return e.msg }
+func (e abortError) HTTPCode() int          { return e.code }
+func (e abortError) ErrorDetails() []string { return e.details }
@@ -6,7 +6,6 @@ package httputil
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -79,18 +78,7 @@ func TestMustReadJSON(t *testing.T) {
 	if got != "application/json" {
 		t.Errorf("expected Content-Type = %q; got: %q", "application/json", got)
 	}
-	type failure struct {
-		Error struct {
-			Code    int      `json:"code"`
-			Message string   `json:"message"`
-			Details []string `json:"details"`
-		} `json:"error"`
-	}
-	var fail failure
-	err = json.NewDecoder(w.Body).Decode(&fail)
-	if err != nil {
-		t.Fatal(err)
-	}
+	fail := ErrorEnvelopeOf(t, w)
 	if fail.Error.Code != http.StatusBadRequest {
 		t.Errorf("expected error code = %d; got: %d", http.StatusBadRequest, fail.Error.Code)
 	}
@@ -138,18 +126,7 @@ func BenchmarkMustReadJSON(b *testing.B) {
 			if got != "application/json" {
 				b.Errorf("expected Content-Type = %q; got: %q", "application/json", got)
 			}
-			type failure struct {
-				Error struct {
-					Code    int      `json:"code"`
-					Message string   `json:"message"`
-					Details []string `json:"details"`
-				} `json:"error"`
-			}
-			var fail failure
-			err = json.NewDecoder(w.Body).Decode(&fail)
-			if err != nil {
-				b.Fatal(err)
-			}
+			fail := ErrorEnvelopeOf(b, w)
 			if fail.Error.Code != http.StatusBadRequest {
 				b.Errorf("expected error code = %d; got: %d", http.StatusBadRequest, fail.Error.Code)
 			}
@@ -160,3 +137,46 @@ func BenchmarkMustReadJSON(b *testing.B) {
 		}
 	})
 }
+
+func TestAbort(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		Abort(http.StatusConflict, "Order already shipped", "shipped at 2017-01-01")
+	}
+
+	req, err := http.NewRequest("POST", "http://localhost/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status = %d; got: %d", http.StatusConflict, w.Code)
+	}
+	fail := ErrorEnvelopeOf(t, w)
+	if fail.Error.Code != http.StatusConflict {
+		t.Errorf("expected error code = %d; got: %d", http.StatusConflict, fail.Error.Code)
+	}
+	if fail.Error.Message != "Order already shipped" {
+		t.Errorf("expected error message = %q; got: %q", "Order already shipped", fail.Error.Message)
+	}
+	if len(fail.Error.Details) != 1 || fail.Error.Details[0] != "shipped at 2017-01-01" {
+		t.Errorf("unexpected error details: %v", fail.Error.Details)
+	}
+}
+
+func TestRecoverJSONErrAbortHandler(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		panic(http.ErrAbortHandler)
+	}
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("expected http.ErrAbortHandler to be re-panicked; got: %v", err)
+		}
+	}()
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	t.Fatal("expected panic")
+}
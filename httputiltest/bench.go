@@ -0,0 +1,242 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+// Package httputiltest provides a harness for benchmarking middleware
+// stacks built with httputil. It is kept in a separate package so that
+// services importing httputil don't pull in the benchmark harness.
+package httputiltest
+
+import (
+	"bytes"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RequestProfile is a mix of requests sent by BenchmarkStack.
+type RequestProfile struct {
+	// Requests are the kinds of requests to send. If empty, a GET
+	// request for "/" is sent.
+	Requests []RequestSpec
+	// ErrorRate is the fraction of requests with JSON or form bodies,
+	// between 0 and 1, that are sent with a malformed body to exercise
+	// the error paths.
+	ErrorRate float64
+	// Seed makes the mix of requests reproducible.
+	Seed int64
+}
+
+// RequestSpec is a kind of request of a RequestProfile.
+type RequestSpec struct {
+	// Method defaults to GET.
+	Method string
+	// Target is the request target, e.g. "/orders?limit=10".
+	// It defaults to "/".
+	Target string
+	// Header are additional request headers.
+	Header http.Header
+	// ContentType is the type of the body, e.g. "application/json" or
+	// "application/x-www-form-urlencoded". JSON and form bodies are
+	// generated as such, others as plain text.
+	ContentType string
+	// BodySize is the approximate size of the body in bytes.
+	BodySize int
+	// Weight is the relative frequency of the request. It defaults to 1.
+	Weight int
+}
+
+// BenchmarkStack sends b.N requests of the profile p to h, e.g. a
+// router wrapped by all middlewares of a service, and reports the
+// allocations per request, the latency percentiles p50, p90, and p99 in
+// nanoseconds, and the fraction of responses with an error status.
+// Use it to detect performance regressions of a middleware stack.
+//
+// Requests are created in advance from templates. Each request gets
+// its own copy of the header and URL, so h may modify them; the
+// allocations for the copies are included in the allocations reported.
+// The latency includes writing the response into a writer that
+// discards it.
+//
+// Example:
+//
+//	func BenchmarkAPI(b *testing.B) {
+//	  httputiltest.BenchmarkStack(b, newRouter(), httputiltest.RequestProfile{
+//	    Requests: []httputiltest.RequestSpec{
+//	      {Target: "/orders?limit=50", Weight: 8},
+//	      {Method: "POST", Target: "/orders", ContentType: "application/json", BodySize: 2048, Weight: 2},
+//	    },
+//	    ErrorRate: 0.05,
+//	  })
+//	}
+func BenchmarkStack(b *testing.B, h http.Handler, p RequestProfile) {
+	b.Helper()
+	specs := p.Requests
+	if len(specs) == 0 {
+		specs = []RequestSpec{{}}
+	}
+	var (
+		benchReqs []*benchRequest
+		weights   []int
+		total     int
+	)
+	for _, spec := range specs {
+		benchReqs = append(benchReqs, newBenchRequest(spec))
+		weight := spec.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		weights = append(weights, total)
+	}
+	rng := rand.New(rand.NewSource(p.Seed))
+	w := &benchResponseWriter{header: make(http.Header)}
+	latencies := make([]time.Duration, 0, b.N)
+	var failed int
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br := benchReqs[sort.SearchInts(weights, rng.Intn(total)+1)]
+		body := br.body
+		if p.ErrorRate > 0 && rng.Float64() < p.ErrorRate {
+			body = br.malformed
+		}
+		r := br.next(body)
+		w.reset()
+
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		latencies = append(latencies, time.Since(start))
+		if w.code >= 400 {
+			failed++
+		}
+	}
+	b.StopTimer()
+
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(q float64) float64 {
+		return float64(latencies[int(float64(len(latencies)-1)*q)].Nanoseconds())
+	}
+	b.ReportMetric(percentile(0.50), "p50-ns")
+	b.ReportMetric(percentile(0.90), "p90-ns")
+	b.ReportMetric(percentile(0.99), "p99-ns")
+	b.ReportMetric(float64(failed)/float64(len(latencies)), "errors/op")
+}
+
+// benchRequest is a reusable request of BenchmarkStack.
+type benchRequest struct {
+	template  *http.Request
+	body      []byte
+	malformed []byte
+	reader    bytes.Reader
+	rc        benchBody
+}
+
+func newBenchRequest(spec RequestSpec) *benchRequest {
+	method, target := spec.Method, spec.Target
+	if method == "" {
+		method = http.MethodGet
+	}
+	if target == "" {
+		target = "/"
+	}
+	br := &benchRequest{template: httptest.NewRequest(method, target, nil)}
+	for name, values := range spec.Header {
+		br.template.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if spec.ContentType != "" {
+		br.template.Header.Set("Content-Type", spec.ContentType)
+	}
+	br.body, br.malformed = benchBodies(spec.ContentType, spec.BodySize)
+	br.rc.Reader = &br.reader
+	return br
+}
+
+// next returns a copy of the template with the given body, and its own
+// header and URL, so changes by the handler don't leak into the next
+// request.
+func (br *benchRequest) next(body []byte) *http.Request {
+	r := new(http.Request)
+	*r = *br.template
+	r.Header = br.template.Header.Clone()
+	u := *br.template.URL
+	r.URL = &u
+	if len(body) > 0 {
+		br.reader.Reset(body)
+		r.Body = &br.rc
+		r.ContentLength = int64(len(body))
+	}
+	return r
+}
+
+// benchBodies returns a body of about the given size for the content
+// type, and a malformed variant of it.
+func benchBodies(contentType string, size int) (body, malformed []byte) {
+	if size <= 0 {
+		return nil, nil
+	}
+	value := strings.Repeat("x", size)
+	switch {
+	case isJSONContentType(contentType):
+		body = []byte(`{"data":"` + value + `"}`)
+		return body, body[:len(body)-1]
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		body = []byte("data=" + value)
+		return body, append([]byte("data=%zz"), value...)
+	}
+	body = []byte(value)
+	return body, body
+}
+
+func isJSONContentType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// benchBody is the reusable body of a benchRequest.
+type benchBody struct {
+	*bytes.Reader
+}
+
+// Close implements io.Closer.
+func (benchBody) Close() error { return nil }
+
+// benchResponseWriter discards the response and keeps the status code.
+type benchResponseWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *benchResponseWriter) reset() {
+	for k := range w.header {
+		delete(w.header, k)
+	}
+	w.code = 0
+}
+
+func (w *benchResponseWriter) Header() http.Header { return w.header }
+
+func (w *benchResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *benchResponseWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher.
+func (w *benchResponseWriter) Flush() {}
@@ -0,0 +1,99 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputiltest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/olivere/httputil"
+)
+
+func TestBenchmarkStack(t *testing.T) {
+	var posts, gets int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer httputil.RecoverJSON(w, r)
+		switch r.Method {
+		case "POST":
+			posts++
+			var v struct{ Data string }
+			httputil.MustReadJSON(r, &v)
+			if len(v.Data) != 512 {
+				t.Errorf("want data of %d bytes, have %d", 512, len(v.Data))
+			}
+			httputil.WriteJSONCode(w, http.StatusCreated, v)
+		default:
+			gets++
+			httputil.WriteJSON(w, map[string]string{"q": r.URL.Query().Get("q")})
+		}
+	})
+	profile := RequestProfile{
+		Requests: []RequestSpec{
+			{Target: "/orders?q=1", Weight: 3},
+			{Method: "POST", Target: "/orders", ContentType: "application/json", BodySize: 512},
+		},
+		ErrorRate: 0.2,
+		Seed:      1,
+	}
+	res := testing.Benchmark(func(b *testing.B) {
+		posts, gets = 0, 0
+		BenchmarkStack(b, h, profile)
+	})
+	if res.N == 0 {
+		t.Fatal("want benchmark to run")
+	}
+	for _, metric := range []string{"p50-ns", "p90-ns", "p99-ns"} {
+		if res.Extra[metric] <= 0 {
+			t.Errorf("want %s to be reported, have %v", metric, res.Extra)
+		}
+	}
+	if res.Extra["p50-ns"] > res.Extra["p99-ns"] {
+		t.Errorf("want p50 <= p99, have %v", res.Extra)
+	}
+	// 20% of the 25% POST requests
+	if have := res.Extra["errors/op"]; have <= 0.02 || have >= 0.08 {
+		t.Errorf("want about 5%% errors, have %v", have)
+	}
+	if have := float64(gets) / float64(res.N); res.N > 100 && (have < 0.65 || have > 0.85) {
+		t.Errorf("want about 75%% GET requests, have %v", have)
+	}
+	if res.AllocsPerOp() <= 0 {
+		t.Errorf("want allocations to be reported, have %d", res.AllocsPerOp())
+	}
+}
+
+func TestBenchmarkStackModifiedRequests(t *testing.T) {
+	var leaked int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-Id") != "" || r.URL.Path != "/orders" || r.Header.Get("Accept") != "application/json" {
+			leaked++
+		}
+		r.Header.Set("X-Request-Id", "1")
+		r.Header.Del("Accept")
+		r.URL.Path = "/rewritten"
+	})
+	profile := RequestProfile{
+		Requests: []RequestSpec{{Target: "/orders", Header: http.Header{"Accept": {"application/json"}}}},
+	}
+	testing.Benchmark(func(b *testing.B) {
+		BenchmarkStack(b, h, profile)
+	})
+	if leaked > 0 {
+		t.Errorf("want requests to be independent, have %d modified requests", leaked)
+	}
+}
+
+func BenchmarkStackJSON(b *testing.B) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer httputil.RecoverJSON(w, r)
+		var v map[string]interface{}
+		httputil.MustReadJSON(r, &v)
+		httputil.WriteJSON(w, v)
+	})
+	BenchmarkStack(b, h, RequestProfile{
+		Requests:  []RequestSpec{{Method: "POST", ContentType: "application/json", BodySize: 4096}},
+		ErrorRate: 0.01,
+	})
+}
@@ -0,0 +1,388 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+// Package images serves images resized and converted on the fly.
+// It is kept in a separate package so that services not serving
+// images don't depend on golang.org/x/image.
+package images
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olivere/httputil"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// FitContain scales the image to fit into the requested size,
+	// keeping its aspect ratio. It never enlarges the image.
+	FitContain = "contain"
+	// FitCover scales and crops the image to fill the requested
+	// size, keeping its aspect ratio.
+	FitCover = "cover"
+	// FitFill stretches the image to the requested size.
+	FitFill = "fill"
+
+	// defaultMaxSize is the default of Options.MaxWidth and
+	// Options.MaxHeight.
+	defaultMaxSize = 4096
+	// defaultMaxPixels is the default of Options.MaxPixels.
+	defaultMaxPixels = 50 * 1000 * 1000
+	// sniffLen is the number of bytes http.DetectContentType considers.
+	sniffLen = 512
+)
+
+// formats maps the values of the "format" query parameter
+// to content types.
+var formats = map[string]string{
+	"avif": "image/avif",
+	"gif":  "image/gif",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// Transform describes how an image is transformed by Serve.
+type Transform struct {
+	// Width and Height are the requested size in pixels. If one of them
+	// is 0, it is derived from the aspect ratio. If both are 0, the
+	// image keeps its size.
+	Width, Height int
+	// Fit is one of FitContain, FitCover, or FitFill.
+	Fit string
+	// Format is the content type of the result, e.g. "image/webp".
+	Format string
+}
+
+// Transformer resizes and converts images for Serve.
+// Implementations e.g. use libvips to support WebP and AVIF.
+type Transformer interface {
+	// Formats returns the content types the transformer can write.
+	Formats() []string
+	// Transform reads the image from src, transforms it as
+	// specified by t, and writes the result to dst.
+	Transform(ctx context.Context, dst io.Writer, src io.Reader, t Transform) error
+}
+
+// DefaultTransformer transforms JPEG, PNG, and GIF images with
+// the standard library. It doesn't support WebP and AVIF.
+var DefaultTransformer Transformer = stdTransformer{}
+
+// Options configures Serve.
+type Options struct {
+	// Transformer transforms the image. It defaults to
+	// DefaultTransformer.
+	Transformer Transformer
+	// MaxWidth and MaxHeight limit the requested size. They default
+	// to 4096 pixels.
+	MaxWidth, MaxHeight int
+	// MaxPixels limits the width times the height of the original
+	// image, so small files that decode into huge images can't exhaust
+	// the memory. It defaults to 50 megapixels. The size is only
+	// checked for formats registered with the image package; other
+	// formats must be checked by the Transformer.
+	MaxPixels int
+	// MaxAge sets the max-age of the Cache-Control header. If 0, no
+	// Cache-Control header is set.
+	MaxAge time.Duration
+	// ETag identifies the version of the original image, e.g. a hash
+	// of its contents. If set, Serve derives an ETag for each variant
+	// and responds to matching If-None-Match requests with
+	// 304 Not Modified without reading img.
+	ETag string
+}
+
+// Serve writes the image read from img, transformed as specified
+// by the query parameters of r:
+//
+//	w       width in pixels
+//	h       height in pixels
+//	fit     "contain" (default), "cover", or "fill"
+//	format  "jpeg", "png", "gif", "webp", or "avif"
+//
+// Without a format, WebP or AVIF is served if the transformer supports
+// it and the client explicitly accepts it, e.g. with an Accept header of
+// "image/avif,image/webp,*/*". Otherwise the image keeps its format.
+// Invalid parameters result in httputil.InvalidParameterHintError, an
+// unknown image format in httputil.UnsupportedMediaTypeError, and an
+// image larger than Options.MaxPixels in httputil.UnprocessableEntityError.
+//
+// Example:
+//
+//	f, err := os.Open(filepath.Join(dir, httputil.MustParamsSlug(r, "name")+".jpg"))
+//	if err != nil {
+//	  panic(httputil.NotFoundError{})
+//	}
+//	defer f.Close()
+//	images.Serve(w, r, f, images.Options{MaxAge: 24 * time.Hour})
+func Serve(w http.ResponseWriter, r *http.Request, img io.Reader, opts Options) {
+	defer httputil.RecoverJSON(w, r)
+	if err := serve(w, r, img, opts); err != nil {
+		httputil.ServeJSONError(w, r, err)
+	}
+}
+
+func serve(w http.ResponseWriter, r *http.Request, img io.Reader, opts Options) error {
+	if opts.Transformer == nil {
+		opts.Transformer = DefaultTransformer
+	}
+	if opts.MaxWidth <= 0 {
+		opts.MaxWidth = defaultMaxSize
+	}
+	if opts.MaxHeight <= 0 {
+		opts.MaxHeight = defaultMaxSize
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = defaultMaxPixels
+	}
+	supported := opts.Transformer.Formats()
+
+	var (
+		t   = Transform{Fit: FitContain}
+		err error
+	)
+	if t.Width, err = queryDimension(r, "w", "Width", opts.MaxWidth); err != nil {
+		return err
+	}
+	if t.Height, err = queryDimension(r, "h", "Height", opts.MaxHeight); err != nil {
+		return err
+	}
+	fit, _, err := httputil.QueryRaw(r, "fit")
+	if err != nil {
+		return err
+	}
+	if fit != "" {
+		t.Fit = strings.ToLower(fit)
+	}
+	switch t.Fit {
+	case FitContain, FitCover, FitFill:
+	default:
+		return httputil.InvalidParameterHintError{Parameter: "fit", Hint: "Fit must be one of contain, cover, or fill"}
+	}
+	format, _, err := httputil.QueryRaw(r, "format")
+	if err != nil {
+		return err
+	}
+	if format != "" {
+		format = strings.ToLower(format)
+		t.Format = formats[format]
+		if !containsString(supported, t.Format) {
+			return httputil.InvalidParameterHintError{Parameter: "format", Hint: fmt.Sprintf("Format %q is not supported", format)}
+		}
+	} else {
+		w.Header().Add("Vary", "Accept")
+		for _, mt := range []string{"image/avif", "image/webp"} {
+			if containsString(supported, mt) && acceptsFormat(r, mt) {
+				t.Format = mt
+				break
+			}
+		}
+	}
+
+	// Caching headers are only set on success, so errors aren't cached
+	var etag string
+	if opts.ETag != "" {
+		etag = fmt.Sprintf(`"%s-%dx%d-%s-%s"`, strings.Trim(opts.ETag, `"`), t.Width, t.Height, t.Fit, t.Format)
+	}
+	writeHeader := func(code int) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		if opts.MaxAge > 0 {
+			w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(opts.MaxAge/time.Second)))
+		}
+		w.WriteHeader(code)
+	}
+	if etag != "" && r.Header.Get("If-None-Match") == etag {
+		writeHeader(http.StatusNotModified)
+		return nil
+	}
+
+	br := bufio.NewReaderSize(img, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	original := http.DetectContentType(head)
+	if !strings.HasPrefix(original, "image/") {
+		return httputil.UnsupportedMediaTypeError{}
+	}
+	if t.Format == "" {
+		t.Format = original
+		if !containsString(supported, original) {
+			t.Format = "image/jpeg"
+		}
+	}
+
+	if t.Width == 0 && t.Height == 0 && t.Format == original {
+		// Nothing to do
+		w.Header().Set("Content-Type", t.Format)
+		writeHeader(http.StatusOK)
+		io.Copy(w, br)
+		return nil
+	}
+
+	// Check the size in the header before the image is decoded
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(br, &header))
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		// Left to the transformer
+	case err != nil:
+		return httputil.UnsupportedMediaTypeError{}
+	case int64(cfg.Width)*int64(cfg.Height) > int64(opts.MaxPixels):
+		return httputil.UnprocessableEntityError{Errors: []string{fmt.Sprintf("Image must not have more than %d pixels", opts.MaxPixels)}}
+	}
+
+	var buf bytes.Buffer
+	if err := opts.Transformer.Transform(r.Context(), &buf, io.MultiReader(&header, br), t); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", t.Format)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	writeHeader(http.StatusOK)
+	buf.WriteTo(w)
+	return nil
+}
+
+// queryDimension returns the query string parameter key as a size
+// between 0 and max pixels, or 0 if r doesn't have it.
+func queryDimension(r *http.Request, key, name string, max int) (int, error) {
+	s, _, err := httputil.QueryRaw(r, key)
+	if err != nil {
+		return 0, err
+	}
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > max {
+		return 0, httputil.InvalidParameterHintError{Parameter: key, Hint: fmt.Sprintf("%s must be between 0 and %d", name, max)}
+	}
+	return n, nil
+}
+
+// acceptsFormat returns true if the Accept header of r lists mt
+// explicitly. Browsers send "*/*" without supporting every format.
+func acceptsFormat(r *http.Request, mt string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || typ != mt {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(q, 64); err != nil || f <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// stdTransformer implements DefaultTransformer.
+type stdTransformer struct{}
+
+func (stdTransformer) Formats() []string {
+	return []string{"image/jpeg", "image/png", "image/gif"}
+}
+
+func (stdTransformer) Transform(ctx context.Context, dst io.Writer, src io.Reader, t Transform) error {
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return httputil.UnsupportedMediaTypeError{}
+	}
+	img = resize(img, t)
+	switch t.Format {
+	case "image/jpeg":
+		return jpeg.Encode(dst, img, &jpeg.Options{Quality: 85})
+	case "image/png":
+		return png.Encode(dst, img)
+	case "image/gif":
+		return gif.Encode(dst, img, nil)
+	}
+	return fmt.Errorf("images: unsupported image format %q", t.Format)
+}
+
+// resize scales img as specified by t.
+func resize(img image.Image, t Transform) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if srcW == 0 || srcH == 0 || (t.Width == 0 && t.Height == 0) {
+		return img
+	}
+	fit := t.Fit
+	if t.Width == 0 || t.Height == 0 {
+		// Derive the other side from the aspect ratio
+		fit = FitContain
+	}
+
+	srcRect := b
+	var w, h int
+	switch fit {
+	case FitFill:
+		w, h = t.Width, t.Height
+	case FitCover:
+		w, h = t.Width, t.Height
+		// Crop the center of the source to the requested aspect ratio
+		if srcW*h > srcH*w {
+			cropW := srcH * w / h
+			x := b.Min.X + (srcW-cropW)/2
+			srcRect = image.Rect(x, b.Min.Y, x+cropW, b.Max.Y)
+		} else {
+			cropH := srcW * h / w
+			y := b.Min.Y + (srcH-cropH)/2
+			srcRect = image.Rect(b.Min.X, y, b.Max.X, y+cropH)
+		}
+	default:
+		scale := 1.0
+		if t.Width > 0 {
+			scale = float64(t.Width) / float64(srcW)
+		}
+		if t.Height > 0 {
+			if s := float64(t.Height) / float64(srcH); t.Width == 0 || s < scale {
+				scale = s
+			}
+		}
+		if scale >= 1 {
+			return img
+		}
+		w = int(float64(srcW)*scale + 0.5)
+		h = int(float64(srcH)*scale + 0.5)
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, srcRect, draw.Src, nil)
+	return dst
+}
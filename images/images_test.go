@@ -0,0 +1,192 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package images
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olivere/httputil"
+)
+
+func testImagePNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestServe(t *testing.T) {
+	data := testImagePNG(t, 200, 100)
+
+	tests := []struct {
+		Query       string
+		ContentType string
+		Width       int
+		Height      int
+	}{
+		{Query: "", ContentType: "image/png", Width: 200, Height: 100},
+		{Query: "w=50", ContentType: "image/png", Width: 50, Height: 25},
+		{Query: "h=20", ContentType: "image/png", Width: 40, Height: 20},
+		{Query: "w=50&h=50", ContentType: "image/png", Width: 50, Height: 25},
+		{Query: "w=50&h=50&fit=cover", ContentType: "image/png", Width: 50, Height: 50},
+		{Query: "w=30&h=60&fit=fill", ContentType: "image/png", Width: 30, Height: 60},
+		{Query: "w=400", ContentType: "image/png", Width: 200, Height: 100},
+		{Query: "w=20&format=jpeg", ContentType: "image/jpeg", Width: 20, Height: 10},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/img?"+tt.Query, nil)
+		w := httptest.NewRecorder()
+		Serve(w, req, bytes.NewReader(data), Options{MaxAge: time.Hour})
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d: %s", i, want, have, w.Body)
+		}
+		if want, have := tt.ContentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if want, have := "public, max-age=3600", w.Header().Get("Cache-Control"); want != have {
+			t.Errorf("#%d: want Cache-Control %q, have %q", i, want, have)
+		}
+		cfg, _, err := image.DecodeConfig(w.Body)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if cfg.Width != tt.Width || cfg.Height != tt.Height {
+			t.Errorf("#%d: want %dx%d, have %dx%d", i, tt.Width, tt.Height, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestServeFailures(t *testing.T) {
+	data := testImagePNG(t, 10, 10)
+	tests := []struct {
+		Query string
+		Image string
+		Code  int
+	}{
+		{Query: "w=-1", Code: http.StatusBadRequest},
+		{Query: "w=abc", Code: http.StatusBadRequest},
+		{Query: "h=1.5", Code: http.StatusBadRequest},
+		{Query: "h=5000", Code: http.StatusBadRequest},
+		{Query: "fit=zoom", Code: http.StatusBadRequest},
+		{Query: "format=webp", Code: http.StatusBadRequest},
+		{Query: "format=bmp", Code: http.StatusBadRequest},
+		{Query: "w=5", Image: "<html></html>", Code: http.StatusUnsupportedMediaType},
+	}
+	for i, tt := range tests {
+		img := string(data)
+		if tt.Image != "" {
+			img = tt.Image
+		}
+		req := httptest.NewRequest("GET", "/img?"+tt.Query, nil)
+		w := httptest.NewRecorder()
+		Serve(w, req, strings.NewReader(img), Options{MaxAge: time.Hour})
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if have := w.Header().Get("Cache-Control"); have != "" {
+			t.Errorf("#%d: want no Cache-Control on errors, have %q", i, have)
+		}
+	}
+}
+
+// testWebPTransformer pretends to convert images to WebP.
+type testWebPTransformer struct{}
+
+func (testWebPTransformer) Formats() []string {
+	return []string{"image/png", "image/webp"}
+}
+
+func (testWebPTransformer) Transform(ctx context.Context, dst io.Writer, src io.Reader, t Transform) error {
+	if t.Format != "image/webp" {
+		return DefaultTransformer.Transform(ctx, dst, src, t)
+	}
+	_, err := io.WriteString(dst, "RIFF\x00\x00\x00\x00WEBPVP8 ")
+	return err
+}
+
+func TestServeNegotiation(t *testing.T) {
+	data := testImagePNG(t, 10, 10)
+	opts := Options{Transformer: testWebPTransformer{}, ETag: `"v1"`}
+
+	tests := []struct {
+		Accept      string
+		ContentType string
+	}{
+		{Accept: "", ContentType: "image/png"},
+		{Accept: "*/*", ContentType: "image/png"},
+		{Accept: "image/avif,image/webp,*/*", ContentType: "image/webp"},
+		{Accept: "image/webp;q=0,*/*", ContentType: "image/png"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/img", nil)
+		req.Header.Set("Accept", tt.Accept)
+		w := httptest.NewRecorder()
+		Serve(w, req, bytes.NewReader(data), opts)
+		if want, have := tt.ContentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if want, have := "Accept", w.Header().Get("Vary"); want != have {
+			t.Errorf("#%d: want Vary %q, have %q", i, want, have)
+		}
+
+		// Revalidate
+		etag := w.Header().Get("ETag")
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		Serve(w, req, strings.NewReader(""), opts)
+		if want, have := http.StatusNotModified, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestServeMaxPixels(t *testing.T) {
+	data := testImagePNG(t, 100, 100)
+	tests := []struct {
+		MaxPixels int
+		Code      int
+	}{
+		{MaxPixels: 0, Code: http.StatusOK},
+		{MaxPixels: 100 * 100, Code: http.StatusOK},
+		{MaxPixels: 100*100 - 1, Code: http.StatusUnprocessableEntity},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/img?w=10", nil)
+		w := httptest.NewRecorder()
+		Serve(w, req, bytes.NewReader(data), Options{MaxPixels: tt.MaxPixels})
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestServeDuplicateParameters(t *testing.T) {
+	data := testImagePNG(t, 10, 10)
+	h := httputil.WithDuplicateParameterPolicy(httputil.DuplicatesReject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, bytes.NewReader(data), Options{})
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/img?w=5&w=500", nil))
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
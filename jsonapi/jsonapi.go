@@ -0,0 +1,340 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+// Package jsonapi reads and writes documents as specified by JSON:API 1.1
+// (https://jsonapi.org/format/), i.e. resources with attributes and
+// relationships, compound documents with included resources, errors,
+// and pagination links.
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/olivere/httputil"
+)
+
+// ContentType is the media type of JSON:API documents.
+const ContentType = "application/vnd.api+json"
+
+// Document is the top-level object of a JSON:API request or response.
+type Document struct {
+	// Data is the primary data. A nil Data is omitted, e.g. in error
+	// documents. Use One(nil) to return "data": null.
+	Data *Data `json:"data,omitempty"`
+	// Errors is the list of errors. It must not be combined with Data.
+	Errors []Error `json:"errors,omitempty"`
+	// Included holds resources related to the primary data.
+	Included []Resource `json:"included,omitempty"`
+	// Links holds links of the document, e.g. for pagination.
+	Links Links `json:"links,omitempty"`
+	// Meta holds non-standard meta information.
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// UnmarshalJSON deserializes the document. Unlike the default behavior
+// of encoding/json, "data": null results in a non-nil Data, so a missing
+// "data" member can be told apart.
+func (doc *Document) UnmarshalJSON(data []byte) error {
+	type document Document
+	var v struct {
+		document
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*doc = Document(v.document)
+	if v.Data != nil {
+		doc.Data = new(Data)
+		return doc.Data.UnmarshalJSON(v.Data)
+	}
+	return nil
+}
+
+// Data is the primary data of a Document: A single resource, null, or
+// a list of resources.
+type Data struct {
+	// One is the single resource, or nil for null.
+	One *Resource
+	// Many is the list of resources if IsMany is true.
+	Many []Resource
+	// IsMany indicates that the data is a list of resources.
+	IsMany bool
+}
+
+// One returns primary data consisting of a single resource.
+// If r is nil, the data is serialized as null.
+func One(r *Resource) *Data {
+	return &Data{One: r}
+}
+
+// Many returns primary data consisting of a list of resources.
+func Many(resources ...Resource) *Data {
+	if resources == nil {
+		resources = []Resource{}
+	}
+	return &Data{Many: resources, IsMany: true}
+}
+
+// MarshalJSON serializes the data as a resource, null, or an array.
+func (d Data) MarshalJSON() ([]byte, error) {
+	if d.IsMany {
+		if d.Many == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(d.Many)
+	}
+	return json.Marshal(d.One)
+}
+
+// UnmarshalJSON deserializes a resource, null, or an array.
+func (d *Data) UnmarshalJSON(data []byte) error {
+	*d = Data{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		d.IsMany = true
+		return json.Unmarshal(data, &d.Many)
+	}
+	return json.Unmarshal(data, &d.One)
+}
+
+// Resource is a resource object.
+type Resource struct {
+	// Type is the type of the resource, e.g. "articles". It is required.
+	Type string `json:"type"`
+	// ID identifies the resource. It may be blank for resources that
+	// are created by the client.
+	ID string `json:"id,omitempty"`
+	// Attributes holds the attributes of the resource, typically a struct
+	// or a map. After deserialization, use DecodeAttributes to convert it
+	// into a struct.
+	Attributes interface{} `json:"attributes,omitempty"`
+	// Relationships holds the relationships of the resource by name.
+	Relationships map[string]Relationship `json:"relationships,omitempty"`
+	// Links holds links of the resource, e.g. "self".
+	Links Links `json:"links,omitempty"`
+	// Meta holds non-standard meta information.
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Identifier returns the resource identifier of r.
+func (r Resource) Identifier() ResourceIdentifier {
+	return ResourceIdentifier{Type: r.Type, ID: r.ID}
+}
+
+// DecodeAttributes converts the attributes of r into dst, e.g.
+// a pointer to a struct.
+func (r Resource) DecodeAttributes(dst interface{}) error {
+	data, err := json.Marshal(r.Attributes)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// ResourceIdentifier identifies a resource, e.g. in a relationship.
+type ResourceIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Relationship describes the relation of a resource to other resources.
+type Relationship struct {
+	// Data is the resource linkage. A nil Data is omitted.
+	Data *Linkage `json:"data,omitempty"`
+	// Links holds links of the relationship, e.g. "self" and "related".
+	Links Links `json:"links,omitempty"`
+	// Meta holds non-standard meta information.
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Linkage is the resource linkage of a Relationship: A single resource
+// identifier, null, or a list of resource identifiers.
+type Linkage struct {
+	// One is the single resource identifier, or nil for null.
+	One *ResourceIdentifier
+	// Many is the list of resource identifiers if IsMany is true.
+	Many []ResourceIdentifier
+	// IsMany indicates a to-many relationship.
+	IsMany bool
+}
+
+// ToOne returns a to-one relationship to the resource with the given
+// type and id. If id is blank, the linkage is serialized as null.
+func ToOne(typ, id string) Relationship {
+	if id == "" {
+		return Relationship{Data: &Linkage{}}
+	}
+	return Relationship{Data: &Linkage{One: &ResourceIdentifier{Type: typ, ID: id}}}
+}
+
+// ToMany returns a to-many relationship to the resources with the
+// given type and ids.
+func ToMany(typ string, ids ...string) Relationship {
+	many := make([]ResourceIdentifier, len(ids))
+	for i, id := range ids {
+		many[i] = ResourceIdentifier{Type: typ, ID: id}
+	}
+	return Relationship{Data: &Linkage{Many: many, IsMany: true}}
+}
+
+// MarshalJSON serializes the linkage as an identifier, null, or an array.
+func (l Linkage) MarshalJSON() ([]byte, error) {
+	if l.IsMany {
+		if l.Many == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(l.Many)
+	}
+	return json.Marshal(l.One)
+}
+
+// UnmarshalJSON deserializes an identifier, null, or an array.
+func (l *Linkage) UnmarshalJSON(data []byte) error {
+	*l = Linkage{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		l.IsMany = true
+		return json.Unmarshal(data, &l.Many)
+	}
+	return json.Unmarshal(data, &l.One)
+}
+
+// Links maps link names like "self" or "next" to URLs.
+type Links map[string]string
+
+// PaginationLinks returns the links "first", "prev", "next", and "last"
+// for pagination. Blank URLs are omitted.
+func PaginationLinks(first, prev, next, last string) Links {
+	links := make(Links)
+	for name, url := range map[string]string{"first": first, "prev": prev, "next": next, "last": last} {
+		if url != "" {
+			links[name] = url
+		}
+	}
+	return links
+}
+
+// Error is an error object.
+type Error struct {
+	ID     string                 `json:"id,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Source *ErrorSource           `json:"source,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// ErrorSource refers to the part of the request that caused an error.
+type ErrorSource struct {
+	// Pointer is a JSON Pointer to the value in the request document,
+	// e.g. "/data/attributes/title".
+	Pointer string `json:"pointer,omitempty"`
+	// Parameter is the name of the query parameter that caused the error.
+	Parameter string `json:"parameter,omitempty"`
+	// Header is the name of the request header that caused the error.
+	Header string `json:"header,omitempty"`
+}
+
+// Errors converts err into error objects, one per error detail, with the
+// HTTP status code and message as in httputil.WriteJSONError.
+func Errors(err interface{}) []Error {
+	body := httputil.NewErrorBody(err)
+	status := strconv.Itoa(body.Code)
+	if len(body.Details) == 0 {
+		return []Error{{Status: status, Code: body.Reason, Title: body.Message}}
+	}
+	errs := make([]Error, len(body.Details))
+	for i, detail := range body.Details {
+		errs[i] = Error{Status: status, Code: body.Reason, Title: body.Message, Detail: detail}
+	}
+	return errs
+}
+
+// WriteJSONAPI writes v as a JSON:API document into w with the given
+// HTTP status code. v can be a Document, a Resource, or a list of
+// resources; the latter two are written as the primary data of a
+// document.
+func WriteJSONAPI(w http.ResponseWriter, code int, v interface{}) {
+	var doc Document
+	switch v := v.(type) {
+	case Document:
+		doc = v
+	case *Document:
+		doc = *v
+	case Resource:
+		doc.Data = One(&v)
+	case *Resource:
+		doc.Data = One(v)
+	case []Resource:
+		doc.Data = Many(v...)
+	default:
+		WriteError(w, httputil.ServerError(fmt.Sprintf("jsonapi: unsupported type %T", v)))
+		return
+	}
+	writeDocument(w, code, doc)
+}
+
+// WriteError writes err as a JSON:API error document. The HTTP status
+// code is determined as in httputil.WriteJSONError. Use
+// httputil.SetErrorEncoder(httputil.JSONAPIErrorEncoder) to make
+// httputil.RecoverJSON and the Must* helpers write JSON:API errors, too.
+func WriteError(w http.ResponseWriter, err interface{}) {
+	writeDocument(w, httputil.NewErrorBody(err).Code, Document{Errors: Errors(err)})
+}
+
+func writeDocument(w http.ResponseWriter, code int, doc Document) {
+	js, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		http.Error(w, "JSON serialization error", http.StatusInternalServerError)
+		return
+	}
+	js = append(js, '\n')
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(code)
+	w.Write(js)
+}
+
+// ReadJSONAPI deserializes the body of r into a Document. As required
+// by the specification, it returns httputil.UnsupportedMediaTypeError
+// if the request has a Content-Type other than ContentType or specifies
+// media type parameters other than "ext" and "profile".
+func ReadJSONAPI(r *http.Request) (*Document, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != ContentType {
+		return nil, httputil.UnsupportedMediaTypeError{}
+	}
+	for name := range params {
+		if name != "ext" && name != "profile" {
+			return nil, httputil.UnsupportedMediaTypeError{}
+		}
+	}
+	var doc Document
+	if err := httputil.ReadJSON(r, &doc); err != nil {
+		return nil, httputil.HTTPError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if doc.Data == nil {
+		return nil, httputil.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid JSON:API document",
+			Details: []string{`Missing member "data"`},
+		}
+	}
+	return &doc, nil
+}
+
+// MustReadJSONAPI is like ReadJSONAPI, but panics on errors.
+func MustReadJSONAPI(r *http.Request) *Document {
+	doc, err := ReadJSONAPI(r)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/olivere/httputil"
+)
+
+type article struct {
+	Title string `json:"title"`
+}
+
+func TestWriteJSONAPI(t *testing.T) {
+	doc := Document{
+		Data: Many(Resource{
+			Type:       "articles",
+			ID:         "1",
+			Attributes: article{Title: "JSON:API paints my bikeshed!"},
+			Relationships: map[string]Relationship{
+				"author":   ToOne("people", "9"),
+				"comments": ToMany("comments", "5", "12"),
+				"editor":   ToOne("people", ""),
+			},
+			Links: Links{"self": "/articles/1"},
+		}),
+		Included: []Resource{
+			{Type: "people", ID: "9", Attributes: map[string]string{"name": "Dan"}},
+		},
+		Links: PaginationLinks("", "", "/articles?page[offset]=2", "/articles?page[offset]=10"),
+	}
+	w := httptest.NewRecorder()
+	WriteJSONAPI(w, http.StatusOK, doc)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := ContentType, w.Header().Get("Content-Type"); want != have {
+		t.Errorf("want Content-Type %q, have %q", want, have)
+	}
+	want := `{
+		"data": [{
+			"type": "articles",
+			"id": "1",
+			"attributes": {"title": "JSON:API paints my bikeshed!"},
+			"relationships": {
+				"author": {"data": {"type": "people", "id": "9"}},
+				"comments": {"data": [{"type": "comments", "id": "5"}, {"type": "comments", "id": "12"}]},
+				"editor": {"data": null}
+			},
+			"links": {"self": "/articles/1"}
+		}],
+		"included": [{"type": "people", "id": "9", "attributes": {"name": "Dan"}}],
+		"links": {"last": "/articles?page[offset]=10", "next": "/articles?page[offset]=2"}
+	}`
+	if !httputil.EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+}
+
+func TestWriteJSONAPIResource(t *testing.T) {
+	tests := []struct {
+		Value interface{}
+		Want  string
+	}{
+		{Value: Resource{Type: "articles", ID: "1"}, Want: `{"data":{"type":"articles","id":"1"}}`},
+		{Value: &Resource{Type: "articles", ID: "1"}, Want: `{"data":{"type":"articles","id":"1"}}`},
+		{Value: []Resource(nil), Want: `{"data":[]}`},
+		{Value: Document{Data: One(nil)}, Want: `{"data":null}`},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteJSONAPI(w, http.StatusOK, tt.Value)
+		if !httputil.EqualJSON([]byte(tt.Want), w.Body.Bytes()) {
+			t.Errorf("#%d: want %s, have %s", i, tt.Want, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	WriteJSONAPI(w, http.StatusOK, "unsupported")
+	if want, have := http.StatusInternalServerError, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, httputil.UnprocessableEntityError{Errors: []string{"Title is blank"}})
+	if want, have := http.StatusUnprocessableEntity, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	want := `{"errors":[{"status":"422","title":"Record has semantic errors","detail":"Title is blank"}]}`
+	if !httputil.EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+}
+
+func TestReadJSONAPI(t *testing.T) {
+	body := `{
+		"data": {
+			"type": "articles",
+			"attributes": {"title": "Ember Hamster"},
+			"relationships": {"tags": {"data": [{"type": "tags", "id": "2"}]}}
+		}
+	}`
+	req := httptest.NewRequest("POST", "/articles", strings.NewReader(body))
+	req.Header.Set("Content-Type", ContentType)
+	doc, err := ReadJSONAPI(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Data.IsMany || doc.Data.One == nil {
+		t.Fatalf("want a single resource, have %+v", doc.Data)
+	}
+	res := doc.Data.One
+	if want, have := "articles", res.Type; want != have {
+		t.Errorf("want type %q, have %q", want, have)
+	}
+	var a article
+	if err := res.DecodeAttributes(&a); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "Ember Hamster", a.Title; want != have {
+		t.Errorf("want title %q, have %q", want, have)
+	}
+	tags := res.Relationships["tags"].Data
+	if want, have := []ResourceIdentifier{{Type: "tags", ID: "2"}}, tags.Many; !tags.IsMany || !reflect.DeepEqual(want, have) {
+		t.Errorf("want tags %v, have %+v", want, tags)
+	}
+}
+
+func TestReadJSONAPIFailures(t *testing.T) {
+	tests := []struct {
+		ContentType string
+		Body        string
+		Code        int
+	}{
+		{ContentType: "application/json", Body: `{"data":null}`, Code: http.StatusUnsupportedMediaType},
+		{ContentType: ContentType + "; charset=utf-8", Body: `{"data":null}`, Code: http.StatusUnsupportedMediaType},
+		{ContentType: ContentType + `; profile="https://example.com/p"`, Body: `{"data":null}`, Code: 0},
+		{ContentType: ContentType, Body: `{"data":`, Code: http.StatusBadRequest},
+		{ContentType: ContentType, Body: `{"meta":{}}`, Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.Body))
+		req.Header.Set("Content-Type", tt.ContentType)
+		_, err := ReadJSONAPI(req)
+		code := 0
+		if err != nil {
+			code = err.(interface{ HTTPCode() int }).HTTPCode()
+		}
+		if want, have := tt.Code, code; want != have {
+			t.Errorf("#%d: want code %d, have %d (%v)", i, want, have, err)
+		}
+	}
+}
+
+func TestDataRoundTrip(t *testing.T) {
+	for _, in := range []string{`null`, `[]`, `{"type":"a","id":"1"}`} {
+		var d Data
+		if err := json.Unmarshal([]byte(in), &d); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !httputil.EqualJSON([]byte(in), out) {
+			t.Errorf("want %s, have %s", in, out)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+	// JSONRPCServerError is used for errors of this package, e.g.
+	// NotFoundError. The HTTP status code is passed in the data.
+	JSONRPCServerError = -32000
+)
+
+// JSONRPCError is an error object as specified in JSON-RPC 2.0.
+// Methods may return a *JSONRPCError to control the error code.
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error returns the error in text form.
+func (e *JSONRPCError) Error() string { return e.Message }
+
+type jsonrpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonrpcResponse struct {
+	Version string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError    `json:"error,omitempty"`
+	ID      json.RawMessage  `json:"id"`
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// JSONRPCHandler is a http.Handler that dispatches JSON-RPC 2.0 requests,
+// both single and batched, to registered methods.
+type JSONRPCHandler struct {
+	mu      sync.RWMutex
+	methods map[string]reflect.Value
+}
+
+// NewJSONRPCHandler creates a new JSONRPCHandler without any methods.
+func NewJSONRPCHandler() *JSONRPCHandler {
+	return &JSONRPCHandler{methods: make(map[string]reflect.Value)}
+}
+
+// Register adds the Go func fn as method name. The func must have one
+// of the following signatures, where P is deserialized from the params
+// of the request and R is serialized as the result:
+//
+//	func(ctx context.Context) (R, error)
+//	func(ctx context.Context, params P) (R, error)
+func (h *JSONRPCHandler) Register(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func ||
+		t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != contextType ||
+		t.NumOut() != 2 || t.Out(1) != errorType {
+		return fmt.Errorf("jsonrpc: invalid signature %v for method %q", t, name)
+	}
+	h.mu.Lock()
+	h.methods[name] = v
+	h.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP handles a JSON-RPC 2.0 request.
+func (h *JSONRPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSONError(w, r, InvalidMethodError{})
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 8<<20))
+	if err != nil {
+		h.write(w, jsonrpcFailure(nil, &JSONRPCError{Code: JSONRPCParseError, Message: "Parse error"}))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	// Batch
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			h.write(w, jsonrpcFailure(nil, &JSONRPCError{Code: JSONRPCParseError, Message: "Parse error"}))
+			return
+		}
+		if len(batch) == 0 {
+			h.write(w, jsonrpcFailure(nil, &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "Invalid Request"}))
+			return
+		}
+		var responses []*jsonrpcResponse
+		for _, raw := range batch {
+			if resp := h.call(r.Context(), raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.write(w, responses)
+		return
+	}
+
+	// Single
+	if resp := h.call(r.Context(), body); resp != nil {
+		h.write(w, resp)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *JSONRPCHandler) write(w http.ResponseWriter, v interface{}) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		js, _ = json.Marshal(jsonrpcFailure(nil, &JSONRPCError{Code: JSONRPCInternalError, Message: "Internal error"}))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// call invokes a single request. It returns nil for notifications.
+func (h *JSONRPCHandler) call(ctx context.Context, raw json.RawMessage) (resp *jsonrpcResponse) {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return jsonrpcFailure(nil, &JSONRPCError{Code: JSONRPCParseError, Message: "Parse error"})
+		}
+		return jsonrpcFailure(nil, &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "Invalid Request"})
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		return jsonrpcFailure(req.ID, &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "Invalid Request"})
+	}
+	notification := len(req.ID) == 0
+
+	h.mu.RLock()
+	fn, found := h.methods[req.Method]
+	h.mu.RUnlock()
+	if !found {
+		if notification {
+			return nil
+		}
+		return jsonrpcFailure(req.ID, &JSONRPCError{Code: JSONRPCMethodNotFound, Message: "Method not found"})
+	}
+
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	if fn.Type().NumIn() == 2 {
+		pt := fn.Type().In(1)
+		pv := reflect.New(pt)
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, pv.Interface()); err != nil {
+				if notification {
+					return nil
+				}
+				return jsonrpcFailure(req.ID, &JSONRPCError{Code: JSONRPCInvalidParams, Message: "Invalid params"})
+			}
+		}
+		args = append(args, pv.Elem())
+	}
+
+	defer func() {
+		if rerr := recover(); rerr != nil {
+			resp = nil
+			if !notification {
+				resp = jsonrpcFailure(req.ID, &JSONRPCError{Code: JSONRPCInternalError, Message: "Internal error"})
+			}
+		}
+	}()
+	out := fn.Call(args)
+	if notification {
+		return nil
+	}
+	if err, _ := out[1].Interface().(error); err != nil {
+		return jsonrpcFailure(req.ID, toJSONRPCError(err))
+	}
+	result, err := json.Marshal(out[0].Interface())
+	if err != nil {
+		return jsonrpcFailure(req.ID, &JSONRPCError{Code: JSONRPCInternalError, Message: "Internal error"})
+	}
+	return &jsonrpcResponse{Version: "2.0", Result: (*json.RawMessage)(&result), ID: req.ID}
+}
+
+func jsonrpcFailure(id json.RawMessage, err *JSONRPCError) *jsonrpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &jsonrpcResponse{Version: "2.0", Error: err, ID: id}
+}
+
+// toJSONRPCError maps errors of this package to JSON-RPC error objects.
+// Errors with HTTP status 400 become "Invalid params", all other errors
+// become server errors with the HTTP status code and details as data.
+func toJSONRPCError(err error) *JSONRPCError {
+	if e, ok := err.(*JSONRPCError); ok {
+		return e
+	}
+	code := 500
+	if i, ok := err.(httpCoder); ok {
+		code = i.HTTPCode()
+	}
+	data := map[string]interface{}{"status": code}
+	if i, ok := err.(httpErrorDetails); ok {
+		if details := i.ErrorDetails(); len(details) > 0 {
+			data["details"] = details
+		}
+	}
+	e := &JSONRPCError{Code: JSONRPCServerError, Message: err.Error(), Data: data}
+	if code == http.StatusBadRequest {
+		e.Code = JSONRPCInvalidParams
+	}
+	return e
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONRPCHandler(t *testing.T) {
+	h := NewJSONRPCHandler()
+	type sumParams struct {
+		A, B int
+	}
+	if err := h.Register("sum", func(ctx context.Context, p sumParams) (int, error) {
+		return p.A + p.B, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Register("find", func(ctx context.Context, id []int) (interface{}, error) {
+		return nil, NotFoundError{}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Register("validate", func(ctx context.Context) (bool, error) {
+		return false, InvalidParameterError("name")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Register("invalid", func(p sumParams) int { return 0 }); err == nil {
+		t.Fatal("expected Register to fail for invalid signature")
+	}
+
+	tests := []struct {
+		Body string
+		Code int
+		Want string
+	}{
+		{
+			Body: `{"jsonrpc":"2.0","method":"sum","params":{"A":1,"B":2},"id":1}`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","result":3,"id":1}`,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method":"sum","params":{"A":0,"B":0},"id":"a"}`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","result":0,"id":"a"}`,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method":"nope","id":1}`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":1}`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":1}`,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method":"find","params":[1],"id":1}`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","error":{"code":-32000,"message":"Record not found","data":{"status":404}},"id":1}`,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method":"validate","id":1}`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid parameter \"name\"","data":{"status":400}},"id":1}`,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method":"sum","params":{"A":1,"B":2}}`,
+			Code: 204,
+			Want: ``,
+		},
+		{
+			Body: `{"jsonrpc":"2.0","method"`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`,
+		},
+		{
+			Body: `[]`,
+			Code: 200,
+			Want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+		},
+		{
+			Body: `[{"jsonrpc":"2.0","method":"sum","params":{"A":1,"B":2},"id":1},{"jsonrpc":"2.0","method":"sum"},1]`,
+			Code: 200,
+			Want: `[{"jsonrpc":"2.0","result":3,"id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`,
+		},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(tt.Body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Want, w.Body.String(); want != have {
+			t.Errorf("#%d: want body\n%s\nhave\n%s", i, want, have)
+		}
+	}
+}
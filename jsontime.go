@@ -0,0 +1,164 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONDuration is a time.Duration that is written to JSON as a string
+// like "1h30m". It can be read from a string like "1h30m" or "250ms",
+// or from an integer number of milliseconds.
+type JSONDuration time.Duration
+
+// Duration returns d as a time.Duration.
+func (d JSONDuration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String returns d in a form like "1h30m", without trailing zero units.
+func (d JSONDuration) String() string {
+	s := time.Duration(d).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d JSONDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *JSONDuration) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return d.UnmarshalText([]byte(s))
+	}
+	ms, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s: want a string or milliseconds", data)
+	}
+	*d = JSONDuration(time.Duration(ms) * time.Millisecond)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d JSONDuration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, e.g. for query
+// string parameters. Like UnmarshalJSON, it accepts integer milliseconds.
+func (d *JSONDuration) UnmarshalText(text []byte) error {
+	if ms, err := strconv.ParseInt(string(text), 10, 64); err == nil {
+		*d = JSONDuration(time.Duration(ms) * time.Millisecond)
+		return nil
+	}
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = JSONDuration(v)
+	return nil
+}
+
+// DefaultJSONTimeLayout is the default layout of JSONTime.
+const DefaultJSONTimeLayout = time.RFC3339
+
+// SetJSONTimeLayout sets the layout JSONTime uses for all services of
+// the process, e.g. time.RFC3339Nano. Passing an empty string restores
+// DefaultJSONTimeLayout.
+func SetJSONTimeLayout(layout string) {
+	updateConfig(func(cfg *Config) { cfg.JSONTimeLayout = layout })
+}
+
+func currentJSONTimeLayout() string {
+	if layout := CurrentConfig().JSONTimeLayout; layout != "" {
+		return layout
+	}
+	return DefaultJSONTimeLayout
+}
+
+// JSONTime is a time.Time that is always written in UTC, with the layout
+// set by SetJSONTimeLayout. The zero time is written as null. JSONTime
+// reads times in the layout as well as in RFC 3339, and converts them
+// to UTC.
+type JSONTime struct {
+	time.Time
+}
+
+// NewJSONTime returns t as a JSONTime.
+func NewJSONTime(t time.Time) JSONTime {
+	return JSONTime{Time: t.UTC()}
+}
+
+// String returns t in the layout set by SetJSONTimeLayout.
+func (t JSONTime) String() string {
+	return t.Time.UTC().Format(currentJSONTimeLayout())
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t JSONTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *JSONTime) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid time %s: want a string", data)
+	}
+	return t.UnmarshalText([]byte(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t JSONTime) MarshalText() ([]byte, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, e.g. for query
+// string parameters.
+func (t *JSONTime) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		t.Time = time.Time{}
+		return nil
+	}
+	v, err := time.Parse(currentJSONTimeLayout(), string(text))
+	if err != nil {
+		var err2 error
+		if v, err2 = time.Parse(time.RFC3339Nano, string(text)); err2 != nil {
+			return err
+		}
+	}
+	t.Time = v.UTC()
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestJSONDuration(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Want    time.Duration
+		WantErr bool
+		Output  string
+	}{
+		{Input: `"1h30m"`, Want: 90 * time.Minute, Output: `"1h30m"`},
+		{Input: `"2h"`, Want: 2 * time.Hour, Output: `"2h"`},
+		{Input: `"1m30s"`, Want: 90 * time.Second, Output: `"1m30s"`},
+		{Input: `"250ms"`, Want: 250 * time.Millisecond, Output: `"250ms"`},
+		{Input: `1500`, Want: 1500 * time.Millisecond, Output: `"1.5s"`},
+		{Input: `"1500"`, Want: 1500 * time.Millisecond, Output: `"1.5s"`},
+		{Input: `0`, Want: 0, Output: `"0s"`},
+		{Input: `1.5`, WantErr: true},
+		{Input: `"soon"`, WantErr: true},
+	}
+	for i, tt := range tests {
+		var d JSONDuration
+		err := json.Unmarshal([]byte(tt.Input), &d)
+		if tt.WantErr {
+			if err == nil {
+				t.Errorf("#%d: want error, have %v", i, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Want, d.Duration(); want != have {
+			t.Errorf("#%d: want %v, have %v", i, want, have)
+		}
+		js, _ := json.Marshal(d)
+		if want, have := tt.Output, string(js); want != have {
+			t.Errorf("#%d: want %s, have %s", i, want, have)
+		}
+	}
+}
+
+func TestJSONTime(t *testing.T) {
+	defer SetJSONTimeLayout("")
+
+	type event struct {
+		At      JSONTime `json:"at"`
+		Expires JSONTime `json:"expires"`
+	}
+	var ev event
+	if err := json.Unmarshal([]byte(`{"at":"2017-05-01T12:00:00+02:00"}`), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC), ev.At.Time; !want.Equal(have) || have.Location() != time.UTC {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	js, _ := json.Marshal(ev)
+	if want, have := `{"at":"2017-05-01T10:00:00Z","expires":null}`, string(js); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	SetJSONTimeLayout("2006-01-02 15:04:05")
+	js, _ = json.Marshal(ev)
+	if want, have := `{"at":"2017-05-01 10:00:00","expires":null}`, string(js); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	// RFC 3339 is still accepted
+	for _, input := range []string{`"2017-05-01 10:00:00"`, `"2017-05-01T10:00:00Z"`} {
+		var at JSONTime
+		if err := json.Unmarshal([]byte(input), &at); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if !at.Equal(ev.At.Time) {
+			t.Errorf("%s: want %v, have %v", input, ev.At, at)
+		}
+	}
+	var at JSONTime
+	if err := json.Unmarshal([]byte(`1493632800`), &at); err == nil {
+		t.Error("want error for number")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentTypeJOSE is the content type of payloads in JWE compact
+// serialization, as used by ReadEncryptedJSON and WriteEncryptedJSON.
+const ContentTypeJOSE = "application/jose"
+
+// JWEKeyProvider provides the keys for encrypting and decrypting payloads
+// with ReadEncryptedJSON and WriteEncryptedJSON. Keys are used directly as
+// content encryption keys ("dir") and must be 16, 24, or 32 bytes long,
+// selecting A128GCM, A192GCM, or A256GCM respectively.
+type JWEKeyProvider interface {
+	// EncryptionKey returns the key to encrypt with, and its identifier.
+	EncryptionKey(ctx context.Context) (kid string, key []byte, err error)
+	// DecryptionKey returns the key with the given identifier.
+	DecryptionKey(ctx context.Context, kid string) ([]byte, error)
+}
+
+// StaticJWEKey is a JWEKeyProvider with a single key.
+type StaticJWEKey struct {
+	ID  string
+	Key []byte
+}
+
+// EncryptionKey returns the key.
+func (k StaticJWEKey) EncryptionKey(ctx context.Context) (string, []byte, error) {
+	return k.ID, k.Key, nil
+}
+
+// DecryptionKey returns the key if kid matches its identifier.
+func (k StaticJWEKey) DecryptionKey(ctx context.Context, kid string) ([]byte, error) {
+	if kid != k.ID {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return k.Key, nil
+}
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid,omitempty"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// ReadEncryptedJSON decrypts the body of the request, given in JWE compact
+// serialization, and deserializes the plaintext into dst as JSON.
+// The request must have a Content-Type of ContentTypeJOSE, otherwise
+// UnsupportedMediaTypeError is returned. Like ReadJSON, a maximum size
+// of 8 MB is permitted, and the JSONCodec of the Config in effect for r
+// is used.
+func ReadEncryptedJSON(r *http.Request, keys JWEKeyProvider, dst interface{}) error {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != ContentTypeJOSE {
+		return UnsupportedMediaTypeError{}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 8<<20))
+	if err != nil {
+		return InvalidJSONError{fmt.Errorf("invalid JWE data: %v", err)}
+	}
+	plaintext, err := decryptJWE(r.Context(), keys, strings.TrimSpace(string(data)))
+	if err != nil {
+		return InvalidJSONError{fmt.Errorf("invalid JWE data: %v", err)}
+	}
+	if err := unmarshalJSONFor(r, plaintext, dst); err != nil {
+		return InvalidJSONError{fmt.Errorf("invalid JSON data: %v", err)}
+	}
+	return nil
+}
+
+// WriteEncryptedJSON serializes data as JSON, encrypts it in JWE compact
+// serialization and writes it into w with the given HTTP status code.
+// Like ServeJSONError, it uses the Config in effect for r.
+func WriteEncryptedJSON(w http.ResponseWriter, r *http.Request, code int, data interface{}, keys JWEKeyProvider) {
+	js, err := marshalCompactJSONFor(r, data)
+	if err != nil {
+		badRequestError(w, r, "JSON serialization error: %v", err)
+		return
+	}
+	token, err := encryptJWE(r.Context(), keys, js)
+	if err != nil {
+		writeJSONError(w, r, ServerError("Unable to encrypt response"))
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeJOSE)
+	w.WriteHeader(code)
+	io.WriteString(w, token)
+}
+
+func jweEncryption(key []byte) (string, error) {
+	switch len(key) {
+	case 16:
+		return "A128GCM", nil
+	case 24:
+		return "A192GCM", nil
+	case 32:
+		return "A256GCM", nil
+	}
+	return "", errors.New("invalid key size")
+}
+
+func encryptJWE(ctx context.Context, keys JWEKeyProvider, plaintext []byte) (string, error) {
+	kid, key, err := keys.EncryptionKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	enc, err := jweEncryption(key)
+	if err != nil {
+		return "", err
+	}
+	hdr, err := json.Marshal(jweHeader{Alg: "dir", Enc: enc, Kid: kid, Cty: "application/json"})
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(hdr)
+	sealed := aead.Seal(nil, iv, plaintext, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+	return strings.Join([]string{
+		protected,
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+func decryptJWE(ctx context.Context, keys JWEKeyProvider, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, errors.New("expected compact serialization")
+	}
+	var raw [5][]byte
+	for i, p := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(p)
+		if err != nil {
+			return nil, err
+		}
+		raw[i] = b
+	}
+	var hdr jweHeader
+	if err := json.Unmarshal(raw[0], &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Alg != "dir" || len(raw[1]) != 0 {
+		return nil, fmt.Errorf("unsupported algorithm %q", hdr.Alg)
+	}
+	key, err := keys.DecryptionKey(ctx, hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if enc, err := jweEncryption(key); err != nil || enc != hdr.Enc {
+		return nil, fmt.Errorf("unsupported encryption %q", hdr.Enc)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw[2]) != aead.NonceSize() {
+		return nil, errors.New("invalid initialization vector")
+	}
+	return aead.Open(nil, raw[2], append(raw[3], raw[4]...), []byte(parts[0]))
+}
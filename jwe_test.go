@@ -0,0 +1,66 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedJSONRoundtrip(t *testing.T) {
+	keys := StaticJWEKey{ID: "partner-1", Key: []byte("0123456789abcdef0123456789abcdef")}
+
+	type payload struct {
+		IBAN string `json:"iban"`
+	}
+
+	w := httptest.NewRecorder()
+	WriteEncryptedJSON(w, httptest.NewRequest("GET", "/", nil), http.StatusOK, payload{IBAN: "DE89370400440532013000"}, keys)
+	if want, have := ContentTypeJOSE, w.Header().Get("Content-Type"); want != have {
+		t.Fatalf("want Content-Type %q, have %q", want, have)
+	}
+	if strings.Contains(w.Body.String(), "DE89") {
+		t.Fatalf("expected body to be encrypted; got: %s", w.Body.String())
+	}
+	if n := strings.Count(w.Body.String(), "."); n != 4 {
+		t.Fatalf("expected JWE compact serialization with 5 parts; got: %s", w.Body.String())
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(w.Body.Bytes()))
+	req.Header.Set("Content-Type", ContentTypeJOSE)
+	var dst payload
+	if err := ReadEncryptedJSON(req, keys, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "DE89370400440532013000", dst.IBAN; want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+}
+
+func TestReadEncryptedJSONFailure(t *testing.T) {
+	keys := StaticJWEKey{ID: "partner-1", Key: []byte("0123456789abcdef")}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"iban":"DE89370400440532013000"}`))
+	req.Header.Set("Content-Type", "application/json")
+	var dst struct{}
+	if _, ok := ReadEncryptedJSON(req, keys, &dst).(UnsupportedMediaTypeError); !ok {
+		t.Fatal("expected UnsupportedMediaTypeError")
+	}
+
+	w := httptest.NewRecorder()
+	WriteEncryptedJSON(w, httptest.NewRequest("GET", "/", nil), http.StatusOK, dst, keys)
+	tampered := []byte(w.Body.String())
+	tampered[len(tampered)-2] ^= 1
+
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(tampered))
+	req.Header.Set("Content-Type", ContentTypeJOSE)
+	err := ReadEncryptedJSON(req, keys, &dst)
+	if _, ok := err.(InvalidJSONError); !ok {
+		t.Fatalf("expected InvalidJSONError; got: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJWK returns the public JWK of key.
+func testJWK(kid string, key crypto.Signer) map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(pub)}
+	}
+	return nil
+}
+
+// testJWT returns a token with the given claims, signed by key.
+func testJWT(t *testing.T, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	var alg string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		alg = "ES256"
+	case ed25519.PrivateKey:
+		alg = "EdDSA"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	var err error
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, sum[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(input))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// testIdentityProvider serves a discovery document and a JWKS.
+type testIdentityProvider struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     []map[string]string
+	failing  bool
+	requests int32
+}
+
+func newTestIdentityProvider(keys ...map[string]string) *testIdentityProvider {
+	idp := &testIdentityProvider{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, map[string]string{"issuer": idp.URL, "jwks_uri": idp.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&idp.requests, 1)
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		if idp.failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		WriteJSON(w, map[string]interface{}{"keys": idp.keys})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *testIdentityProvider) setKeys(failing bool, keys ...map[string]string) {
+	idp.mu.Lock()
+	idp.failing = failing
+	if keys != nil {
+		idp.keys = keys
+	}
+	idp.mu.Unlock()
+}
+
+func TestKeySetProvider(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	idp := newTestIdentityProvider(testJWK("rsa", rsaKey), testJWK("ec", ecKey))
+	defer idp.Close()
+
+	keys := NewKeySetProvider(KeySetConfig{
+		DiscoveryURL:       idp.URL + "/.well-known/openid-configuration",
+		MinRefreshInterval: time.Millisecond,
+	})
+	ctx := context.Background()
+	if issuer, err := keys.Issuer(ctx); err != nil || issuer != idp.URL {
+		t.Fatalf("want issuer %q, have %q (%v)", idp.URL, issuer, err)
+	}
+
+	claims := map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	for _, kid := range []string{"rsa", "ec"} {
+		key := map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey}[kid]
+		have, err := VerifyJWT(ctx, testJWT(t, kid, key, claims), keys)
+		if err != nil {
+			t.Fatalf("%s: %v", kid, err)
+		}
+		if want, have := "alice", have.String("sub"); want != have {
+			t.Errorf("%s: want sub %q, have %q", kid, want, have)
+		}
+	}
+
+	// Key rotation: unknown key ids trigger a refresh
+	idp.setKeys(false, testJWK("ed", edKey))
+	time.Sleep(5 * time.Millisecond)
+	if _, err := VerifyJWT(ctx, testJWT(t, "ed", edKey, claims), keys); err != nil {
+		t.Fatalf("want rotated key to be found, have %v", err)
+	}
+
+	// Failing refreshes keep the previous keys
+	idp.setKeys(true)
+	time.Sleep(5 * time.Millisecond)
+	if err := keys.Refresh(ctx); err != nil {
+		t.Errorf("want previous keys to be kept, have %v", err)
+	}
+	if _, err := VerifyJWT(ctx, testJWT(t, "ed", edKey, claims), keys); err != nil {
+		t.Errorf("want previous keys to be used, have %v", err)
+	}
+	if _, err := VerifyJWT(ctx, testJWT(t, "rsa", rsaKey, claims), keys); err == nil {
+		t.Error("want error for removed key")
+	}
+
+	// Invalid tokens
+	expired := map[string]interface{}{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}
+	if _, err := VerifyJWT(ctx, testJWT(t, "ed", edKey, expired), keys); err == nil {
+		t.Error("want error for expired token")
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := VerifyJWT(ctx, testJWT(t, "ed", otherKey, claims), keys); err == nil {
+		t.Error("want error for invalid signature")
+	}
+	if _, err := VerifyJWT(ctx, "not.a.jwt", keys); err == nil {
+		t.Error("want error for malformed token")
+	}
+	noExpiry := map[string]interface{}{"sub": "alice"}
+	if _, err := VerifyJWT(ctx, testJWT(t, "ed", edKey, noExpiry), keys); err == nil {
+		t.Error("want error for token without exp")
+	}
+}
+
+func TestVerifyJWTSignatureCurve(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	input := []byte("header.payload")
+	sum := sha512.Sum384(input)
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	if err := verifyJWTSignature("ES384", &key.PublicKey, input, sig); err == nil {
+		t.Error("want error for ES384 with a P-256 key")
+	}
+}
+
+func TestKeySetProviderMinRefreshInterval(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	idp := newTestIdentityProvider(testJWK("ed", edKey))
+	defer idp.Close()
+
+	keys := NewKeySetProvider(KeySetConfig{JWKSURL: idp.URL + "/jwks"})
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if _, err := keys.PublicKey(ctx, "unknown"); err == nil {
+			t.Fatal("want error for unknown key")
+		}
+	}
+	if want, have := int32(1), atomic.LoadInt32(&idp.requests); want != have {
+		t.Errorf("want %d request, have %d", want, have)
+	}
+}
+
+func TestRequireBearerJWT(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	idp := newTestIdentityProvider(testJWK("ed", edKey))
+	defer idp.Close()
+
+	h := RequireBearerJWT(JWTConfig{
+		Keys:     NewKeySetProvider(KeySetConfig{JWKSURL: idp.URL + "/jwks"}),
+		Issuer:   "https://accounts.example.com",
+		Audience: "orders",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(JWTClaimsFromContext(r.Context()).String("sub")))
+	}))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		Claims map[string]interface{}
+		Code   int
+	}{
+		{Claims: nil, Code: http.StatusUnauthorized},
+		{Claims: map[string]interface{}{"sub": "alice", "iss": "https://accounts.example.com", "aud": "orders", "exp": exp}, Code: http.StatusOK},
+		{Claims: map[string]interface{}{"sub": "alice", "iss": "https://accounts.example.com", "aud": []string{"billing", "orders"}, "exp": exp}, Code: http.StatusOK},
+		{Claims: map[string]interface{}{"sub": "alice", "iss": "https://evil.example.com", "aud": "orders", "exp": exp}, Code: http.StatusUnauthorized},
+		{Claims: map[string]interface{}{"sub": "alice", "iss": "https://accounts.example.com", "aud": "billing", "exp": exp}, Code: http.StatusUnauthorized},
+		{Claims: map[string]interface{}{"sub": "alice", "iss": "https://accounts.example.com", "aud": "orders"}, Code: http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.Claims != nil {
+			req.Header.Set("Authorization", "Bearer "+testJWT(t, "ed", edKey, tt.Claims))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := "alice", w.Body.String(); want != have {
+				t.Errorf("#%d: want %q, have %q", i, want, have)
+			}
+		} else if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("#%d: want WWW-Authenticate header", i)
+		}
+	}
+}
+
+func TestJWTConfigVerify(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	idp := newTestIdentityProvider(testJWK("ed", edKey))
+	defer idp.Close()
+	discovery := NewKeySetProvider(KeySetConfig{DiscoveryURL: idp.URL + "/.well-known/openid-configuration"})
+	jwksOnly := NewKeySetProvider(KeySetConfig{JWKSURL: idp.URL + "/jwks"})
+
+	exp := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		Config JWTConfig
+		Claims map[string]interface{}
+		OK     bool
+	}{
+		// Issuer defaults to the issuer of the discovery document
+		{Config: JWTConfig{Keys: discovery, Audience: "orders"}, Claims: map[string]interface{}{"iss": idp.URL, "aud": "orders", "exp": exp}, OK: true},
+		{Config: JWTConfig{Keys: discovery, Audience: "orders"}, Claims: map[string]interface{}{"iss": "https://evil.example.com", "aud": "orders", "exp": exp}},
+		// No issuer to check
+		{Config: JWTConfig{Keys: jwksOnly, Audience: "orders"}, Claims: map[string]interface{}{"iss": idp.URL, "aud": "orders", "exp": exp}},
+		{Config: JWTConfig{Keys: jwksOnly, Audience: "orders", SkipIssuer: true}, Claims: map[string]interface{}{"aud": "orders", "exp": exp}, OK: true},
+		// Audience
+		{Config: JWTConfig{Keys: jwksOnly, SkipIssuer: true}, Claims: map[string]interface{}{"aud": "orders", "exp": exp}},
+		{Config: JWTConfig{Keys: jwksOnly, SkipIssuer: true, SkipAudience: true}, Claims: map[string]interface{}{"aud": "billing", "exp": exp}, OK: true},
+		// Expiry
+		{Config: JWTConfig{Keys: jwksOnly, SkipIssuer: true, SkipAudience: true}, Claims: map[string]interface{}{"sub": "alice"}},
+		{Config: JWTConfig{Keys: jwksOnly, SkipIssuer: true, SkipAudience: true, AllowNoExpiry: true}, Claims: map[string]interface{}{"sub": "alice"}, OK: true},
+	}
+	for i, tt := range tests {
+		_, err := tt.Config.Verify(context.Background(), testJWT(t, "ed", edKey, tt.Claims))
+		if want, have := tt.OK, err == nil; want != have {
+			t.Errorf("#%d: want ok=%v, have %v", i, want, have)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic without Audience")
+		}
+	}()
+	RequireBearerJWT(JWTConfig{Keys: jwksOnly})
+}
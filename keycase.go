@@ -0,0 +1,251 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// KeyCase is the casing of the keys of JSON objects, see RewriteKeys.
+type KeyCase int
+
+const (
+	// KeepCase leaves keys unchanged.
+	KeepCase KeyCase = iota
+	// SnakeCase rewrites keys like "userId" to "user_id".
+	SnakeCase
+	// CamelCase rewrites keys like "user_id" to "userId".
+	CamelCase
+)
+
+// KeyCaseConfig configures RewriteKeys.
+type KeyCaseConfig struct {
+	// Default is the casing used if the request asks for no profile
+	// of Profiles. It defaults to KeepCase.
+	Default KeyCase
+	// Profiles maps the profile parameter of the Accept header to a
+	// casing, e.g. "camel" to CamelCase for a request with
+	// "Accept: application/json; profile=camel".
+	Profiles map[string]KeyCase
+}
+
+// RewriteKeys returns a middleware that rewrites the keys of JSON
+// objects in responses, e.g. to serve legacy snake_case clients as well
+// as camelCase clients from the same handlers. Use it per route with a
+// fixed Default, or let clients choose with Profiles.
+//
+// Only responses with a JSON content type are rewritten. The output is
+// rewritten as it is written, so streaming responses work as well.
+// Keys containing escape sequences are left unchanged.
+//
+// Example:
+//
+//	mw := httputil.RewriteKeys(httputil.KeyCaseConfig{
+//	  Profiles: map[string]httputil.KeyCase{"camel": httputil.CamelCase},
+//	})
+func RewriteKeys(cfg KeyCaseConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kc := cfg.Default
+			if len(cfg.Profiles) > 0 {
+				w.Header().Add("Vary", "Accept")
+				if c, found := cfg.Profiles[acceptProfile(r)]; found {
+					kc = c
+				}
+			}
+			if kc == KeepCase {
+				next.ServeHTTP(w, r)
+				return
+			}
+			kw := &keyCaseResponseWriter{responseWriter: responseWriter{w}, kr: &keyRewriter{kc: kc}}
+			next.ServeHTTP(wrapResponseWriter(kw), r)
+			kw.finish()
+		})
+	}
+}
+
+// acceptProfile returns the profile parameter of the Accept header of r.
+func acceptProfile(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if _, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil {
+			if profile := params["profile"]; profile != "" {
+				return profile
+			}
+		}
+	}
+	return ""
+}
+
+// keyCaseResponseWriter is the http.ResponseWriter used by RewriteKeys.
+type keyCaseResponseWriter struct {
+	responseWriter
+	kr          *keyRewriter
+	wroteHeader bool
+	active      bool
+}
+
+func (kw *keyCaseResponseWriter) WriteHeader(code int) {
+	if kw.wroteHeader {
+		return
+	}
+	kw.wroteHeader = true
+	if isJSONContentType(kw.Header().Get("Content-Type")) {
+		kw.active = true
+		kw.Header().Del("Content-Length")
+	}
+	kw.ResponseWriter.WriteHeader(code)
+}
+
+func (kw *keyCaseResponseWriter) Write(p []byte) (int, error) {
+	if !kw.wroteHeader {
+		kw.WriteHeader(http.StatusOK)
+	}
+	if !kw.active {
+		return kw.ResponseWriter.Write(p)
+	}
+	if _, err := kw.ResponseWriter.Write(kw.kr.rewrite(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finish writes what is left of an incomplete JSON document.
+func (kw *keyCaseResponseWriter) finish() {
+	if kw.active && len(kw.kr.pending) > 0 {
+		kw.ResponseWriter.Write(kw.kr.pending)
+		kw.kr.pending = nil
+	}
+}
+
+// keyRewriter rewrites the keys of JSON objects in a stream of JSON
+// data, leaving everything else byte by byte unchanged.
+type keyRewriter struct {
+	kc        KeyCase
+	stack     []byte // '{' or '['
+	expectKey bool
+	pending   []byte // incomplete string at the end of the last chunk
+}
+
+// rewrite returns p with rewritten keys. Strings that are incomplete
+// at the end of p are kept until the next call.
+func (kr *keyRewriter) rewrite(p []byte) []byte {
+	data := p
+	if len(kr.pending) > 0 {
+		data = append(kr.pending, p...)
+		kr.pending = nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '{':
+			kr.stack = append(kr.stack, c)
+			kr.expectKey = true
+		case '[':
+			kr.stack = append(kr.stack, c)
+			kr.expectKey = false
+		case '}', ']':
+			if len(kr.stack) > 0 {
+				kr.stack = kr.stack[:len(kr.stack)-1]
+			}
+			kr.expectKey = false
+		case ',':
+			kr.expectKey = len(kr.stack) > 0 && kr.stack[len(kr.stack)-1] == '{'
+		case ':':
+			kr.expectKey = false
+		case '"':
+			end, escaped := scanJSONString(data, i+1)
+			if end < 0 {
+				kr.pending = append([]byte(nil), data[i:]...)
+				return out
+			}
+			if kr.expectKey && !escaped {
+				out = append(out, '"')
+				out = append(out, convertKeyCase(string(data[i+1:end]), kr.kc)...)
+				out = append(out, '"')
+				kr.expectKey = false
+			} else {
+				out = append(out, data[i:end+1]...)
+			}
+			i = end
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// scanJSONString returns the index of the closing quote of the JSON
+// string starting at data[start], and whether it contains escape
+// sequences. It returns -1 if the string is incomplete.
+func scanJSONString(data []byte, start int) (int, bool) {
+	escaped := false
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			escaped = true
+			i++
+		case '"':
+			return i, escaped
+		}
+	}
+	return -1, escaped
+}
+
+// convertKeyCase returns key in the casing kc.
+func convertKeyCase(key string, kc KeyCase) string {
+	switch kc {
+	case SnakeCase:
+		return toSnakeCase(key)
+	case CamelCase:
+		return toCamelCase(key)
+	}
+	return key
+}
+
+// toSnakeCase converts e.g. "userId" and "userID" to "user_id".
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					sb.WriteByte('_')
+				}
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// toCamelCase converts e.g. "user_id" to "userId".
+func toCamelCase(s string) string {
+	var sb strings.Builder
+	upper := false
+	for i, r := range s {
+		if (r == '_' || r == '-') && i > 0 {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	if upper {
+		// Keep trailing separators
+		sb.WriteString(s[len(strings.TrimRight(s, "_-")):])
+	}
+	return sb.String()
+}
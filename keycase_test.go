@@ -0,0 +1,90 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConvertKeyCase(t *testing.T) {
+	tests := []struct {
+		Input string
+		Case  KeyCase
+		Want  string
+	}{
+		{"userId", SnakeCase, "user_id"},
+		{"userID", SnakeCase, "user_id"},
+		{"HTTPStatus", SnakeCase, "http_status"},
+		{"line2Total", SnakeCase, "line2_total"},
+		{"user_id", SnakeCase, "user_id"},
+		{"user_id", CamelCase, "userId"},
+		{"created-at", CamelCase, "createdAt"},
+		{"userId", CamelCase, "userId"},
+		{"_id", CamelCase, "_id"},
+		{"user_id", KeepCase, "user_id"},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Want, convertKeyCase(tt.Input, tt.Case); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestKeyRewriterChunks(t *testing.T) {
+	input := `{"user_id":1,"tags":["first_name",{"last_name":"a_b"}],"a_b":"x","nested_obj":{"is_ok":true}}`
+	want := `{"userId":1,"tags":["first_name",{"lastName":"a_b"}],"aB":"x","nestedObj":{"isOk":true}}`
+	for size := 1; size <= len(input); size++ {
+		kr := &keyRewriter{kc: CamelCase}
+		var have []byte
+		for i := 0; i < len(input); i += size {
+			end := i + size
+			if end > len(input) {
+				end = len(input)
+			}
+			have = append(have, kr.rewrite([]byte(input[i:end]))...)
+		}
+		if string(have) != want {
+			t.Fatalf("chunk size %d: want\n%s\nhave\n%s", size, want, have)
+		}
+	}
+}
+
+func TestRewriteKeys(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/text" {
+			w.Write([]byte(`{"user_id":1}`))
+			return
+		}
+		WriteJSON(w, map[string]interface{}{"user_id": 1})
+	})
+	mw := RewriteKeys(KeyCaseConfig{Profiles: map[string]KeyCase{"camel": CamelCase}})
+
+	tests := []struct {
+		Path   string
+		Accept string
+		Want   string
+	}{
+		{"/", "", "{\n  \"user_id\": 1\n}\n"},
+		{"/", `application/json; profile="camel"`, "{\n  \"userId\": 1\n}\n"},
+		{"/", `text/html, application/json;profile=camel`, "{\n  \"userId\": 1\n}\n"},
+		{"/text", `application/json; profile=camel`, `{"user_id":1}`},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", tt.Path, nil)
+		if tt.Accept != "" {
+			r.Header.Set("Accept", tt.Accept)
+		}
+		mw(h).ServeHTTP(w, r)
+		if want, have := tt.Want, w.Body.String(); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+		if want, have := "Accept", w.Header().Get("Vary"); want != have {
+			t.Errorf("#%d: want Vary %q, have %q", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Link is a web link as specified in RFC 8288, e.g. as used in the
+// Link header.
+type Link struct {
+	// URL is the target of the link.
+	URL string
+	// Rel is the relation type, e.g. "next" or "preload".
+	Rel string
+	// As is the destination of a preload link, e.g. "script" or "style".
+	As string
+	// Type is the media type of the target.
+	Type string
+	// Params are additional target attributes.
+	Params map[string]string
+}
+
+// String formats the link as a value of the Link header, e.g.
+// `</app.js>; rel="preload"; as="script"`.
+func (l Link) String() string {
+	var b strings.Builder
+	b.WriteString("<")
+	b.WriteString(l.URL)
+	b.WriteString(">")
+	writeParam := func(key, value string) {
+		b.WriteString("; ")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(value))
+	}
+	if l.Rel != "" {
+		writeParam("rel", l.Rel)
+	}
+	if l.As != "" {
+		writeParam("as", l.As)
+	}
+	if l.Type != "" {
+		writeParam("type", l.Type)
+	}
+	keys := make([]string, 0, len(l.Params))
+	for k := range l.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeParam(k, l.Params[k])
+	}
+	return b.String()
+}
+
+// Links is a list of web links.
+type Links []Link
+
+// Add appends a link with the given URL and relation type.
+func (links Links) Add(url, rel string) Links {
+	return append(links, Link{URL: url, Rel: rel})
+}
+
+// Preload appends a preload link for the given URL and destination,
+// e.g. "script", "style", or "font".
+func (links Links) Preload(url, as string) Links {
+	return append(links, Link{URL: url, Rel: "preload", As: as})
+}
+
+// String formats the links as value of the Link header.
+func (links Links) String() string {
+	parts := make([]string, len(links))
+	for i, l := range links {
+		parts[i] = l.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SetLinkHeader adds the links to the Link header of the response.
+func SetLinkHeader(w http.ResponseWriter, links ...Link) {
+	if len(links) > 0 {
+		w.Header().Add("Link", Links(links).String())
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	links := Links{}.
+		Add("/orders?page=2", "next").
+		Preload("/app.js", "script")
+	links = append(links, Link{URL: "/font.woff2", Rel: "preload", As: "font", Type: "font/woff2", Params: map[string]string{"crossorigin": "anonymous"}})
+
+	want := `</orders?page=2>; rel="next", </app.js>; rel="preload"; as="script", </font.woff2>; rel="preload"; as="font"; type="font/woff2"; crossorigin="anonymous"`
+	if have := links.String(); want != have {
+		t.Fatalf("want\n%s\nhave\n%s", want, have)
+	}
+
+	w := httptest.NewRecorder()
+	SetLinkHeader(w, links...)
+	if have := w.Header().Get("Link"); want != have {
+		t.Fatalf("want Link header\n%s\nhave\n%s", want, have)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultSocketMode are the permissions of unix domain sockets created
+// by ServerConfig.Listen: read and write for owner and group.
+const DefaultSocketMode os.FileMode = 0660
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// Listen returns the listener for cfg.Addr, which is one of:
+//
+//   - a TCP address like ":8080" or "127.0.0.1:8080".
+//   - "unix:" and the path of a unix domain socket, e.g.
+//     "unix:/run/api/api.sock". A stale socket file is removed, and the
+//     permissions are set to cfg.SocketMode.
+//   - "systemd" for the first socket passed by systemd socket activation
+//     (LISTEN_FDS), or "systemd:" and a name for the socket with the
+//     FileDescriptorName of the socket unit (LISTEN_FDNAMES).
+func (cfg ServerConfig) Listen() (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(cfg.Addr, "unix:"):
+		mode := cfg.SocketMode
+		if mode == 0 {
+			mode = DefaultSocketMode
+		}
+		return listenUnix(strings.TrimPrefix(cfg.Addr, "unix:"), mode)
+	case cfg.Addr == "systemd" || strings.HasPrefix(cfg.Addr, "systemd:"):
+		return listenSystemd(strings.TrimPrefix(strings.TrimPrefix(cfg.Addr, "systemd"), ":"), listenFDsStart)
+	}
+	return net.Listen("tcp", cfg.Addr)
+}
+
+// listenUnix listens on the unix domain socket at path with the
+// permissions mode.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		// Stale socket of a previous process
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// listenSystemd returns the listener passed by systemd with the given
+// name, or the first one if name is empty. The file descriptors passed
+// by systemd begin at start.
+func listenSystemd(name string, start int) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd: LISTEN_PID is not set for this process")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, errors.New("no sockets passed by systemd: LISTEN_FDS is not set")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n; i++ {
+		fdName := ""
+		if i < len(names) {
+			fdName = names[i]
+		}
+		if name != "" && fdName != name {
+			continue
+		}
+		f := os.NewFile(uintptr(start+i), fdName)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket %d passed by systemd: %w", i, err)
+		}
+		return ln, nil
+	}
+	return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+//go:build unix
+
+package httputil
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+
+	// Stale socket of a previous process
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix domain sockets not supported: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	cfg := DefaultServerConfig()
+	cfg.Addr = "unix:" + path
+	ln, err := cfg.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := DefaultSocketMode, fi.Mode().Perm(); want != have {
+		t.Errorf("want mode %o, have %o", want, have)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	res, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if want, have := "ok", string(body); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// Don't remove other files
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0600)
+	if _, err := listenUnix(file, DefaultSocketMode); err == nil {
+		t.Error("want error for regular file")
+	}
+}
+
+func TestListenSystemd(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Skipf("file listeners not supported: %v", err)
+	}
+	defer f.Close()
+
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "http")
+	if _, err := listenSystemd("", int(f.Fd())); err == nil {
+		t.Fatal("want error for other process")
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	if _, err := listenSystemd("grpc", int(f.Fd())); err == nil {
+		t.Fatal("want error for unknown name")
+	}
+	// listenSystemd takes ownership of the file descriptor
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := listenSystemd("http", fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if want, have := tcp.Addr().String(), ln.Addr().String(); want != have {
+		t.Errorf("want address %s, have %s", want, have)
+	}
+}
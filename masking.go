@@ -0,0 +1,242 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Masker is implemented by types that mask personal data themselves,
+// e.g. when masking depends on more than one field. Mask is called on
+// a copy of the value to be written by WriteJSONMasked, after the fields
+// tagged with "mask" or "redact" have been masked.
+type Masker interface {
+	Mask()
+}
+
+// WriteJSONMasked is like WriteJSONCode, but masks personal data in v
+// unless the caller is privileged, see Unmask. Fields of v are masked
+// by their struct tags:
+//
+//   - `mask:"last4"` keeps the last 4 characters of a string and replaces
+//     the others with '*'. "firstN" and "lastN" work for any N.
+//   - `mask:"email"` keeps the first character and the domain of an email
+//     address, e.g. "j***@example.com".
+//   - `mask:"all"` replaces all characters with '*'.
+//   - `redact:"true"` sets the field to its zero value, so it is omitted
+//     with "omitempty".
+//
+// The "mask" tag works for strings, string pointers, and string slices.
+// Fields of other types with a "mask" tag are set to their zero value,
+// like with "redact", so a misplaced tag never reveals the value.
+//
+// Nested structs, pointers, slices, and maps are masked as well, and
+// types implementing Masker are asked to mask themselves. v is left
+// unchanged.
+//
+// Example:
+//
+//	type Customer struct {
+//	  Name  string `json:"name"`
+//	  Email string `json:"email" mask:"email"`
+//	  IBAN  string `json:"iban" mask:"last4"`
+//	  TaxID string `json:"tax_id,omitempty" redact:"true"`
+//	}
+//
+//	httputil.WriteJSONMasked(w, http.StatusOK, customer)
+func WriteJSONMasked(w http.ResponseWriter, code int, v interface{}) {
+	if !isUnmaskedResponse(w) {
+		v = MaskFields(v)
+	}
+	WriteJSONCode(w, code, v)
+}
+
+// MaskFields returns a copy of v with its fields masked as described
+// in WriteJSONMasked, e.g. to log it.
+func MaskFields(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return maskValue(reflect.ValueOf(v)).Interface()
+}
+
+type unmaskedContextKey struct{}
+
+// Unmask is a middleware that disables masking in WriteJSONMasked for
+// privileged callers, e.g. for support staff with a certain role. Without
+// Unmask, WriteJSONMasked always masks.
+//
+// Example:
+//
+//	mw := httputil.Unmask(func(r *http.Request) bool {
+//	  return httputil.JWTClaimsFromContext(r.Context()).String("role") == "support"
+//	})
+func Unmask(privileged func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !privileged(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), unmaskedContextKey{}, true)
+			next.ServeHTTP(wrapResponseWriter(&unmaskedResponseWriter{responseWriter{w}}), r.WithContext(ctx))
+		})
+	}
+}
+
+// IsUnmasked returns true if Unmask found the caller of the request
+// with ctx to be privileged.
+func IsUnmasked(ctx context.Context) bool {
+	unmasked, _ := ctx.Value(unmaskedContextKey{}).(bool)
+	return unmasked
+}
+
+// unmaskedResponseWriter marks responses to privileged callers.
+type unmaskedResponseWriter struct {
+	responseWriter
+}
+
+// isUnmaskedResponse returns true if w has been marked by Unmask.
+func isUnmaskedResponse(w http.ResponseWriter) bool {
+	for {
+		switch x := w.(type) {
+		case *unmaskedResponseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+var maskerType = reflect.TypeOf((*Masker)(nil)).Elem()
+
+// maskValue returns a deep copy of v with masked fields.
+func maskValue(v reflect.Value) reflect.Value {
+	t := v.Type()
+	out := v
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out = reflect.New(t.Elem())
+		out.Elem().Set(maskValue(v.Elem()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out = reflect.New(t).Elem()
+		out.Set(maskValue(v.Elem()))
+	case reflect.Struct:
+		out = reflect.New(t).Elem()
+		out.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			f := out.Field(i)
+			if redact, _ := strconv.ParseBool(sf.Tag.Get("redact")); redact {
+				f.Set(reflect.Zero(sf.Type))
+			} else if mask := sf.Tag.Get("mask"); mask != "" {
+				maskField(f, mask)
+			} else {
+				f.Set(maskValue(f))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() || t.Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out = reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(maskValue(v.Index(i)))
+		}
+	case reflect.Array:
+		out = reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(maskValue(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out = reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), maskValue(iter.Value()))
+		}
+	}
+
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(maskerType) {
+		p := reflect.New(t)
+		p.Elem().Set(out)
+		p.Interface().(Masker).Mask()
+		out = p.Elem()
+	}
+	return out
+}
+
+// maskField masks the string, string pointer, or string slice f.
+// Fields of other types are set to their zero value.
+func maskField(f reflect.Value, mask string) {
+	switch {
+	case f.Kind() == reflect.String:
+		f.SetString(maskString(f.String(), mask))
+	case f.Kind() == reflect.Pointer && f.Type().Elem().Kind() == reflect.String:
+		if !f.IsNil() {
+			p := reflect.New(f.Type().Elem())
+			p.Elem().SetString(maskString(f.Elem().String(), mask))
+			f.Set(p)
+		}
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		if !f.IsNil() {
+			s := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			for i := 0; i < f.Len(); i++ {
+				s.Index(i).SetString(maskString(f.Index(i).String(), mask))
+			}
+			f.Set(s)
+		}
+	default:
+		f.Set(reflect.Zero(f.Type()))
+	}
+}
+
+// maskString masks s with the mask of a "mask" struct tag.
+// Unknown masks mask all characters.
+func maskString(s, mask string) string {
+	if s == "" {
+		return s
+	}
+	runes := []rune(s)
+	keepFirst, keepLast := 0, 0
+	switch {
+	case mask == "email":
+		at := strings.LastIndexByte(s, '@')
+		if at <= 0 {
+			return strings.Repeat("*", len(runes))
+		}
+		local := []rune(s[:at])
+		return string(local[0]) + strings.Repeat("*", len(local)-1) + s[at:]
+	case strings.HasPrefix(mask, "first"):
+		keepFirst, _ = strconv.Atoi(mask[len("first"):])
+	case strings.HasPrefix(mask, "last"):
+		keepLast, _ = strconv.Atoi(mask[len("last"):])
+	}
+	if keepFirst < 0 || keepLast < 0 || keepFirst+keepLast >= len(runes) {
+		// Don't reveal short values completely
+		keepFirst, keepLast = 0, 0
+	}
+	for i := keepFirst; i < len(runes)-keepLast; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type maskedCard struct {
+	Number string `json:"number" mask:"last4"`
+	CVC    string `json:"cvc,omitempty" redact:"true"`
+}
+
+type maskedCustomer struct {
+	Name   string            `json:"name"`
+	Email  string            `json:"email" mask:"email"`
+	Phone  *string           `json:"phone,omitempty" mask:"first3"`
+	Cards  []maskedCard      `json:"cards"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Secret maskedSecret      `json:"secret"`
+}
+
+type maskedSecret struct {
+	Value string `json:"value"`
+}
+
+func (s *maskedSecret) Mask() {
+	s.Value = "***"
+}
+
+func TestMaskString(t *testing.T) {
+	tests := []struct {
+		Input string
+		Mask  string
+		Want  string
+	}{
+		{"4111111111111111", "last4", "************1111"},
+		{"+4989123456", "first3", "+49********"},
+		{"jane@example.com", "email", "j***@example.com"},
+		{"no-email", "email", "********"},
+		{"abc", "last4", "***"},
+		{"secret", "all", "******"},
+		{"", "all", ""},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Want, maskString(tt.Input, tt.Mask); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestWriteJSONMasked(t *testing.T) {
+	phone := "+4989123456"
+	customer := &maskedCustomer{
+		Name:   "Jane",
+		Email:  "jane@example.com",
+		Phone:  &phone,
+		Cards:  []maskedCard{{Number: "4111111111111111", CVC: "123"}},
+		Secret: maskedSecret{Value: "s3cret"},
+	}
+	h := func(w http.ResponseWriter, r *http.Request) {
+		WriteJSONMasked(w, http.StatusOK, customer)
+	}
+
+	tests := []struct {
+		Handler http.Handler
+		Header  string
+		Want    string
+	}{
+		{
+			Handler: http.HandlerFunc(h),
+			Want:    `{"name":"Jane","email":"j***@example.com","phone":"+49********","cards":[{"number":"************1111"}],"secret":{"value":"***"}}`,
+		},
+		{
+			Handler: Unmask(func(r *http.Request) bool { return r.Header.Get("X-Role") == "support" })(http.HandlerFunc(h)),
+			Want:    `{"name":"Jane","email":"j***@example.com","phone":"+49********","cards":[{"number":"************1111"}],"secret":{"value":"***"}}`,
+		},
+		{
+			Handler: Unmask(func(r *http.Request) bool { return r.Header.Get("X-Role") == "support" })(http.HandlerFunc(h)),
+			Header:  "support",
+			Want:    `{"name":"Jane","email":"jane@example.com","phone":"+4989123456","cards":[{"number":"4111111111111111","cvc":"123"}],"secret":{"value":"s3cret"}}`,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.Header != "" {
+			r.Header.Set("X-Role", tt.Header)
+		}
+		tt.Handler.ServeHTTP(w, r)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		if !EqualJSON([]byte(tt.Want), w.Body.Bytes()) {
+			t.Errorf("#%d: want\n%s\nhave\n%s", i, tt.Want, w.Body.String())
+		}
+	}
+
+	// The original is left unchanged
+	if customer.Email != "jane@example.com" || phone != "+4989123456" || customer.Cards[0].CVC != "123" || customer.Secret.Value != "s3cret" {
+		t.Errorf("want original to be unchanged, have %+v", customer)
+	}
+}
+
+func TestWriteJSONMaskedNonString(t *testing.T) {
+	type account struct {
+		Name    string   `json:"name"`
+		Balance int      `json:"balance" mask:"all"`
+		Limit   *float64 `json:"limit" mask:"all"`
+		PINs    []int    `json:"pins" mask:"last4"`
+	}
+	limit := 1000.0
+	v := &account{Name: "Jane", Balance: 4711, Limit: &limit, PINs: []int{1234}}
+
+	w := httptest.NewRecorder()
+	WriteJSONMasked(w, http.StatusOK, v)
+	if want := `{"name":"Jane","balance":0,"limit":null,"pins":null}`; !EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+	if v.Balance != 4711 || *v.Limit != 1000 || v.PINs[0] != 1234 {
+		t.Errorf("want original to be unchanged, have %+v", v)
+	}
+}
+
+func TestIsUnmasked(t *testing.T) {
+	var unmasked bool
+	h := Unmask(func(r *http.Request) bool { return true })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unmasked = IsUnmasked(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !unmasked {
+		t.Error("want IsUnmasked to be true")
+	}
+}
@@ -0,0 +1,511 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MessageSigner creates signatures for HTTP Message Signatures as
+// specified in RFC 9421.
+type MessageSigner interface {
+	// KeyID returns the identifier of the key, passed as "keyid" parameter.
+	KeyID() string
+	// Algorithm returns the name of the algorithm, e.g. "hmac-sha256".
+	Algorithm() string
+	// Sign returns the signature of the signature base.
+	Sign(base []byte) ([]byte, error)
+}
+
+// MessageVerifier verifies signatures for HTTP Message Signatures as
+// specified in RFC 9421.
+type MessageVerifier interface {
+	// Algorithm returns the name of the algorithm, e.g. "hmac-sha256".
+	Algorithm() string
+	// Verify returns an error if sig is not a valid signature of base.
+	Verify(base, sig []byte) error
+}
+
+// KeyResolver returns the MessageVerifier for the given key identifier.
+type KeyResolver func(keyID string) (MessageVerifier, error)
+
+// HMACMessageKey is a shared secret that implements both MessageSigner
+// and MessageVerifier with the "hmac-sha256" algorithm.
+type HMACMessageKey struct {
+	ID  string
+	Key []byte
+}
+
+// KeyID returns the identifier of the key.
+func (k HMACMessageKey) KeyID() string { return k.ID }
+
+// Algorithm returns "hmac-sha256".
+func (HMACMessageKey) Algorithm() string { return "hmac-sha256" }
+
+// Sign returns the HMAC SHA-256 of base.
+func (k HMACMessageKey) Sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.Key)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+// Verify checks the HMAC SHA-256 of base.
+func (k HMACMessageKey) Verify(base, sig []byte) error {
+	want, _ := k.Sign(base)
+	if !hmac.Equal(want, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Ed25519MessageKey implements MessageSigner and MessageVerifier with
+// the "ed25519" algorithm. PrivateKey may be nil when used for verifying.
+type Ed25519MessageKey struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+// KeyID returns the identifier of the key.
+func (k Ed25519MessageKey) KeyID() string { return k.ID }
+
+// Algorithm returns "ed25519".
+func (Ed25519MessageKey) Algorithm() string { return "ed25519" }
+
+// Sign signs base with the private key.
+func (k Ed25519MessageKey) Sign(base []byte) ([]byte, error) {
+	if len(k.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+	return ed25519.Sign(k.PrivateKey, base), nil
+}
+
+// Verify checks the signature of base with the public key.
+func (k Ed25519MessageKey) Verify(base, sig []byte) error {
+	if len(k.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(k.PublicKey, base, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// rfc9421Label is the signature label used by SignRequestRFC9421.
+const rfc9421Label = "sig1"
+
+// SignRequestRFC9421 signs r with signer and sets the Signature-Input and
+// Signature headers as specified in RFC 9421. The fields are the covered
+// components, i.e. either derived components like "@method", "@authority",
+// "@path", "@query", "@target-uri", "@request-target", and "@scheme",
+// or header names like "content-digest". If no fields are passed,
+// "@method", "@authority", and "@request-target" are covered. Requests
+// with a body then also get a Content-Digest header, which is covered
+// as well.
+func SignRequestRFC9421(r *http.Request, signer MessageSigner, fields ...string) error {
+	if len(fields) == 0 {
+		fields = []string{"@method", "@authority", "@request-target"}
+		if rfc9421HasBody(r) {
+			body, err := readRFC9421Body(r)
+			if err != nil {
+				return err
+			}
+			r.Header.Set("Content-Digest", ContentDigest(body))
+			fields = append(fields, "content-digest")
+		}
+	}
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = strconv.Quote(strings.ToLower(f))
+	}
+	params := fmt.Sprintf("(%s);created=%d;keyid=%q;alg=%q",
+		strings.Join(quoted, " "),
+		time.Now().Unix(),
+		signer.KeyID(),
+		signer.Algorithm())
+	base, err := rfc9421SignatureBase(r, fields, params)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign(base)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Signature-Input", rfc9421Label+"="+params)
+	r.Header.Set("Signature", rfc9421Label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// RFC9421Config configures VerifyRequestRFC9421WithConfig.
+type RFC9421Config struct {
+	// MaxAge rejects signatures created longer ago, so captured requests
+	// can't be replayed after that. Signatures without a "created"
+	// parameter are rejected. It defaults to DefaultRFC9421MaxAge.
+	// A negative value means no limit.
+	MaxAge time.Duration
+	// ClockSkew is the tolerated difference between the clocks of
+	// signer and verifier. It defaults to one minute.
+	ClockSkew time.Duration
+	// RequiredComponents must be covered by every signature. If nil,
+	// "@method", "@authority", and "@request-target" are required, and
+	// "content-digest" for requests with a body. "@target-uri" may be
+	// covered instead of "@authority" and "@request-target".
+	RequiredComponents []string
+}
+
+// DefaultRFC9421MaxAge is the default maximum age of signatures accepted
+// by VerifyRequestRFC9421.
+const DefaultRFC9421MaxAge = 5 * time.Minute
+
+// VerifyRequestRFC9421 verifies the signatures of r as specified in RFC 9421.
+// Every signature in the Signature-Input header must be valid.
+// The verifier is looked up via resolver by the "keyid" parameter.
+// Signatures with an "expires" parameter in the past, a "created"
+// parameter in the future or older than DefaultRFC9421MaxAge, or that
+// don't cover the default components of RFC9421Config.RequiredComponents
+// are rejected. If "content-digest" is covered, the Content-Digest header
+// must match the body. VerifyRequestRFC9421 returns UnauthorizedError if
+// r is not signed or if a signature is invalid.
+func VerifyRequestRFC9421(r *http.Request, resolver KeyResolver) error {
+	return VerifyRequestRFC9421WithConfig(r, resolver, RFC9421Config{})
+}
+
+// VerifyRequestRFC9421WithConfig is like VerifyRequestRFC9421, but
+// checks the age and covered components of signatures as per cfg.
+func VerifyRequestRFC9421WithConfig(r *http.Request, resolver KeyResolver, cfg RFC9421Config) error {
+	if cfg.ClockSkew <= 0 {
+		cfg.ClockSkew = time.Minute
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = DefaultRFC9421MaxAge
+	}
+	required := cfg.RequiredComponents
+	if required == nil {
+		required = []string{"@method", "@authority", "@request-target"}
+		if rfc9421HasBody(r) {
+			required = append(required, "content-digest")
+		}
+	}
+	inputs, err := parseRFC9421Dictionary(r.Header.Get("Signature-Input"))
+	if err != nil || len(inputs) == 0 {
+		return UnauthorizedError{}
+	}
+	sigs, err := parseRFC9421Dictionary(r.Header.Get("Signature"))
+	if err != nil {
+		return UnauthorizedError{}
+	}
+	now := time.Now()
+	for label, params := range inputs {
+		sigValue, ok := sigs[label]
+		if !ok || len(sigValue) < 2 || sigValue[0] != ':' || sigValue[len(sigValue)-1] != ':' {
+			return UnauthorizedError{}
+		}
+		sig, err := base64.StdEncoding.DecodeString(sigValue[1 : len(sigValue)-1])
+		if err != nil {
+			return UnauthorizedError{}
+		}
+		fields, p, err := parseRFC9421Params(params)
+		if err != nil {
+			return UnauthorizedError{}
+		}
+		if !p.isFresh(now, cfg) || !rfc9421Covers(fields, required) {
+			return UnauthorizedError{}
+		}
+		verifier, err := resolver(p.keyID)
+		if err != nil || verifier == nil {
+			return UnauthorizedError{}
+		}
+		if p.alg != "" && p.alg != verifier.Algorithm() {
+			return UnauthorizedError{}
+		}
+		base, err := rfc9421SignatureBase(r, fields, params)
+		if err != nil {
+			return UnauthorizedError{}
+		}
+		if err := verifier.Verify(base, sig); err != nil {
+			return UnauthorizedError{}
+		}
+		if rfc9421Covers(fields, []string{"content-digest"}) {
+			body, err := readRFC9421Body(r)
+			if err != nil {
+				return err
+			}
+			if !VerifyContentDigest(r.Header.Get("Content-Digest"), body) {
+				return UnauthorizedError{}
+			}
+		}
+	}
+	return nil
+}
+
+// rfc9421Covers returns true if fields is not empty and contains all of
+// the required components. "@target-uri" covers both "@authority" and
+// "@request-target".
+func rfc9421Covers(fields, required []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	covered := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		covered[strings.ToLower(f)] = true
+	}
+	for _, f := range required {
+		f = strings.ToLower(f)
+		if covered[f] {
+			continue
+		}
+		if (f == "@authority" || f == "@request-target") && covered["@target-uri"] {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// rfc9421HasBody returns true if r has a body.
+func rfc9421HasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// readRFC9421Body reads the body of r and restores it afterwards, so
+// handlers and transports can still read it.
+func readRFC9421Body(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxDigestBodySize+1))
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDigestBodySize {
+		return nil, RequestEntityTooLargeError{}
+	}
+	return data, nil
+}
+
+// VerifyRFC9421 returns a middleware that rejects requests that do not
+// pass VerifyRequestRFC9421.
+func VerifyRFC9421(resolver KeyResolver) func(http.Handler) http.Handler {
+	return VerifyRFC9421WithConfig(resolver, RFC9421Config{})
+}
+
+// VerifyRFC9421WithConfig returns a middleware that rejects requests
+// that do not pass VerifyRequestRFC9421WithConfig.
+func VerifyRFC9421WithConfig(resolver KeyResolver, cfg RFC9421Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := VerifyRequestRFC9421WithConfig(r, resolver, cfg); err != nil {
+				writeJSONError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RFC9421Transport is a http.RoundTripper that signs outgoing requests
+// with SignRequestRFC9421.
+type RFC9421Transport struct {
+	// Base is the underlying transport. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Signer is used to sign requests.
+	Signer MessageSigner
+	// Fields are the covered components.
+	Fields []string
+}
+
+// RoundTrip signs a copy of r and passes it to the underlying transport.
+func (t *RFC9421Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r2 := r.Clone(r.Context())
+	if err := SignRequestRFC9421(r2, t.Signer, t.Fields...); err != nil {
+		return nil, err
+	}
+	return base.RoundTrip(r2)
+}
+
+// rfc9421SignatureBase creates the signature base as specified in
+// RFC 9421, Section 2.5.
+func rfc9421SignatureBase(r *http.Request, fields []string, params string) ([]byte, error) {
+	var b strings.Builder
+	for _, f := range fields {
+		f = strings.ToLower(f)
+		v, err := rfc9421ComponentValue(r, f)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "%q: %s\n", f, v)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+	return []byte(b.String()), nil
+}
+
+func rfc9421ComponentValue(r *http.Request, name string) (string, error) {
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@authority":
+		return strings.ToLower(rfc9421Host(r)), nil
+	case "@scheme":
+		return rfc9421Scheme(r), nil
+	case "@target-uri":
+		return rfc9421Scheme(r) + "://" + strings.ToLower(rfc9421Host(r)) + r.URL.RequestURI(), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		if p := r.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+	if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("unsupported derived component %q", name)
+	}
+	values, ok := r.Header[http.CanonicalHeaderKey(name)]
+	if !ok {
+		return "", fmt.Errorf("missing header %q", name)
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+func rfc9421Host(r *http.Request) string {
+	if r.Host != "" {
+		return r.Host
+	}
+	return r.URL.Host
+}
+
+func rfc9421Scheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// parseRFC9421Dictionary splits a structured field dictionary into its
+// members, keeping the values (including their parameters) verbatim.
+func parseRFC9421Dictionary(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	var inQuote, inList bool
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch c := s[i]; {
+			case c == '"' && (i == 0 || s[i-1] != '\\'):
+				inQuote = !inQuote
+				continue
+			case inQuote:
+				continue
+			case c == '(':
+				inList = true
+				continue
+			case c == ')':
+				inList = false
+				continue
+			case c != ',' || inList:
+				continue
+			}
+		}
+		member := strings.TrimSpace(s[start:i])
+		start = i + 1
+		if member == "" {
+			continue
+		}
+		eq := strings.IndexByte(member, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid dictionary member %q", member)
+		}
+		m[member[:eq]] = member[eq+1:]
+	}
+	if inQuote || inList {
+		return nil, errors.New("unterminated dictionary")
+	}
+	return m, nil
+}
+
+// rfc9421Params are the signature parameters of a Signature-Input member.
+type rfc9421Params struct {
+	keyID   string
+	alg     string
+	created int64 // 0 if missing
+	expires int64 // 0 if missing
+}
+
+// isFresh returns true if the signature is not expired, not created in
+// the future, and not older than cfg.MaxAge, at time now.
+func (p rfc9421Params) isFresh(now time.Time, cfg RFC9421Config) bool {
+	if p.expires != 0 && now.After(time.Unix(p.expires, 0).Add(cfg.ClockSkew)) {
+		return false
+	}
+	if p.created != 0 && now.Before(time.Unix(p.created, 0).Add(-cfg.ClockSkew)) {
+		return false
+	}
+	if cfg.MaxAge > 0 && (p.created == 0 || now.After(time.Unix(p.created, 0).Add(cfg.MaxAge+cfg.ClockSkew))) {
+		return false
+	}
+	return true
+}
+
+// parseRFC9421Params parses the covered components and the keyid, alg,
+// created, and expires parameters of a Signature-Input member.
+func parseRFC9421Params(s string) (fields []string, p rfc9421Params, err error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, p, errors.New("missing inner list")
+	}
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, p, errors.New("unterminated inner list")
+	}
+	for _, item := range strings.Fields(s[1:end]) {
+		f, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, p, fmt.Errorf("invalid component %s", item)
+		}
+		fields = append(fields, f)
+	}
+	for _, param := range strings.Split(s[end+1:], ";") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "keyid":
+			p.keyID, err = strconv.Unquote(kv[1])
+		case "alg":
+			p.alg, err = strconv.Unquote(kv[1])
+		case "created":
+			p.created, err = strconv.ParseInt(kv[1], 10, 64)
+		case "expires":
+			p.expires, err = strconv.ParseInt(kv[1], 10, 64)
+		}
+		if err != nil {
+			return nil, p, fmt.Errorf("invalid parameter %s", param)
+		}
+	}
+	return fields, p, nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequestRFC9421TestVector(t *testing.T) {
+	// Test vector from RFC 9421, Appendix B.2.5
+	key, err := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("POST", "http://example.com/foo?param=Value&Pet=dog", nil)
+	r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Signature-Input", `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`)
+	r.Header.Set("Signature", `sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:`)
+
+	resolver := func(keyID string) (MessageVerifier, error) {
+		if keyID != "test-shared-secret" {
+			return nil, errors.New("unknown key")
+		}
+		return HMACMessageKey{ID: keyID, Key: key}, nil
+	}
+	cfg := RFC9421Config{MaxAge: -1, RequiredComponents: []string{"@authority"}}
+	if err := VerifyRequestRFC9421WithConfig(r, resolver, cfg); err != nil {
+		t.Fatalf("expected signature to verify; got: %v", err)
+	}
+	if err := VerifyRequestRFC9421(r, resolver); err == nil {
+		t.Fatal("expected signature to not verify with the default config")
+	}
+	r.Header.Set("Content-Type", "text/plain")
+	if err := VerifyRequestRFC9421WithConfig(r, resolver, cfg); err == nil {
+		t.Fatal("expected signature to not verify after modifying a covered header")
+	}
+}
+
+func TestSignRequestRFC9421(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := Ed25519MessageKey{ID: "k1", PrivateKey: priv, PublicKey: pub}
+	resolver := func(keyID string) (MessageVerifier, error) {
+		return Ed25519MessageKey{ID: keyID, PublicKey: pub}, nil
+	}
+
+	r, _ := http.NewRequest("GET", "http://example.com/orders?page=2", nil)
+	r.Header.Set("Content-Digest", ContentDigest(nil))
+	if err := SignRequestRFC9421(r, signer, "@method", "@target-uri", "content-digest"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRequestRFC9421(r, resolver); err != nil {
+		t.Fatalf("expected signature to verify; got: %v", err)
+	}
+
+	r.URL.RawQuery = "page=3"
+	if err := VerifyRequestRFC9421(r, resolver); err == nil {
+		t.Fatal("expected signature to not verify after modifying the URI")
+	}
+}
+
+func TestVerifyRFC9421Middleware(t *testing.T) {
+	key := HMACMessageKey{ID: "k1", Key: []byte("secret")}
+	resolver := func(keyID string) (MessageVerifier, error) { return key, nil }
+	h := VerifyRFC9421(resolver)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tests := []struct {
+		Client *http.Client
+		Want   int
+	}{
+		{Client: http.DefaultClient, Want: http.StatusUnauthorized},
+		{Client: &http.Client{Transport: &RFC9421Transport{Signer: key}}, Want: http.StatusNoContent},
+	}
+	for i, tt := range tests {
+		resp, err := tt.Client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if want, have := tt.Want, resp.StatusCode; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestVerifyRequestRFC9421Freshness(t *testing.T) {
+	key := HMACMessageKey{ID: "k1", Key: []byte("secret")}
+	resolver := func(keyID string) (MessageVerifier, error) { return key, nil }
+	now := time.Now().Unix()
+
+	tests := []struct {
+		Params string
+		Config RFC9421Config
+		OK     bool
+	}{
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now), OK: true},
+		{Params: fmt.Sprintf(`("@method");created=%d;expires=%d;keyid="k1"`, now-60, now+60), OK: true},
+		{Params: fmt.Sprintf(`("@method");created=%d;expires=%d;keyid="k1"`, now-600, now-300)},
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now+3600)},
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now+30), OK: true},
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now-3600)},
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now-3600), Config: RFC9421Config{MaxAge: -1}, OK: true},
+		{Params: `("@method");keyid="k1"`},
+		{Params: `("@method");keyid="k1"`, Config: RFC9421Config{MaxAge: -1}, OK: true},
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now-3600), Config: RFC9421Config{MaxAge: 5 * time.Minute}},
+		{Params: fmt.Sprintf(`("@method");created=%d;keyid="k1"`, now-60), Config: RFC9421Config{MaxAge: 5 * time.Minute}, OK: true},
+		{Params: `("@method");keyid="k1"`, Config: RFC9421Config{MaxAge: 5 * time.Minute}},
+		{Params: `("@method");created=soon;keyid="k1"`},
+	}
+	for i, tt := range tests {
+		r, _ := http.NewRequest("GET", "http://example.com/", nil)
+		base, err := rfc9421SignatureBase(r, []string{"@method"}, tt.Params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, _ := key.Sign(base)
+		r.Header.Set("Signature-Input", "sig1="+tt.Params)
+		r.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(sig)+":")
+		cfg := tt.Config
+		cfg.RequiredComponents = []string{"@method"}
+		err = VerifyRequestRFC9421WithConfig(r, resolver, cfg)
+		if want, have := tt.OK, err == nil; want != have {
+			t.Errorf("#%d: want ok=%v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestVerifyRequestRFC9421Components(t *testing.T) {
+	key := HMACMessageKey{ID: "k1", Key: []byte("secret")}
+	resolver := func(keyID string) (MessageVerifier, error) { return key, nil }
+
+	tests := []struct {
+		Body   string
+		Fields []string
+		Config RFC9421Config
+		OK     bool
+	}{
+		{Fields: []string{"@method", "@authority", "@request-target"}, OK: true},
+		{Fields: []string{"@method", "@target-uri"}, OK: true},
+		{Fields: []string{"@method", "@authority"}},
+		{Fields: []string{"@authority", "@request-target"}},
+		{Fields: []string{}},
+		{Fields: []string{}, Config: RFC9421Config{RequiredComponents: []string{}}},
+		{Fields: []string{"@method"}, Config: RFC9421Config{RequiredComponents: []string{"@method"}}, OK: true},
+		{Body: "{}", OK: true},
+		{Body: "{}", Fields: []string{"@method", "@authority", "@request-target"}},
+		{Body: "{}", Fields: []string{"@method", "@target-uri", "content-digest"}, OK: true},
+	}
+	for i, tt := range tests {
+		r, _ := http.NewRequest("POST", "http://example.com/orders?page=2", strings.NewReader(tt.Body))
+		if tt.Fields != nil && len(tt.Fields) == 0 {
+			params := fmt.Sprintf(`();created=%d;keyid="k1"`, time.Now().Unix())
+			base, err := rfc9421SignatureBase(r, nil, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, _ := key.Sign(base)
+			r.Header.Set("Signature-Input", "sig1="+params)
+			r.Header.Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(sig)+":")
+		} else {
+			if tt.Body != "" && tt.Fields != nil {
+				r.Header.Set("Content-Digest", ContentDigest([]byte(tt.Body)))
+			}
+			if err := SignRequestRFC9421(r, key, tt.Fields...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := VerifyRequestRFC9421WithConfig(r, resolver, tt.Config)
+		if want, have := tt.OK, err == nil; want != have {
+			t.Errorf("#%d: want ok=%v, have %v", i, want, have)
+		}
+	}
+}
+
+func TestVerifyRequestRFC9421ContentDigest(t *testing.T) {
+	key := HMACMessageKey{ID: "k1", Key: []byte("secret")}
+	resolver := func(keyID string) (MessageVerifier, error) { return key, nil }
+
+	r, _ := http.NewRequest("POST", "http://example.com/orders", strings.NewReader(`{"amount":1}`))
+	if err := SignRequestRFC9421(r, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRequestRFC9421(r, resolver); err != nil {
+		t.Fatalf("expected signature to verify; got: %v", err)
+	}
+	if data, _ := ioutil.ReadAll(r.Body); string(data) != `{"amount":1}` {
+		t.Errorf("want body to be restored, have %q", data)
+	}
+
+	r.Body = ioutil.NopCloser(strings.NewReader(`{"amount":1000}`))
+	if err := VerifyRequestRFC9421(r, resolver); err == nil {
+		t.Fatal("expected signature to not verify after swapping the body")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMirrorMaxBodySize is the default of MirrorConfig.MaxBodySize.
+	defaultMirrorMaxBodySize = 1 << 20
+	// defaultMirrorMaxInFlight is the default of MirrorConfig.MaxInFlight.
+	defaultMirrorMaxInFlight = 100
+)
+
+// Headers removed from mirrored requests: hop-by-hop headers always,
+// credentials unless MirrorConfig.KeepAuth is set.
+var (
+	mirrorHopHeaders  = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+	mirrorAuthHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+)
+
+// MirrorConfig configures MirrorWithConfig.
+type MirrorConfig struct {
+	// Target is the base URL of the shadow environment. The path of
+	// mirrored requests is appended to its path.
+	Target *url.URL
+	// Percent is the percentage of requests to mirror, between 0 and 100.
+	Percent float64
+	// MaxBodySize is the maximum size of request bodies. Requests with
+	// larger bodies are not mirrored. It defaults to 1 MB.
+	MaxBodySize int64
+	// MaxInFlight is the maximum number of concurrent mirrored requests.
+	// Further requests are not mirrored, so a slow shadow environment
+	// can't exhaust production. It defaults to 100.
+	MaxInFlight int
+	// KeepAuth keeps the Authorization, Proxy-Authorization, and Cookie
+	// headers. By default, they are removed.
+	KeepAuth bool
+	// Client sends the mirrored requests. It defaults to a client with
+	// a timeout of 10 seconds.
+	Client *http.Client
+	// OnError is called with errors of mirrored requests, if set.
+	OnError func(err error)
+}
+
+// Mirror returns a handler that passes all requests to next and, in
+// addition, asynchronously sends a copy of percent of them (between 0
+// and 100) to the target, e.g. to validate a migration with production
+// traffic. The responses of the target are ignored. Credentials are
+// removed, see MirrorWithConfig for details and more options.
+func Mirror(next http.Handler, target *url.URL, percent float64) http.Handler {
+	return MirrorWithConfig(next, MirrorConfig{Target: target, Percent: percent})
+}
+
+// MirrorWithConfig is like Mirror, with more options.
+func MirrorWithConfig(next http.Handler, cfg MirrorConfig) http.Handler {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultMirrorMaxBodySize
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultMirrorMaxInFlight
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	inFlight := make(chan struct{}, cfg.MaxInFlight)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.Target == nil || rand.Float64()*100 >= cfg.Percent || r.ContentLength > cfg.MaxBodySize {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			data, err := ioutil.ReadAll(io.LimitReader(r.Body, cfg.MaxBodySize+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+			if err != nil || int64(len(data)) > cfg.MaxBodySize {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body = data
+		}
+
+		select {
+		case inFlight <- struct{}{}:
+			req := newMirrorRequest(r, cfg, body)
+			go func() {
+				defer func() { <-inFlight }()
+				res, err := cfg.Client.Do(req)
+				if err != nil {
+					if cfg.OnError != nil {
+						cfg.OnError(err)
+					}
+					return
+				}
+				DrainAndClose(res)
+			}()
+		default:
+			// Too many mirrored requests in flight
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newMirrorRequest returns a copy of r for the target of cfg.
+func newMirrorRequest(r *http.Request, cfg MirrorConfig, body []byte) *http.Request {
+	u := *cfg.Target
+	u.Path = path.Join("/", cfg.Target.Path, r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	// Detached from the context of r, as it outlives r
+	req, _ := http.NewRequestWithContext(context.Background(), r.Method, u.String(), bytes.NewReader(body))
+	req.Header = r.Header.Clone()
+	for _, key := range mirrorHopHeaders {
+		req.Header.Del(key)
+	}
+	if !cfg.KeepAuth {
+		for _, key := range mirrorAuthHeaders {
+			req.Header.Del(key)
+		}
+	}
+	req.Header.Set("X-Mirrored-By", "httputil")
+	if host := RequestHost(r); host != "" {
+		req.Header.Set("X-Forwarded-Host", host)
+	}
+	return req
+}
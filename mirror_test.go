@@ -0,0 +1,101 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mirroredRequest struct {
+	Method, URI, Body string
+	Header            http.Header
+}
+
+func TestMirror(t *testing.T) {
+	mirrored := make(chan mirroredRequest, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mirrored <- mirroredRequest{Method: r.Method, URI: r.URL.RequestURI(), Body: string(body), Header: r.Header}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+	target, _ := url.Parse(shadow.URL + "/v2")
+
+	prod := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+
+	req := httptest.NewRequest("POST", "/orders?dry=1", strings.NewReader(`{"qty":1}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	Mirror(prod, target, 100).ServeHTTP(w, req)
+
+	if want, have := `{"qty":1}`, w.Body.String(); want != have {
+		t.Errorf("want production response %q, have %q", want, have)
+	}
+	select {
+	case m := <-mirrored:
+		if want, have := "POST", m.Method; want != have {
+			t.Errorf("want method %q, have %q", want, have)
+		}
+		if want, have := "/v2/orders?dry=1", m.URI; want != have {
+			t.Errorf("want URI %q, have %q", want, have)
+		}
+		if want, have := `{"qty":1}`, m.Body; want != have {
+			t.Errorf("want body %q, have %q", want, have)
+		}
+		if have := m.Header.Get("Authorization"); have != "" {
+			t.Errorf("want Authorization to be stripped, have %q", have)
+		}
+		if want, have := "application/json", m.Header.Get("Content-Type"); want != have {
+			t.Errorf("want Content-Type %q, have %q", want, have)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want request to be mirrored")
+	}
+}
+
+func TestMirrorSkips(t *testing.T) {
+	mirrored := make(chan struct{}, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- struct{}{}
+	}))
+	defer shadow.Close()
+	target, _ := url.Parse(shadow.URL)
+
+	prod := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+	tests := []struct {
+		Handler http.Handler
+		Body    string
+	}{
+		{Handler: Mirror(prod, target, 0), Body: "small"},
+		{Handler: MirrorWithConfig(prod, MirrorConfig{Target: target, Percent: 100, MaxBodySize: 4}), Body: "too large"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.Body))
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		tt.Handler.ServeHTTP(w, req)
+		if want, have := tt.Body, w.Body.String(); want != have {
+			t.Errorf("#%d: want production response %q, have %q", i, want, have)
+		}
+	}
+	select {
+	case <-mirrored:
+		t.Fatal("want no mirrored requests")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
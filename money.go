@@ -0,0 +1,289 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const moneyHint = "Expected an amount with an ISO 4217 currency like 12.34 EUR"
+
+// Money is an amount of money in the minor unit of its currency, e.g.
+// 1234 with currency "EUR" for 12.34 €. Amounts are never represented
+// as floats.
+//
+// Money is written to JSON as {"amount":"12.34","currency":"EUR"}.
+// Amounts can be read from JSON strings as well as from numbers.
+type Money struct {
+	// Amount in the minor unit of Currency, e.g. cents.
+	Amount int64
+	// Currency is an ISO 4217 currency code like "EUR".
+	Currency string
+}
+
+// currencyDigits lists the currencies whose minor unit is not 1/100.
+var currencyDigits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyDigits returns the number of decimal places of the minor
+// unit of currency, e.g. 2 for "EUR" and 0 for "JPY".
+func CurrencyDigits(currency string) int {
+	if digits, found := currencyDigits[strings.ToUpper(currency)]; found {
+		return digits
+	}
+	return 2
+}
+
+// ParseMoney parses amount, e.g. "12.34" or "-5", in currency.
+// Amounts with more decimal places than the currency has are invalid.
+func ParseMoney(amount, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !isCurrencyCode(currency) {
+		return Money{}, fmt.Errorf("invalid currency %q", currency)
+	}
+	minor, err := parseMinorUnits(strings.TrimSpace(amount), CurrencyDigits(currency))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// ParseMoneyString parses a string like "12.34 EUR" or "EUR 12.34".
+func ParseMoneyString(s string) (Money, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if isCurrencyCode(strings.ToUpper(fields[0])) {
+		return ParseMoney(fields[1], fields[0])
+	}
+	return ParseMoney(fields[0], fields[1])
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMinorUnits parses a decimal like "-12.3" into minor units with
+// the given number of decimal places, e.g. -1230 for 2 digits.
+func parseMinorUnits(s string, digits int) (int64, error) {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || (hasFrac && frac == "") || len(frac) > digits || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", sign+s)
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	minor, err := strconv.ParseInt(sign+whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", sign+s)
+	}
+	return minor, nil
+}
+
+// Decimal returns the amount in decimal form, e.g. "12.34".
+func (m Money) Decimal() string {
+	digits := CurrencyDigits(m.Currency)
+	s := strconv.FormatInt(m.Amount, 10)
+	sign := ""
+	if m.Amount < 0 {
+		sign, s = "-", s[1:]
+	}
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+// String returns m in a form like "12.34 EUR".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero returns true if the amount of m is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m + o. It fails if the currencies differ.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("currency mismatch: %s and %s", m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		return Money{}, errors.New("amount overflow")
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. It fails if the currencies differ.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m multiplied by n, e.g. for the total of n items. It
+// fails if the amount overflows.
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Amount * n
+	if m.Amount != 0 && (product/m.Amount != n || (m.Amount == -1 && n == math.MinInt64)) {
+		return Money{}, errors.New("amount overflow")
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Allocate splits m into n parts that differ by at most one minor
+// unit and add up to m, e.g. 10.00 EUR into 3.34, 3.33, and 3.33.
+func (m Money) Allocate(n int) []Money {
+	if n <= 0 {
+		return nil
+	}
+	parts := make([]Money, n)
+	base, rem := m.Amount/int64(n), m.Amount%int64(n)
+	for i := range parts {
+		parts[i] = Money{Amount: base, Currency: m.Currency}
+		switch {
+		case rem > 0:
+			parts[i].Amount++
+			rem--
+		case rem < 0:
+			parts[i].Amount--
+			rem++
+		}
+	}
+	return parts
+}
+
+// Validate returns an InvalidParameterHintError for param if m has no
+// valid currency code.
+func (m Money) Validate(param string) error {
+	if !isCurrencyCode(m.Currency) {
+		return InvalidParameterHintError{Parameter: param, Hint: moneyHint}
+	}
+	return nil
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var v moneyJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	money, err := ParseMoney(v.Amount.String(), v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// MustFormMoney checks if the request r has a Form value with the
+// specified key that is an amount with currency like "12.34 EUR".
+// If is doesn't, it will panic.
+func MustFormMoney(r *http.Request, key string) Money {
+	v := mustFormValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	m, err := ParseMoneyString(v)
+	if err != nil {
+		panic(InvalidParameterHintError{Parameter: key, Hint: moneyHint})
+	}
+	return m
+}
+
+// MustQueryMoney checks if the request r has a query string with the
+// specified key that is an amount with currency like "12.34 EUR".
+// If is doesn't, it will panic.
+func MustQueryMoney(r *http.Request, key string) Money {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	m, err := ParseMoneyString(v)
+	if err != nil {
+		panic(InvalidParameterHintError{Parameter: key, Hint: moneyHint})
+	}
+	return m
+}
+
+// FormMoney checks if the request r has a Form value with the
+// specified key that is an amount with currency like "12.34 EUR".
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterHintError if the value is malformed.
+func FormMoney(r *http.Request, key string, defaultValue Money) Money {
+	v := formValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	m, err := ParseMoneyString(v)
+	if err != nil {
+		panic(InvalidParameterHintError{Parameter: key, Hint: moneyHint})
+	}
+	return m
+}
+
+// QueryMoney checks if the request r has a query string with the
+// specified key that is an amount with currency like "12.34 EUR".
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterHintError if the value is malformed.
+func QueryMoney(r *http.Request, key string, defaultValue Money) Money {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	m, err := ParseMoneyString(v)
+	if err != nil {
+		panic(InvalidParameterHintError{Parameter: key, Hint: moneyHint})
+	}
+	return m
+}
@@ -0,0 +1,209 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Want    Money
+		String  string
+		WantErr bool
+	}{
+		{Input: "12.34 EUR", Want: Money{1234, "EUR"}, String: "12.34 EUR"},
+		{Input: "eur 12.3", Want: Money{1230, "EUR"}, String: "12.30 EUR"},
+		{Input: "-0.05 USD", Want: Money{-5, "USD"}, String: "-0.05 USD"},
+		{Input: "7 EUR", Want: Money{700, "EUR"}, String: "7.00 EUR"},
+		{Input: "1500 JPY", Want: Money{1500, "JPY"}, String: "1500 JPY"},
+		{Input: "1.5 KWD", Want: Money{1500, "KWD"}, String: "1.500 KWD"},
+		{Input: "1.5 JPY", WantErr: true},
+		{Input: "12.345 EUR", WantErr: true},
+		{Input: "12. EUR", WantErr: true},
+		{Input: "1-2 EUR", WantErr: true},
+		{Input: "12.34 EURO", WantErr: true},
+		{Input: "12.34", WantErr: true},
+		{Input: "99999999999999999999 EUR", WantErr: true},
+	}
+	for i, tt := range tests {
+		m, err := ParseMoneyString(tt.Input)
+		if tt.WantErr {
+			if err == nil {
+				t.Errorf("#%d: want error, have %v", i, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Want, m; want != have {
+			t.Errorf("#%d: want %+v, have %+v", i, want, have)
+		}
+		if want, have := tt.String, m.String(); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a, b := Money{1000, "EUR"}, Money{250, "EUR"}
+	if sum, err := a.Add(b); err != nil || sum != (Money{1250, "EUR"}) {
+		t.Errorf("want 12.50 EUR, have %v, %v", sum, err)
+	}
+	if diff, err := b.Sub(a); err != nil || diff != (Money{-750, "EUR"}) {
+		t.Errorf("want -7.50 EUR, have %v, %v", diff, err)
+	}
+	if _, err := a.Add(Money{1, "USD"}); err == nil {
+		t.Error("want currency mismatch")
+	}
+	if _, err := (Money{1 << 62, "EUR"}).Add(Money{1 << 62, "EUR"}); err == nil {
+		t.Error("want overflow")
+	}
+	if product, err := a.Mul(3); err != nil || product != (Money{3000, "EUR"}) {
+		t.Errorf("want 30.00 EUR, have %v, %v", product, err)
+	}
+	if product, err := a.Mul(-2); err != nil || product != (Money{-2000, "EUR"}) {
+		t.Errorf("want -20.00 EUR, have %v, %v", product, err)
+	}
+	for _, tt := range []struct{ Amount, N int64 }{
+		{1 << 62, 2},
+		{1 << 62, -3},
+		{-1, math.MinInt64},
+		{math.MinInt64, -1},
+	} {
+		if _, err := (Money{tt.Amount, "EUR"}).Mul(tt.N); err == nil {
+			t.Errorf("%d * %d: want overflow", tt.Amount, tt.N)
+		}
+	}
+
+	parts := a.Allocate(3)
+	if want, have := "[3.34 EUR 3.33 EUR 3.33 EUR]", formatMoneys(parts); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	parts = a.Neg().Allocate(3)
+	if want, have := "[-3.34 EUR -3.33 EUR -3.33 EUR]", formatMoneys(parts); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+}
+
+func formatMoneys(parts []Money) string {
+	s := "["
+	for i, p := range parts {
+		if i > 0 {
+			s += " "
+		}
+		s += p.String()
+	}
+	return s + "]"
+}
+
+func TestMoneyJSON(t *testing.T) {
+	js, err := json.Marshal(Money{1234, "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"amount":"12.34","currency":"EUR"}`, string(js); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	tests := []struct {
+		Input   string
+		Want    Money
+		WantErr bool
+	}{
+		{Input: `{"amount":"12.34","currency":"EUR"}`, Want: Money{1234, "EUR"}},
+		{Input: `{"amount":12.34,"currency":"eur"}`, Want: Money{1234, "EUR"}},
+		{Input: `{"amount":"0.1","currency":"XXXX"}`, WantErr: true},
+		{Input: `{"amount":0.001,"currency":"EUR"}`, WantErr: true},
+	}
+	for i, tt := range tests {
+		var m Money
+		err := json.Unmarshal([]byte(tt.Input), &m)
+		if tt.WantErr {
+			if err == nil {
+				t.Errorf("#%d: want error, have %v", i, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Want, m; want != have {
+			t.Errorf("#%d: want %+v, have %+v", i, want, have)
+		}
+	}
+}
+
+func TestMustQueryMoney(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		m := MustQueryMoney(r, "price")
+		w.Write([]byte(m.String()))
+	}
+
+	tests := []struct {
+		URL  string
+		Code int
+		Body string
+	}{
+		{URL: "/?price=12.34+EUR", Code: http.StatusOK, Body: "12.34 EUR"},
+		{URL: "/", Code: http.StatusBadRequest},
+		{URL: "/?price=cheap", Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", tt.URL, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		}
+	}
+
+	def := Money{100, "EUR"}
+	for i, tt := range []struct {
+		URL  string
+		Want Money
+	}{
+		{URL: "/?price=12.34+USD", Want: Money{1234, "USD"}},
+		{URL: "/", Want: def},
+	} {
+		r := httptest.NewRequest("GET", tt.URL, nil)
+		if want, have := tt.Want, QueryMoney(r, "price", def); want != have {
+			t.Errorf("#%d: want QueryMoney %v, have %v", i, want, have)
+		}
+		if want, have := tt.Want, FormMoney(r, "price", def); want != have {
+			t.Errorf("#%d: want FormMoney %v, have %v", i, want, have)
+		}
+	}
+	for i, f := range []func(r *http.Request){
+		func(r *http.Request) { QueryMoney(r, "price", def) },
+		func(r *http.Request) { FormMoney(r, "price", def) },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(InvalidParameterHintError); !ok {
+					t.Errorf("#%d: want panic with InvalidParameterHintError for a malformed amount", i)
+				}
+			}()
+			f(httptest.NewRequest("GET", "/?price=cheap", nil))
+		}()
+	}
+
+	if err := (Money{100, "euro"}).Validate("price"); err == nil {
+		t.Error("want invalid currency")
+	} else if _, ok := err.(InvalidParameterHintError); !ok {
+		t.Errorf("want InvalidParameterHintError, have %T", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+)
+
+// MultipartResponse streams a multipart/mixed response with several
+// parts, e.g. a JSON document plus attachments. Use NewMultipartResponse
+// to create one, add parts, and finally call Close.
+//
+// Example:
+//
+//	mr := httputil.NewMultipartResponse(w)
+//	mr.WriteJSON(report)
+//	mr.WriteFile("text/csv", "report.csv", csvReader)
+//	mr.Close()
+type MultipartResponse struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	mw      *multipart.Writer
+	started bool
+}
+
+// NewMultipartResponse creates a new MultipartResponse writing into w.
+// The response will be sent with HTTP status 200 once the first part
+// is written. It uses the global Config; use NewMultipartResponseFor
+// for the Config set by WithConfig to take effect.
+func NewMultipartResponse(w http.ResponseWriter) *MultipartResponse {
+	return NewMultipartResponseFor(w, nil)
+}
+
+// NewMultipartResponseFor is like NewMultipartResponse, but serializes
+// JSON parts with the JSONCodec of the Config in effect for r.
+func NewMultipartResponseFor(w http.ResponseWriter, r *http.Request) *MultipartResponse {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{
+		"boundary": mw.Boundary(),
+	}))
+	return &MultipartResponse{w: w, r: r, mw: mw}
+}
+
+// Boundary returns the boundary that separates the parts.
+func (m *MultipartResponse) Boundary() string {
+	return m.mw.Boundary()
+}
+
+// CreatePart starts a new part with the given headers and returns a writer
+// for its body. The body must be written completely before creating the
+// next part.
+func (m *MultipartResponse) CreatePart(header textproto.MIMEHeader) (io.Writer, error) {
+	if !m.started {
+		m.started = true
+		m.w.WriteHeader(http.StatusOK)
+	} else if f, ok := m.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return m.mw.CreatePart(header)
+}
+
+// WriteJSON adds a part with data serialized as JSON.
+func (m *MultipartResponse) WriteJSON(data interface{}) error {
+	js, err := marshalCompactJSONFor(m.r, data)
+	if err != nil {
+		return err
+	}
+	pw, err := m.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"application/json"},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = pw.Write(js)
+	return err
+}
+
+// WriteFile adds a part with the given content type, copying its body
+// from r. If filename is not empty, the part gets a Content-Disposition
+// header of type attachment.
+func (m *MultipartResponse) WriteFile(contentType, filename string, r io.Reader) error {
+	header := textproto.MIMEHeader{
+		"Content-Type": {contentType},
+	}
+	if filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": filename,
+		}))
+	}
+	pw, err := m.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(pw, r); err != nil {
+		return fmt.Errorf("unable to write part %q: %v", filename, err)
+	}
+	return nil
+}
+
+// Close writes the trailing boundary. It must be called once all parts
+// have been written.
+func (m *MultipartResponse) Close() error {
+	if !m.started {
+		m.started = true
+		m.w.WriteHeader(http.StatusOK)
+	}
+	return m.mw.Close()
+}
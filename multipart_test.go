@@ -0,0 +1,68 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultipartResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	mr := NewMultipartResponse(w)
+	if err := mr.WriteJSON(map[string]int{"total": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.WriteFile("text/csv", "report.csv", strings.NewReader("a,b\n1,2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != 200 {
+		t.Fatalf("expected status = %d; got: %d", 200, w.Code)
+	}
+	mt, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != "multipart/mixed" || params["boundary"] != mr.Boundary() {
+		t.Fatalf("unexpected Content-Type %q", w.Header().Get("Content-Type"))
+	}
+
+	tests := []struct {
+		ContentType string
+		FileName    string
+		Body        string
+	}{
+		{ContentType: "application/json", Body: `{"total":2}`},
+		{ContentType: "text/csv", FileName: "report.csv", Body: "a,b\n1,2\n"},
+	}
+	mrd := multipart.NewReader(w.Body, params["boundary"])
+	for i, tt := range tests {
+		p, err := mrd.NextPart()
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.ContentType, p.Header.Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if want, have := tt.FileName, p.FileName(); want != have {
+			t.Errorf("#%d: want file name %q, have %q", i, want, have)
+		}
+		body, _ := ioutil.ReadAll(p)
+		if want, have := tt.Body, string(body); want != have {
+			t.Errorf("#%d: want body %q, have %q", i, want, have)
+		}
+	}
+	if _, err := mrd.NextPart(); err == nil {
+		t.Fatal("expected no more parts")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NegotiateContentType returns the best offered content type for the
+// request, based on its Accept header and the q-values therein.
+// If the request has no Accept header, the first offer is returned.
+// If no offer is acceptable, an empty string is returned.
+// On ties, the offer that comes first wins.
+func NegotiateContentType(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return ""
+	}
+	ranges := parseAccept(accept)
+
+	var best string
+	bestQ := 0.0
+	for _, offer := range offers {
+		q := acceptQuality(ranges, strings.ToLower(offer))
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaRange is a single media range of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		if mt == "" {
+			continue
+		}
+		slash := strings.IndexByte(mt, '/')
+		if slash < 0 {
+			continue
+		}
+		mr := mediaRange{typ: mt[:slash], subtype: mt[slash+1:], q: 1}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// acceptQuality returns the q-value of the most specific media range
+// that matches the content type.
+func acceptQuality(ranges []mediaRange, contentType string) float64 {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	slash := strings.IndexByte(contentType, '/')
+	if slash < 0 {
+		return 0
+	}
+	typ, subtype := contentType[:slash], contentType[slash+1:]
+
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		var s int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
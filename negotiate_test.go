@@ -0,0 +1,35 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		Accept string
+		Offers []string
+		Want   string
+	}{
+		{Accept: "", Offers: []string{"application/json", "text/csv"}, Want: "application/json"},
+		{Accept: "text/csv", Offers: []string{"application/json", "text/csv"}, Want: "text/csv"},
+		{Accept: "*/*", Offers: []string{"application/json", "text/csv"}, Want: "application/json"},
+		{Accept: "text/*", Offers: []string{"application/json", "text/csv"}, Want: "text/csv"},
+		{Accept: "text/csv;q=0.5, application/json", Offers: []string{"text/csv", "application/json"}, Want: "application/json"},
+		{Accept: "text/*;q=0.9, text/csv;q=0", Offers: []string{"text/csv", "text/plain"}, Want: "text/plain"},
+		{Accept: "image/png", Offers: []string{"application/json", "text/csv"}, Want: ""},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.Accept != "" {
+			req.Header.Set("Accept", tt.Accept)
+		}
+		if want, have := tt.Want, NegotiateContentType(req, tt.Offers...); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
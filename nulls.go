@@ -0,0 +1,265 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// JSONPolicy configures how WriteJSONWithPolicy writes null values and
+// empty collections, so services agree on one convention regardless of
+// the omitempty tags and nil slices of their types.
+type JSONPolicy struct {
+	// OmitNull removes fields whose value is null from JSON objects.
+	// Elements of arrays are kept.
+	OmitNull bool
+	// EmptyCollections writes nil slices as [] and nil maps as {}
+	// instead of null. Fields with omitempty are still omitted, and
+	// nil byte slices are still written as null.
+	EmptyCollections bool
+}
+
+// WriteJSONWithPolicy is like WriteJSONCode, but applies the policy p.
+//
+// Example:
+//
+//	p := httputil.JSONPolicy{OmitNull: true, EmptyCollections: true}
+//	httputil.WriteJSONWithPolicy(w, http.StatusOK, order, p)
+func WriteJSONWithPolicy(w http.ResponseWriter, code int, data interface{}, p JSONPolicy) {
+	js, err := p.Marshal(data)
+	if err != nil {
+		BadRequestError(w, "JSON serialization error: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(js)
+}
+
+// Marshal serializes data the way WriteJSONWithPolicy writes it.
+func (p JSONPolicy) Marshal(data interface{}) ([]byte, error) {
+	if p.EmptyCollections && data != nil {
+		data = fillEmptyCollections(reflect.ValueOf(data)).Interface()
+	}
+	if !p.OmitNull {
+		return marshalJSON(data)
+	}
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	v, err := decodeWithoutNulls(dec)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJSON(v)
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// fillEmptyCollections returns a deep copy of v with nil slices and
+// maps replaced by empty ones. Types that marshal themselves are left
+// unchanged.
+func fillEmptyCollections(v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(t.Elem())
+		out.Elem().Set(fillEmptyCollections(v.Elem()))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(t).Elem()
+		out.Set(fillEmptyCollections(v.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(t).Elem()
+		out.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				out.Field(i).Set(fillEmptyCollections(out.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(fillEmptyCollections(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(fillEmptyCollections(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		out := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), fillEmptyCollections(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
+
+// jsonObject is a JSON object that keeps the order of its fields.
+type jsonObject []jsonField
+
+type jsonField struct {
+	Name  string
+	Value interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeWithoutNulls decodes the next JSON value of dec, removing
+// fields whose value is null, and keeping the order of fields.
+func decodeWithoutNulls(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeWithoutNulls(dec)
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				obj = append(obj, jsonField{Name: name.(string), Value: value})
+			}
+		}
+		_, err = dec.Token() // }
+		return obj, err
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeWithoutNulls(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token() // ]
+		return arr, err
+	}
+	return tok, nil
+}
+
+// Optional is a field of a request body that may be absent, e.g. in
+// a PATCH request. Set is true if the field was present, even if its
+// value was null.
+//
+// Example:
+//
+//	type UpdateUser struct {
+//	  Name  httputil.Optional[string] `json:"name"`
+//	  Email httputil.Nullable[string] `json:"email"`
+//	}
+//
+//	if req.Name.Set {
+//	  user.Name = req.Name.Value
+//	}
+type Optional[T any] struct {
+	Value T
+	Set   bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value)
+}
+
+// Nullable is a field of a request body that may be absent, null, or
+// have a value, e.g. to distinguish "leave unchanged" from "clear" in
+// a PATCH request. Nullable is written as null if it is not set or
+// null, so it can be removed with JSONPolicy.OmitNull.
+type Nullable[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+// NullableOf returns a Nullable set to v.
+func NullableOf[T any](v T) Nullable[T] {
+	return Nullable[T]{Value: v, Set: true}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(bytes.TrimSpace(data)) == "null" {
+		var zero T
+		n.Value, n.Null = zero, true
+		return nil
+	}
+	n.Null = false
+	return json.Unmarshal(data, &n.Value)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n Nullable[T]) MarshalJSON() ([]byte, error) {
+	if !n.Set || n.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Value)
+}
+
+// Get returns the value and true if n has a value that is not null.
+func (n Nullable[T]) Get() (T, bool) {
+	return n.Value, n.Set && !n.Null
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONPolicy(t *testing.T) {
+	type item struct {
+		ID   int               `json:"id"`
+		Note *string           `json:"note"`
+		Tags []string          `json:"tags"`
+		Meta map[string]string `json:"meta"`
+		Opt  []string          `json:"opt,omitempty"`
+	}
+	type order struct {
+		Zebra string      `json:"zebra"`
+		Items []item      `json:"items"`
+		Extra interface{} `json:"extra"`
+		Raw   []byte      `json:"raw"`
+	}
+	v := order{Zebra: "z", Items: []item{{ID: 1}}}
+
+	tests := []struct {
+		Policy JSONPolicy
+		Want   string
+	}{
+		{
+			Policy: JSONPolicy{},
+			Want:   `{"zebra":"z","items":[{"id":1,"note":null,"tags":null,"meta":null}],"extra":null,"raw":null}`,
+		},
+		{
+			Policy: JSONPolicy{OmitNull: true},
+			Want:   `{"zebra":"z","items":[{"id":1}]}`,
+		},
+		{
+			Policy: JSONPolicy{EmptyCollections: true},
+			Want:   `{"zebra":"z","items":[{"id":1,"note":null,"tags":[],"meta":{}}],"extra":null,"raw":null}`,
+		},
+		{
+			Policy: JSONPolicy{OmitNull: true, EmptyCollections: true},
+			Want:   `{"zebra":"z","items":[{"id":1,"tags":[],"meta":{}}]}`,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteJSONWithPolicy(w, http.StatusCreated, v, tt.Policy)
+		if want, have := http.StatusCreated, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		if !EqualJSON([]byte(tt.Want), w.Body.Bytes()) {
+			t.Errorf("#%d: want\n%s\nhave\n%s", i, tt.Want, w.Body.String())
+		}
+	}
+
+	// Arrays keep null elements
+	js, err := JSONPolicy{OmitNull: true}.Marshal([]interface{}{1, nil, map[string]interface{}{"a": nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[\n  1,\n  null,\n  {}\n]\n", string(js); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestOptionalAndNullable(t *testing.T) {
+	type patch struct {
+		Name  Optional[string] `json:"name"`
+		Email Nullable[string] `json:"email"`
+	}
+	tests := []struct {
+		Input     string
+		NameSet   bool
+		Name      string
+		EmailSet  bool
+		EmailNull bool
+		Email     string
+	}{
+		{Input: `{}`},
+		{Input: `{"name":"a","email":"a@example.com"}`, NameSet: true, Name: "a", EmailSet: true, Email: "a@example.com"},
+		{Input: `{"name":null,"email":null}`, NameSet: true, EmailSet: true, EmailNull: true},
+	}
+	for i, tt := range tests {
+		var p patch
+		if err := json.Unmarshal([]byte(tt.Input), &p); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p.Name.Set != tt.NameSet || p.Name.Value != tt.Name {
+			t.Errorf("#%d: want name %v/%q, have %v/%q", i, tt.NameSet, tt.Name, p.Name.Set, p.Name.Value)
+		}
+		if p.Email.Set != tt.EmailSet || p.Email.Null != tt.EmailNull || p.Email.Value != tt.Email {
+			t.Errorf("#%d: want email %v/%v/%q, have %+v", i, tt.EmailSet, tt.EmailNull, tt.Email, p.Email)
+		}
+	}
+
+	js, err := json.Marshal(patch{Name: Optional[string]{Value: "a", Set: true}, Email: NullableOf("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"name":"a","email":"b"}`, string(js); want != have {
+		t.Errorf("want %s, have %s", want, have)
+	}
+	if v, ok := (Nullable[int]{Set: true, Null: true}).Get(); ok || v != 0 {
+		t.Errorf("want null, have %v/%v", v, ok)
+	}
+}
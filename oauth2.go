@@ -0,0 +1,405 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultOAuth2StateCookie is the default of OAuth2Config.StateCookie.
+const DefaultOAuth2StateCookie = "oauth2_state"
+
+// OAuth2Config configures NewOAuth2Handlers.
+type OAuth2Config struct {
+	// ClientID and ClientSecret are the credentials of the client.
+	// ClientSecret may be empty for public clients.
+	ClientID     string
+	ClientSecret string
+	// AuthURL and TokenURL are the authorization and token endpoints
+	// of the provider.
+	AuthURL  string
+	TokenURL string
+	// EndSessionURL is the RP-initiated logout endpoint of the provider,
+	// if any.
+	EndSessionURL string
+	// RedirectURL is the absolute URL of the Callback handler.
+	RedirectURL string
+	// Scopes are the requested scopes. They default to "openid".
+	Scopes []string
+
+	// Secret signs the state cookie. It is required.
+	Secret []byte
+	// StateCookie is the name of the cookie that keeps the state of the
+	// login between redirects. It defaults to DefaultOAuth2StateCookie.
+	StateCookie string
+
+	// Keys verifies the ID token, e.g. a KeySetProvider. If nil, the
+	// ID token is not verified, which is only safe if TokenURL is
+	// trusted and reached via TLS.
+	Keys KeySet
+	// Issuer is the expected issuer of the ID token. If empty, it
+	// defaults to the issuer of the discovery document of Keys, as in
+	// JWTConfig.
+	Issuer string
+
+	// Client sends the token requests. It defaults to a client with a
+	// timeout of 10 seconds.
+	Client *http.Client
+
+	// OnLogin is called after a successful login to establish a session,
+	// e.g. by setting a session cookie. Returning an error aborts the login.
+	OnLogin func(w http.ResponseWriter, r *http.Request, token *OAuth2Token) error
+	// Subject returns the authenticated user of a request to the Logout
+	// handler, or an empty string if there is none. The csrf_token of
+	// Logout is bound to it.
+	Subject func(r *http.Request) string
+	// OnLogout is called to end the session. It returns the ID token of
+	// the session, if known, to pass as hint to EndSessionURL.
+	OnLogout func(w http.ResponseWriter, r *http.Request) (idToken string, err error)
+	// PostLogoutRedirectURL is where users end up after logout. It
+	// defaults to "/".
+	PostLogoutRedirectURL string
+}
+
+// OAuth2Token is the response of the token endpoint.
+type OAuth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+
+	// Claims are the claims of the ID token, if any.
+	Claims JWTClaims `json:"-"`
+}
+
+// OAuth2Handlers implement the OAuth2 authorization code flow with PKCE
+// (RFC 7636), and OpenID Connect login and logout, for web applications.
+// Create them with NewOAuth2Handlers.
+//
+// Example:
+//
+//	auth, err := httputil.NewOAuth2Handlers(httputil.OAuth2Config{
+//	  ClientID:    "orders-web",
+//	  AuthURL:     "https://accounts.example.com/authorize",
+//	  TokenURL:    "https://accounts.example.com/token",
+//	  RedirectURL: "https://orders.example.com/auth/callback",
+//	  Secret:      stateSecret,
+//	  Keys:        keys,
+//	  Subject:     sessions.User,
+//	  OnLogin:     sessions.Start,
+//	  OnLogout:    sessions.End,
+//	})
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	router.Handle("/auth/login", http.HandlerFunc(auth.Login))
+//	router.Handle("/auth/callback", http.HandlerFunc(auth.Callback))
+//	router.Handle("/auth/logout", http.HandlerFunc(auth.Logout))
+//
+// Logout expects a POST with the csrf_token of LogoutToken, e.g. from
+// a form on the pages of signed-in users, so other sites can't sign
+// users out.
+type OAuth2Handlers struct {
+	cfg OAuth2Config
+}
+
+// oauth2State is kept in the signed state cookie during login.
+type oauth2State struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Nonce    string `json:"n"`
+	ReturnTo string `json:"r,omitempty"`
+}
+
+// NewOAuth2Handlers returns the handlers for cfg. It returns an error if
+// cfg has no Secret to sign the state cookie.
+func NewOAuth2Handlers(cfg OAuth2Config) (*OAuth2Handlers, error) {
+	if len(cfg.Secret) == 0 {
+		return nil, errors.New("httputil: OAuth2Config.Secret is required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid"}
+	}
+	if cfg.StateCookie == "" {
+		cfg.StateCookie = DefaultOAuth2StateCookie
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.PostLogoutRedirectURL == "" {
+		cfg.PostLogoutRedirectURL = "/"
+	}
+	return &OAuth2Handlers{cfg: cfg}, nil
+}
+
+// Login redirects to the authorization endpoint. The optional query
+// parameter "return_to" is a local path to redirect to after login.
+func (h *OAuth2Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	st := oauth2State{
+		State:    randomToken(),
+		Verifier: randomToken(),
+		Nonce:    randomToken(),
+	}
+	if returnTo := r.URL.Query().Get("return_to"); isLocalRedirect(returnTo) {
+		st.ReturnTo = returnTo
+	}
+	data, _ := json.Marshal(st)
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.cfg.StateCookie,
+		Value:    signCookieValue(base64.RawURLEncoding.EncodeToString(data), h.cfg.Secret),
+		Path:     "/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   RequestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	challenge := sha256.Sum256([]byte(st.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {h.cfg.ClientID},
+		"redirect_uri":          {h.cfg.RedirectURL},
+		"scope":                 {strings.Join(h.cfg.Scopes, " ")},
+		"state":                 {st.State},
+		"nonce":                 {st.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	http.Redirect(w, r, appendQuery(h.cfg.AuthURL, q), http.StatusFound)
+}
+
+// Callback completes the login: it validates the state, exchanges the
+// code for tokens, verifies the ID token, calls OnLogin, and redirects
+// to the return path of Login. It returns InvalidXSRFToken if the state
+// is invalid, and UnauthorizedError if the provider denies the login.
+func (h *OAuth2Handlers) Callback(w http.ResponseWriter, r *http.Request) {
+	st, ok := h.state(r)
+	// The state cookie is used only once
+	http.SetCookie(w, &http.Cookie{Name: h.cfg.StateCookie, Path: "/", MaxAge: -1})
+	q := r.URL.Query()
+	if !ok || !hmac.Equal([]byte(q.Get("state")), []byte(st.State)) {
+		writeJSONError(w, r, InvalidXSRFToken{})
+		return
+	}
+	if q.Get("error") != "" {
+		writeJSONError(w, r, UnauthorizedError{})
+		return
+	}
+	code := q.Get("code")
+	if code == "" {
+		writeJSONError(w, r, MissingParameterError("code"))
+		return
+	}
+
+	token, err := h.exchange(r, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {h.cfg.RedirectURL},
+		"code_verifier": {st.Verifier},
+	})
+	if err != nil {
+		writeJSONError(w, r, err)
+		return
+	}
+	if token.IDToken != "" && h.cfg.Keys != nil {
+		cfg := JWTConfig{Keys: h.cfg.Keys, Issuer: h.cfg.Issuer, Audience: h.cfg.ClientID}
+		claims, err := cfg.Verify(r.Context(), token.IDToken)
+		if err != nil || !hmac.Equal([]byte(claims.String("nonce")), []byte(st.Nonce)) {
+			writeJSONError(w, r, UnauthorizedError{})
+			return
+		}
+		token.Claims = claims
+	}
+
+	if h.cfg.OnLogin != nil {
+		if err := h.cfg.OnLogin(w, r, token); err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+	}
+	returnTo := st.ReturnTo
+	if !isLocalRedirect(returnTo) {
+		returnTo = "/"
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+// Logout calls OnLogout to end the session, and redirects to the
+// EndSessionURL of the provider, if any, or to PostLogoutRedirectURL.
+// It expects a POST with the csrf_token of LogoutToken, and returns
+// InvalidXSRFToken if it is invalid, and UnauthorizedError if there is
+// no user as returned by OAuth2Config.Subject.
+func (h *OAuth2Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSONError(w, r, InvalidMethodError{})
+		return
+	}
+	subject := h.subject(r)
+	if subject == "" {
+		writeJSONError(w, r, UnauthorizedError{})
+		return
+	}
+	token := r.PostFormValue("csrf_token")
+	if !hmac.Equal([]byte(token), []byte(h.csrfToken(subject))) {
+		writeJSONError(w, r, InvalidXSRFToken{})
+		return
+	}
+
+	var idToken string
+	if h.cfg.OnLogout != nil {
+		var err error
+		if idToken, err = h.cfg.OnLogout(w, r); err != nil {
+			writeJSONError(w, r, err)
+			return
+		}
+	}
+	if h.cfg.EndSessionURL == "" {
+		http.Redirect(w, r, h.cfg.PostLogoutRedirectURL, http.StatusFound)
+		return
+	}
+	q := url.Values{
+		"client_id":                {h.cfg.ClientID},
+		"post_logout_redirect_uri": {h.cfg.PostLogoutRedirectURL},
+	}
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	http.Redirect(w, r, appendQuery(h.cfg.EndSessionURL, q), http.StatusFound)
+}
+
+// LogoutToken returns the csrf_token to post to Logout for the user of
+// r, or an empty string if there is none.
+func (h *OAuth2Handlers) LogoutToken(r *http.Request) string {
+	subject := h.subject(r)
+	if subject == "" {
+		return ""
+	}
+	return h.csrfToken(subject)
+}
+
+// subject returns the user of r as per OAuth2Config.Subject.
+func (h *OAuth2Handlers) subject(r *http.Request) string {
+	if h.cfg.Subject == nil {
+		return ""
+	}
+	return h.cfg.Subject(r)
+}
+
+// csrfToken returns the CSRF token of the Logout handler for subject.
+func (h *OAuth2Handlers) csrfToken(subject string) string {
+	mac := hmac.New(sha256.New, h.cfg.Secret)
+	mac.Write([]byte("logout"))
+	mac.Write([]byte{0})
+	mac.Write([]byte(subject))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// state returns the verified state cookie.
+func (h *OAuth2Handlers) state(r *http.Request) (oauth2State, bool) {
+	var st oauth2State
+	if len(h.cfg.Secret) == 0 {
+		return st, false
+	}
+	c, err := r.Cookie(h.cfg.StateCookie)
+	if err != nil {
+		return st, false
+	}
+	v, ok := verifyCookieValue(c.Value, h.cfg.Secret)
+	if !ok {
+		return st, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil || json.Unmarshal(data, &st) != nil || st.State == "" {
+		return st, false
+	}
+	return st, true
+}
+
+// exchange requests a token from the token endpoint. It returns
+// UnauthorizedError if the provider rejects the grant, and
+// ServiceUnavailableError if the provider fails.
+func (h *OAuth2Handlers) exchange(r *http.Request, form url.Values) (*OAuth2Token, error) {
+	if h.cfg.ClientSecret == "" {
+		form.Set("client_id", h.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), "POST", h.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if h.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(h.cfg.ClientID), url.QueryEscape(h.cfg.ClientSecret))
+	}
+	res, err := h.cfg.Client.Do(req)
+	if err != nil {
+		return nil, ServiceUnavailableError{}
+	}
+	defer DrainAndClose(res)
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, ServiceUnavailableError{}
+	}
+	switch {
+	case res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized:
+		return nil, UnauthorizedError{}
+	case res.StatusCode != http.StatusOK:
+		return nil, ServiceUnavailableError{}
+	}
+	var token OAuth2Token
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return nil, ServiceUnavailableError{}
+	}
+	return &token, nil
+}
+
+// randomToken returns 32 random bytes, base64url-encoded.
+func randomToken() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(errors.New("httputil: unable to read random bytes"))
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// isLocalRedirect returns true if s is a path on the same host, so it
+// can't be abused as an open redirect. Browsers ignore tabs and line
+// breaks in URLs and treat backslashes like slashes, so e.g. "/\t/evil.com"
+// would be followed to "//evil.com"; such values are rejected.
+func isLocalRedirect(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == 0x7f || c == '\\' {
+			return false
+		}
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return false
+	}
+	return strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(u.Path, "//") &&
+		strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//")
+}
+
+// appendQuery adds q to the query string of rawURL.
+func appendQuery(rawURL string, q url.Values) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + q.Encode()
+	}
+	return rawURL + "?" + q.Encode()
+}
@@ -0,0 +1,300 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuth2Handlers(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	idp := newTestIdentityProvider(testJWK("ed", edKey))
+	defer idp.Close()
+
+	// The authorization request, as seen by the provider
+	var authorize url.Values
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "code-123" ||
+			base64.RawURLEncoding.EncodeToString(challenge[:]) != authorize.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			WriteJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if id, secret, _ := r.BasicAuth(); id != "web" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		WriteJSON(w, map[string]interface{}{
+			"access_token": "access-123",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token": testJWT(t, "ed", edKey, map[string]interface{}{
+				"iss":   "https://accounts.example.com",
+				"aud":   "web",
+				"sub":   "alice",
+				"nonce": authorize.Get("nonce"),
+				"exp":   time.Now().Add(time.Hour).Unix(),
+			}),
+		})
+	}))
+	defer tokenSrv.Close()
+
+	var session *OAuth2Token
+	auth, err := NewOAuth2Handlers(OAuth2Config{
+		ClientID:      "web",
+		ClientSecret:  "s3cr3t",
+		AuthURL:       "https://accounts.example.com/authorize",
+		TokenURL:      tokenSrv.URL,
+		EndSessionURL: "https://accounts.example.com/logout",
+		RedirectURL:   "https://app.example.com/auth/callback",
+		Secret:        []byte("secret"),
+		Keys:          NewKeySetProvider(KeySetConfig{JWKSURL: idp.URL + "/jwks"}),
+		Issuer:        "https://accounts.example.com",
+		OnLogin: func(w http.ResponseWriter, r *http.Request, token *OAuth2Token) error {
+			session = token
+			return nil
+		},
+		Subject: func(r *http.Request) string {
+			if session == nil {
+				return ""
+			}
+			return session.Claims.String("sub")
+		},
+		OnLogout: func(w http.ResponseWriter, r *http.Request) (string, error) {
+			idToken := session.IDToken
+			session = nil
+			return idToken, nil
+		},
+		PostLogoutRedirectURL: "https://app.example.com/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(returnTo string) (*http.Cookie, url.Values) {
+		w := httptest.NewRecorder()
+		auth.Login(w, httptest.NewRequest("GET", "/auth/login?return_to="+url.QueryEscape(returnTo), nil))
+		if want, have := http.StatusFound, w.Code; want != have {
+			t.Fatalf("want status %d, have %d", want, have)
+		}
+		loc, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("want state cookie, have %v", cookies)
+		}
+		return cookies[0], loc.Query()
+	}
+	callback := func(cookie *http.Cookie, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/auth/callback?"+query, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		auth.Callback(w, req)
+		return w
+	}
+
+	// Successful login
+	cookie, q := login("/orders")
+	authorize = q
+	if want, have := "S256", q.Get("code_challenge_method"); want != have {
+		t.Errorf("want code_challenge_method %q, have %q", want, have)
+	}
+	if want, have := "openid", q.Get("scope"); want != have {
+		t.Errorf("want scope %q, have %q", want, have)
+	}
+	w := callback(cookie, "code=code-123&state="+q.Get("state"))
+	if want, have := http.StatusFound, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body.String())
+	}
+	if want, have := "/orders", w.Header().Get("Location"); want != have {
+		t.Errorf("want Location %q, have %q", want, have)
+	}
+	if session == nil || session.AccessToken != "access-123" || session.Claims.String("sub") != "alice" {
+		t.Fatalf("want session for alice, have %+v", session)
+	}
+
+	// Logout requires a POST with the csrf_token
+	logout := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/auth/logout", strings.NewReader(url.Values{"csrf_token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		auth.Logout(w, req)
+		return w
+	}
+	token := auth.LogoutToken(httptest.NewRequest("GET", "/", nil))
+	if token == "" {
+		t.Fatal("want csrf_token for logout")
+	}
+	for i, tt := range []struct {
+		Method string
+		Token  string
+		Code   int
+	}{
+		{Method: "GET", Token: token, Code: http.StatusMethodNotAllowed},
+		{Method: "POST", Token: "", Code: http.StatusBadRequest},
+		{Method: "POST", Token: "forged", Code: http.StatusBadRequest},
+	} {
+		if want, have := tt.Code, logout(tt.Method, tt.Token).Code; want != have {
+			t.Errorf("logout #%d: want status %d, have %d", i, want, have)
+		}
+		if session == nil {
+			t.Fatalf("logout #%d: want session to be kept", i)
+		}
+	}
+	w = logout("POST", token)
+	if want, have := http.StatusFound, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body.String())
+	}
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if want, have := "accounts.example.com", loc.Host; want != have {
+		t.Errorf("want logout at %q, have %q", want, have)
+	}
+	if loc.Query().Get("id_token_hint") == "" {
+		t.Error("want id_token_hint")
+	}
+	if session != nil {
+		t.Error("want session to be ended")
+	}
+	if want, have := http.StatusUnauthorized, logout("POST", token).Code; want != have {
+		t.Errorf("want status %d without a session, have %d", want, have)
+	}
+
+	// Failures
+	cookie, q = login("https://evil.example.com")
+	authorize = q
+	tests := []struct {
+		Cookie *http.Cookie
+		Query  string
+		Code   int
+	}{
+		{Cookie: nil, Query: "code=code-123&state=" + q.Get("state"), Code: http.StatusBadRequest},
+		{Cookie: cookie, Query: "code=code-123&state=forged", Code: http.StatusBadRequest},
+		{Cookie: &http.Cookie{Name: cookie.Name, Value: "forged." + cookie.Value}, Query: "code=code-123&state=" + q.Get("state"), Code: http.StatusBadRequest},
+		{Cookie: cookie, Query: "error=access_denied&state=" + q.Get("state"), Code: http.StatusUnauthorized},
+		{Cookie: cookie, Query: "code=wrong&state=" + q.Get("state"), Code: http.StatusUnauthorized},
+		{Cookie: cookie, Query: "code=code-123&state=" + q.Get("state"), Code: http.StatusFound},
+	}
+	for i, tt := range tests {
+		w := callback(tt.Cookie, tt.Query)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusFound {
+			// No open redirects
+			if want, have := "/", w.Header().Get("Location"); want != have {
+				t.Errorf("#%d: want Location %q, have %q", i, want, have)
+			}
+		}
+	}
+}
+
+func TestOAuth2HandlersIssuer(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	idp := newTestIdentityProvider(testJWK("ed", edKey))
+	defer idp.Close()
+
+	var issuer, nonce string
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, map[string]interface{}{
+			"access_token": "access-123",
+			"token_type":   "Bearer",
+			"id_token": testJWT(t, "ed", edKey, map[string]interface{}{
+				"iss":   issuer,
+				"aud":   "web",
+				"sub":   "alice",
+				"nonce": nonce,
+				"exp":   time.Now().Add(time.Hour).Unix(),
+			}),
+		})
+	}))
+	defer tokenSrv.Close()
+
+	// Without Issuer, the issuer of the discovery document is expected
+	auth, err := NewOAuth2Handlers(OAuth2Config{
+		ClientID:    "web",
+		AuthURL:     idp.URL + "/authorize",
+		TokenURL:    tokenSrv.URL,
+		RedirectURL: "https://app.example.com/auth/callback",
+		Secret:      []byte("secret"),
+		Keys:        NewKeySetProvider(KeySetConfig{DiscoveryURL: idp.URL + "/.well-known/openid-configuration"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Issuer string
+		Code   int
+	}{
+		{Issuer: "https://evil.example.com", Code: http.StatusUnauthorized},
+		{Issuer: "", Code: http.StatusUnauthorized},
+		{Issuer: idp.URL, Code: http.StatusFound},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		auth.Login(w, httptest.NewRequest("GET", "/auth/login", nil))
+		loc, _ := url.Parse(w.Header().Get("Location"))
+		issuer, nonce = tt.Issuer, loc.Query().Get("nonce")
+
+		req := httptest.NewRequest("GET", "/auth/callback?code=code-123&state="+loc.Query().Get("state"), nil)
+		req.AddCookie(w.Result().Cookies()[0])
+		w = httptest.NewRecorder()
+		auth.Callback(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestOAuth2HandlersRequireSecret(t *testing.T) {
+	if _, err := NewOAuth2Handlers(OAuth2Config{ClientID: "web"}); err == nil {
+		t.Fatal("want error without Secret")
+	}
+}
+
+func TestIsLocalRedirect(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  bool
+	}{
+		{"/", true},
+		{"/orders", true},
+		{"/orders?status=open#top", true},
+		{"", false},
+		{"orders", false},
+		{"//evil.com", false},
+		{"/\\evil.com", false},
+		{"/\\/evil.com", false},
+		{"/\t/evil.com", false},
+		{"/\r/evil.com", false},
+		{"/\n/evil.com", false},
+		{"/\r\n/evil.com", false},
+		{"\t//evil.com", false},
+		{"/orders\x00", false},
+		{"https://evil.com", false},
+		{"https:/evil.com", false},
+		{"javascript:alert(1)", false},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, isLocalRedirect(tt.Input); want != have {
+			t.Errorf("isLocalRedirect(%q): want %v, have %v", tt.Input, want, have)
+		}
+	}
+}
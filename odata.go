@@ -0,0 +1,467 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ODataQuery holds the system query options of an OData request
+// as parsed by ParseODataQuery.
+type ODataQuery struct {
+	// Top is the maximum number of records to return ($top),
+	// or -1 if not specified.
+	Top int
+	// Skip is the number of records to skip ($skip).
+	Skip int
+	// OrderBy is the sort order ($orderby).
+	OrderBy []ODataOrder
+	// Select is the list of fields to return ($select).
+	Select []string
+	// Filter is the filter expression ($filter), or nil.
+	Filter FilterExpr
+}
+
+// ODataOrder is a sort field of $orderby.
+type ODataOrder struct {
+	Field string
+	Desc  bool
+}
+
+// FilterExpr is a node of a filter expression, e.g. as parsed from
+// the $filter option of ParseODataQuery. It is one of *FilterCompare,
+// *FilterLogical, *FilterNot, or *FilterFunc.
+type FilterExpr interface {
+	filterExpr()
+}
+
+// Comparison operators of FilterCompare.
+const (
+	FilterEq = "eq"
+	FilterNe = "ne"
+	FilterGt = "gt"
+	FilterGe = "ge"
+	FilterLt = "lt"
+	FilterLe = "le"
+)
+
+// FilterCompare compares a field to a literal value, e.g. "price lt 10".
+type FilterCompare struct {
+	Field string
+	// Op is one of FilterEq, FilterNe, FilterGt, FilterGe, FilterLt, or FilterLe.
+	Op string
+	// Value is a string, an int64, a float64, a bool, or nil for null.
+	Value interface{}
+}
+
+// FilterLogical combines two expressions with "and" or "or".
+type FilterLogical struct {
+	// Op is either "and" or "or".
+	Op          string
+	Left, Right FilterExpr
+}
+
+// FilterNot negates an expression.
+type FilterNot struct {
+	Expr FilterExpr
+}
+
+// FilterFunc is a string function applied to a field, e.g.
+// "contains(name,'bike')".
+type FilterFunc struct {
+	// Name is one of "contains", "startswith", or "endswith".
+	Name  string
+	Field string
+	Value string
+}
+
+func (*FilterCompare) filterExpr() {}
+func (*FilterLogical) filterExpr() {}
+func (*FilterNot) filterExpr()     {}
+func (*FilterFunc) filterExpr()    {}
+
+const (
+	// maxODataTop is the maximum value of $top.
+	maxODataTop = 1000
+	// maxFilterDepth limits the nesting of filter expressions.
+	maxFilterDepth = 32
+)
+
+// ParseODataQuery parses the OData system query options $top, $skip,
+// $orderby, $select, and $filter of r. The $filter option supports a
+// safe subset of OData: comparisons (eq, ne, gt, ge, lt, le) of fields
+// with literals, the functions contains, startswith, and endswith,
+// and the logical operators and, or, and not, with parentheses. The
+// result is an AST that can't contain anything else.
+//
+// Invalid or unsupported options, e.g. $expand, result in an
+// InvalidParameterHintError. Other query string parameters are ignored.
+func ParseODataQuery(r *http.Request) (*ODataQuery, error) {
+	q := &ODataQuery{Top: -1}
+	for name := range r.URL.Query() {
+		if !strings.HasPrefix(name, "$") {
+			continue
+		}
+		value, err := lookupQuery(r, name)
+		if err != nil {
+			return nil, err
+		}
+		invalid := func(format string, args ...interface{}) error {
+			return InvalidParameterHintError{Parameter: name, Hint: fmt.Sprintf(format, args...)}
+		}
+		switch name {
+		case "$top":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > maxODataTop {
+				return nil, invalid("Expected an integer between 0 and %d", maxODataTop)
+			}
+			q.Top = n
+		case "$skip":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, invalid("Expected a non-negative integer")
+			}
+			q.Skip = n
+		case "$orderby":
+			for _, part := range strings.Split(value, ",") {
+				fields := strings.Fields(part)
+				if len(fields) == 0 || len(fields) > 2 || !isODataField(fields[0]) {
+					return nil, invalid("Expected a list of fields like \"name asc,price desc\"")
+				}
+				o := ODataOrder{Field: fields[0]}
+				if len(fields) == 2 {
+					switch fields[1] {
+					case "asc":
+					case "desc":
+						o.Desc = true
+					default:
+						return nil, invalid("Expected asc or desc, got %q", fields[1])
+					}
+				}
+				q.OrderBy = append(q.OrderBy, o)
+			}
+		case "$select":
+			for _, field := range strings.Split(value, ",") {
+				field = strings.TrimSpace(field)
+				if !isODataField(field) {
+					return nil, invalid("Expected a list of fields like \"name,price\"")
+				}
+				q.Select = append(q.Select, field)
+			}
+		case "$filter":
+			expr, err := ParseFilter(value)
+			if err != nil {
+				return nil, invalid("%v", err)
+			}
+			q.Filter = expr
+		default:
+			return nil, invalid("Unsupported query option")
+		}
+	}
+	return q, nil
+}
+
+// MustParseODataQuery is like ParseODataQuery, but panics on errors.
+func MustParseODataQuery(r *http.Request) *ODataQuery {
+	q, err := ParseODataQuery(r)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// isODataField returns true if s is a field name, optionally
+// with a path like "address/city".
+func isODataField(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, seg := range strings.Split(s, "/") {
+		if seg == "" {
+			return false
+		}
+		for i := 0; i < len(seg); i++ {
+			c := seg[i]
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			case c >= '0' && c <= '9' && i > 0:
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ParseFilter parses a filter expression in the OData syntax supported
+// by ParseODataQuery, e.g. "price lt 10 and contains(name,'bike')".
+// An empty expression results in a nil FilterExpr.
+func ParseFilter(s string) (FilterExpr, error) {
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("Unexpected %q", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type filterTokenKind int
+
+const (
+	filterIdent filterTokenKind = iota
+	filterString
+	filterNumber
+	filterPunct
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, filterToken{kind: filterPunct, text: string(c)})
+			i++
+		case c == '\'':
+			// Strings are quoted with single quotes, which are escaped by doubling
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, fmt.Errorf("Unterminated string")
+				}
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						b.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: b.String()})
+		case c == '-' || c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterNumber, text: s[i:j]})
+			i = j
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			j := i + 1
+			for j < len(s) && (s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9' || s[j] == '_' || s[j] == '/') {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: filterIdent, text: s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("Unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return tok, fmt.Errorf("Unexpected end of filter")
+	}
+	p.pos++
+	return tok, nil
+}
+
+func (p *filterParser) expect(text string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if tok.kind != filterPunct || tok.text != text {
+		return fmt.Errorf("Expected %q, got %q", text, tok.text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr(depth int) (FilterExpr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != filterIdent || tok.text != "or" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &FilterLogical{Op: "or", Left: left, Right: right}
+	}
+}
+
+func (p *filterParser) parseAnd(depth int) (FilterExpr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != filterIdent || tok.text != "and" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &FilterLogical{Op: "and", Left: left, Right: right}
+	}
+}
+
+func (p *filterParser) parseUnary(depth int) (FilterExpr, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("Filter is nested too deeply")
+	}
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tok.kind == filterPunct && tok.text == "(":
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tok.kind == filterIdent && tok.text == "not":
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &FilterNot{Expr: expr}, nil
+	case tok.kind == filterIdent && isFilterFunc(tok.text):
+		return p.parseFunc(tok.text)
+	case tok.kind == filterIdent && isODataField(tok.text):
+		return p.parseCompare(tok.text)
+	}
+	return nil, fmt.Errorf("Unexpected %q", tok.text)
+}
+
+func isFilterFunc(name string) bool {
+	switch name {
+	case "contains", "startswith", "endswith":
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseFunc(name string) (FilterExpr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.kind != filterIdent || !isODataField(field.text) {
+		return nil, fmt.Errorf("Expected a field name in %s, got %q", name, field.text)
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if value.kind != filterString {
+		return nil, fmt.Errorf("Expected a string in %s, got %q", name, value.text)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &FilterFunc{Name: name, Field: field.text, Value: value.text}, nil
+}
+
+func (p *filterParser) parseCompare(field string) (FilterExpr, error) {
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case FilterEq, FilterNe, FilterGt, FilterGe, FilterLt, FilterLe:
+	default:
+		return nil, fmt.Errorf("Expected a comparison operator after %q, got %q", field, op.text)
+	}
+	lit, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch lit.kind {
+	case filterString:
+		value = lit.text
+	case filterNumber:
+		if n, err := strconv.ParseInt(lit.text, 10, 64); err == nil {
+			value = n
+		} else if f, err := strconv.ParseFloat(lit.text, 64); err == nil {
+			value = f
+		} else {
+			return nil, fmt.Errorf("Invalid number %q", lit.text)
+		}
+	case filterIdent:
+		switch lit.text {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			return nil, fmt.Errorf("Expected a literal, got %q", lit.text)
+		}
+	default:
+		return nil, fmt.Errorf("Expected a literal, got %q", lit.text)
+	}
+	return &FilterCompare{Field: field, Op: op.text, Value: value}, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseODataQuery(t *testing.T) {
+	v := url.Values{
+		"$top":     {"10"},
+		"$skip":    {"20"},
+		"$orderby": {"name, price desc"},
+		"$select":  {"id,name,address/city"},
+		"$filter":  {"price lt 10.5 and (contains(name,'bike') or not startswith(name,'O''Neil'))"},
+		"other":    {"ignored"},
+	}
+	req := httptest.NewRequest("GET", "/products?"+v.Encode(), nil)
+	q, err := ParseODataQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ODataQuery{
+		Top:     10,
+		Skip:    20,
+		OrderBy: []ODataOrder{{Field: "name"}, {Field: "price", Desc: true}},
+		Select:  []string{"id", "name", "address/city"},
+		Filter: &FilterLogical{
+			Op:   "and",
+			Left: &FilterCompare{Field: "price", Op: FilterLt, Value: 10.5},
+			Right: &FilterLogical{
+				Op:    "or",
+				Left:  &FilterFunc{Name: "contains", Field: "name", Value: "bike"},
+				Right: &FilterNot{Expr: &FilterFunc{Name: "startswith", Field: "name", Value: "O'Neil"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(want, q) {
+		t.Errorf("want %+v, have %+v", want, q)
+	}
+}
+
+func TestParseODataQueryDefaults(t *testing.T) {
+	q, err := ParseODataQuery(httptest.NewRequest("GET", "/products", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := -1, q.Top; want != have {
+		t.Errorf("want Top %d, have %d", want, have)
+	}
+	if q.Filter != nil {
+		t.Errorf("want no filter, have %+v", q.Filter)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		Filter string
+		Want   FilterExpr
+		Err    bool
+	}{
+		{Filter: "", Want: nil},
+		{Filter: "id eq 42", Want: &FilterCompare{Field: "id", Op: FilterEq, Value: int64(42)}},
+		{Filter: "temp gt -3", Want: &FilterCompare{Field: "temp", Op: FilterGt, Value: int64(-3)}},
+		{Filter: "active eq true", Want: &FilterCompare{Field: "active", Op: FilterEq, Value: true}},
+		{Filter: "deleted_at ne null", Want: &FilterCompare{Field: "deleted_at", Op: FilterNe, Value: nil}},
+		{Filter: "a eq 1 or b eq 2 and c eq 3", Want: &FilterLogical{
+			Op:   "or",
+			Left: &FilterCompare{Field: "a", Op: FilterEq, Value: int64(1)},
+			Right: &FilterLogical{
+				Op:    "and",
+				Left:  &FilterCompare{Field: "b", Op: FilterEq, Value: int64(2)},
+				Right: &FilterCompare{Field: "c", Op: FilterEq, Value: int64(3)},
+			},
+		}},
+		{Filter: "name eq 'x' ; drop table users", Err: true},
+		{Filter: "name eq 'unterminated", Err: true},
+		{Filter: "name like 'x%'", Err: true},
+		{Filter: "name eq other", Err: true},
+		{Filter: "(name eq 'x'", Err: true},
+		{Filter: "name eq 'x')", Err: true},
+		{Filter: "length(name) gt 3", Err: true},
+		{Filter: "contains(name, 3)", Err: true},
+		{Filter: "name eq", Err: true},
+		{Filter: "price eq 1.2.3", Err: true},
+		{Filter: strings.Repeat("(", 40) + "a eq 1" + strings.Repeat(")", 40), Err: true},
+	}
+	for i, tt := range tests {
+		have, err := ParseFilter(tt.Filter)
+		if tt.Err {
+			if err == nil {
+				t.Errorf("#%d: %q: want error, have %+v", i, tt.Filter, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %q: %v", i, tt.Filter, err)
+		}
+		if !reflect.DeepEqual(tt.Want, have) {
+			t.Errorf("#%d: %q: want %+v, have %+v", i, tt.Filter, tt.Want, have)
+		}
+	}
+}
+
+func TestParseODataQueryFailures(t *testing.T) {
+	tests := []string{
+		"$top=abc",
+		"$top=-1",
+		"$top=100000",
+		"$skip=-5",
+		"$orderby=name%20sideways",
+		"$orderby=",
+		"$select=name,,id",
+		"$select=na-me",
+		"$filter=name%20eq",
+		"$expand=orders",
+	}
+	for i, query := range tests {
+		req := httptest.NewRequest("GET", "/?"+query, nil)
+		_, err := ParseODataQuery(req)
+		if err == nil {
+			t.Errorf("#%d: %s: want error", i, query)
+			continue
+		}
+		if want, have := http.StatusBadRequest, err.(httpCoder).HTTPCode(); want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteLister returns the HTTP methods of the registered routes that
+// match the URL of a request, e.g. GET and PUT for "/orders/1".
+// Methods returns nil if no route matches the URL. A route that
+// accepts all methods is reported with OPTIONS, as it answers OPTIONS
+// requests itself.
+//
+// Use MuxRoutes for a gorilla/mux router.
+type RouteLister interface {
+	Methods(r *http.Request) []string
+}
+
+// RouteListerFunc is a function that implements RouteLister.
+type RouteListerFunc func(r *http.Request) []string
+
+// Methods calls f(r).
+func (f RouteListerFunc) Methods(r *http.Request) []string { return f(r) }
+
+// MuxRoutes returns a RouteLister for the routes of a gorilla/mux
+// router, including routes of subrouters.
+func MuxRoutes(router *mux.Router) RouteLister {
+	return RouteListerFunc(func(r *http.Request) []string {
+		var methods []string
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			if route.GetHandler() == nil {
+				// e.g. the route of a subrouter
+				return nil
+			}
+			var match mux.RouteMatch
+			if !route.Match(r, &match) && match.MatchErr != mux.ErrMethodMismatch {
+				return nil
+			}
+			if m, err := route.GetMethods(); err == nil {
+				methods = append(methods, m...)
+			} else {
+				methods = append(methods, http.MethodOptions)
+			}
+			return nil
+		})
+		return methods
+	})
+}
+
+// allowedMethods returns the sorted, unique methods including OPTIONS,
+// as used in the Allow header.
+func allowedMethods(methods []string) []string {
+	seen := map[string]bool{http.MethodOptions: true}
+	allowed := []string{http.MethodOptions}
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if !seen[method] {
+			seen[method] = true
+			allowed = append(allowed, method)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// Options returns a middleware that answers OPTIONS requests with
+// 204 No Content and an Allow header with the methods of the routes
+// that match the URL. Requests for URLs without routes, and for routes
+// that handle OPTIONS themselves, are passed to the next handler.
+//
+// Wrap the router with the middleware instead of registering it with
+// the router, as gorilla/mux only runs middleware for matching routes.
+// To answer CORS preflight requests with the same methods, set
+// CORSConfig.Routes.
+//
+// Example:
+//
+//	r := mux.NewRouter()
+//	r.HandleFunc("/orders/{id}", getOrder).Methods("GET")
+//	h := httputil.Options(httputil.MuxRoutes(r))(r)
+func Options(routes RouteLister) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			methods := routes.Methods(r)
+			if len(methods) == 0 || containsString(methods, http.MethodOptions) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Allow", strings.Join(allowedMethods(methods), ", "))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func newOptionsTestRouter() *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	router := mux.NewRouter()
+	router.HandleFunc("/orders", ok).Methods("GET", "POST")
+	router.HandleFunc("/orders/{id:[0-9]+}", ok).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}", ok).Methods("put", "DELETE")
+	router.HandleFunc("/custom", ok)
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/users", ok).Methods("GET")
+	return router
+}
+
+func TestOptions(t *testing.T) {
+	router := newOptionsTestRouter()
+	h := Options(MuxRoutes(router))(router)
+
+	tests := []struct {
+		Method string
+		Path   string
+		Code   int
+		Allow  string
+	}{
+		{Method: "OPTIONS", Path: "/orders", Code: http.StatusNoContent, Allow: "GET, OPTIONS, POST"},
+		{Method: "OPTIONS", Path: "/orders/1", Code: http.StatusNoContent, Allow: "DELETE, GET, OPTIONS, PUT"},
+		{Method: "OPTIONS", Path: "/admin/users", Code: http.StatusNoContent, Allow: "GET, OPTIONS"},
+		{Method: "OPTIONS", Path: "/orders/abc", Code: http.StatusNotFound},
+		{Method: "OPTIONS", Path: "/unknown", Code: http.StatusNotFound},
+		{Method: "OPTIONS", Path: "/custom", Code: http.StatusOK},
+		{Method: "GET", Path: "/orders/1", Code: http.StatusOK},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.Method, tt.Path, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Allow, w.Header().Get("Allow"); want != have {
+			t.Errorf("#%d: want Allow %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestCORSWithRoutes(t *testing.T) {
+	router := newOptionsTestRouter()
+	h := CORS(CORSConfig{
+		AllowedOrigins: []string{"*"},
+		Routes:         MuxRoutes(router),
+	})(router)
+
+	tests := []struct {
+		Path         string
+		Code         int
+		AllowMethods string
+	}{
+		{Path: "/orders/1", Code: http.StatusNoContent, AllowMethods: "GET, PUT, DELETE"},
+		{Path: "/custom", Code: http.StatusNoContent, AllowMethods: "GET, HEAD, POST, PUT, PATCH, DELETE"},
+		{Path: "/unknown", Code: http.StatusNotFound},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("OPTIONS", tt.Path, nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", "DELETE")
+		h.ServeHTTP(w, r)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.AllowMethods, w.Header().Get("Access-Control-Allow-Methods"); want != have {
+			t.Errorf("#%d: want Access-Control-Allow-Methods %q, have %q", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultParallelism is the maximum number of tasks run concurrently by Parallel.
+const DefaultParallelism = 8
+
+// ParallelError is returned by Parallel if one or more tasks fail.
+type ParallelError struct {
+	// Errors holds the error of each task by position, or nil if the
+	// task succeeded.
+	Errors []error
+}
+
+// Error returns the error in text form.
+func (e ParallelError) Error() string {
+	n := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			n++
+		}
+	}
+	return fmt.Sprintf("%d of %d tasks failed", n, len(e.Errors))
+}
+
+// HTTPCode returns the HTTP status code of the error. If all failed tasks
+// share the same HTTP status code, that code is returned, otherwise 500.
+func (e ParallelError) HTTPCode() int {
+	code := 0
+	for _, err := range e.Errors {
+		if err == nil {
+			continue
+		}
+		c := http.StatusInternalServerError
+		if i, ok := err.(httpCoder); ok {
+			c = i.HTTPCode()
+		}
+		if code != 0 && code != c {
+			return http.StatusInternalServerError
+		}
+		code = c
+	}
+	if code == 0 {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+// ErrorDetails returns the error message of each failed task.
+func (e ParallelError) ErrorDetails() []string {
+	var details []string
+	for i, err := range e.Errors {
+		if err != nil {
+			details = append(details, fmt.Sprintf("Task %d: %v", i, err))
+		}
+	}
+	return details
+}
+
+// Parallel runs tasks concurrently, with at most DefaultParallelism at
+// a time. See ParallelN for details.
+func Parallel(ctx context.Context, tasks ...func(ctx context.Context) error) error {
+	return ParallelN(ctx, DefaultParallelism, tasks...)
+}
+
+// ParallelN runs tasks concurrently, with at most n at a time, and waits
+// for all of them to finish. Panics in tasks are recovered as in SafeFunc.
+// Tasks that have not started when ctx is done, e.g. because the deadline
+// of the request has passed, are skipped and fail with TimeoutError.
+// A failing task does not cancel the others.
+//
+// If one or more tasks fail, ParallelN returns a ParallelError with the
+// error of each task, suitable for WriteJSONError.
+func ParallelN(ctx context.Context, n int, tasks ...func(ctx context.Context) error) error {
+	if n <= 0 {
+		n = 1
+	}
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, n)
+		errs   = make([]error, len(tasks))
+		failed bool
+		mu     sync.Mutex
+	)
+	for i, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			for j := i; j < len(tasks); j++ {
+				errs[j] = contextError(err)
+			}
+			failed = true
+			mu.Unlock()
+			break
+		}
+		wg.Add(1)
+		go func(i int, task func(ctx context.Context) error) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := SafeFunc(task)(ctx); err != nil {
+				mu.Lock()
+				errs[i] = err
+				failed = true
+				mu.Unlock()
+			}
+		}(i, task)
+	}
+	wg.Wait()
+	if !failed {
+		return nil
+	}
+	return ParallelError{Errors: errs}
+}
+
+// contextError converts the error of a done context into an error of
+// this package.
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return TimeoutError{}
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	var calls int32
+	task := func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+	if err := Parallel(context.Background(), task, task, task); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(3), atomic.LoadInt32(&calls); want != have {
+		t.Errorf("want %d calls, have %d", want, have)
+	}
+}
+
+func TestParallelNBoundsConcurrency(t *testing.T) {
+	var running, peak int32
+	task := func(ctx context.Context) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	tasks := make([]func(ctx context.Context) error, 10)
+	for i := range tasks {
+		tasks[i] = task
+	}
+	if err := ParallelN(context.Background(), 3, tasks...); err != nil {
+		t.Fatal(err)
+	}
+	if have := atomic.LoadInt32(&peak); have > 3 {
+		t.Errorf("want at most 3 concurrent tasks, have %d", have)
+	}
+}
+
+func TestParallelErrors(t *testing.T) {
+	err := Parallel(context.Background(),
+		func(ctx context.Context) error { return nil },
+		func(ctx context.Context) error { return NotFoundError{} },
+		func(ctx context.Context) error { panic(NotFoundError{}) },
+	)
+	var perr ParallelError
+	if !errors.As(err, &perr) {
+		t.Fatalf("want ParallelError, have %v", err)
+	}
+	if want, have := "2 of 3 tasks failed", perr.Error(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	w := httptest.NewRecorder()
+	WriteJSONError(w, err)
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	resp := ErrorEnvelopeOf(t, w)
+	want := []string{"Task 1: Record not found", "Task 2: Record not found"}
+	if len(resp.Error.Details) != len(want) {
+		t.Fatalf("want details %v, have %v", want, resp.Error.Details)
+	}
+	for i := range want {
+		if want[i] != resp.Error.Details[i] {
+			t.Errorf("#%d: want detail %q, have %q", i, want[i], resp.Error.Details[i])
+		}
+	}
+
+	mixed := ParallelError{Errors: []error{NotFoundError{}, errors.New("kaboom")}}
+	if want, have := http.StatusInternalServerError, mixed.HTTPCode(); want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
+
+func TestParallelDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return contextError(ctx.Err())
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+	err := ParallelN(ctx, 1, slow, slow, slow)
+	var perr ParallelError
+	if !errors.As(err, &perr) {
+		t.Fatalf("want ParallelError, have %v", err)
+	}
+	for i, err := range perr.Errors {
+		if _, ok := err.(TimeoutError); !ok {
+			t.Errorf("#%d: want TimeoutError, have %v", i, err)
+		}
+	}
+	if want, have := http.StatusGatewayTimeout, perr.HTTPCode(); want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
@@ -18,7 +18,7 @@ import (
 // MustFormString checks if the request r has a Form value with
 // the specified key of type string. If is doesn't, it will panic.
 func MustFormString(r *http.Request, key string) string {
-	v := r.FormValue(key)
+	v := mustFormValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -29,7 +29,7 @@ func MustFormString(r *http.Request, key string) string {
 // the specified key that can be converted to a bool.
 // If is doesn't, it will panic.
 func MustFormBool(r *http.Request, key string) bool {
-	v := r.FormValue(key)
+	v := mustFormValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -44,7 +44,7 @@ func MustFormBool(r *http.Request, key string) bool {
 // the specified key that can be converted to an int.
 // If is doesn't, it will panic.
 func MustFormInt(r *http.Request, key string) int {
-	v := r.FormValue(key)
+	v := mustFormValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -59,7 +59,7 @@ func MustFormInt(r *http.Request, key string) int {
 // the specified key that can be converted to an int32.
 // If is doesn't, it will panic.
 func MustFormInt32(r *http.Request, key string) int32 {
-	v := r.FormValue(key)
+	v := mustFormValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -74,7 +74,7 @@ func MustFormInt32(r *http.Request, key string) int32 {
 // the specified key that can be converted to an int64.
 // If is doesn't, it will panic.
 func MustFormInt64(r *http.Request, key string) int64 {
-	v := r.FormValue(key)
+	v := mustFormValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -89,7 +89,7 @@ func MustFormInt64(r *http.Request, key string) int64 {
 // the specified key that can be converted to a float64.
 // If is doesn't, it will panic.
 func MustFormFloat64(r *http.Request, key string) float64 {
-	v := r.FormValue(key)
+	v := mustFormValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -103,7 +103,7 @@ func MustFormFloat64(r *http.Request, key string) float64 {
 // FormString checks if the request r has a Form value with
 // the specified key. If is doesn't, it will return defaultValue.
 func FormString(r *http.Request, key string, defaultValue string) string {
-	if v := r.FormValue(key); v != "" {
+	if v := formValue(r, key); v != "" {
 		return v
 	}
 	return defaultValue
@@ -113,7 +113,7 @@ func FormString(r *http.Request, key string, defaultValue string) string {
 // the specified key that can be converted to a bool.
 // If is doesn't, it will return defaultValue.
 func FormBool(r *http.Request, key string, defaultValue bool) bool {
-	v := r.FormValue(key)
+	v := formValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -128,7 +128,7 @@ func FormBool(r *http.Request, key string, defaultValue bool) bool {
 // the specified key that can be converted to an int.
 // If is doesn't, it will return defaultValue.
 func FormInt(r *http.Request, key string, defaultValue int) int {
-	v := r.FormValue(key)
+	v := formValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -143,7 +143,7 @@ func FormInt(r *http.Request, key string, defaultValue int) int {
 // the specified key that can be converted to an int32.
 // If is doesn't, it will return defaultValue.
 func FormInt32(r *http.Request, key string, defaultValue int32) int32 {
-	v := r.FormValue(key)
+	v := formValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -158,7 +158,7 @@ func FormInt32(r *http.Request, key string, defaultValue int32) int32 {
 // the specified key that can be converted to an int64.
 // If is doesn't, it will return defaultValue.
 func FormInt64(r *http.Request, key string, defaultValue int64) int64 {
-	v := r.FormValue(key)
+	v := formValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -173,7 +173,7 @@ func FormInt64(r *http.Request, key string, defaultValue int64) int64 {
 // the specified key that can be converted to a float64.
 // If is doesn't, it will return defaultValue.
 func FormFloat64(r *http.Request, key string, defaultValue float64) float64 {
-	v := r.FormValue(key)
+	v := formValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -189,7 +189,7 @@ func FormFloat64(r *http.Request, key string, defaultValue float64) float64 {
 // MustQueryString checks if the request r has a query string with
 // the specified key. If is doesn't, it will panic.
 func MustQueryString(r *http.Request, key string) string {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -200,7 +200,7 @@ func MustQueryString(r *http.Request, key string) string {
 // the specified key that can be converted to a bool.
 // If is doesn't, it will panic.
 func MustQueryBool(r *http.Request, key string) bool {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -215,7 +215,7 @@ func MustQueryBool(r *http.Request, key string) bool {
 // the specified key that can be converted to an int.
 // If is doesn't, it will panic.
 func MustQueryInt(r *http.Request, key string) int {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -230,7 +230,7 @@ func MustQueryInt(r *http.Request, key string) int {
 // the specified key that can be converted to an int32.
 // If is doesn't, it will panic.
 func MustQueryInt32(r *http.Request, key string) int32 {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -245,7 +245,7 @@ func MustQueryInt32(r *http.Request, key string) int32 {
 // the specified key that can be converted to an int64.
 // If is doesn't, it will panic.
 func MustQueryInt64(r *http.Request, key string) int64 {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -260,7 +260,7 @@ func MustQueryInt64(r *http.Request, key string) int64 {
 // the specified key that can be converted to a float64.
 // If is doesn't, it will panic.
 func MustQueryFloat64(r *http.Request, key string) float64 {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		panic(MissingParameterError(key))
 	}
@@ -276,7 +276,7 @@ func MustQueryFloat64(r *http.Request, key string) float64 {
 // the given layout format.
 // If is doesn't, it will return defaultValue or a zero time.
 func MustQueryTime(r *http.Request, key, layout string) time.Time {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		var t time.Time
 		return t
@@ -293,7 +293,7 @@ func MustQueryTime(r *http.Request, key, layout string) time.Time {
 // the given layout format.
 // If is doesn't, it will return defaultValue or a zero time.
 func MustQueryTimeWithDefault(r *http.Request, key, layout string, defaultValue time.Time) time.Time {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -308,7 +308,7 @@ func MustQueryTimeWithDefault(r *http.Request, key, layout string, defaultValue
 // the specified key that can be converted to a time.Duration.
 // If is doesn't, it will return defaultValue or a zero time.
 func MustQueryDuration(r *http.Request, key string) time.Duration {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		var d time.Duration
 		return d
@@ -324,7 +324,7 @@ func MustQueryDuration(r *http.Request, key string) time.Duration {
 // the specified key that can be converted to a time.Duration.
 // If is doesn't, it will return defaultValue or a zero time.
 func MustQueryDurationWithDefault(r *http.Request, key string, defaultValue time.Duration) time.Duration {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -338,7 +338,7 @@ func MustQueryDurationWithDefault(r *http.Request, key string, defaultValue time
 // QueryString checks if the request r has a query string with
 // the specified key. If is doesn't, it will return defaultValue.
 func QueryString(r *http.Request, key string, defaultValue string) string {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -349,7 +349,7 @@ func QueryString(r *http.Request, key string, defaultValue string) string {
 // the specified key. If is doesn't, it will return defaultValue.
 // Otherwise it'll split the string by a comma and return the resulting array.
 func QueryStringArray(r *http.Request, key string, defaultValue []string) []string {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -360,7 +360,7 @@ func QueryStringArray(r *http.Request, key string, defaultValue []string) []stri
 // the specified key that can be converted to a bool.
 // If is doesn't, it will return defaultValue.
 func QueryBool(r *http.Request, key string, defaultValue bool) bool {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -375,7 +375,7 @@ func QueryBool(r *http.Request, key string, defaultValue bool) bool {
 // the specified key that can be converted to an int.
 // If is doesn't, it will return defaultValue.
 func QueryInt(r *http.Request, key string, defaultValue int) int {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -390,7 +390,7 @@ func QueryInt(r *http.Request, key string, defaultValue int) int {
 // the specified key that can be converted to an int32.
 // If is doesn't, it will return defaultValue.
 func QueryInt32(r *http.Request, key string, defaultValue int32) int32 {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -405,7 +405,7 @@ func QueryInt32(r *http.Request, key string, defaultValue int32) int32 {
 // the specified key that can be converted to an int64.
 // If is doesn't, it will return defaultValue.
 func QueryInt64(r *http.Request, key string, defaultValue int64) int64 {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -420,7 +420,7 @@ func QueryInt64(r *http.Request, key string, defaultValue int64) int64 {
 // the specified key that can be converted to a float64.
 // If is doesn't, it will return defaultValue.
 func QueryFloat64(r *http.Request, key string, defaultValue float64) float64 {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -436,7 +436,7 @@ func QueryFloat64(r *http.Request, key string, defaultValue float64) float64 {
 // the given layout format.
 // If is doesn't, it will return defaultValue or a zero time.
 func QueryTime(r *http.Request, key, layout string) time.Time {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		var t time.Time
 		return t
@@ -454,7 +454,7 @@ func QueryTime(r *http.Request, key, layout string) time.Time {
 // the given layout format.
 // If is doesn't, it will return defaultValue or a zero time.
 func QueryTimeWithDefault(r *http.Request, key, layout string, defaultValue time.Time) time.Time {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
@@ -469,7 +469,7 @@ func QueryTimeWithDefault(r *http.Request, key, layout string, defaultValue time
 // the specified key that can be converted to a time.Duration.
 // If is doesn't, it will return defaultValue or a zero duration.
 func QueryDuration(r *http.Request, key string) time.Duration {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		var d time.Duration
 		return d
@@ -486,7 +486,7 @@ func QueryDuration(r *http.Request, key string) time.Duration {
 // the specified key that can be converted to a time.Duration.
 // If is doesn't, it will return defaultValue or a zero duration.
 func QueryDurationWithDefault(r *http.Request, key string, defaultValue time.Duration) time.Duration {
-	v := r.URL.Query().Get(key)
+	v := mustQueryValue(r, key)
 	if v == "" {
 		return defaultValue
 	}
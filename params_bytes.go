@@ -0,0 +1,176 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// decodeBase64 decodes s in either the standard or the URL-safe
+// base64 encoding, with or without padding. It returns false if s is
+// malformed or decodes to more than maxLen bytes.
+func decodeBase64(s string, maxLen int) ([]byte, bool) {
+	s = strings.TrimRight(s, "=")
+	if base64.RawStdEncoding.DecodedLen(len(s)) > maxLen {
+		return nil, false
+	}
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	b, err := enc.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// decodeHex decodes the hex string s. It returns false if s is
+// malformed or decodes to more than maxLen bytes.
+func decodeHex(s string, maxLen int) ([]byte, bool) {
+	if hex.DecodedLen(len(s)) > maxLen {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// -- FormValue --
+
+// MustFormBytesBase64 checks if the request r has a Form value with
+// the specified key that can be decoded from base64 (standard or
+// URL-safe, with or without padding) into at most maxLen bytes.
+// If is doesn't, it will panic.
+func MustFormBytesBase64(r *http.Request, key string, maxLen int) []byte {
+	v := mustFormValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	b, ok := decodeBase64(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// MustFormBytesHex checks if the request r has a Form value with
+// the specified key that can be decoded from hex into at most
+// maxLen bytes. If is doesn't, it will panic.
+func MustFormBytesHex(r *http.Request, key string, maxLen int) []byte {
+	v := mustFormValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	b, ok := decodeHex(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// FormBytesBase64 checks if the request r has a Form value with
+// the specified key that can be decoded from base64 (standard or
+// URL-safe, with or without padding) into at most maxLen bytes.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the value is malformed or too long.
+func FormBytesBase64(r *http.Request, key string, maxLen int, defaultValue []byte) []byte {
+	v := formValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	b, ok := decodeBase64(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// FormBytesHex checks if the request r has a Form value with
+// the specified key that can be decoded from hex into at most
+// maxLen bytes. If is doesn't, it will return defaultValue. It panics
+// with InvalidParameterError if the value is malformed or too long.
+func FormBytesHex(r *http.Request, key string, maxLen int, defaultValue []byte) []byte {
+	v := formValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	b, ok := decodeHex(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// -- Query string --
+
+// MustQueryBytesBase64 checks if the request r has a query string with
+// the specified key that can be decoded from base64 (standard or
+// URL-safe, with or without padding) into at most maxLen bytes.
+// If is doesn't, it will panic.
+func MustQueryBytesBase64(r *http.Request, key string, maxLen int) []byte {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	b, ok := decodeBase64(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// MustQueryBytesHex checks if the request r has a query string with
+// the specified key that can be decoded from hex into at most
+// maxLen bytes. If is doesn't, it will panic.
+func MustQueryBytesHex(r *http.Request, key string, maxLen int) []byte {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		panic(MissingParameterError(key))
+	}
+	b, ok := decodeHex(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// QueryBytesBase64 checks if the request r has a query string with
+// the specified key that can be decoded from base64 (standard or
+// URL-safe, with or without padding) into at most maxLen bytes.
+// If is doesn't, it will return defaultValue. It panics with
+// InvalidParameterError if the value is malformed or too long.
+func QueryBytesBase64(r *http.Request, key string, maxLen int, defaultValue []byte) []byte {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	b, ok := decodeBase64(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
+
+// QueryBytesHex checks if the request r has a query string with
+// the specified key that can be decoded from hex into at most
+// maxLen bytes. If is doesn't, it will return defaultValue. It panics
+// with InvalidParameterError if the value is malformed or too long.
+func QueryBytesHex(r *http.Request, key string, maxLen int, defaultValue []byte) []byte {
+	v := mustQueryValue(r, key)
+	if v == "" {
+		return defaultValue
+	}
+	b, ok := decodeHex(v, maxLen)
+	if !ok {
+		panic(InvalidParameterError(key))
+	}
+	return b
+}
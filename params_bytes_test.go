@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMustQueryBytesBase64(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		fmt.Fprintf(w, "%x", MustQueryBytesBase64(r, "cursor", 4))
+	}
+
+	tests := []struct {
+		Value string
+		Code  int
+		Body  string
+	}{
+		{Value: "+/8=", Code: http.StatusOK, Body: "fbff"},
+		{Value: "-_8", Code: http.StatusOK, Body: "fbff"},
+		{Value: "AQIDBA==", Code: http.StatusOK, Body: "01020304"},
+		{Value: "AQIDBAU=", Code: http.StatusBadRequest},
+		{Value: "!!!", Code: http.StatusBadRequest},
+		{Value: "", Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/?cursor="+url.QueryEscape(tt.Value), nil)
+		w := httptest.NewRecorder()
+		h(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		}
+	}
+}
+
+func TestQueryBytesHex(t *testing.T) {
+	def := []byte{0}
+	tests := []struct {
+		Value string
+		Want  []byte // nil if the value is invalid
+	}{
+		{Value: "", Want: def},
+		{Value: "cafe", Want: []byte{0xca, 0xfe}},
+		{Value: "CAFE", Want: []byte{0xca, 0xfe}},
+		{Value: "caf"},
+		{Value: "cafebabe00"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/?sha="+tt.Value, nil)
+		func() {
+			defer func() {
+				if _, ok := recover().(InvalidParameterError); ok != (tt.Want == nil) {
+					t.Errorf("#%d: want panic with InvalidParameterError=%v", i, tt.Want == nil)
+				}
+			}()
+			if want, have := tt.Want, QueryBytesHex(req, "sha", 4, def); !bytes.Equal(want, have) {
+				t.Errorf("#%d: want %x, have %x", i, want, have)
+			}
+		}()
+	}
+}
+
+func TestFormBytes(t *testing.T) {
+	def := []byte{0}
+	tests := []struct {
+		Body string
+		Hex  []byte
+		B64  []byte
+	}{
+		{Body: "", Hex: def, B64: def},
+		{Body: "sha=cafe&key=yv4", Hex: []byte{0xca, 0xfe}, B64: []byte{0xca, 0xfe}},
+		{Body: "sha=caf&key=!!"},
+		{Body: "sha=cafebabe00&key=yv66vgA"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.Body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		func() {
+			defer func() {
+				if _, ok := recover().(InvalidParameterError); ok != (tt.Hex == nil) {
+					t.Errorf("#%d: want hex panic with InvalidParameterError=%v", i, tt.Hex == nil)
+				}
+			}()
+			if want, have := tt.Hex, FormBytesHex(req, "sha", 4, def); !bytes.Equal(want, have) {
+				t.Errorf("#%d: want hex %x, have %x", i, want, have)
+			}
+		}()
+		func() {
+			defer func() {
+				if _, ok := recover().(InvalidParameterError); ok != (tt.B64 == nil) {
+					t.Errorf("#%d: want base64 panic with InvalidParameterError=%v", i, tt.B64 == nil)
+				}
+			}()
+			if want, have := tt.B64, FormBytesBase64(req, "key", 4, def); !bytes.Equal(want, have) {
+				t.Errorf("#%d: want base64 %x, have %x", i, want, have)
+			}
+		}()
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WriteJSONPointer writes data as JSON into w with HTTP status code 200,
+// like WriteJSON. If the request has a "pointer" query parameter, only
+// the part of data referenced by it as a JSON Pointer (RFC 6901) is
+// written, e.g. "/items/0/price" for the price of the first item. This
+// helps to inspect large responses without adding endpoints.
+//
+// It returns InvalidParameterHintError for invalid pointers, and 404 Not
+// Found if the pointer references a non-existent value. The parameter is
+// read with QueryRaw, so the DuplicateParameterPolicy applies.
+func WriteJSONPointer(w http.ResponseWriter, r *http.Request, data interface{}) {
+	pointer, ok, err := QueryRaw(r, "pointer")
+	if err != nil {
+		writeJSONError(w, r, err)
+		return
+	}
+	if !ok {
+		WriteJSON(w, data)
+		return
+	}
+	js, err := json.Marshal(data)
+	if err != nil {
+		badRequestError(w, r, "JSON serialization error: %v", err)
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		badRequestError(w, r, "JSON serialization error: %v", err)
+		return
+	}
+	v, err := resolveJSONPointer(doc, pointer)
+	if err != nil {
+		writeJSONError(w, r, err)
+		return
+	}
+	WriteJSON(w, v)
+}
+
+// resolveJSONPointer returns the value referenced by pointer in doc,
+// which must be decoded into interface{}.
+func resolveJSONPointer(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, InvalidParameterHintError{Parameter: "pointer", Hint: `must be empty or begin with "/", e.g. /items/0`}
+	}
+	v := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch x := v.(type) {
+		case map[string]interface{}:
+			child, ok := x[token]
+			if !ok {
+				return nil, jsonPointerNotFound(pointer)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+				if token == "-" {
+					return nil, jsonPointerNotFound(pointer)
+				}
+				return nil, InvalidParameterHintError{Parameter: "pointer", Hint: fmt.Sprintf("%q is not an array index", token)}
+			}
+			if i >= len(x) {
+				return nil, jsonPointerNotFound(pointer)
+			}
+			v = x[i]
+		default:
+			return nil, jsonPointerNotFound(pointer)
+		}
+	}
+	return v, nil
+}
+
+func jsonPointerNotFound(pointer string) error {
+	return HTTPError{
+		Code:    http.StatusNotFound,
+		Message: "JSON Pointer references no value",
+		Details: []string{pointer},
+	}
+}
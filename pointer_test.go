@@ -0,0 +1,74 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestWriteJSONPointer(t *testing.T) {
+	type item struct {
+		SKU   string  `json:"sku"`
+		Price float64 `json:"price"`
+	}
+	data := struct {
+		ID    int64             `json:"id"`
+		Items []item            `json:"items"`
+		Attrs map[string]string `json:"attrs"`
+	}{
+		ID:    9007199254740993,
+		Items: []item{{SKU: "a", Price: 1.5}, {SKU: "b", Price: 2}},
+		Attrs: map[string]string{"a/b": "slash", "m~n": "tilde", "": "empty"},
+	}
+
+	tests := []struct {
+		Query  string
+		Policy DuplicateParameterPolicy
+		Code   int
+		Body   string
+	}{
+		{Query: "", Code: http.StatusOK, Body: `{"id":9007199254740993,"items":[{"sku":"a","price":1.5},{"sku":"b","price":2}],"attrs":{"":"empty","a/b":"slash","m~n":"tilde"}}`},
+		{Query: "pointer=", Code: http.StatusOK, Body: `{"attrs":{"":"empty","a/b":"slash","m~n":"tilde"},"id":9007199254740993,"items":[{"price":1.5,"sku":"a"},{"price":2,"sku":"b"}]}`},
+		{Query: "pointer=/id", Code: http.StatusOK, Body: `9007199254740993`},
+		{Query: "pointer=/items/1", Code: http.StatusOK, Body: `{"price":2,"sku":"b"}`},
+		{Query: "pointer=/items/0/sku", Code: http.StatusOK, Body: `"a"`},
+		{Query: "pointer=" + url.QueryEscape("/attrs/a~1b"), Code: http.StatusOK, Body: `"slash"`},
+		{Query: "pointer=" + url.QueryEscape("/attrs/m~0n"), Code: http.StatusOK, Body: `"tilde"`},
+		{Query: "pointer=/attrs/", Code: http.StatusOK, Body: `"empty"`},
+		{Query: "pointer=/items/2", Code: http.StatusNotFound},
+		{Query: "pointer=/items/-", Code: http.StatusNotFound},
+		{Query: "pointer=/missing", Code: http.StatusNotFound},
+		{Query: "pointer=/id/0", Code: http.StatusNotFound},
+		{Query: "pointer=/items/01", Code: http.StatusBadRequest},
+		{Query: "pointer=/items/%2B1", Code: http.StatusBadRequest},
+		{Query: "pointer=items", Code: http.StatusBadRequest},
+		{Query: "pointer=/id&pointer=/items/0", Code: http.StatusOK, Body: `9007199254740993`},
+		{Query: "pointer=/id&pointer=/items/0", Policy: DuplicatesLastWins, Code: http.StatusOK, Body: `{"price":1.5,"sku":"a"}`},
+		{Query: "pointer=/id&pointer=/items/0", Policy: DuplicatesReject, Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		h := WithDuplicateParameterPolicy(tt.Policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteJSONPointer(w, r, data)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/?"+tt.Query, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+			continue
+		}
+		if tt.Code != http.StatusOK {
+			if want, have := tt.Code, ErrorEnvelopeOf(t, w).Error.Code; want != have {
+				t.Errorf("#%d: want error code %d, have %d", i, want, have)
+			}
+			continue
+		}
+		if !EqualJSON([]byte(tt.Body), w.Body.Bytes()) {
+			t.Errorf("#%d: want %s, have %s", i, tt.Body, w.Body.String())
+		}
+	}
+}
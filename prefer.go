@@ -0,0 +1,105 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preferences are the preferences of a client, as passed in the Prefer
+// header (RFC 7240).
+type Preferences struct {
+	// Return is either "minimal" or "representation", if given.
+	Return string
+	// Wait is the time the client is willing to wait for a response.
+	Wait time.Duration
+	// RespondAsync is true if the client prefers an asynchronous response.
+	RespondAsync bool
+	// Handling is either "strict" or "lenient", if given.
+	Handling string
+	// All contains all preferences by their lowercase name,
+	// including the ones above. Preferences without a value
+	// map to an empty string.
+	All map[string]string
+}
+
+// Has returns true if the client passed the preference with the given name.
+func (p Preferences) Has(name string) bool {
+	_, found := p.All[strings.ToLower(name)]
+	return found
+}
+
+// ParsePrefer parses the Prefer headers of r. If the same preference is
+// given more than once, the first occurrence wins. Parameters of
+// preferences are ignored.
+func ParsePrefer(r *http.Request) Preferences {
+	p := Preferences{All: make(map[string]string)}
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range splitQuoted(header, ',') {
+			// Strip parameters
+			pref = strings.TrimSpace(splitQuoted(pref, ';')[0])
+			if pref == "" {
+				continue
+			}
+			name, value := pref, ""
+			if i := strings.IndexByte(pref, '='); i >= 0 {
+				name, value = strings.TrimSpace(pref[:i]), strings.TrimSpace(pref[i+1:])
+				if s, err := strconv.Unquote(value); err == nil {
+					value = s
+				}
+			}
+			name = strings.ToLower(name)
+			if _, found := p.All[name]; found {
+				continue
+			}
+			p.All[name] = value
+
+			switch name {
+			case "return":
+				p.Return = strings.ToLower(value)
+			case "wait":
+				if secs, err := strconv.ParseInt(value, 10, 64); err == nil && secs >= 0 {
+					p.Wait = time.Duration(secs) * time.Second
+				}
+			case "respond-async":
+				p.RespondAsync = true
+			case "handling":
+				p.Handling = strings.ToLower(value)
+			}
+		}
+	}
+	return p
+}
+
+// SetPreferenceApplied adds the applied preferences to the
+// Preference-Applied response header, e.g. "return=minimal".
+func SetPreferenceApplied(w http.ResponseWriter, prefs ...string) {
+	if len(prefs) > 0 {
+		w.Header().Add("Preference-Applied", strings.Join(prefs, ", "))
+	}
+	w.Header().Add("Vary", "Prefer")
+}
+
+// splitQuoted splits s by sep, ignoring separators in quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var inQuote bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuote:
+			i++
+		case s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParsePrefer(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Add("Prefer", `respond-async, wait=10`)
+	req.Header.Add("Prefer", `Return=Minimal; foo="a,b", handling=strict, wait=20, x-custom="a, b"`)
+
+	p := ParsePrefer(req)
+	if want, have := "minimal", p.Return; want != have {
+		t.Errorf("Return: want %q, have %q", want, have)
+	}
+	if want, have := 10*time.Second, p.Wait; want != have {
+		t.Errorf("Wait: want %v, have %v", want, have)
+	}
+	if !p.RespondAsync {
+		t.Error("RespondAsync: want true")
+	}
+	if want, have := "strict", p.Handling; want != have {
+		t.Errorf("Handling: want %q, have %q", want, have)
+	}
+	if want, have := "a, b", p.All["x-custom"]; want != have {
+		t.Errorf("x-custom: want %q, have %q", want, have)
+	}
+	if !p.Has("Respond-Async") || p.Has("foo") {
+		t.Errorf("unexpected preferences: %v", p.All)
+	}
+
+	if p := ParsePrefer(httptest.NewRequest("GET", "/", nil)); p.Return != "" || len(p.All) != 0 {
+		t.Errorf("expected no preferences; got: %+v", p)
+	}
+}
+
+func TestSetPreferenceApplied(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetPreferenceApplied(w, "return=minimal", "handling=strict")
+	if want, have := "return=minimal, handling=strict", w.Header().Get("Preference-Applied"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := "Prefer", w.Header().Get("Vary"); want != have {
+		t.Errorf("want Vary %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentTypeProtobuf is the content type used for binary Protocol Buffers.
+// ReadProto also accepts the alternative "application/x-protobuf".
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// isProtobufContentType returns true for the content types of binary
+// Protocol Buffers.
+func isProtobufContentType(mt string) bool {
+	return mt == "application/x-protobuf" || mt == "application/protobuf"
+}
+
+// ReadProto deserializes the body of the request into msg. Depending on
+// the Content-Type, the body is expected to be either binary Protocol
+// Buffers (application/x-protobuf or application/protobuf) or their JSON
+// mapping (application/json). Other content types are rejected with
+// UnsupportedMediaTypeError. A maximum size of 8 MB is permitted.
+func ReadProto(r *http.Request, msg proto.Message) error {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !isProtobufContentType(mt) && mt != "application/json" {
+		return UnsupportedMediaTypeError{}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, 8<<20))
+	if err != nil {
+		return InvalidJSONError{fmt.Errorf("invalid protobuf data: %v", err)}
+	}
+	if mt == "application/json" {
+		if err := protojson.Unmarshal(data, msg); err != nil {
+			return InvalidJSONError{fmt.Errorf("invalid JSON data: %v", err)}
+		}
+		return nil
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return InvalidJSONError{fmt.Errorf("invalid protobuf data: %v", err)}
+	}
+	return nil
+}
+
+// WriteProto writes msg as binary Protocol Buffers into w and sets the
+// HTTP status code.
+func WriteProto(w http.ResponseWriter, code int, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		WriteJSONError(w, ServerError(fmt.Sprintf("Protobuf serialization error: %v", err)))
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeProtobuf)
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// WriteProtoJSON writes msg in the JSON mapping of Protocol Buffers into w
+// and sets the HTTP status code.
+func WriteProtoJSON(w http.ResponseWriter, code int, msg proto.Message) {
+	js, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(msg)
+	if err != nil {
+		WriteJSONError(w, ServerError(fmt.Sprintf("JSON serialization error: %v", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// WriteProtoNegotiated writes msg either as binary Protocol Buffers or
+// as JSON, depending on the Accept header of r. JSON is preferred.
+func WriteProtoNegotiated(w http.ResponseWriter, r *http.Request, code int, msg proto.Message) {
+	w.Header().Add("Vary", "Accept")
+	ct := NegotiateContentType(r, "application/json", ContentTypeProtobuf, "application/protobuf")
+	if isProtobufContentType(ct) {
+		WriteProto(w, code, msg)
+		return
+	}
+	WriteProtoJSON(w, code, msg)
+}
+
+// WriteProtoError writes err as a google.rpc.Status message, either as
+// binary Protocol Buffers or as JSON, depending on the Accept header of r.
+// The HTTP status code is determined like in WriteJSONError, and is
+// mapped to the corresponding gRPC code. If err is a GrpcError, its
+// status is passed through. Like ServeJSONError, the message and details
+// are hidden as per the ErrorVerbosity of the Config in effect for r.
+func WriteProtoError(w http.ResponseWriter, r *http.Request, err interface{}) {
+	code := 500
+	if i, ok := err.(httpCoder); ok {
+		code = i.HTTPCode()
+	}
+	var st *status.Status
+	if e, ok := err.(GrpcError); ok {
+		st = status.Convert(e.Err)
+	} else {
+		st = status.New(grpcCodeFromHTTP(code), fmt.Sprint(err))
+	}
+	notifyErrorWritten(r, code, err)
+	logErrorWritten(w, r, code, st.Message())
+	if configFor(r).ErrorVerbosity.hides(code) {
+		st = status.New(st.Code(), http.StatusText(code))
+	}
+	writeErrorHeaders(w, err)
+	WriteProtoNegotiated(w, r, code, st.Proto())
+}
+
+// grpcCodeFromHTTP maps a HTTP status code to a gRPC code.
+func grpcCodeFromHTTP(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented, http.StatusMethodNotAllowed:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if code >= 400 && code < 500 {
+		return codes.InvalidArgument
+	}
+	return codes.Internal
+}
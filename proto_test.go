@@ -0,0 +1,124 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestReadProto(t *testing.T) {
+	bin, _ := proto.Marshal(wrapperspb.String("Oliver"))
+	tests := []struct {
+		ContentType string
+		Body        []byte
+		Err         bool
+	}{
+		{ContentType: "application/x-protobuf", Body: bin},
+		{ContentType: "application/protobuf", Body: bin},
+		{ContentType: "application/json", Body: []byte(`"Oliver"`)},
+		{ContentType: "text/plain", Body: []byte(`Oliver`), Err: true},
+		{ContentType: "application/json", Body: []byte(`{`), Err: true},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(tt.Body))
+		req.Header.Set("Content-Type", tt.ContentType)
+		var msg wrapperspb.StringValue
+		err := ReadProto(req, &msg)
+		if tt.Err {
+			if err == nil {
+				t.Errorf("#%d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if want, have := "Oliver", msg.GetValue(); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestWriteProtoNegotiated(t *testing.T) {
+	tests := []struct {
+		Accept      string
+		ContentType string
+	}{
+		{Accept: "", ContentType: "application/json"},
+		{Accept: "application/json", ContentType: "application/json"},
+		{Accept: "application/x-protobuf", ContentType: ContentTypeProtobuf},
+		{Accept: "application/protobuf", ContentType: ContentTypeProtobuf},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.Accept != "" {
+			req.Header.Set("Accept", tt.Accept)
+		}
+		w := httptest.NewRecorder()
+		WriteProtoNegotiated(w, req, http.StatusCreated, wrapperspb.String("Oliver"))
+		if w.Code != http.StatusCreated {
+			t.Errorf("#%d: expected status = %d; got: %d", i, http.StatusCreated, w.Code)
+		}
+		if want, have := tt.ContentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		var msg wrapperspb.StringValue
+		var err error
+		if tt.ContentType == ContentTypeProtobuf {
+			err = proto.Unmarshal(w.Body.Bytes(), &msg)
+		} else {
+			err = protojson.Unmarshal(w.Body.Bytes(), &msg)
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+		} else if want, have := "Oliver", msg.GetValue(); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestWriteProtoError(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	WriteProtoError(w, req, NotFoundError{})
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status = %d; got: %d", http.StatusNotFound, w.Code)
+	}
+	var st spb.Status
+	if err := proto.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(codes.NotFound), st.GetCode(); want != have {
+		t.Errorf("want code %d, have %d", want, have)
+	}
+	if want, have := "Record not found", st.GetMessage(); want != have {
+		t.Errorf("want message %q, have %q", want, have)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	WriteProtoError(w, req, InvalidParameterError("id"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status = %d; got: %d", http.StatusBadRequest, w.Code)
+	}
+	if err := protojson.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(codes.InvalidArgument), st.GetCode(); want != have {
+		t.Errorf("want code %d, have %d", want, have)
+	}
+}
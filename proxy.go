@@ -0,0 +1,278 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ProxyConfig specifies which reverse proxies in front of the server are
+// trusted, and which of their headers are consulted by ClientIP,
+// RequestScheme, RequestHost, and AbsoluteURL. Headers are only taken
+// into account if the request comes from a trusted proxy.
+type ProxyConfig struct {
+	// TrustedCIDRs are the networks of trusted proxies,
+	// e.g. "10.0.0.0/8" or "::1/128".
+	TrustedCIDRs []string
+	// TrustXFF enables the X-Forwarded-For, X-Forwarded-Proto, and
+	// X-Forwarded-Host headers.
+	TrustXFF bool
+	// TrustForwarded enables the Forwarded header (RFC 7239). It takes
+	// precedence over the X-Forwarded-* headers.
+	TrustForwarded bool
+	// MaxHops is the maximum number of proxy hops that are inspected
+	// when determining the client IP. Zero means no limit.
+	MaxHops int
+}
+
+// SetProxyConfig sets the global ProxyConfig. By default, no proxy is
+// trusted. Use WithProxyConfig to override it for some handlers only.
+func SetProxyConfig(cfg ProxyConfig) {
+	updateConfig(func(c *Config) { c.Proxy = cfg })
+}
+
+// WithProxyConfig returns a middleware that makes the helpers use cfg
+// instead of the ProxyConfig of the Config in effect for all requests
+// passing through. It is a shortcut for setting Config.Proxy with
+// WithConfig.
+func WithProxyConfig(cfg ProxyConfig) func(http.Handler) http.Handler {
+	return overrideConfig(func(c *Config) { c.Proxy = cfg })
+}
+
+// ProxyConfigFromRequest returns the ProxyConfig in effect for r.
+func ProxyConfigFromRequest(r *http.Request) ProxyConfig {
+	return configFor(r).Proxy
+}
+
+// Trusts returns true if ip belongs to one of the trusted networks.
+func (cfg ProxyConfig) Trusts(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cfg.TrustedCIDRs {
+		if ipnet := parseCIDR(cidr); ipnet != nil && ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// cidrs caches the networks parsed by parseCIDR. Invalid networks are
+// kept as nil.
+var cidrs sync.Map // string -> *net.IPNet
+
+// parseCIDR returns the network cidr, or nil if it is invalid. Each
+// network is parsed only once.
+func parseCIDR(cidr string) *net.IPNet {
+	if v, ok := cidrs.Load(cidr); ok {
+		return v.(*net.IPNet)
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		ipnet = nil
+	}
+	cidrs.Store(cidr, ipnet)
+	return ipnet
+}
+
+// ClientIP returns the IP address of the client that sent r. If r comes
+// from a trusted proxy, the forwarding headers are walked from the nearest
+// to the farthest hop, and the first address that is not a trusted proxy
+// is returned.
+func ClientIP(r *http.Request) string {
+	remote := remoteHost(r)
+	cfg := ProxyConfigFromRequest(r)
+	if !cfg.Trusts(net.ParseIP(remote)) {
+		return remote
+	}
+	hops := forwardedFor(r, cfg)
+	ip := remote
+	for i, n := len(hops)-1, 0; i >= 0; i, n = i-1, n+1 {
+		if cfg.MaxHops > 0 && n >= cfg.MaxHops {
+			break
+		}
+		ip = hops[i]
+		if !cfg.Trusts(net.ParseIP(ip)) {
+			break
+		}
+	}
+	return ip
+}
+
+// RequestScheme returns the scheme the client used to send r,
+// i.e. "http" or "https", taking trusted proxies into account. Like
+// ClientIP, it walks the forwarding headers from the nearest hop, and
+// uses the value added by the farthest trusted proxy.
+func RequestScheme(r *http.Request) string {
+	cfg := ProxyConfigFromRequest(r)
+	if cfg.Trusts(net.ParseIP(remoteHost(r))) {
+		if proto := forwardedParam(r, cfg, "proto", "X-Forwarded-Proto", isForwardedProto); proto != "" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// RequestHost returns the host the client used to send r, taking
+// trusted proxies into account like RequestScheme. Hosts with slashes,
+// user info, or white space are ignored.
+func RequestHost(r *http.Request) string {
+	cfg := ProxyConfigFromRequest(r)
+	if cfg.Trusts(net.ParseIP(remoteHost(r))) {
+		if host := forwardedParam(r, cfg, "host", "X-Forwarded-Host", isForwardedHost); host != "" {
+			return host
+		}
+	}
+	return r.Host
+}
+
+// AbsoluteURL resolves the reference ref, e.g. "/orders/1", against the
+// URL of r as seen by the client, taking trusted proxies into account.
+func AbsoluteURL(r *http.Request, ref string) string {
+	base := &url.URL{
+		Scheme: RequestScheme(r),
+		Host:   RequestHost(r),
+		Path:   r.URL.Path,
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return base.String()
+	}
+	return base.ResolveReference(u).String()
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns the client addresses of all hops, from the
+// farthest to the nearest.
+func forwardedFor(r *http.Request, cfg ProxyConfig) []string {
+	var hops []string
+	if cfg.TrustForwarded {
+		for _, elem := range forwardedElements(r) {
+			if v := elem["for"]; v != "" {
+				hops = append(hops, stripPort(v))
+			}
+		}
+		if len(hops) > 0 {
+			return hops
+		}
+	}
+	if cfg.TrustXFF {
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, ip := range strings.Split(header, ",") {
+				if ip = strings.TrimSpace(ip); ip != "" {
+					hops = append(hops, ip)
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedParam returns the parameter of the Forwarded elements, or
+// the value of the given X-Forwarded-* header. The values are walked
+// from the nearest hop: Each value added by a trusted proxy replaces
+// the previous one, and the walk stops at the first hop that is not a
+// trusted proxy or has an invalid value, so clients cannot spoof it.
+func forwardedParam(r *http.Request, cfg ProxyConfig, param, header string, valid func(string) bool) string {
+	if cfg.TrustForwarded {
+		var values, hops []string
+		for _, elem := range forwardedElements(r) {
+			values = append(values, elem[param])
+			hops = append(hops, stripPort(elem["for"]))
+		}
+		if v := trustedParam(cfg, values, hops, valid); v != "" {
+			return v
+		}
+	}
+	if cfg.TrustXFF {
+		return trustedParam(cfg, headerList(r, header), headerList(r, "X-Forwarded-For"), valid)
+	}
+	return ""
+}
+
+// trustedParam returns the value added by the farthest trusted proxy.
+// values are the values of the hops, from the farthest to the nearest,
+// and hops are the addresses the proxies received the request from.
+func trustedParam(cfg ProxyConfig, values, hops []string, valid func(string) bool) string {
+	var value string
+	for n := 0; n < len(values); n++ {
+		if cfg.MaxHops > 0 && n >= cfg.MaxHops {
+			break
+		}
+		if v := strings.ToLower(values[len(values)-1-n]); v != "" {
+			if !valid(v) {
+				break
+			}
+			value = v
+		}
+		// The next value was added by the client of this hop
+		if n >= len(hops) || !cfg.Trusts(net.ParseIP(hops[len(hops)-1-n])) {
+			break
+		}
+	}
+	return value
+}
+
+// headerList returns the comma-separated values of the header.
+func headerList(r *http.Request, header string) []string {
+	var values []string
+	for _, v := range r.Header.Values(header) {
+		for _, s := range strings.Split(v, ",") {
+			values = append(values, strings.TrimSpace(s))
+		}
+	}
+	return values
+}
+
+func isForwardedProto(proto string) bool {
+	return proto == "http" || proto == "https"
+}
+
+func isForwardedHost(host string) bool {
+	return !strings.ContainsAny(host, "/@\\") && strings.IndexFunc(host, unicode.IsSpace) < 0
+}
+
+// forwardedElements parses the Forwarded headers of r (RFC 7239).
+func forwardedElements(r *http.Request) []map[string]string {
+	var elems []map[string]string
+	for _, header := range r.Header.Values("Forwarded") {
+		for _, elem := range splitQuoted(header, ',') {
+			m := make(map[string]string)
+			for _, pair := range splitQuoted(elem, ';') {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				m[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+			elems = append(elems, m)
+		}
+	}
+	return elems
+}
+
+// stripPort removes the port from a node in a Forwarded header,
+// e.g. "[2001:db8::1]:4711" or "192.0.2.60:4711".
+func stripPort(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.Trim(node, "[]")
+}
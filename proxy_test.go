@@ -0,0 +1,158 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		Config     ProxyConfig
+		RemoteAddr string
+		Header     http.Header
+		Want       string
+	}{
+		{
+			// Proxy headers are ignored by default
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+			Want:       "10.0.0.1",
+		},
+		{
+			// Proxy headers are ignored from untrusted peers
+			Config:     ProxyConfig{TrustedCIDRs: []string{"10.0.0.0/8"}, TrustXFF: true},
+			RemoteAddr: "192.168.1.1:1234",
+			Header:     http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+			Want:       "192.168.1.1",
+		},
+		{
+			// Spoofed entries left of the first untrusted hop are ignored
+			Config:     ProxyConfig{TrustedCIDRs: []string{"10.0.0.0/8"}, TrustXFF: true},
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"X-Forwarded-For": {"6.6.6.6, 1.2.3.4, 10.0.0.2"}},
+			Want:       "1.2.3.4",
+		},
+		{
+			Config:     ProxyConfig{TrustedCIDRs: []string{"10.0.0.0/8"}, TrustXFF: true, MaxHops: 1},
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"X-Forwarded-For": {"1.2.3.4, 10.0.0.2"}},
+			Want:       "10.0.0.2",
+		},
+		{
+			Config:     ProxyConfig{TrustedCIDRs: []string{"10.0.0.0/8"}, TrustForwarded: true},
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"Forwarded": {`for=192.0.2.43, for="[2001:db8:cafe::17]:4711"`}},
+			Want:       "2001:db8:cafe::17",
+		},
+		{
+			// Forwarded is not trusted
+			Config:     ProxyConfig{TrustedCIDRs: []string{"10.0.0.0/8"}, TrustXFF: true},
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"Forwarded": {`for=192.0.2.43`}},
+			Want:       "10.0.0.1",
+		},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.RemoteAddr
+		req.Header = tt.Header
+		var have string
+		WithProxyConfig(tt.Config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			have = ClientIP(r)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		if want := tt.Want; want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	SetProxyConfig(ProxyConfig{TrustedCIDRs: []string{"10.0.0.0/8"}, TrustXFF: true, TrustForwarded: true})
+	defer SetProxyConfig(ProxyConfig{})
+
+	tests := []struct {
+		RemoteAddr string
+		Header     http.Header
+		Ref        string
+		Want       string
+	}{
+		{
+			RemoteAddr: "192.168.1.1:1234",
+			Header:     http.Header{"X-Forwarded-Proto": {"https"}},
+			Ref:        "/orders/1",
+			Want:       "http://example.com/orders/1",
+		},
+		{
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"api.example.com"}},
+			Ref:        "/orders/1",
+			Want:       "https://api.example.com/orders/1",
+		},
+		{
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"Forwarded": {`proto=https;host="shop.example.com"`}, "X-Forwarded-Proto": {"http"}},
+			Ref:        "2",
+			Want:       "https://shop.example.com/orders/2",
+		},
+		{
+			// The client spoofs Forwarded, and the trusted proxy appends to it
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"Forwarded": {`host=evil.com;proto=http, for=1.2.3.4;host=shop.example.com;proto=https`}},
+			Ref:        "/orders/1",
+			Want:       "https://shop.example.com/orders/1",
+		},
+		{
+			// The client spoofs X-Forwarded-Host, and the trusted proxy appends to it
+			RemoteAddr: "10.0.0.1:1234",
+			Header: http.Header{
+				"X-Forwarded-For":   {"1.2.3.4"},
+				"X-Forwarded-Host":  {"evil.com, api.example.com"},
+				"X-Forwarded-Proto": {"http, https"},
+			},
+			Ref:  "/orders/1",
+			Want: "https://api.example.com/orders/1",
+		},
+		{
+			// Values of trusted proxies farther away are used
+			RemoteAddr: "10.0.0.1:1234",
+			Header: http.Header{
+				"X-Forwarded-For":  {"6.6.6.6, 1.2.3.4, 10.0.0.2"},
+				"X-Forwarded-Host": {"evil.com, api.example.com, internal.local"},
+			},
+			Ref:  "/orders/1",
+			Want: "http://api.example.com/orders/1",
+		},
+		{
+			// Invalid hosts are ignored
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"X-Forwarded-Host": {"evil.com/path"}},
+			Ref:        "/orders/1",
+			Want:       "http://example.com/orders/1",
+		},
+		{
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"Forwarded": {`host="user@evil.com"`}},
+			Ref:        "/orders/1",
+			Want:       "http://example.com/orders/1",
+		},
+		{
+			RemoteAddr: "10.0.0.1:1234",
+			Header:     http.Header{"X-Forwarded-Host": {"evil.com\texample.com"}},
+			Ref:        "/orders/1",
+			Want:       "http://example.com/orders/1",
+		},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/orders/1", nil)
+		req.RemoteAddr = tt.RemoteAddr
+		req.Header = tt.Header
+		if want, have := tt.Want, AbsoluteURL(req, tt.Ref); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
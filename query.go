@@ -0,0 +1,78 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// StrictQuery checks that the query string of r only contains the
+// allowed parameters. It returns UnexpectedParametersError with the
+// names of all other parameters, sorted, so that typos like
+// "?pagesize=" instead of "?page_size=" don't silently fall back
+// to defaults.
+func StrictQuery(r *http.Request, allowed ...string) error {
+	ok := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		ok[name] = true
+	}
+	var unexpected []string
+	for name := range r.URL.Query() {
+		if !ok[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	if len(unexpected) > 0 {
+		sort.Strings(unexpected)
+		return UnexpectedParametersError(unexpected)
+	}
+	return nil
+}
+
+// MustStrictQuery is like StrictQuery, but panics on errors.
+func MustStrictQuery(r *http.Request, allowed ...string) {
+	if err := StrictQuery(r, allowed...); err != nil {
+		panic(err)
+	}
+}
+
+// CanonicalQuery returns the query string of r in canonical form,
+// suitable for signing and cache keys: Parameters are sorted by name,
+// the order of multiple values of the same parameter is preserved,
+// and all names and values are percent-encoded as in RFC 3986
+// (e.g. a space becomes "%20").
+func CanonicalQuery(r *http.Request) string {
+	return canonicalQuery(r.URL.Query())
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		ek := rfc3986Escape(k)
+		for _, v := range values[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(ek)
+			b.WriteByte('=')
+			b.WriteString(rfc3986Escape(v))
+		}
+	}
+	return b.String()
+}
+
+// rfc3986Escape percent-encodes all but the unreserved characters of RFC 3986.
+func rfc3986Escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
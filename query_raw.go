@@ -0,0 +1,118 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+type queryCacheContextKey struct{}
+
+// queryCache holds the query string of a request, parsed on first use.
+type queryCache struct {
+	once   sync.Once
+	values url.Values
+}
+
+// CacheQuery returns a middleware that makes CachedQuery parse the
+// query string of each request only once, no matter how often it is
+// called, e.g. by the Query* helpers.
+func CacheQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), queryCacheContextKey{}, &queryCache{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// CachedQuery returns the parsed query string of r. With the CacheQuery
+// middleware, it is parsed only once per request; without it, it is
+// parsed on each call like r.URL.Query(). The result is shared, so
+// callers must not modify it.
+func CachedQuery(r *http.Request) url.Values {
+	c, ok := r.Context().Value(queryCacheContextKey{}).(*queryCache)
+	if !ok {
+		return r.URL.Query()
+	}
+	c.once.Do(func() {
+		c.values = r.URL.Query()
+	})
+	return c.values
+}
+
+// QueryRaw returns the value of the query string parameter key, and
+// false if r doesn't have it, applying the DuplicateParameterPolicy.
+// Unlike r.URL.Query(), it scans the query string without parsing it
+// into a map, and doesn't allocate unless key or value are escaped.
+// With the CacheQuery middleware, the cached query string is used.
+// It returns DuplicateParameterError if the policy rejects the
+// parameter.
+//
+// The Query* helpers use it, so simple handlers don't pay for parsing
+// the whole query string on each call.
+func QueryRaw(r *http.Request, key string) (string, bool, error) {
+	if _, ok := r.Context().Value(queryCacheContextKey{}).(*queryCache); ok {
+		values := CachedQuery(r)[key]
+		if len(values) == 0 {
+			return "", false, nil
+		}
+		v, err := pickValue(r, key, values)
+		return v, err == nil, err
+	}
+
+	var first, last string
+	n := 0
+	query := r.URL.RawQuery
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if pair == "" || strings.Contains(pair, ";") {
+			// Like url.ParseQuery, which rejects semicolons
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		if !queryKeyEquals(k, key) {
+			continue
+		}
+		v, ok := queryUnescape(v)
+		if !ok {
+			continue
+		}
+		if n == 0 {
+			first = v
+		}
+		last = v
+		n++
+	}
+	switch n {
+	case 0:
+		return "", false, nil
+	case 1:
+		return first, true, nil
+	}
+	v, err := pickDuplicate(r, key, first, last)
+	return v, err == nil, err
+}
+
+// queryKeyEquals returns true if the escaped key k equals key.
+func queryKeyEquals(k, key string) bool {
+	if !strings.ContainsAny(k, "%+") {
+		return k == key
+	}
+	k, ok := queryUnescape(k)
+	return ok && k == key
+}
+
+// queryUnescape unescapes s, without allocating if s isn't escaped.
+func queryUnescape(s string) (string, bool) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, true
+	}
+	s, err := url.QueryUnescape(s)
+	return s, err == nil
+}
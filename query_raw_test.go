@@ -0,0 +1,127 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryRaw(t *testing.T) {
+	tests := []struct {
+		Query string
+		Key   string
+		Want  string
+		OK    bool
+	}{
+		{Query: "", Key: "q"},
+		{Query: "q=go", Key: "q", Want: "go", OK: true},
+		{Query: "a=1&q=go&b=2", Key: "q", Want: "go", OK: true},
+		{Query: "q=", Key: "q", Want: "", OK: true},
+		{Query: "q", Key: "q", Want: "", OK: true},
+		{Query: "qq=1", Key: "q"},
+		{Query: "q=hello+world%21", Key: "q", Want: "hello world!", OK: true},
+		{Query: "first%20name=Oliver", Key: "first name", Want: "Oliver", OK: true},
+		{Query: "q=%zz&q=ok", Key: "q", Want: "ok", OK: true},
+		{Query: "q=1;q=2", Key: "q"},
+		{Query: "q=1&&q=2", Key: "q", Want: "1", OK: true},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/?"+tt.Query, nil)
+		have, ok, err := QueryRaw(r, tt.Key)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want := tt.OK; want != ok {
+			t.Errorf("#%d: want ok=%v, have %v", i, want, ok)
+		}
+		if want := tt.Want; want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+		// Same as parsing with the standard library
+		values, found := r.URL.Query()[tt.Key]
+		if found != ok || (found && values[0] != have) {
+			t.Errorf("#%d: want %q like url.Values, have %q", i, values, have)
+		}
+	}
+}
+
+func TestQueryRawDuplicates(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?id=1&id=2", nil)
+	for _, cached := range []bool{false, true} {
+		var (
+			have string
+			err  error
+		)
+		h := WithDuplicateParameterPolicy(DuplicatesLastWins)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			have, _, _ = QueryRaw(r, "id")
+		}))
+		if cached {
+			h = CacheQuery(h)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if want := "2"; want != have {
+			t.Errorf("cached=%v: want %q, have %q", cached, want, have)
+		}
+
+		h = WithDuplicateParameterPolicy(DuplicatesReject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _, err = QueryRaw(r, "id")
+		}))
+		if cached {
+			h = CacheQuery(h)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if _, ok := err.(DuplicateParameterError); !ok {
+			t.Errorf("cached=%v: want DuplicateParameterError, have %v", cached, err)
+		}
+	}
+}
+
+func TestQueryRawAllocs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?limit=10&offset=20&sort=name&q=go", nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		if v, ok, _ := QueryRaw(r, "q"); !ok || v != "go" {
+			t.Fatalf("want %q, have %q", "go", v)
+		}
+		MustQueryInt(r, "limit")
+	})
+	if allocs != 0 {
+		t.Errorf("want no allocations, have %v", allocs)
+	}
+}
+
+func TestCacheQuery(t *testing.T) {
+	h := CacheQuery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := CachedQuery(r)
+		r.URL.RawQuery = "limit=20"
+		if want, have := "10", CachedQuery(r).Get("limit"); want != have {
+			t.Errorf("want query string to be parsed once, have limit=%q", have)
+		}
+		if want, have := "10", mustQueryValue(r, "limit"); want != have {
+			t.Errorf("want cached query string to be used, have limit=%q", have)
+		}
+		if want, have := len(q), len(CachedQuery(r)); want != have {
+			t.Errorf("want %d values, have %d", want, have)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?limit=10", nil))
+}
+
+func BenchmarkQueryRaw(b *testing.B) {
+	r := httptest.NewRequest("GET", "/?limit=10&offset=20&sort=name&q=go", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		QueryRaw(r, "q")
+	}
+}
+
+func BenchmarkURLQuery(b *testing.B) {
+	r := httptest.NewRequest("GET", "/?limit=10&offset=20&sort=name&q=go", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.URL.Query().Get("q")
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestStrictQuery(t *testing.T) {
+	req := httptest.NewRequest("GET", "/orders?page=1&pagesize=10&sort=asc", nil)
+	if err := StrictQuery(req, "page", "pagesize", "sort"); err != nil {
+		t.Fatalf("expected no error; got: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/orders?page=1&pagesize=10&sorting=asc", nil)
+	err := StrictQuery(req, "page", "page_size", "sort")
+	uerr, ok := err.(UnexpectedParametersError)
+	if !ok {
+		t.Fatalf("expected UnexpectedParametersError; got: %v", err)
+	}
+	if want, have := []string{`Unexpected parameter "pagesize"`, `Unexpected parameter "sorting"`}, uerr.ErrorDetails(); !reflect.DeepEqual(want, have) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if want, have := http.StatusBadRequest, uerr.HTTPCode(); want != have {
+		t.Fatalf("want %d, have %d", want, have)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		Query string
+		Want  string
+	}{
+		{Query: "", Want: ""},
+		{Query: "b=2&a=1", Want: "a=1&b=2"},
+		{Query: "q=hello+world&a=%7E", Want: "a=~&q=hello%20world"},
+		{Query: "tag=z&tag=a&id=1", Want: "id=1&tag=z&tag=a"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/?"+tt.Query, nil)
+		if want, have := tt.Want, CanonicalQuery(req); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
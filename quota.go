@@ -0,0 +1,277 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QuotaPeriod is the period a quota applies to.
+type QuotaPeriod int
+
+const (
+	// QuotaDaily resets at midnight UTC. Exceeding it returns
+	// TooManyRequestsError with reason "DAILY_QUOTA_EXCEEDED".
+	QuotaDaily QuotaPeriod = iota
+	// QuotaMonthly resets on the first day of the month, UTC. Exceeding
+	// it returns PaymentRequiredError with reason "MONTHLY_QUOTA_EXCEEDED".
+	QuotaMonthly
+)
+
+// window returns the identifier of the period that t is in, and the
+// time the period ends.
+func (p QuotaPeriod) window(t time.Time) (string, time.Time) {
+	t = t.UTC()
+	if p == QuotaMonthly {
+		return t.Format("2006-01"), time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Format("2006-01-02"), time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// QuotaLimit is the maximum usage in a period.
+type QuotaLimit struct {
+	Period QuotaPeriod
+	Limit  int64
+}
+
+// QuotaStatus is the usage of a key in a period.
+type QuotaStatus struct {
+	Period    QuotaPeriod
+	Limit     int64
+	Used      int64
+	ResetsAt  time.Time
+	Remaining int64
+}
+
+// QuotaStore keeps the usage counters of QuotaManager.
+type QuotaStore interface {
+	// Add adds n, which may be negative, to the counter key and returns
+	// its new value. The counter can be removed after expires.
+	Add(ctx context.Context, key string, n int64, expires time.Time) (int64, error)
+}
+
+// QuotaConfig configures NewQuotaManager.
+type QuotaConfig struct {
+	// Store keeps the usage. It defaults to a MemoryQuotaStore, which is
+	// only suitable for a single instance.
+	Store QuotaStore
+	// Limits returns the limits of an API key, e.g. by its plan.
+	Limits func(ctx context.Context, key string) ([]QuotaLimit, error)
+	// Key returns the API key of a request for EnforceQuota. It defaults
+	// to the X-Api-Key header, or else the bearer token. Keys are hashed
+	// before they are used in the names of counters in the Store, so
+	// credentials don't end up in e.g. Redis.
+	Key func(r *http.Request) string
+}
+
+// QuotaManager tracks the usage of API keys per day and month, and
+// enforces limits on it. In contrast to rate limiting, which protects
+// the service from bursts, quotas meter the usage of a plan. Create one
+// with NewQuotaManager.
+type QuotaManager struct {
+	cfg QuotaConfig
+	now func() time.Time
+}
+
+// NewQuotaManager returns a QuotaManager for cfg.
+func NewQuotaManager(cfg QuotaConfig) *QuotaManager {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryQuotaStore()
+	}
+	if cfg.Key == nil {
+		cfg.Key = func(r *http.Request) string {
+			if key := r.Header.Get("X-Api-Key"); key != "" {
+				return key
+			}
+			token, _ := BearerToken(r)
+			return token
+		}
+	}
+	return &QuotaManager{cfg: cfg, now: time.Now}
+}
+
+// Charge adds n to the usage of key in all periods it has limits for.
+// If that exceeds a limit, the usage is left unchanged and an error is
+// returned: PaymentRequiredError for monthly limits, TooManyRequestsError
+// for daily limits. The returned status is the one of the limit with
+// the least remaining usage.
+func (m *QuotaManager) Charge(ctx context.Context, key string, n int64) (QuotaStatus, error) {
+	limits, err := m.limits(ctx, key)
+	if err != nil || len(limits) == 0 {
+		return QuotaStatus{}, err
+	}
+	now := m.now()
+	type charge struct {
+		counter  string
+		resetsAt time.Time
+	}
+	var (
+		charged  []charge
+		tightest QuotaStatus
+	)
+	rollback := func() {
+		for _, c := range charged {
+			m.cfg.Store.Add(ctx, c.counter, -n, c.resetsAt)
+		}
+	}
+	for i, limit := range limits {
+		window, resetsAt := limit.Period.window(now)
+		counter := quotaCounterName(key, window)
+		used, err := m.cfg.Store.Add(ctx, counter, n, resetsAt)
+		if err != nil {
+			rollback()
+			return QuotaStatus{}, err
+		}
+		charged = append(charged, charge{counter: counter, resetsAt: resetsAt})
+		st := QuotaStatus{
+			Period:    limit.Period,
+			Limit:     limit.Limit,
+			Used:      used,
+			ResetsAt:  resetsAt,
+			Remaining: limit.Limit - used,
+		}
+		if used > limit.Limit {
+			rollback()
+			st.Used -= n
+			st.Remaining += n
+			if st.Remaining < 0 {
+				st.Remaining = 0
+			}
+			return st, quotaExceededError(st, now)
+		}
+		if i == 0 || st.Remaining < tightest.Remaining {
+			tightest = st
+		}
+	}
+	return tightest, nil
+}
+
+// Usage returns the usage of key in all periods it has limits for.
+func (m *QuotaManager) Usage(ctx context.Context, key string) ([]QuotaStatus, error) {
+	limits, err := m.limits(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	var list []QuotaStatus
+	for _, limit := range limits {
+		window, resetsAt := limit.Period.window(now)
+		used, err := m.cfg.Store.Add(ctx, quotaCounterName(key, window), 0, resetsAt)
+		if err != nil {
+			return nil, err
+		}
+		remaining := limit.Limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		list = append(list, QuotaStatus{
+			Period:    limit.Period,
+			Limit:     limit.Limit,
+			Used:      used,
+			ResetsAt:  resetsAt,
+			Remaining: remaining,
+		})
+	}
+	return list, nil
+}
+
+func (m *QuotaManager) limits(ctx context.Context, key string) ([]QuotaLimit, error) {
+	if m.cfg.Limits == nil || key == "" {
+		return nil, nil
+	}
+	return m.cfg.Limits(ctx, key)
+}
+
+// quotaCounterName returns the name of the counter of key in window.
+// The key is hashed, as it is usually a credential.
+func quotaCounterName(key, window string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + ":" + window
+}
+
+// quotaExceededError returns the error for an exceeded quota.
+func quotaExceededError(st QuotaStatus, now time.Time) error {
+	if st.Period == QuotaMonthly {
+		return PaymentRequiredError{Reason: "MONTHLY_QUOTA_EXCEEDED"}
+	}
+	return TooManyRequestsError{RetryAfter: st.ResetsAt.Sub(now), Reason: "DAILY_QUOTA_EXCEEDED"}
+}
+
+// EnforceQuota returns a middleware that charges each request to the API
+// key of the request, and rejects it if a quota of the key is exceeded.
+// Responses have the X-Quota-Limit, X-Quota-Remaining, and X-Quota-Reset
+// (in seconds) headers of the tightest quota. Requests without API key
+// are passed through.
+//
+// Use it after the middleware that authenticates the API key, so
+// requests with invalid keys are rejected before they are charged, and
+// can't fill the Store with counters of arbitrary keys.
+func EnforceQuota(m *QuotaManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st, err := m.Charge(r.Context(), m.cfg.Key(r), 1)
+			if st.Limit > 0 {
+				h := w.Header()
+				h.Set("X-Quota-Limit", strconv.FormatInt(st.Limit, 10))
+				h.Set("X-Quota-Remaining", strconv.FormatInt(st.Remaining, 10))
+				h.Set("X-Quota-Reset", strconv.FormatInt(int64(st.ResetsAt.Sub(m.now())/time.Second), 10))
+			}
+			if err != nil {
+				writeJSONError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MemoryQuotaStore is a QuotaStore in memory. Expired counters are
+// removed periodically. It is safe for concurrent use.
+type MemoryQuotaStore struct {
+	mu       sync.Mutex
+	counters map[string]*quotaCounter
+	lastGC   time.Time
+}
+
+type quotaCounter struct {
+	n       int64
+	expires time.Time
+}
+
+// NewMemoryQuotaStore returns an empty store.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counters: make(map[string]*quotaCounter)}
+}
+
+// Add adds n to the counter key and returns its new value.
+func (s *MemoryQuotaStore) Add(ctx context.Context, key string, n int64, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastGC) > time.Hour {
+		for k, c := range s.counters {
+			if now.After(c.expires) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastGC = now
+	}
+	c, found := s.counters[key]
+	if !found {
+		c = &quotaCounter{}
+		s.counters[key] = c
+	}
+	c.n += n
+	if expires.After(c.expires) {
+		c.expires = expires
+	}
+	return c.n, nil
+}
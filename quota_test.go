@@ -0,0 +1,135 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnforceQuota(t *testing.T) {
+	m := NewQuotaManager(QuotaConfig{
+		Limits: func(ctx context.Context, key string) ([]QuotaLimit, error) {
+			if key != "free" {
+				return nil, nil
+			}
+			return []QuotaLimit{{Period: QuotaDaily, Limit: 2}, {Period: QuotaMonthly, Limit: 3}}, nil
+		},
+	})
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	h := EnforceQuota(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		Key       string
+		Day       int
+		Code      int
+		Remaining string
+		Reset     string
+		Reason    string
+	}{
+		{Key: "", Day: 16, Code: http.StatusNoContent},
+		{Key: "unlimited", Day: 16, Code: http.StatusNoContent},
+		{Key: "free", Day: 16, Code: http.StatusNoContent, Remaining: "1", Reset: "3600"},
+		{Key: "free", Day: 16, Code: http.StatusNoContent, Remaining: "0", Reset: "3600"},
+		{Key: "free", Day: 16, Code: http.StatusTooManyRequests, Remaining: "0", Reset: "3600", Reason: "DAILY_QUOTA_EXCEEDED"},
+		{Key: "free", Day: 17, Code: http.StatusNoContent, Remaining: "0", Reset: "1213200"},
+		{Key: "free", Day: 17, Code: http.StatusPaymentRequired, Remaining: "0", Reset: "1213200", Reason: "MONTHLY_QUOTA_EXCEEDED"},
+	}
+	for i, tt := range tests {
+		now = time.Date(2026, 10, tt.Day, 23, 0, 0, 0, time.UTC)
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.Key != "" {
+			req.Header.Set("X-Api-Key", tt.Key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Remaining, w.Header().Get("X-Quota-Remaining"); want != have {
+			t.Errorf("#%d: want X-Quota-Remaining %q, have %q", i, want, have)
+		}
+		if want, have := tt.Reset, w.Header().Get("X-Quota-Reset"); want != have {
+			t.Errorf("#%d: want X-Quota-Reset %q, have %q", i, want, have)
+		}
+		if tt.Code == http.StatusTooManyRequests {
+			if want, have := tt.Reset, w.Header().Get("Retry-After"); want != have {
+				t.Errorf("#%d: want Retry-After %q, have %q", i, want, have)
+			}
+		}
+		if tt.Reason != "" {
+			if want, have := tt.Reason, ErrorEnvelopeOf(t, w).Error.Reason; want != have {
+				t.Errorf("#%d: want reason %q, have %q", i, want, have)
+			}
+		}
+	}
+
+	usage, err := m.Usage(context.Background(), "free")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[0].Used != 1 || usage[1].Used != 3 {
+		t.Errorf("want daily usage 1 and monthly usage 3, have %+v", usage)
+	}
+}
+
+// recordingQuotaStore records the counters and expiry times passed to Add.
+type recordingQuotaStore struct {
+	*MemoryQuotaStore
+	keys    []string
+	expires []time.Time
+}
+
+func (s *recordingQuotaStore) Add(ctx context.Context, key string, n int64, expires time.Time) (int64, error) {
+	s.keys = append(s.keys, key)
+	s.expires = append(s.expires, expires)
+	return s.MemoryQuotaStore.Add(ctx, key, n, expires)
+}
+
+func TestQuotaManagerStore(t *testing.T) {
+	store := &recordingQuotaStore{MemoryQuotaStore: NewMemoryQuotaStore()}
+	m := NewQuotaManager(QuotaConfig{
+		Store: store,
+		Limits: func(ctx context.Context, key string) ([]QuotaLimit, error) {
+			return []QuotaLimit{{Period: QuotaMonthly, Limit: 10}, {Period: QuotaDaily, Limit: 1}}, nil
+		},
+	})
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if _, err := m.Charge(ctx, "sk_live_secret", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Charge(ctx, "sk_live_secret", 1); err == nil {
+		t.Fatal("want daily quota to be exceeded")
+	}
+	for i, key := range store.keys {
+		if strings.Contains(key, "sk_live_secret") {
+			t.Errorf("#%d: want hashed key, have %q", i, key)
+		}
+	}
+
+	// Rollbacks keep the expiry of the counters
+	expires := make(map[string]time.Time)
+	for i, key := range store.keys {
+		if first, found := expires[key]; found && !first.Equal(store.expires[i]) {
+			t.Errorf("#%d: want expiry %v of %q, have %v", i, first, key, store.expires[i])
+		}
+		expires[key] = store.expires[i]
+	}
+	usage, _ := m.Usage(ctx, "sk_live_secret")
+	if len(usage) != 2 || usage[0].Used != 1 || usage[1].Used != 1 {
+		t.Errorf("want monthly usage 1 and daily usage 1, have %+v", usage)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the values removed by a Redactor.
+const Redacted = "[REDACTED]"
+
+// Redactor removes secrets and personal data from requests and responses
+// before they are logged or dumped. The package uses the Redactor set by
+// SetRedactor in DumpRequestOut, DebugMode, CurlCommand, the error
+// messages of ReadJSON, and the logs of SlogMiddleware, so there is one
+// place to declare what must never appear in logs.
+type Redactor struct {
+	// Headers are patterns of header names whose values are redacted,
+	// matched case-insensitively with path.Match, e.g. "Authorization"
+	// or "X-*-Token".
+	Headers []string
+	// Fields are JSON fields whose values are redacted. A name like
+	// "password" matches the field at any depth, and a path like
+	// "$.card.number" only that field. Elements of arrays share the
+	// path of the array, e.g. "$.cards.number". Names also match query
+	// string and form parameters.
+	Fields []string
+	// Patterns are regular expressions whose matches are redacted in
+	// bodies and other text, e.g. credit card numbers.
+	Patterns []*regexp.Regexp
+
+	mu        sync.Mutex
+	fieldsKey string
+	jsonRE    *regexp.Regexp
+	formRE    *regexp.Regexp
+}
+
+// NewDefaultRedactor returns the Redactor used by default. It redacts
+// credentials, cookies, and debug tokens in headers, and common names of
+// secrets in JSON bodies and query strings.
+func NewDefaultRedactor() *Redactor {
+	return &Redactor{
+		Headers: []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie", "X-Api-Key", DefaultDebugHeader},
+		Fields:  []string{"password", "secret", "client_secret", "access_token", "refresh_token", "id_token", "api_key"},
+	}
+}
+
+// defaultRedactor is used if no Redactor is configured.
+var defaultRedactor = NewDefaultRedactor()
+
+// SetRedactor sets the Redactor used by the package. Passing nil
+// restores the default, see NewDefaultRedactor.
+//
+// Example:
+//
+//	r := httputil.NewDefaultRedactor()
+//	r.Fields = append(r.Fields, "ssn", "$.card.number")
+//	r.Patterns = append(r.Patterns, regexp.MustCompile(`\b\d{13,16}\b`))
+//	httputil.SetRedactor(r)
+func SetRedactor(r *Redactor) {
+	updateConfig(func(cfg *Config) { cfg.Redactor = r })
+}
+
+// redactorFor returns the Redactor in effect for r, which may be nil.
+func redactorFor(r *http.Request) *Redactor {
+	if rd := configFor(r).Redactor; rd != nil {
+		return rd
+	}
+	return defaultRedactor
+}
+
+// RedactHeader returns a copy of h with the values of matching
+// headers replaced by Redacted.
+func (rd *Redactor) RedactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for key := range h {
+		if rd.isRedactedHeader(key) {
+			h[key] = []string{Redacted}
+		}
+	}
+	return h
+}
+
+func (rd *Redactor) isRedactedHeader(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range rd.Headers {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL returns u in text form, without password and with the
+// values of matching query string parameters replaced by Redacted.
+func (rd *Redactor) RedactURL(u *url.URL) string {
+	v := *u
+	if v.User != nil {
+		if _, hasPassword := v.User.Password(); hasPassword {
+			v.User = url.UserPassword(v.User.Username(), "xxxxx")
+		}
+	}
+	if v.RawQuery != "" {
+		q := v.Query()
+		redacted := false
+		for name := range q {
+			if rd.isRedactedField("", name) {
+				q.Set(name, Redacted)
+				redacted = true
+			}
+		}
+		if redacted {
+			v.RawQuery = strings.ReplaceAll(q.Encode(), url.QueryEscape(Redacted), Redacted)
+		}
+	}
+	return v.String()
+}
+
+// RedactJSON returns data with the values of matching fields replaced
+// by Redacted, and matches of Patterns redacted. If data is not valid
+// JSON, e.g. because it is truncated, it is redacted with RedactText.
+func (rd *Redactor) RedactJSON(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(rd.RedactText(string(data)))
+	}
+	v = rd.redactValue("$", v)
+	out, err := json.Marshal(v)
+	if err != nil {
+		return []byte(rd.RedactText(string(data)))
+	}
+	return []byte(rd.redactPatterns(string(out)))
+}
+
+func (rd *Redactor) redactValue(p string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if rd.isRedactedField(p+"."+key, key) {
+				v[key] = Redacted
+			} else {
+				v[key] = rd.redactValue(p+"."+key, child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = rd.redactValue(p, child)
+		}
+	}
+	return v
+}
+
+func (rd *Redactor) isRedactedField(p, name string) bool {
+	for _, field := range rd.Fields {
+		if strings.HasPrefix(field, "$") {
+			if field == p {
+				return true
+			}
+		} else if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactText redacts matches of Patterns in s, as well as the values of
+// matching JSON fields and URL-encoded form parameters, e.g. in a log
+// message, a truncated body, or a request dump. As s is not parsed,
+// paths in Fields match by their last field name, and only JSON values
+// that are strings, numbers, booleans, or null are redacted.
+func (rd *Redactor) RedactText(s string) string {
+	jsonRE, formRE := rd.fieldRegexps()
+	if jsonRE != nil {
+		s = jsonRE.ReplaceAllString(s, `${1}"`+Redacted+`"`)
+		s = formRE.ReplaceAllString(s, `${1}`+Redacted)
+	}
+	return rd.redactPatterns(s)
+}
+
+// fieldRegexps returns the regular expressions matching the JSON values
+// and form parameters of Fields. They are compiled again if Fields has
+// changed since the last call.
+func (rd *Redactor) fieldRegexps() (jsonRE, formRE *regexp.Regexp) {
+	key := strings.Join(rd.Fields, "\x00")
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if key == rd.fieldsKey && (rd.jsonRE != nil || key == "") {
+		return rd.jsonRE, rd.formRE
+	}
+	var names []string
+	for _, field := range rd.Fields {
+		if i := strings.LastIndexByte(field, '.'); i >= 0 {
+			field = field[i+1:]
+		}
+		if field != "" {
+			names = append(names, regexp.QuoteMeta(field))
+		}
+	}
+	rd.fieldsKey, rd.jsonRE, rd.formRE = key, nil, nil
+	if len(names) > 0 {
+		alt := strings.Join(names, "|")
+		rd.jsonRE = regexp.MustCompile(`(?i)("(?:` + alt + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"|-?[0-9][0-9.eE+-]*|true|false|null)`)
+		rd.formRE = regexp.MustCompile(`(?i)((?:^|[?&\s])(?:` + alt + `)=)[^&\s]*`)
+	}
+	return rd.jsonRE, rd.formRE
+}
+
+func (rd *Redactor) redactPatterns(s string) string {
+	for _, re := range rd.Patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers nonces that have been used by clients.
+type NonceStore interface {
+	// UseNonce records nonce until expires. It returns false if the
+	// nonce has already been used and has not yet expired.
+	UseNonce(ctx context.Context, nonce string, expires time.Time) (bool, error)
+}
+
+// NonceReleaser is implemented by NonceStores that can forget a nonce
+// before it expires. PreventDuplicateSubmits uses it to accept the retry
+// of a submit that has failed.
+type NonceReleaser interface {
+	// ReleaseNonce removes nonce, so it can be used again.
+	ReleaseNonce(ctx context.Context, nonce string) error
+}
+
+// MemoryNonceStore is an in-memory NonceStore. Its zero value is
+// ready to use. It is safe for concurrent use.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	sweep  time.Time
+}
+
+// UseNonce records nonce until expires.
+func (s *MemoryNonceStore) UseNonce(ctx context.Context, nonce string, expires time.Time) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nonces == nil {
+		s.nonces = make(map[string]time.Time)
+	}
+	if now.After(s.sweep) {
+		for n, exp := range s.nonces {
+			if now.After(exp) {
+				delete(s.nonces, n)
+			}
+		}
+		s.sweep = now.Add(time.Minute)
+	}
+	if exp, found := s.nonces[nonce]; found && !now.After(exp) {
+		return false, nil
+	}
+	s.nonces[nonce] = expires
+	return true, nil
+}
+
+// ReleaseNonce removes nonce, so it can be used again.
+func (s *MemoryNonceStore) ReleaseNonce(ctx context.Context, nonce string) error {
+	s.mu.Lock()
+	delete(s.nonces, nonce)
+	s.mu.Unlock()
+	return nil
+}
+
+// ReplayConfig configures the ReplayProtection middleware.
+type ReplayConfig struct {
+	// Store records the nonces. It is required.
+	Store NonceStore
+	// MaxSkew is the maximum difference between the timestamp of the
+	// request and the time of the server. It defaults to 5 minutes.
+	MaxSkew time.Duration
+	// NonceHeader is the name of the header with the client nonce.
+	// It defaults to X-Nonce.
+	NonceHeader string
+	// TimestampHeader is the name of the header with the time of the
+	// request in seconds since the Unix epoch. It defaults to X-Timestamp.
+	TimestampHeader string
+}
+
+// ReplayProtection returns a middleware that rejects replayed requests.
+// Every request must have a nonce and a timestamp header. Requests with
+// a missing, invalid, or stale timestamp, or without a nonce, are rejected
+// with UnauthorizedError. Requests with a nonce that has already been
+// used are rejected with ConflictError.
+//
+// ReplayProtection is typically used together with request signing,
+// e.g. VerifyRFC9421, with both headers being covered by the signature.
+func ReplayProtection(cfg ReplayConfig) func(http.Handler) http.Handler {
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+	if cfg.NonceHeader == "" {
+		cfg.NonceHeader = "X-Nonce"
+	}
+	if cfg.TimestampHeader == "" {
+		cfg.TimestampHeader = "X-Timestamp"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce := r.Header.Get(cfg.NonceHeader)
+			if nonce == "" {
+				writeJSONError(w, r, UnauthorizedError{})
+				return
+			}
+			secs, err := strconv.ParseInt(r.Header.Get(cfg.TimestampHeader), 10, 64)
+			if err != nil {
+				writeJSONError(w, r, UnauthorizedError{})
+				return
+			}
+			ts := time.Unix(secs, 0)
+			if skew := time.Since(ts); skew > cfg.MaxSkew || skew < -cfg.MaxSkew {
+				writeJSONError(w, r, UnauthorizedError{})
+				return
+			}
+			// The nonce must be kept as long as the timestamp is acceptable
+			ok, err := cfg.Store.UseNonce(r.Context(), nonce, ts.Add(cfg.MaxSkew))
+			if err != nil {
+				writeJSONError(w, r, ServerError("Unable to check nonce"))
+				return
+			}
+			if !ok {
+				writeJSONError(w, r, ConflictError{})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplayProtection(t *testing.T) {
+	h := ReplayProtection(ReplayConfig{Store: &MemoryNonceStore{}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	now := time.Now().Unix()
+	tests := []struct {
+		Nonce     string
+		Timestamp string
+		Want      int
+	}{
+		{Nonce: "", Timestamp: strconv.FormatInt(now, 10), Want: http.StatusUnauthorized},
+		{Nonce: "a", Timestamp: "", Want: http.StatusUnauthorized},
+		{Nonce: "a", Timestamp: "yesterday", Want: http.StatusUnauthorized},
+		{Nonce: "a", Timestamp: strconv.FormatInt(now-3600, 10), Want: http.StatusUnauthorized},
+		{Nonce: "a", Timestamp: strconv.FormatInt(now+3600, 10), Want: http.StatusUnauthorized},
+		{Nonce: "a", Timestamp: strconv.FormatInt(now, 10), Want: http.StatusNoContent},
+		{Nonce: "a", Timestamp: strconv.FormatInt(now, 10), Want: http.StatusConflict},
+		{Nonce: "b", Timestamp: strconv.FormatInt(now-60, 10), Want: http.StatusNoContent},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "http://example.com/payments", nil)
+		if tt.Nonce != "" {
+			req.Header.Set("X-Nonce", tt.Nonce)
+		}
+		if tt.Timestamp != "" {
+			req.Header.Set("X-Timestamp", tt.Timestamp)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Want, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
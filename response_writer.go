@@ -0,0 +1,82 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+)
+
+// responseWriter is embedded by the http.ResponseWriter wrappers of the
+// middlewares of this package. It forwards http.Flusher, and Unwrap
+// returns the underlying http.ResponseWriter for http.ResponseController.
+// Wrappers are passed to the next handler with wrapResponseWriter, which
+// also forwards http.Hijacker and http.Pusher.
+type responseWriter struct {
+	http.ResponseWriter
+}
+
+// Flush implements http.Flusher.
+func (rw responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (rw responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// responseWriterWrapper is implemented by the wrappers embedding
+// responseWriter.
+type responseWriterWrapper interface {
+	http.ResponseWriter
+	http.Flusher
+	Unwrap() http.ResponseWriter
+}
+
+// wrapResponseWriter returns ww, extended by http.Hijacker and
+// http.Pusher if the http.ResponseWriter it wraps implements them, so
+// e.g. WebSockets keep working behind the middlewares. io.ReaderFrom is
+// not forwarded, as it would bypass the Write method of ww.
+func wrapResponseWriter(ww responseWriterWrapper) http.ResponseWriter {
+	w := ww.Unwrap()
+	h, hijacker := w.(http.Hijacker)
+	p, pusher := w.(http.Pusher)
+	switch {
+	case hijacker && pusher:
+		return hijackPushResponseWriter{ww, h, p}
+	case hijacker:
+		return hijackResponseWriter{ww, h}
+	case pusher:
+		return pushResponseWriter{ww, p}
+	}
+	return ww
+}
+
+type hijackResponseWriter struct {
+	responseWriterWrapper
+	http.Hijacker
+}
+
+// Unwrap returns the wrapper, so the middlewares can find it.
+func (w hijackResponseWriter) Unwrap() http.ResponseWriter { return w.responseWriterWrapper }
+
+type pushResponseWriter struct {
+	responseWriterWrapper
+	http.Pusher
+}
+
+// Unwrap returns the wrapper, so the middlewares can find it.
+func (w pushResponseWriter) Unwrap() http.ResponseWriter { return w.responseWriterWrapper }
+
+type hijackPushResponseWriter struct {
+	responseWriterWrapper
+	http.Hijacker
+	http.Pusher
+}
+
+// Unwrap returns the wrapper, so the middlewares can find it.
+func (w hijackPushResponseWriter) Unwrap() http.ResponseWriter { return w.responseWriterWrapper }
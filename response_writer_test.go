@@ -0,0 +1,84 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWrapResponseWriterHijack(t *testing.T) {
+	middlewares := []func(http.Handler) http.Handler{
+		SlogMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		ServerTimingMiddleware(),
+		SlowRequests(SlowRequestConfig{Threshold: time.Hour}),
+		Unmask(func(r *http.Request) bool { return true }),
+		RewriteKeys(KeyCaseConfig{Default: CamelCase}),
+	}
+	for i, mw := range middlewares {
+		srv := httptest.NewServer(mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(http.Flusher); !ok {
+				t.Errorf("#%d: want http.Flusher", i)
+			}
+			// e.g. gorilla/websocket asserts http.Hijacker
+			hj, ok := w.(http.Hijacker)
+			if !ok {
+				t.Errorf("#%d: want http.Hijacker", i)
+				return
+			}
+			conn, buf, err := hj.Hijack()
+			if err != nil {
+				t.Errorf("#%d: want to hijack the connection, have %v", i, err)
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			buf.Flush()
+		})))
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := http.StatusSwitchingProtocols, res.StatusCode; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestWrapResponseWriterUnwrap(t *testing.T) {
+	// httptest.ResponseRecorder doesn't implement http.Hijacker
+	type hijackRecorder struct {
+		*httptest.ResponseRecorder
+		http.Hijacker
+	}
+	w := hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	uw := &unmaskedResponseWriter{responseWriter{w}}
+	ww := wrapResponseWriter(uw)
+	if _, ok := ww.(http.Hijacker); !ok {
+		t.Fatal("want http.Hijacker")
+	}
+	if _, ok := ww.(http.Pusher); ok {
+		t.Fatal("want no http.Pusher")
+	}
+	if !isUnmaskedResponse(ww) {
+		t.Fatal("want the wrapper to be found")
+	}
+	if _, ok := wrapResponseWriter(&unmaskedResponseWriter{responseWriter{httptest.NewRecorder()}}).(http.Hijacker); ok {
+		t.Fatal("want no http.Hijacker")
+	}
+}
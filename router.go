@@ -0,0 +1,72 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// NotFoundHandler returns a handler that responds with NotFoundError,
+// e.g. for unknown routes. Use it as the NotFoundHandler of a router
+// so that its responses have the same format as all other errors.
+//
+// The error is written as JSON, or as a simple HTML page if the client
+// prefers text/html, e.g. a browser.
+//
+// Example:
+//
+//	r := mux.NewRouter()
+//	r.NotFoundHandler = httputil.NotFoundHandler()
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeNegotiatedError(w, r, NotFoundError{})
+	})
+}
+
+// MethodNotAllowedHandler returns a handler that responds with
+// InvalidMethodError and 405 Method Not Allowed, and sets the Allow
+// header to the allowed methods, if any. Like NotFoundHandler, it
+// writes the error as JSON or HTML.
+//
+// Example:
+//
+//	r := mux.NewRouter()
+//	r.MethodNotAllowedHandler = httputil.MethodNotAllowedHandler("GET", "POST")
+func MethodNotAllowedHandler(allowed ...string) http.Handler {
+	var err error = InvalidMethodError{}
+	if len(allowed) > 0 {
+		err = HTTPError{
+			Code:    http.StatusMethodNotAllowed,
+			Message: InvalidMethodError{}.Error(),
+			Header:  http.Header{"Allow": {strings.Join(allowed, ", ")}},
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeNegotiatedError(w, r, err)
+	})
+}
+
+// writeNegotiatedError writes err as a simple HTML page if the client
+// prefers text/html over JSON, and as usual otherwise.
+func writeNegotiatedError(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Add("Vary", "Accept")
+	if NegotiateContentType(r, "application/json", "text/html") != "text/html" {
+		writeJSONError(w, r, err)
+		return
+	}
+	body := NewErrorBody(err)
+	notifyErrorWritten(r, body.Code, err)
+	logErrorWritten(w, r, body.Code, body.Message)
+	writeErrorHeaders(w, err)
+	title := html.EscapeString(fmt.Sprintf("%d %s", body.Code, http.StatusText(body.Code)))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(body.Code)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body><h1>%s</h1><p>%s</p></body></html>\n",
+		title, title, html.EscapeString(body.Message))
+}
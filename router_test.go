@@ -0,0 +1,76 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNotFoundAndMethodNotAllowedHandler(t *testing.T) {
+	router := mux.NewRouter()
+	router.NotFoundHandler = NotFoundHandler()
+	router.MethodNotAllowedHandler = MethodNotAllowedHandler("GET", "PUT")
+	router.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET", "PUT")
+
+	tests := []struct {
+		Method string
+		Path   string
+		Accept string
+		Code   int
+		Allow  string
+		HTML   bool
+	}{
+		{Method: "GET", Path: "/unknown", Code: http.StatusNotFound},
+		{Method: "GET", Path: "/unknown", Accept: "application/json", Code: http.StatusNotFound},
+		{Method: "GET", Path: "/unknown", Accept: "text/html,application/xhtml+xml,*/*;q=0.8", Code: http.StatusNotFound, HTML: true},
+		{Method: "DELETE", Path: "/orders/1", Code: http.StatusMethodNotAllowed, Allow: "GET, PUT"},
+		{Method: "DELETE", Path: "/orders/1", Accept: "text/html", Code: http.StatusMethodNotAllowed, Allow: "GET, PUT", HTML: true},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.Method, tt.Path, nil)
+		if tt.Accept != "" {
+			r.Header.Set("Accept", tt.Accept)
+		}
+		router.ServeHTTP(w, r)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Allow, w.Header().Get("Allow"); want != have {
+			t.Errorf("#%d: want Allow %q, have %q", i, want, have)
+		}
+		if tt.HTML {
+			if want, have := "text/html; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+				t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+			}
+			if !strings.Contains(w.Body.String(), "<h1>") {
+				t.Errorf("#%d: want HTML page, have %q", i, w.Body.String())
+			}
+			continue
+		}
+		if want, have := tt.Code, ErrorEnvelopeOf(t, w).Error.Code; want != have {
+			t.Errorf("#%d: want error code %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestMethodNotAllowedHandlerWithoutMethods(t *testing.T) {
+	w := httptest.NewRecorder()
+	MethodNotAllowedHandler().ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if want, have := http.StatusMethodNotAllowed, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	if have := w.Header().Get("Allow"); have != "" {
+		t.Errorf("want no Allow header, have %q", have)
+	}
+	if want, have := "Invalid HTTP method", ErrorEnvelopeOf(t, w).Error.Message; want != have {
+		t.Errorf("want message %q, have %q", want, have)
+	}
+}
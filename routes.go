@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteDoc documents a route, see RouteRegistry.
+type RouteDoc struct {
+	// Method is the HTTP method, e.g. "GET".
+	Method string `json:"method"`
+	// Path is the path template, e.g. "/orders/{id}".
+	Path string `json:"path"`
+	// Name is a short, unique name, e.g. "getOrder".
+	Name string `json:"name,omitempty"`
+	// Description explains what the route does.
+	Description string `json:"description,omitempty"`
+	// Params are the parameters of the route.
+	Params []ParamDoc `json:"params,omitempty"`
+	// Auth is the kind of authentication required, e.g. "bearer",
+	// or empty for none.
+	Auth string `json:"auth,omitempty"`
+	// Scopes are the scopes or permissions required.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// ParamDoc documents a parameter of a route.
+type ParamDoc struct {
+	// Name of the parameter, e.g. "id".
+	Name string `json:"name"`
+	// In is where the parameter is passed: "path", "query", "header",
+	// or "body".
+	In string `json:"in"`
+	// Type is e.g. "string", "int", or "bool".
+	Type string `json:"type,omitempty"`
+	// Required is true if the parameter must be passed.
+	Required bool `json:"required,omitempty"`
+	// Description explains the parameter.
+	Description string `json:"description,omitempty"`
+}
+
+// RouteRegistry collects the documentation of routes and serves it as
+// JSON, e.g. on a /routes endpoint of an internal service or an admin
+// endpoint. It is a lightweight alternative to a full OpenAPI document.
+//
+// Example:
+//
+//	routes := httputil.NewRouteRegistry()
+//	routes.Handle(router, httputil.RouteDoc{
+//	  Method:      "GET",
+//	  Path:        "/orders/{id}",
+//	  Name:        "getOrder",
+//	  Description: "Returns an order.",
+//	  Params:      []httputil.ParamDoc{{Name: "id", In: "path", Type: "int", Required: true}},
+//	  Auth:        "bearer",
+//	}, getOrder)
+//	router.Handle("/routes", routes).Methods("GET")
+type RouteRegistry struct {
+	mu     sync.RWMutex
+	routes map[string]RouteDoc
+}
+
+// NewRouteRegistry returns a new RouteRegistry.
+func NewRouteRegistry() *RouteRegistry {
+	return &RouteRegistry{routes: make(map[string]RouteDoc)}
+}
+
+// Register adds the documentation of a route. A route registered
+// before with the same method and path is replaced.
+func (reg *RouteRegistry) Register(doc RouteDoc) {
+	reg.mu.Lock()
+	reg.routes[doc.Method+" "+doc.Path] = doc
+	reg.mu.Unlock()
+}
+
+// Handle registers h with the method and path of doc at the
+// gorilla/mux router, and registers doc.
+func (reg *RouteRegistry) Handle(router *mux.Router, doc RouteDoc, h http.Handler) *mux.Route {
+	reg.Register(doc)
+	return router.Handle(doc.Path, h).Methods(doc.Method)
+}
+
+// Routes returns the documentation of all routes, ordered by path
+// and method.
+func (reg *RouteRegistry) Routes() []RouteDoc {
+	reg.mu.RLock()
+	routes := make([]RouteDoc, 0, len(reg.routes))
+	for _, doc := range reg.routes {
+		routes = append(routes, doc)
+	}
+	reg.mu.RUnlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// ServeHTTP writes the documentation of all routes as JSON.
+func (reg *RouteRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, struct {
+		Routes []RouteDoc `json:"routes"`
+	}{reg.Routes()})
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRouteRegistry(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	router := mux.NewRouter()
+	routes := NewRouteRegistry()
+	routes.Handle(router, RouteDoc{
+		Method:      "GET",
+		Path:        "/orders/{id}",
+		Name:        "getOrder",
+		Description: "Returns an order.",
+		Params:      []ParamDoc{{Name: "id", In: "path", Type: "int", Required: true}},
+		Auth:        "bearer",
+		Scopes:      []string{"orders.read"},
+	}, ok)
+	routes.Handle(router, RouteDoc{Method: "DELETE", Path: "/orders/{id}", Name: "deleteOrder"}, ok)
+	routes.Register(RouteDoc{Method: "GET", Path: "/health", Name: "old"})
+	routes.Register(RouteDoc{Method: "GET", Path: "/health", Name: "health"})
+	router.Handle("/routes", routes).Methods("GET")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/orders/1", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/routes", nil))
+	want := `{"routes":[
+		{"method":"GET","path":"/health","name":"health"},
+		{"method":"DELETE","path":"/orders/{id}","name":"deleteOrder"},
+		{"method":"GET","path":"/orders/{id}","name":"getOrder","description":"Returns an order.",
+		 "params":[{"name":"id","in":"path","type":"int","required":true}],"auth":"bearer","scopes":["orders.read"]}
+	]}`
+	if !EqualJSON([]byte(want), w.Body.Bytes()) {
+		t.Errorf("want %s, have %s", want, w.Body.String())
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxSchemaBodySize is the maximum size of bodies sampled by
+	// SchemaRecorder. Larger bodies are skipped.
+	maxSchemaBodySize = 1 << 20
+	// maxSchemaRoutes is the maximum number of routes recorded.
+	maxSchemaRoutes = 1000
+	// maxSchemaFields is the maximum number of fields recorded per
+	// route and direction, e.g. if objects are used as maps.
+	maxSchemaFields = 1000
+	// maxSchemaDepth is the maximum nesting depth recorded.
+	maxSchemaDepth = 32
+)
+
+// RouteSchema is the shape of the JSON requests and responses of a route.
+// It maps paths like "$.items[].id" to JSON types like "number" or, if
+// several have been seen, "null|string".
+type RouteSchema struct {
+	Request  map[string]string `json:"request,omitempty"`
+	Response map[string]string `json:"response,omitempty"`
+}
+
+// SchemaRecorder samples the shapes of JSON requests and responses per
+// route, i.e. field names and types but no values. Use RecordSchemas to
+// sample requests, serve the recorder on an admin endpoint to inspect the
+// schemas, and DiffSchemas to compare them against a committed baseline
+// to detect accidental changes of the API contract.
+//
+// Example:
+//
+//	rec := httputil.NewSchemaRecorder(0.01)
+//	router.Use(httputil.RecordSchemas(rec))
+//	admin.Handle("/schemas", rec)
+type SchemaRecorder struct {
+	sampleRate float64
+
+	mu     sync.Mutex
+	routes map[string]*routeSchema
+}
+
+type routeSchema struct {
+	request, response map[string]map[string]bool
+}
+
+// NewSchemaRecorder creates a SchemaRecorder that samples the given
+// fraction of requests, between 0 and 1.
+func NewSchemaRecorder(sampleRate float64) *SchemaRecorder {
+	return &SchemaRecorder{
+		sampleRate: sampleRate,
+		routes:     make(map[string]*routeSchema),
+	}
+}
+
+// RecordSchemas returns a middleware that samples requests and responses
+// into rec. Routes are identified by method and route template (see
+// RouteTemplate), so use the middleware with Router.Use. Only successful
+// responses are recorded.
+func RecordSchemas(rec *SchemaRecorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rec.sampleRate <= 0 || rand.Float64() >= rec.sampleRate {
+				next.ServeHTTP(w, r)
+				return
+			}
+			route := RouteTemplate(r)
+			if route == "" {
+				route = r.URL.Path
+			}
+			route = r.Method + " " + route
+
+			if isJSONContentType(r.Header.Get("Content-Type")) && r.Body != nil {
+				body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxSchemaBodySize+1))
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				if len(body) <= maxSchemaBodySize {
+					rec.record(route, false, body)
+				}
+			}
+
+			sw := &schemaResponseWriter{statusResponseWriter: statusResponseWriter{responseWriter: responseWriter{w}}}
+			next.ServeHTTP(wrapResponseWriter(sw), r)
+			if code := sw.status(); code >= 200 && code < 300 && !sw.overflow &&
+				isJSONContentType(w.Header().Get("Content-Type")) {
+				rec.record(route, true, sw.body.Bytes())
+			}
+		})
+	}
+}
+
+func isJSONContentType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// record adds the shape of the JSON document data to route.
+func (rec *SchemaRecorder) record(route string, response bool, data []byte) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rs, found := rec.routes[route]
+	if !found {
+		if len(rec.routes) >= maxSchemaRoutes {
+			return
+		}
+		rs = &routeSchema{
+			request:  make(map[string]map[string]bool),
+			response: make(map[string]map[string]bool),
+		}
+		rec.routes[route] = rs
+	}
+	fields := rs.request
+	if response {
+		fields = rs.response
+	}
+	addSchemaFields(fields, "$", v, 0)
+}
+
+// addSchemaFields adds the type of v at path, and of its children.
+func addSchemaFields(fields map[string]map[string]bool, path string, v interface{}, depth int) {
+	if depth > maxSchemaDepth {
+		return
+	}
+	types, found := fields[path]
+	if !found {
+		if len(fields) >= maxSchemaFields {
+			return
+		}
+		types = make(map[string]bool)
+		fields[path] = types
+	}
+	switch x := v.(type) {
+	case nil:
+		types["null"] = true
+	case bool:
+		types["boolean"] = true
+	case float64:
+		types["number"] = true
+	case string:
+		types["string"] = true
+	case []interface{}:
+		types["array"] = true
+		for _, elem := range x {
+			addSchemaFields(fields, path+"[]", elem, depth+1)
+		}
+	case map[string]interface{}:
+		types["object"] = true
+		for key, elem := range x {
+			addSchemaFields(fields, path+"."+key, elem, depth+1)
+		}
+	}
+}
+
+// Schemas returns the schemas recorded so far, by route.
+func (rec *SchemaRecorder) Schemas() map[string]RouteSchema {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	schemas := make(map[string]RouteSchema, len(rec.routes))
+	for route, rs := range rec.routes {
+		schemas[route] = RouteSchema{
+			Request:  schemaTypes(rs.request),
+			Response: schemaTypes(rs.response),
+		}
+	}
+	return schemas
+}
+
+func schemaTypes(fields map[string]map[string]bool) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(fields))
+	for path, types := range fields {
+		list := make([]string, 0, len(types))
+		for typ := range types {
+			list = append(list, typ)
+		}
+		sort.Strings(list)
+		m[path] = strings.Join(list, "|")
+	}
+	return m
+}
+
+// ServeHTTP writes the recorded schemas as JSON, e.g. for an admin
+// endpoint. Save the output as a baseline for DiffSchemas.
+func (rec *SchemaRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, rec.Schemas())
+}
+
+// schemaResponseWriter records the beginning of the response body.
+type schemaResponseWriter struct {
+	statusResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (sw *schemaResponseWriter) Write(p []byte) (int, error) {
+	if !sw.overflow {
+		if sw.body.Len()+len(p) > maxSchemaBodySize {
+			sw.overflow = true
+			sw.body.Reset()
+		} else {
+			sw.body.Write(p)
+		}
+	}
+	return sw.statusResponseWriter.Write(p)
+}
+
+// SchemaChange is a difference between two schemas, see DiffSchemas.
+type SchemaChange struct {
+	// Route is e.g. "GET /users/{id}".
+	Route string `json:"route"`
+	// Field is the path of the field, prefixed with "request" or
+	// "response", e.g. "response $.name". It is empty for new routes.
+	Field string `json:"field,omitempty"`
+	// Kind is "added", "removed", or "changed".
+	Kind string `json:"kind"`
+	// Old and New are the types before and after the change.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// String returns the change in text form.
+func (c SchemaChange) String() string {
+	switch {
+	case c.Field == "":
+		return fmt.Sprintf("%s: route %s", c.Route, c.Kind)
+	case c.Kind == "changed":
+		return fmt.Sprintf("%s: %s changed from %s to %s", c.Route, c.Field, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s: %s %s", c.Route, c.Field, c.Kind)
+}
+
+// DiffSchemas compares the current schemas, e.g. from SchemaRecorder,
+// with a baseline, e.g. a committed file with the output of
+// SchemaRecorder.ServeHTTP. Routes that have not been recorded in current
+// are skipped, as they might not have been sampled yet. The changes are
+// sorted by route and field.
+func DiffSchemas(baseline, current map[string]RouteSchema) []SchemaChange {
+	var changes []SchemaChange
+	for route, cur := range current {
+		base, found := baseline[route]
+		if !found {
+			changes = append(changes, SchemaChange{Route: route, Kind: "added"})
+			continue
+		}
+		changes = appendSchemaChanges(changes, route, "request ", base.Request, cur.Request)
+		changes = appendSchemaChanges(changes, route, "response ", base.Response, cur.Response)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Route != changes[j].Route {
+			return changes[i].Route < changes[j].Route
+		}
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+func appendSchemaChanges(changes []SchemaChange, route, prefix string, base, cur map[string]string) []SchemaChange {
+	for path, typ := range cur {
+		old, found := base[path]
+		switch {
+		case !found:
+			changes = append(changes, SchemaChange{Route: route, Field: prefix + path, Kind: "added", New: typ})
+		case old != typ:
+			changes = append(changes, SchemaChange{Route: route, Field: prefix + path, Kind: "changed", Old: old, New: typ})
+		}
+	}
+	for path, typ := range base {
+		if _, found := cur[path]; !found {
+			changes = append(changes, SchemaChange{Route: route, Field: prefix + path, Kind: "removed", Old: typ})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRecordSchemas(t *testing.T) {
+	rec := NewSchemaRecorder(1)
+	router := mux.NewRouter()
+	router.Use(RecordSchemas(rec))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !json.Valid(body) {
+			t.Errorf("want request body to be restored, have %q", body)
+		}
+		if MustParamsString(r, "id") == "404" {
+			WriteJSONError(w, NotFoundError{})
+			return
+		}
+		WriteJSON(w, map[string]interface{}{
+			"id":    MustParamsString(r, "id"),
+			"tags":  []string{"admin"},
+			"email": nil,
+		})
+	}).Methods("PUT")
+
+	for _, tt := range []struct{ ID, Body string }{
+		{ID: "1", Body: `{"name":"Oliver","age":42}`},
+		{ID: "2", Body: `{"name":null,"address":{"city":"Munich"}}`},
+		{ID: "404", Body: `{"secret":"s3cr3t"}`},
+	} {
+		req := httptest.NewRequest("PUT", "/users/"+tt.ID, strings.NewReader(tt.Body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	want := map[string]RouteSchema{
+		"PUT /users/{id}": {
+			Request: map[string]string{
+				"$":              "object",
+				"$.name":         "null|string",
+				"$.age":          "number",
+				"$.address":      "object",
+				"$.address.city": "string",
+				"$.secret":       "string",
+			},
+			Response: map[string]string{
+				"$":        "object",
+				"$.id":     "string",
+				"$.tags":   "array",
+				"$.tags[]": "string",
+				"$.email":  "null",
+			},
+		},
+	}
+	if have := rec.Schemas(); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+
+	// Admin endpoint
+	w := httptest.NewRecorder()
+	rec.ServeHTTP(w, httptest.NewRequest("GET", "/admin/schemas", nil))
+	if have := DecodeAs[map[string]RouteSchema](t, w); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
+
+func TestRecordSchemasSampling(t *testing.T) {
+	rec := NewSchemaRecorder(0)
+	h := RecordSchemas(rec)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, map[string]int{"n": 1})
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if have := rec.Schemas(); len(have) != 0 {
+		t.Errorf("want no schemas, have %+v", have)
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	baseline := map[string]RouteSchema{
+		"GET /users/{id}": {Response: map[string]string{"$": "object", "$.id": "number", "$.name": "string"}},
+		"GET /orders":     {Response: map[string]string{"$": "array"}},
+	}
+	current := map[string]RouteSchema{
+		"GET /users/{id}": {Response: map[string]string{"$": "object", "$.id": "string", "$.email": "string"}},
+		"GET /health":     {Response: map[string]string{"$": "object"}},
+	}
+	have := DiffSchemas(baseline, current)
+	want := []SchemaChange{
+		{Route: "GET /health", Kind: "added"},
+		{Route: "GET /users/{id}", Field: "response $.email", Kind: "added", New: "string"},
+		{Route: "GET /users/{id}", Field: "response $.id", Kind: "changed", Old: "number", New: "string"},
+		{Route: "GET /users/{id}", Field: "response $.name", Kind: "removed", Old: "string"},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+	if want, have := "GET /users/{id}: response $.id changed from number to string", have[2].String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// maxSearchQueryLength is the maximum length of a search query in bytes.
+const maxSearchQueryLength = 1024
+
+// SearchQuery is a parsed free-text search query as returned by
+// ParseSearchQuery.
+type SearchQuery struct {
+	// Terms are the unquoted words of the query, e.g. "red" and "shoes"
+	// in `red shoes`.
+	Terms []string
+	// Phrases are the quoted parts of the query without the quotes,
+	// e.g. "running shoes" in `"running shoes"`.
+	Phrases []string
+	// Filters are the field:value pairs of the query, in order.
+	Filters []SearchFilter
+}
+
+// SearchFilter is a field:value pair of a SearchQuery,
+// e.g. `color:red` or `brand:"Acme Corp"`.
+type SearchFilter struct {
+	Field string
+	Value string
+}
+
+// IsEmpty returns true if the query has neither terms, phrases, nor filters.
+func (q *SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && len(q.Filters) == 0
+}
+
+// FilterValues returns the values of all filters on field, in order.
+func (q *SearchQuery) FilterValues(field string) []string {
+	var values []string
+	for _, f := range q.Filters {
+		if f.Field == field {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// ParseSearchQuery parses the query string parameter key of r as a
+// free-text search query like
+//
+//	red shoes "running shoes" brand:acme size:"42 EU"
+//
+// into terms, quoted phrases, and field:value filters. Only the fields
+// listed in fields are accepted as filters. A missing parameter results
+// in an empty SearchQuery. If the query is malformed or uses a field that
+// is not allowed, it returns an InvalidParameterHintError.
+func ParseSearchQuery(r *http.Request, key string, fields ...string) (*SearchQuery, error) {
+	v, err := lookupQuery(r, key)
+	if err != nil {
+		return nil, err
+	}
+	return parseSearchQuery(key, v, fields)
+}
+
+// MustParseSearchQuery is like ParseSearchQuery, but panics on errors.
+func MustParseSearchQuery(r *http.Request, key string, fields ...string) *SearchQuery {
+	q, err := ParseSearchQuery(r, key, fields...)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func parseSearchQuery(key, s string, fields []string) (*SearchQuery, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return InvalidParameterHintError{Parameter: key, Hint: fmt.Sprintf(format, args...)}
+	}
+	if len(s) > maxSearchQueryLength {
+		return nil, invalid("Search query must not be longer than %d characters", maxSearchQueryLength)
+	}
+	allowed := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		allowed[field] = true
+	}
+
+	q := &SearchQuery{}
+	for s = strings.TrimLeftFunc(s, unicode.IsSpace); s != ""; s = strings.TrimLeftFunc(s, unicode.IsSpace) {
+		if s[0] == '"' {
+			phrase, rest, ok := cutQuoted(s)
+			if !ok {
+				return nil, invalid("Unterminated quote in search query")
+			}
+			if phrase = strings.TrimSpace(phrase); phrase != "" {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			s = rest
+			continue
+		}
+
+		// A token ends at whitespace or at the start of a quoted value
+		end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(s)
+		}
+		token := s[:end]
+		s = s[end:]
+
+		i := strings.IndexByte(token, ':')
+		if i < 0 {
+			q.Terms = append(q.Terms, token)
+			continue
+		}
+		field, value := token[:i], token[i+1:]
+		if field == "" {
+			return nil, invalid("Missing field name in %q", token)
+		}
+		if !allowed[field] {
+			if len(fields) == 0 {
+				return nil, invalid("Search query does not support filters")
+			}
+			return nil, invalid("Unknown search field %q, expected one of: %s", field, strings.Join(fields, ", "))
+		}
+		if value == "" && strings.HasPrefix(s, `"`) {
+			var ok bool
+			value, s, ok = cutQuoted(s)
+			if !ok {
+				return nil, invalid("Unterminated quote in search query")
+			}
+		}
+		if value == "" {
+			return nil, invalid("Missing value for search field %q", field)
+		}
+		q.Filters = append(q.Filters, SearchFilter{Field: field, Value: value})
+	}
+	return q, nil
+}
+
+// cutQuoted expects s to start with a double quote and returns the text
+// up to the closing quote and the remainder after it.
+func cutQuoted(s string) (quoted, rest string, ok bool) {
+	end := strings.IndexByte(s[1:], '"')
+	if end < 0 {
+		return "", s, false
+	}
+	return s[1 : end+1], s[end+2:], true
+}
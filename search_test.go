@@ -0,0 +1,120 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		Query string
+		Want  *SearchQuery
+		Err   bool
+	}{
+		{
+			Query: "",
+			Want:  &SearchQuery{},
+		},
+		{
+			Query: "  red   shoes ",
+			Want:  &SearchQuery{Terms: []string{"red", "shoes"}},
+		},
+		{
+			Query: `red "running shoes" brand:acme size:"42 EU"`,
+			Want: &SearchQuery{
+				Terms:   []string{"red"},
+				Phrases: []string{"running shoes"},
+				Filters: []SearchFilter{
+					{Field: "brand", Value: "acme"},
+					{Field: "size", Value: "42 EU"},
+				},
+			},
+		},
+		{
+			Query: `brand:acme brand:globex""`,
+			Want: &SearchQuery{
+				Filters: []SearchFilter{
+					{Field: "brand", Value: "acme"},
+					{Field: "brand", Value: "globex"},
+				},
+			},
+		},
+		{
+			Query: `brand:url:with:colons`,
+			Want: &SearchQuery{
+				Filters: []SearchFilter{{Field: "brand", Value: "url:with:colons"}},
+			},
+		},
+		{Query: `"running shoes`, Err: true},
+		{Query: `size:"42`, Err: true},
+		{Query: `color:red`, Err: true},
+		{Query: `:red`, Err: true},
+		{Query: `brand:`, Err: true},
+		{Query: strings.Repeat("a", maxSearchQueryLength+1), Err: true},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/?"+url.Values{"q": {tt.Query}}.Encode(), nil)
+		have, err := ParseSearchQuery(req, "q", "brand", "size")
+		if tt.Err {
+			var perr InvalidParameterError
+			if !errors.As(err, &perr) {
+				t.Errorf("#%d: want InvalidParameterError, have %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(tt.Want, have) {
+			t.Errorf("#%d: want %+v, have %+v", i, tt.Want, have)
+		}
+	}
+}
+
+func TestParseSearchQueryWithoutFields(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?q=brand:acme", nil)
+	_, err := ParseSearchQuery(req, "q")
+	herr, ok := err.(InvalidParameterHintError)
+	if !ok {
+		t.Fatalf("want InvalidParameterHintError, have %v", err)
+	}
+	if want, have := "Search query does not support filters", herr.Hint; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestSearchQueryFilterValues(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?q=brand:acme+red+brand:globex", nil)
+	q := MustParseSearchQuery(req, "q", "brand")
+	if want, have := []string{"acme", "globex"}, q.FilterValues("brand"); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if q.IsEmpty() {
+		t.Error("want query to be non-empty")
+	}
+}
+
+func TestMustParseSearchQuery(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		MustParseSearchQuery(r, "q", "brand")
+	}
+	req := httptest.NewRequest("GET", "/?q=color:red", nil)
+	w := httptest.NewRecorder()
+	h(w, req)
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want := `Unknown search field \"color\", expected one of: brand`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("want body to contain %s, have %s", want, w.Body.String())
+	}
+}
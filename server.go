@@ -0,0 +1,435 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ServerConfig is the configuration of an HTTP server run by RunServer.
+// Load it with ServerConfigFromEnv, override it with RegisterFlags, and
+// check it with Validate, so all services share one configuration shape.
+type ServerConfig struct {
+	// Addr is the address to listen on. It defaults to ":8080".
+	// See Listen for unix domain sockets and systemd socket activation.
+	Addr string
+	// SocketMode are the permissions of a unix domain socket.
+	// It defaults to DefaultSocketMode.
+	SocketMode os.FileMode
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout, and IdleTimeout are
+	// passed to http.Server.
+	//
+	// WriteTimeout also ends Server-Sent Events, long polls, and large
+	// downloads after 30 seconds by default. Handlers of such routes lift
+	// it with http.NewResponseController(w).SetWriteDeadline(time.Time{}),
+	// or the timeout is disabled for all routes by setting it to 0.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long RunServer waits for active requests
+	// when shutting down.
+	ShutdownTimeout time.Duration
+	// TLSCertFile and TLSKeyFile enable TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// MaxHeaderBytes is passed to http.Server.
+	MaxHeaderBytes int
+	// MaxBodyBytes limits the size of request bodies if positive.
+	MaxBodyBytes int64
+	// TrustedProxies are the networks of trusted reverse proxies,
+	// see ProxyConfig.
+	TrustedProxies []string
+	// CORS enables CORS if it has allowed origins.
+	CORS CORSConfig
+	// H2C serves HTTP/2 without TLS (h2c) in addition to HTTP/1.1,
+	// e.g. behind a service mesh or an internal load balancer.
+	H2C bool
+	// Monitor tracks the server if set. It is not loaded from the
+	// environment.
+	Monitor *ServerMonitor
+	// Readiness is marked as ready by RunServer once the server accepts
+	// connections and the Warmup tasks are finished, and as not ready
+	// when it shuts down. It is not loaded from the environment.
+	Readiness *Readiness
+	// Warmup are tasks run by RunServer before the server is marked as
+	// ready, see Readiness.Warmup. Without Readiness, they are run before
+	// the server accepts connections. Errors of the tasks are ignored,
+	// so tasks should log them. It is not loaded from the environment.
+	Warmup []func(context.Context) error
+	// Streams is shut down by RunServer together with the server, so
+	// long-lived streams are told to terminate instead of blocking the
+	// shutdown until ShutdownTimeout. It is not loaded from the
+	// environment.
+	Streams *StreamRegistry
+}
+
+// DefaultServerConfig returns the defaults of ServerConfig.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:              ":8080",
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+	}
+}
+
+// InvalidConfigError is returned when a ServerConfig is invalid.
+// It lists all problems as details.
+type InvalidConfigError struct {
+	Details []string
+}
+
+// Error returns the error in text form.
+func (e InvalidConfigError) Error() string {
+	return "Invalid configuration: " + strings.Join(e.Details, "; ")
+}
+
+// ErrorDetails returns the problems of the configuration.
+func (e InvalidConfigError) ErrorDetails() []string { return e.Details }
+
+// ServerConfigFromEnv returns the defaults of DefaultServerConfig,
+// overridden by environment variables with the given prefix, e.g.
+// "API_ADDR" and "API_READ_TIMEOUT" for prefix "API_". The variables are
+// ADDR, SOCKET_MODE (octal), READ_TIMEOUT, READ_HEADER_TIMEOUT, WRITE_TIMEOUT, IDLE_TIMEOUT,
+// SHUTDOWN_TIMEOUT, TLS_CERT_FILE, TLS_KEY_FILE, MAX_HEADER_BYTES,
+// MAX_BODY_BYTES, TRUSTED_PROXIES, CORS_ALLOWED_ORIGINS,
+// CORS_ALLOW_CREDENTIALS, and H2C. Lists are separated by commas.
+//
+// It returns an InvalidConfigError if variables can't be parsed or the
+// configuration is invalid.
+func ServerConfigFromEnv(prefix string) (ServerConfig, error) {
+	cfg := DefaultServerConfig()
+	var details []string
+	lookup := func(name string) (string, bool) {
+		v, found := os.LookupEnv(prefix + name)
+		return strings.TrimSpace(v), found && strings.TrimSpace(v) != ""
+	}
+	str := func(name string, dst *string) {
+		if v, found := lookup(name); found {
+			*dst = v
+		}
+	}
+	dur := func(name string, dst *time.Duration) {
+		if v, found := lookup(name); found {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				details = append(details, fmt.Sprintf("%s%s: invalid duration %q", prefix, name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+	num := func(name string, dst *int64) {
+		if v, found := lookup(name); found {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				details = append(details, fmt.Sprintf("%s%s: invalid number %q", prefix, name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, found := lookup(name); found {
+			*dst = splitList(v)
+		}
+	}
+
+	str("ADDR", &cfg.Addr)
+	if v, found := lookup("SOCKET_MODE"); found {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			details = append(details, fmt.Sprintf("%sSOCKET_MODE: invalid octal mode %q", prefix, v))
+		}
+		cfg.SocketMode = os.FileMode(mode)
+	}
+	dur("READ_TIMEOUT", &cfg.ReadTimeout)
+	dur("READ_HEADER_TIMEOUT", &cfg.ReadHeaderTimeout)
+	dur("WRITE_TIMEOUT", &cfg.WriteTimeout)
+	dur("IDLE_TIMEOUT", &cfg.IdleTimeout)
+	dur("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	str("TLS_CERT_FILE", &cfg.TLSCertFile)
+	str("TLS_KEY_FILE", &cfg.TLSKeyFile)
+	maxHeaderBytes := int64(cfg.MaxHeaderBytes)
+	num("MAX_HEADER_BYTES", &maxHeaderBytes)
+	cfg.MaxHeaderBytes = int(maxHeaderBytes)
+	num("MAX_BODY_BYTES", &cfg.MaxBodyBytes)
+	list("TRUSTED_PROXIES", &cfg.TrustedProxies)
+	list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	if v, found := lookup("CORS_ALLOW_CREDENTIALS"); found {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			details = append(details, fmt.Sprintf("%sCORS_ALLOW_CREDENTIALS: invalid boolean %q", prefix, v))
+		}
+		cfg.CORS.AllowCredentials = b
+	}
+	if v, found := lookup("H2C"); found {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			details = append(details, fmt.Sprintf("%sH2C: invalid boolean %q", prefix, v))
+		}
+		cfg.H2C = b
+	}
+
+	if len(details) > 0 {
+		return cfg, InvalidConfigError{Details: details}
+	}
+	return cfg, cfg.Validate()
+}
+
+// RegisterFlags registers command line flags for cfg in fs, with the
+// current values of cfg as defaults, e.g. to override environment
+// variables. The flags are named like the environment variables of
+// ServerConfigFromEnv, e.g. -addr and -read-timeout. Call Validate
+// after parsing the flags.
+func (cfg *ServerConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "Address to listen on, e.g. :8080, unix:/path/to.sock, or systemd")
+	fs.Func("socket-mode", "Octal permissions of a unix domain socket", func(s string) error {
+		mode, err := strconv.ParseUint(s, 8, 32)
+		cfg.SocketMode = os.FileMode(mode)
+		return err
+	})
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "Maximum duration for reading requests")
+	fs.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", cfg.ReadHeaderTimeout, "Maximum duration for reading request headers")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "Maximum duration for writing responses")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "Maximum duration of idle keep-alive connections")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "Maximum duration to wait for active requests on shutdown")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert-file", cfg.TLSCertFile, "TLS certificate file")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key-file", cfg.TLSKeyFile, "TLS key file")
+	fs.IntVar(&cfg.MaxHeaderBytes, "max-header-bytes", cfg.MaxHeaderBytes, "Maximum size of request headers")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", cfg.MaxBodyBytes, "Maximum size of request bodies")
+	fs.Func("trusted-proxies", "Comma-separated networks of trusted proxies", func(s string) error {
+		cfg.TrustedProxies = splitList(s)
+		return nil
+	})
+	fs.Func("cors-allowed-origins", "Comma-separated origins allowed to send cross-origin requests", func(s string) error {
+		cfg.CORS.AllowedOrigins = splitList(s)
+		return nil
+	})
+	fs.BoolVar(&cfg.CORS.AllowCredentials, "cors-allow-credentials", cfg.CORS.AllowCredentials, "Allow credentials in cross-origin requests")
+	fs.BoolVar(&cfg.H2C, "h2c", cfg.H2C, "Serve HTTP/2 without TLS")
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate returns an InvalidConfigError if cfg is invalid.
+func (cfg ServerConfig) Validate() error {
+	var details []string
+	if cfg.Addr == "" {
+		details = append(details, "Address must not be empty")
+	}
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"Read timeout", cfg.ReadTimeout},
+		{"Read header timeout", cfg.ReadHeaderTimeout},
+		{"Write timeout", cfg.WriteTimeout},
+		{"Idle timeout", cfg.IdleTimeout},
+		{"Shutdown timeout", cfg.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d < 0 {
+			details = append(details, fmt.Sprintf("%s must not be negative", t.name))
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		details = append(details, "TLS requires both a certificate and a key file")
+	}
+	if cfg.H2C && cfg.TLSCertFile != "" {
+		details = append(details, "H2C can't be used with TLS, which negotiates HTTP/2 itself")
+	}
+	if cfg.SocketMode&^os.ModePerm != 0 {
+		details = append(details, fmt.Sprintf("Socket mode %o must only have permission bits", cfg.SocketMode))
+	}
+	if cfg.MaxHeaderBytes < 0 || cfg.MaxBodyBytes < 0 {
+		details = append(details, "Limits must not be negative")
+	}
+	for _, cidr := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			details = append(details, fmt.Sprintf("Trusted proxy %q is not a network like 10.0.0.0/8", cidr))
+		}
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			details = append(details, fmt.Sprintf("CORS origin %q is not an origin like https://example.com", origin))
+		}
+	}
+	if len(details) > 0 {
+		return InvalidConfigError{Details: details}
+	}
+	return nil
+}
+
+// ProxyConfig returns the ProxyConfig for the trusted proxies of cfg.
+func (cfg ServerConfig) ProxyConfig() ProxyConfig {
+	return ProxyConfig{TrustedCIDRs: cfg.TrustedProxies, TrustXFF: true, TrustForwarded: true}
+}
+
+// Handler returns h wrapped with the middlewares configured by cfg:
+// body size limit, trusted proxies, and CORS.
+func (cfg ServerConfig) Handler(h http.Handler) http.Handler {
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		h = CORS(cfg.CORS)(h)
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		h = WithProxyConfig(cfg.ProxyConfig())(h)
+	}
+	if cfg.MaxBodyBytes > 0 {
+		next := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+	return h
+}
+
+// NewServer returns an http.Server for h configured by cfg.
+// With H2C, clients may use HTTP/2 with prior knowledge or upgrade
+// from HTTP/1.1, and HTTP/1.1 clients are served as usual.
+func (cfg ServerConfig) NewServer(h http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           cfg.Handler(h),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if cfg.H2C {
+		h2s := &http2.Server{IdleTimeout: cfg.IdleTimeout}
+		// Track h2c connections in srv for graceful shutdown
+		http2.ConfigureServer(srv, h2s)
+		srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	}
+	if cfg.Monitor != nil {
+		cfg.Monitor.Attach(srv)
+	}
+	return srv
+}
+
+// RunServer serves h as configured by cfg until ctx is done, and then
+// shuts down gracefully, waiting up to cfg.ShutdownTimeout for active
+// requests and the streams of cfg.Streams. It returns nil after a
+// graceful shutdown. See ServerConfig.Readiness and ServerConfig.Warmup
+// for warming up the server before it receives traffic.
+//
+// Example:
+//
+//	cfg, err := httputil.ServerConfigFromEnv("API_")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+//	defer stop()
+//	cfg.Readiness = httputil.NewReadiness()
+//	cfg.Warmup = []func(context.Context) error{loadCache, pingDatabase}
+//	mux.Handle("/readyz", cfg.Readiness)
+//	if err := httputil.RunServer(ctx, cfg, mux); err != nil {
+//	  log.Fatal(err)
+//	}
+func RunServer(ctx context.Context, cfg ServerConfig, h http.Handler) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	ln, err := cfg.Listen()
+	if err != nil {
+		return err
+	}
+	return serve(ctx, cfg, cfg.NewServer(h), ln)
+}
+
+// serve runs srv on ln until ctx is done.
+func serve(ctx context.Context, cfg ServerConfig, srv *http.Server, ln net.Listener) error {
+	if cfg.Readiness == nil && len(cfg.Warmup) > 0 {
+		Warmup(ctx, cfg.Warmup...)
+	}
+	if cfg.Monitor != nil {
+		cfg.Monitor.SetPhase(PhaseServing)
+		defer cfg.Monitor.SetPhase(PhaseStopped)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			errc <- srv.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+	warmed := make(chan struct{})
+	if cfg.Readiness != nil {
+		// The server responds with "not ready" while warming up
+		go func() {
+			defer close(warmed)
+			cfg.Readiness.Warmup(ctx, cfg.Warmup...)
+		}()
+	} else {
+		close(warmed)
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	if cfg.Readiness != nil {
+		// Warmup returns when ctx is done, and marks the server as ready
+		<-warmed
+		cfg.Readiness.SetReady(false)
+	}
+	shutdownCtx := context.Background()
+	if cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, cfg.ShutdownTimeout)
+		defer cancel()
+	}
+	// Streams are told to terminate while the server waits for them
+	streamErrc := make(chan error, 1)
+	if cfg.Streams != nil {
+		go func() { streamErrc <- cfg.Streams.Shutdown(shutdownCtx) }()
+	} else {
+		streamErrc <- nil
+	}
+	err := srv.Shutdown(shutdownCtx)
+	if streamErr := <-streamErrc; err == nil {
+		err = streamErr
+	}
+	if serveErr := <-errc; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+		err = serveErr
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Phases of a server reported by ServerMonitor.
+const (
+	PhaseStarting = "starting"
+	PhaseServing  = "serving"
+	PhaseDraining = "draining"
+	PhaseStopped  = "stopped"
+)
+
+// ServerMonitor tracks the connections, in-flight requests, and
+// shutdown phase of an http.Server, and reports them as JSON. This
+// helps to see whether a server drains as expected during deploys
+// and incidents.
+//
+// Set ServerConfig.Monitor to use it with RunServer, or use Attach
+// for other servers. Do not expose it to the public.
+//
+// Example:
+//
+//	monitor := httputil.NewServerMonitor()
+//	monitor.Streams = streams
+//	cfg.Monitor = monitor
+//	adminMux.Handle("/debug/server", monitor)
+type ServerMonitor struct {
+	// Streams are reported as active streams by kind, if set.
+	Streams *StreamRegistry
+
+	started  time.Time
+	phase    atomic.Value // string
+	inFlight atomic.Int64
+	hijacked atomic.Int64
+
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+// ServerStats are the statistics reported by ServerMonitor.
+type ServerStats struct {
+	Phase       string         `json:"phase"`
+	Uptime      string         `json:"uptime"`
+	Connections map[string]int `json:"connections"`
+	Hijacked    int64          `json:"hijacked"`
+	InFlight    int64          `json:"in_flight"`
+	Streams     map[string]int `json:"streams,omitempty"`
+}
+
+// NewServerMonitor returns a new ServerMonitor in PhaseStarting.
+func NewServerMonitor() *ServerMonitor {
+	m := &ServerMonitor{
+		started: time.Now(),
+		conns:   make(map[net.Conn]http.ConnState),
+	}
+	m.phase.Store(PhaseStarting)
+	return m
+}
+
+// Attach makes m track srv: its connections, its requests, and the
+// begin of its shutdown. Call it before srv starts serving.
+func (m *ServerMonitor) Attach(srv *http.Server) {
+	connState := srv.ConnState
+	srv.ConnState = func(c net.Conn, state http.ConnState) {
+		m.ConnState(c, state)
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+	srv.Handler = m.Middleware(srv.Handler)
+	srv.RegisterOnShutdown(func() {
+		m.SetPhase(PhaseDraining)
+	})
+}
+
+// ConnState tracks the state of connections. It can be used as
+// http.Server.ConnState.
+func (m *ServerMonitor) ConnState(c net.Conn, state http.ConnState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch state {
+	case http.StateClosed:
+		delete(m.conns, c)
+	case http.StateHijacked:
+		delete(m.conns, c)
+		m.hijacked.Add(1)
+	default:
+		m.conns[c] = state
+	}
+}
+
+// Middleware counts the requests in flight.
+func (m *ServerMonitor) Middleware(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetPhase sets the phase of the server, e.g. PhaseServing.
+func (m *ServerMonitor) SetPhase(phase string) {
+	m.phase.Store(phase)
+}
+
+// Stats returns the current statistics.
+func (m *ServerMonitor) Stats() ServerStats {
+	stats := ServerStats{
+		Phase:       m.phase.Load().(string),
+		Uptime:      time.Since(m.started).Round(time.Second).String(),
+		Connections: map[string]int{"new": 0, "active": 0, "idle": 0},
+		Hijacked:    m.hijacked.Load(),
+		InFlight:    m.inFlight.Load(),
+	}
+	m.mu.Lock()
+	for _, state := range m.conns {
+		stats.Connections[state.String()]++
+	}
+	m.mu.Unlock()
+	if m.Streams != nil {
+		stats.Streams = m.Streams.Len()
+	}
+	return stats
+}
+
+// ServeHTTP writes the statistics as JSON.
+func (m *ServerMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, m.Stats())
+}
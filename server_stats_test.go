@@ -0,0 +1,119 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestServerMonitor(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor := NewServerMonitor()
+	monitor.Streams = NewStreamRegistry()
+	cfg := DefaultServerConfig()
+	cfg.Monitor = monitor
+	entered, release := make(chan struct{}), make(chan struct{})
+	srv := cfg.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := monitor.Streams.Open("sse")
+		if err != nil {
+			WriteJSONError(w, err)
+			return
+		}
+		defer stream.Close()
+		close(entered)
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	if want, have := PhaseStarting, monitor.Stats().Phase; want != have {
+		t.Errorf("want phase %q, have %q", want, have)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- serve(ctx, cfg, srv, ln)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	resc := make(chan error)
+	go func() {
+		res, err := client.Get("http://" + ln.Addr().String())
+		if err == nil {
+			res.Body.Close()
+		}
+		resc <- err
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var stats ServerStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := PhaseServing, stats.Phase; want != have {
+		t.Errorf("want phase %q, have %q", want, have)
+	}
+	if want, have := 1, stats.Connections["active"]; want != have {
+		t.Errorf("want %d active connection, have %d", want, have)
+	}
+	if want, have := int64(1), stats.InFlight; want != have {
+		t.Errorf("want %d request in flight, have %d", want, have)
+	}
+	if want, have := 1, stats.Streams["sse"]; want != have {
+		t.Errorf("want %d stream, have %d", want, have)
+	}
+
+	cancel()
+	waitFor(t, func() bool { return monitor.Stats().Phase == PhaseDraining })
+	close(release)
+	if err := <-resc; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("want graceful shutdown, have %v", err)
+	}
+	stats = monitor.Stats()
+	if want, have := PhaseStopped, stats.Phase; want != have {
+		t.Errorf("want phase %q, have %q", want, have)
+	}
+	if want, have := int64(0), stats.InFlight; want != have {
+		t.Errorf("want %d requests in flight, have %d", want, have)
+	}
+	if want, have := 0, len(stats.Streams); want != have {
+		t.Errorf("want no streams, have %v", stats.Streams)
+	}
+}
+
+func TestServerMonitorConnState(t *testing.T) {
+	monitor := NewServerMonitor()
+	c1, c2 := &net.TCPConn{}, &net.UnixConn{}
+	monitor.ConnState(c1, http.StateNew)
+	monitor.ConnState(c2, http.StateNew)
+	monitor.ConnState(c1, http.StateActive)
+	monitor.ConnState(c1, http.StateIdle)
+	monitor.ConnState(c2, http.StateActive)
+	monitor.ConnState(c2, http.StateHijacked)
+
+	stats := monitor.Stats()
+	if want, have := map[string]int{"new": 0, "active": 0, "idle": 1}, stats.Connections; !reflect.DeepEqual(want, have) {
+		t.Errorf("want connections %v, have %v", want, have)
+	}
+	if want, have := int64(1), stats.Hijacked; want != have {
+		t.Errorf("want %d hijacked connection, have %d", want, have)
+	}
+	monitor.ConnState(c1, http.StateClosed)
+	if want, have := 0, monitor.Stats().Connections["idle"]; want != have {
+		t.Errorf("want %d idle connections, have %d", want, have)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestServerConfigFromEnv(t *testing.T) {
+	t.Setenv("API_ADDR", ":9000")
+	t.Setenv("API_READ_TIMEOUT", "5s")
+	t.Setenv("API_MAX_BODY_BYTES", "1024")
+	t.Setenv("API_TRUSTED_PROXIES", "10.0.0.0/8, 192.168.0.0/16")
+	t.Setenv("API_CORS_ALLOWED_ORIGINS", "https://app.example.com")
+
+	cfg, err := ServerConfigFromEnv("API_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := ":9000", cfg.Addr; want != have {
+		t.Errorf("want addr %q, have %q", want, have)
+	}
+	if want, have := 5*time.Second, cfg.ReadTimeout; want != have {
+		t.Errorf("want read timeout %v, have %v", want, have)
+	}
+	if want, have := DefaultServerConfig().WriteTimeout, cfg.WriteTimeout; want != have {
+		t.Errorf("want default write timeout %v, have %v", want, have)
+	}
+	if want, have := int64(1024), cfg.MaxBodyBytes; want != have {
+		t.Errorf("want max body bytes %d, have %d", want, have)
+	}
+	if want, have := "10.0.0.0/8|192.168.0.0/16", strings.Join(cfg.TrustedProxies, "|"); want != have {
+		t.Errorf("want trusted proxies %q, have %q", want, have)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-addr", ":9001", "-cors-allowed-origins", "*"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := ":9001 5s *", fmt.Sprintf("%s %v %s", cfg.Addr, cfg.ReadTimeout, strings.Join(cfg.CORS.AllowedOrigins, ",")); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	t.Setenv("API_READ_TIMEOUT", "soon")
+	t.Setenv("API_TRUSTED_PROXIES", "10.0.0.1")
+	t.Setenv("API_CORS_ALLOWED_ORIGINS", "app.example.com")
+	_, err = ServerConfigFromEnv("API_")
+	var cerr InvalidConfigError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("want InvalidConfigError, have %v", err)
+	}
+	if want, have := `API_READ_TIMEOUT: invalid duration "soon"`, strings.Join(cerr.ErrorDetails(), "|"); want != have {
+		t.Errorf("want details %q, have %q", want, have)
+	}
+
+	t.Setenv("API_READ_TIMEOUT", "")
+	_, err = ServerConfigFromEnv("API_")
+	if !errors.As(err, &cerr) || len(cerr.Details) != 2 {
+		t.Fatalf("want 2 validation errors, have %v", err)
+	}
+}
+
+func TestServerConfigHandler(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.MaxBodyBytes = 4
+	h := cfg.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			WriteJSONError(w, RequestEntityTooLargeError{})
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("too large")))
+	if want, have := http.StatusRequestEntityTooLarge, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
+
+func TestRunServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := DefaultServerConfig()
+	cfg.Addr = addr
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- RunServer(ctx, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+	}()
+
+	var res *http.Response
+	for i := 0; i < 50; i++ {
+		if res, err = http.Get("http://" + addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if want, have := "ok", string(body); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("want graceful shutdown, have %v", err)
+	}
+
+	cfg.Addr = ""
+	if err := RunServer(context.Background(), cfg, http.NotFoundHandler()); err == nil {
+		t.Error("want invalid config")
+	}
+}
+
+func TestRunServerH2C(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultServerConfig()
+	cfg.H2C = true
+	srv := cfg.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- serve(ctx, cfg, srv, ln)
+	}()
+	url := "http://" + ln.Addr().String()
+
+	h2 := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	for _, tt := range []struct {
+		Client *http.Client
+		Want   string
+	}{
+		{h2, "HTTP/2.0"},
+		{&http.Client{Transport: &http.Transport{}}, "HTTP/1.1"},
+	} {
+		res, err := tt.Client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if want, have := tt.Want, string(body); want != have {
+			t.Errorf("want %s, have %s", want, have)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("want graceful shutdown, have %v", err)
+	}
+
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	if err := cfg.Validate(); err == nil {
+		t.Error("want h2c with TLS to be invalid")
+	}
+}
+
+func TestRunServerStreamsAndReadiness(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultServerConfig()
+	cfg.Readiness = NewReadiness()
+	cfg.Streams = NewStreamRegistry()
+	warm := make(chan struct{})
+	cfg.Warmup = []func(context.Context) error{func(ctx context.Context) error {
+		<-warm
+		return nil
+	}}
+	opened := make(chan struct{})
+	srv := cfg.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/readyz" {
+			cfg.Readiness.ServeHTTP(w, r)
+			return
+		}
+		stream, err := cfg.Streams.Open("sse")
+		if err != nil {
+			ServeJSONError(w, r, err)
+			return
+		}
+		defer stream.Close()
+		close(opened)
+		<-stream.Done()
+		w.Write([]byte("shutdown"))
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- serve(ctx, cfg, srv, ln)
+	}()
+	url := "http://" + ln.Addr().String()
+	tr := &http.Transport{}
+	client := &http.Client{Transport: tr}
+
+	readyz := func() int {
+		res, err := client.Get(url + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if want, have := http.StatusServiceUnavailable, readyz(); want != have {
+		t.Errorf("want status %d while warming up, have %d", want, have)
+	}
+	close(warm)
+	for i := 0; i < 50 && !cfg.Readiness.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want, have := http.StatusOK, readyz(); want != have {
+		t.Errorf("want status %d after warmup, have %d", want, have)
+	}
+
+	bodyc := make(chan string)
+	go func() {
+		res, err := client.Get(url + "/events")
+		if err != nil {
+			bodyc <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		bodyc <- string(body)
+	}()
+	<-opened
+	tr.CloseIdleConnections()
+
+	// The stream must not block the shutdown until ShutdownTimeout
+	start := time.Now()
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("want graceful shutdown, have %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("want shutdown without waiting for the timeout, took %v", d)
+	}
+	if want, have := "shutdown", <-bodyc; want != have {
+		t.Errorf("want body %q, have %q", want, have)
+	}
+	if cfg.Readiness.Ready() {
+		t.Error("want not ready after shutdown")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type timingContextKey struct{}
+
+// TimingMetric is a single measurement of a ServerTiming collector.
+type TimingMetric struct {
+	Name     string
+	Duration time.Duration
+}
+
+// ServerTiming collects the durations of the phases of a request, e.g.
+// database queries or calls to other services. They are sent to the
+// client in the Server-Timing header, so browser developer tools can
+// display them. It is safe for concurrent use.
+type ServerTiming struct {
+	mu      sync.Mutex
+	metrics []TimingMetric
+}
+
+// Timing returns the ServerTiming collector of the request with ctx, as
+// added by ServerTimingMiddleware or DebugMode. If there is none, a new
+// collector is returned whose measurements are discarded, so handlers
+// don't need to check.
+//
+// Example:
+//
+//	tm := httputil.Timing(r.Context())
+//	defer tm.Start("db").Stop()
+func Timing(ctx context.Context) *ServerTiming {
+	if tm, ok := ctx.Value(timingContextKey{}).(*ServerTiming); ok {
+		return tm
+	}
+	return &ServerTiming{}
+}
+
+// Add records a measurement. Names should be short tokens like "db".
+func (tm *ServerTiming) Add(name string, d time.Duration) {
+	tm.mu.Lock()
+	tm.metrics = append(tm.metrics, TimingMetric{Name: name, Duration: d})
+	tm.mu.Unlock()
+}
+
+// Start starts measuring the phase name. Call Stop on the result
+// to record the measurement.
+func (tm *ServerTiming) Start(name string) *TimingSpan {
+	return &TimingSpan{tm: tm, name: name, start: time.Now()}
+}
+
+// Metrics returns the measurements recorded so far.
+func (tm *ServerTiming) Metrics() []TimingMetric {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return append([]TimingMetric(nil), tm.metrics...)
+}
+
+// String returns the measurements as a Server-Timing header value,
+// e.g. "db;dur=12.5, cache;dur=0.3".
+func (tm *ServerTiming) String() string {
+	metrics := tm.Metrics()
+	parts := make([]string, len(metrics))
+	for i, m := range metrics {
+		parts[i] = timingName(m.Name) + ";dur=" + timingDuration(m.Duration)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MarshalJSON serializes the measurements as an object of names and
+// durations in milliseconds, e.g. for the meta data of a response.
+// Durations of measurements with the same name are added.
+//
+// Example:
+//
+//	httputil.WriteJSON(w, map[string]interface{}{
+//	  "data": users,
+//	  "meta": map[string]interface{}{"timing": httputil.Timing(r.Context())},
+//	})
+func (tm *ServerTiming) MarshalJSON() ([]byte, error) {
+	m := make(map[string]float64)
+	for _, metric := range tm.Metrics() {
+		m[metric.Name] += timingMillis(metric.Duration)
+	}
+	return json.Marshal(m)
+}
+
+// TimingSpan is a running measurement, see ServerTiming.Start.
+type TimingSpan struct {
+	tm    *ServerTiming
+	name  string
+	start time.Time
+	once  sync.Once
+}
+
+// Stop records the time since the span has been started. Calling Stop
+// more than once has no effect.
+func (s *TimingSpan) Stop() {
+	s.once.Do(func() {
+		s.tm.Add(s.name, time.Since(s.start))
+	})
+}
+
+// ServerTimingMiddleware returns a middleware that adds a ServerTiming
+// collector to the context of each request, see Timing. Measurements
+// are sent in the Server-Timing header, along with the total time
+// spent in the handler. Measurements recorded after the handler has
+// started writing the response are not sent.
+func ServerTimingMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, found := r.Context().Value(timingContextKey{}).(*ServerTiming); found {
+				// Already collected, e.g. by DebugMode
+				next.ServeHTTP(w, r)
+				return
+			}
+			tm := &ServerTiming{}
+			tw := &timingResponseWriter{responseWriter: responseWriter{w}, tm: tm, start: time.Now(), total: true}
+			ctx := context.WithValue(r.Context(), timingContextKey{}, tm)
+			next.ServeHTTP(wrapResponseWriter(tw), r.WithContext(ctx))
+		})
+	}
+}
+
+// timingResponseWriter adds the Server-Timing header before the
+// response is written.
+type timingResponseWriter struct {
+	responseWriter
+	tm          *ServerTiming
+	start       time.Time
+	total       bool
+	wroteHeader bool
+}
+
+func (tw *timingResponseWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		if v := tw.tm.String(); v != "" {
+			tw.Header().Add("Server-Timing", v)
+		}
+		if tw.total {
+			tw.Header().Add("Server-Timing", "total;dur="+timingDuration(time.Since(tw.start)))
+		}
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timingResponseWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (tw *timingResponseWriter) Flush() {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	tw.responseWriter.Flush()
+}
+
+// timingName replaces characters that are not permitted in
+// Server-Timing metric names.
+func timingName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func timingMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func timingDuration(d time.Duration) string {
+	return strconv.FormatFloat(timingMillis(d), 'f', -1, 64)
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTiming(t *testing.T) {
+	tm := &ServerTiming{}
+	tm.Add("db", 12500*time.Microsecond)
+	tm.Add("cache miss", 300*time.Microsecond)
+	tm.Add("db", 2*time.Millisecond)
+
+	if want, have := "db;dur=12.5, cache_miss;dur=0.3, db;dur=2", tm.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	have, err := json.Marshal(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"cache miss":0.3,"db":14.5}`; !EqualJSON([]byte(want), have) {
+		t.Errorf("want %s, have %s", want, have)
+	}
+
+	span := tm.Start("render")
+	span.Stop()
+	span.Stop()
+	if want, have := 4, len(tm.Metrics()); want != have {
+		t.Errorf("want %d metrics, have %d", want, have)
+	}
+}
+
+func TestServerTimingMiddleware(t *testing.T) {
+	h := ServerTimingMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tm := Timing(r.Context())
+		func() {
+			defer tm.Start("db").Stop()
+		}()
+		WriteJSON(w, map[string]interface{}{"meta": map[string]interface{}{"timing": tm}})
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	values := w.Header().Values("Server-Timing")
+	if want, have := 2, len(values); want != have {
+		t.Fatalf("want %d Server-Timing headers, have %v", want, values)
+	}
+	if !strings.HasPrefix(values[0], "db;dur=") {
+		t.Errorf("want db metric, have %q", values[0])
+	}
+	if !strings.HasPrefix(values[1], "total;dur=") {
+		t.Errorf("want total metric, have %q", values[1])
+	}
+	type response struct {
+		Meta struct {
+			Timing map[string]float64 `json:"timing"`
+		} `json:"meta"`
+	}
+	if _, found := DecodeAs[response](t, w).Meta.Timing["db"]; !found {
+		t.Errorf("want db timing in meta, have %s", w.Body)
+	}
+}
+
+func TestServerTimingWithDebugMode(t *testing.T) {
+	secret := []byte("secret")
+	h := ServerTimingMiddleware()(DebugMode(DebugConfig{Secret: secret})(ServerTimingMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Timing(r.Context()).Add("db", time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(DefaultDebugHeader, NewDebugToken(secret, time.Minute))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if want, have := "db;dur=1", w.Header().Values("Server-Timing")[0]; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if want, have := 2, len(w.Header().Values("Server-Timing")); want != have {
+		t.Errorf("want %d Server-Timing headers, have %v", want, w.Header().Values("Server-Timing"))
+	}
+}
+
+func TestTimingWithoutCollector(t *testing.T) {
+	tm := Timing(context.Background())
+	tm.Start("db").Stop()
+	if want, have := 1, len(tm.Metrics()); want != have {
+		t.Errorf("want %d metrics, have %d", want, have)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignURL(t *testing.T) {
+	secret := []byte("secret")
+	u, _ := url.Parse("https://example.com/downloads/report.pdf?user=42")
+
+	h := RequireSignedURL(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		URL  string
+		Want int
+	}{
+		{URL: SignURL(u, time.Hour, secret).String(), Want: http.StatusOK},
+		{URL: u.String(), Want: http.StatusForbidden},
+		{URL: strings.Replace(SignURL(u, time.Hour, secret).String(), "user=42", "user=43", 1), Want: http.StatusForbidden},
+		{URL: SignURL(u, time.Hour, []byte("other")).String(), Want: http.StatusForbidden},
+		{URL: SignURL(u, -time.Hour, secret).String(), Want: http.StatusGone},
+	}
+	for i, tt := range tests {
+		// Signed URLs are independent of the host, e.g. behind proxies
+		req := httptest.NewRequest("GET", strings.Replace(tt.URL, "https://example.com", "http://internal:8080", 1), nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Want, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
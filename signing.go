@@ -0,0 +1,110 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ResponseSigner signs the serialized body of a response.
+// SignResponse returns the name and the value of the header
+// that carries the signature.
+type ResponseSigner interface {
+	SignResponse(body []byte) (header, value string, err error)
+}
+
+// ContentDigest returns the value of a Content-Digest header as
+// specified in RFC 9530, using the SHA-256 algorithm, e.g.
+// "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:".
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// VerifyContentDigest returns true if the Content-Digest header value
+// contains a SHA-256 digest that matches body. Other algorithms are
+// ignored.
+func VerifyContentDigest(value string, body []byte) bool {
+	want := ContentDigest(body)
+	for _, part := range strings.Split(value, ",") {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(part)), []byte(want)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// HMACSigner is a ResponseSigner that creates a JSON Web Signature (JWS)
+// with detached payload (RFC 7515, Appendix F), using HMAC SHA-256.
+// The signature is returned in the X-JWS-Signature header.
+type HMACSigner struct {
+	// Key is the shared secret.
+	Key []byte
+	// KeyID is optional and gets passed in the "kid" header of the JWS.
+	KeyID string
+}
+
+// SignResponse signs body and returns the X-JWS-Signature header.
+func (s HMACSigner) SignResponse(body []byte) (string, string, error) {
+	hdr := map[string]string{"alg": "HS256"}
+	if s.KeyID != "" {
+		hdr["kid"] = s.KeyID
+	}
+	js, err := json.Marshal(hdr)
+	if err != nil {
+		return "", "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(js)
+	return "X-JWS-Signature", protected + ".." + s.sign(protected, body), nil
+}
+
+// Verify returns true if the detached JWS in value is a valid
+// signature of body.
+func (s HMACSigner) Verify(value string, body []byte) bool {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return false
+	}
+	return hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0], body)))
+}
+
+func (s HMACSigner) sign(protected string, body []byte) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(protected))
+	mac.Write([]byte("."))
+	mac.Write([]byte(base64.RawURLEncoding.EncodeToString(body)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// WriteSignedJSON writes data as JSON into w and sets the HTTP status code,
+// like WriteJSONCode. It adds a Content-Digest header and, if signer is
+// not nil, the signature header returned by signer. Data and errors are
+// written with the Config in effect for r.
+func WriteSignedJSON(w http.ResponseWriter, r *http.Request, code int, data interface{}, signer ResponseSigner) {
+	js, err := marshalJSONFor(r, data)
+	if err != nil {
+		badRequestError(w, r, "JSON serialization error: %v", err)
+		return
+	}
+	if signer != nil {
+		name, value, err := signer.SignResponse(js)
+		if err != nil {
+			writeJSONError(w, r, ServerError("Unable to sign response"))
+			return
+		}
+		w.Header().Set(name, value)
+	}
+	// The digest is only set now, so it doesn't end up on the error
+	w.Header().Set("Content-Digest", ContentDigest(js))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(js)
+}
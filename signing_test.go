@@ -0,0 +1,75 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentDigest(t *testing.T) {
+	// Example from RFC 9530, Section 2
+	body := []byte(`{"hello": "world"}` + "\n")
+	if want, have := "sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:", ContentDigest(body); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if !VerifyContentDigest("sha-512=:abc=:, "+ContentDigest(body), body) {
+		t.Fatal("expected Content-Digest to verify")
+	}
+	if VerifyContentDigest(ContentDigest(body), []byte("tampered")) {
+		t.Fatal("expected Content-Digest to not verify")
+	}
+}
+
+func TestWriteSignedJSON(t *testing.T) {
+	signer := HMACSigner{Key: []byte("secret"), KeyID: "k1"}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	WriteSignedJSON(w, r, 201, map[string]string{"name": "Oliver"}, signer)
+
+	if w.Code != 201 {
+		t.Fatalf("expected status = %d; got: %d", 201, w.Code)
+	}
+	body := w.Body.Bytes()
+	if !VerifyContentDigest(w.Header().Get("Content-Digest"), body) {
+		t.Errorf("expected Content-Digest to match body; got: %q", w.Header().Get("Content-Digest"))
+	}
+	sig := w.Header().Get("X-JWS-Signature")
+	if sig == "" {
+		t.Fatal("expected X-JWS-Signature header")
+	}
+	if !signer.Verify(sig, body) {
+		t.Errorf("expected signature %q to verify", sig)
+	}
+	if signer.Verify(sig, append(body, ' ')) {
+		t.Errorf("expected signature %q to not verify a modified body", sig)
+	}
+	if (HMACSigner{Key: []byte("other")}).Verify(sig, body) {
+		t.Errorf("expected signature %q to not verify with a different key", sig)
+	}
+}
+
+// failingSigner fails to sign responses.
+type failingSigner struct{}
+
+func (failingSigner) SignResponse(body []byte) (string, string, error) {
+	return "", "", errors.New("key unavailable")
+}
+
+func TestWriteSignedJSONFailure(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	WriteSignedJSON(w, r, 201, map[string]string{"name": "Oliver"}, failingSigner{})
+
+	if want, have := http.StatusInternalServerError, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if have := w.Header().Get("Content-Digest"); have != "" {
+		t.Errorf("want no Content-Digest on errors, have %q", have)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+type (
+	loggerContextKey    struct{}
+	requestIDContextKey struct{}
+)
+
+// maxRequestIDLength is the maximum length of an X-Request-Id header
+// accepted from clients.
+const maxRequestIDLength = 128
+
+// SlogMiddleware returns a middleware that attaches a request-scoped
+// logger to the context of each request, with the request id, method,
+// and route as attributes. Use LoggerFromContext to retrieve it.
+//
+// The request id is taken from the X-Request-Id header or generated, and
+// returned in the X-Request-Id header of the response. The route is the
+// template of the gorilla/mux route, so use the middleware with
+// Router.Use to get it.
+//
+// Errors written with WriteJSONError, RecoverJSON, and the other error
+// writers of this package are logged automatically: server errors (5xx)
+// at level Error, client errors (4xx) at level Info.
+//
+// The logger is wrapped with NewRedactingHandler, so messages and
+// attributes are redacted by the Redactor of the request.
+func SlogMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if _, ok := logger.Handler().(*redactingHandler); !ok {
+		logger = slog.New(NewRedactingHandler(logger.Handler()))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-Id")
+			if !isValidRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set("X-Request-Id", id)
+
+			attrs := []any{slog.String("request_id", id), slog.String("method", r.Method)}
+			if route := RouteTemplate(r); route != "" {
+				attrs = append(attrs, slog.String("route", route))
+			}
+			l := logger.With(attrs...)
+
+			ctx := context.WithValue(r.Context(), loggerContextKey{}, l)
+			ctx = context.WithValue(ctx, requestIDContextKey{}, id)
+			next.ServeHTTP(wrapResponseWriter(&slogResponseWriter{responseWriter: responseWriter{w}, logger: l}), r.WithContext(ctx))
+		})
+	}
+}
+
+// LoggerFromContext returns the logger attached by SlogMiddleware,
+// or slog.Default() if there is none.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// RequestIDFromContext returns the request id attached by SlogMiddleware,
+// or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// NewRedactingHandler returns a slog.Handler that redacts records before
+// passing them to h. Messages and string attributes are redacted with
+// RedactText, headers with RedactHeader, URLs with RedactURL, and
+// attributes whose key is a redacted field or header are replaced with
+// Redacted. The Redactor is taken from the Config of the context passed
+// to the logger (see WithConfig), or the global Config for attributes
+// added with Logger.With, which have no context.
+func NewRedactingHandler(h slog.Handler) slog.Handler {
+	return &redactingHandler{next: h}
+}
+
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	rd := redactorFor(nil)
+	if ctx != nil {
+		if cfg := ConfigFromContext(ctx); cfg.Redactor != nil {
+			rd = cfg.Redactor
+		}
+	}
+	out := slog.NewRecord(rec.Time, rec.Level, rd.RedactText(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(rd, a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	rd := redactorFor(nil)
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(rd, a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr returns a redacted copy of a.
+func redactAttr(rd *Redactor, a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Key != "" && (rd.isRedactedField("", a.Key) || rd.isRedactedHeader(a.Key)) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(rd.RedactText(a.Value.String()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, child := range attrs {
+			redacted[i] = redactAttr(rd, child)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case http.Header:
+			a.Value = slog.AnyValue(rd.RedactHeader(v))
+		case *url.URL:
+			a.Value = slog.StringValue(rd.RedactURL(v))
+		case error:
+			a.Value = slog.StringValue(rd.RedactText(v.Error()))
+		case []byte:
+			a.Value = slog.StringValue(rd.RedactText(string(v)))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(rd.RedactText(v.String()))
+		}
+	}
+	return a
+}
+
+// isValidRequestID returns true if id can be safely logged.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// slogResponseWriter carries the request-scoped logger for error writers
+// without access to the request, i.e. WriteJSONError.
+type slogResponseWriter struct {
+	responseWriter
+	logger *slog.Logger
+}
+
+// requestLogger returns the logger attached by SlogMiddleware to either
+// the request or the response writer, or nil if there is none.
+func requestLogger(w http.ResponseWriter, r *http.Request) *slog.Logger {
+	if r != nil {
+		if l, ok := r.Context().Value(loggerContextKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	for {
+		switch x := w.(type) {
+		case *slogResponseWriter:
+			return x.logger
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// logErrorWritten logs an error written as a response, if the request
+// is handled by SlogMiddleware.
+func logErrorWritten(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	l := requestLogger(w, r)
+	if l == nil || code < 400 {
+		return
+	}
+	level := slog.LevelInfo
+	if code >= 500 {
+		level = slog.LevelError
+	}
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	l.Log(ctx, level, "Error written", slog.Int("status", code), slog.String("error", msg))
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// decodeLogLines decodes the JSON log records written to buf.
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestSlogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	router := mux.NewRouter()
+	router.Use(SlogMiddleware(logger))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if want, have := "abc-123", RequestIDFromContext(r.Context()); want != have {
+			t.Errorf("want request id %q, have %q", want, have)
+		}
+		LoggerFromContext(r.Context()).Info("Loading user")
+		WriteJSONError(w, NotFoundError{})
+	})
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("X-Request-Id", "abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if want, have := "abc-123", w.Header().Get("X-Request-Id"); want != have {
+		t.Errorf("want X-Request-Id %q, have %q", want, have)
+	}
+	records := decodeLogLines(t, &buf)
+	if want, have := 2, len(records); want != have {
+		t.Fatalf("want %d log records, have %d: %s", want, have, buf.String())
+	}
+	for _, rec := range records {
+		if want, have := "abc-123", rec["request_id"]; want != have {
+			t.Errorf("want request_id %q, have %v", want, have)
+		}
+		if want, have := "GET", rec["method"]; want != have {
+			t.Errorf("want method %q, have %v", want, have)
+		}
+		if want, have := "/users/{id}", rec["route"]; want != have {
+			t.Errorf("want route %q, have %v", want, have)
+		}
+	}
+	if want, have := "INFO", records[1]["level"]; want != have {
+		t.Errorf("want level %q, have %v", want, have)
+	}
+	if want, have := float64(http.StatusNotFound), records[1]["status"]; want != have {
+		t.Errorf("want status %v, have %v", want, have)
+	}
+}
+
+func TestSlogMiddlewareLevels(t *testing.T) {
+	tests := []struct {
+		Err   interface{}
+		Level string
+	}{
+		{Err: ServerError("Database is down"), Level: "ERROR"},
+		{Err: "something panicked", Level: "ERROR"},
+		{Err: MissingParameterError("name"), Level: "INFO"},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		h := SlogMiddleware(slog.New(slog.NewJSONHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer RecoverJSON(w, r)
+			panic(tt.Err)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		records := decodeLogLines(t, &buf)
+		if want, have := 1, len(records); want != have {
+			t.Fatalf("#%d: want %d log records, have %d", i, want, have)
+		}
+		if want, have := tt.Level, records[0]["level"]; want != have {
+			t.Errorf("#%d: want level %q, have %v", i, want, have)
+		}
+		if id := w.Header().Get("X-Request-Id"); len(id) != 16 {
+			t.Errorf("#%d: want generated X-Request-Id, have %q", i, id)
+		}
+	}
+}
+
+func TestSlogMiddlewareRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	h := SlogMiddleware(slog.New(slog.NewJSONHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).InfoContext(r.Context(), "Login with password=s3cr3t",
+			"password", "s3cr3t",
+			"ssn", "123",
+			"header", r.Header,
+			"url", r.URL,
+			"body", `{"password":1234}`,
+			slog.Group("user", "name", "alice", "password", "s3cr3t"),
+		)
+	}))
+	cfg := Config{Redactor: NewDefaultRedactor()}
+	cfg.Redactor.Fields = append(cfg.Redactor.Fields, "ssn")
+	req := httptest.NewRequest("GET", "/?api_key=s3cr3t", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	WithConfig(cfg)(h).ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(buf.String(), "s3cr3t") || strings.Contains(buf.String(), "1234") || strings.Contains(buf.String(), `"123"`) {
+		t.Fatalf("want secrets redacted, have %s", buf.String())
+	}
+	records := decodeLogLines(t, &buf)
+	if want, have := 1, len(records); want != have {
+		t.Fatalf("want %d log records, have %d", want, have)
+	}
+	if want, have := "Login with password=[REDACTED]", records[0]["msg"]; want != have {
+		t.Errorf("want msg %q, have %v", want, have)
+	}
+	if want, have := "alice", records[0]["user"].(map[string]interface{})["name"]; want != have {
+		t.Errorf("want user name %q, have %v", want, have)
+	}
+}
+
+func TestLoggerFromContextDefault(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if want, have := slog.Default(), LoggerFromContext(req.Context()); want != have {
+		t.Errorf("want default logger, have %v", have)
+	}
+	if have := RequestIDFromContext(req.Context()); have != "" {
+		t.Errorf("want no request id, have %q", have)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSlowRequestThreshold is the default of SlowRequestConfig.Threshold.
+const DefaultSlowRequestThreshold = time.Second
+
+// SlowRequest describes a request that exceeded the threshold of the
+// SlowRequests middleware.
+type SlowRequest struct {
+	// Method and Path of the request.
+	Method, Path string
+	// Route is the template of the gorilla/mux route, see RouteTemplate.
+	Route string
+	// Status is the HTTP status code of the response.
+	Status int
+	// Duration is the time spent in the handler.
+	Duration time.Duration
+	// Timings are the measurements of the Timing collector, if any.
+	Timings []TimingMetric
+	// Goroutines is a goroutine profile in text form, taken when the
+	// threshold was exceeded, if SlowRequestConfig.Profile is set.
+	Goroutines []byte
+}
+
+// SlowRequestConfig configures the SlowRequests middleware.
+type SlowRequestConfig struct {
+	// Threshold is the duration after which a request is slow.
+	// It defaults to DefaultSlowRequestThreshold.
+	Threshold time.Duration
+	// Profile takes a snapshot of all goroutines when a request exceeds
+	// the threshold, while it is still running, to show what it's
+	// waiting for. At most one snapshot is taken at a time.
+	Profile bool
+	// OnSlow is called after a slow request has been handled. It is
+	// called synchronously, so hand off expensive work. It is required.
+	OnSlow func(r *http.Request, slow SlowRequest)
+}
+
+// SlowRequests returns a middleware that reports requests that take
+// longer than a threshold to a callback, e.g. to log them or to
+// collect them for an admin page. This gives visibility into tail
+// latency without a tracing infrastructure. Use the Timing collector
+// in handlers to see where the time was spent.
+func SlowRequests(cfg SlowRequestConfig) func(http.Handler) http.Handler {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultSlowRequestThreshold
+	}
+	var profiling int32
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			var profile atomic.Value
+			if cfg.Profile {
+				timer := time.AfterFunc(cfg.Threshold, func() {
+					if !atomic.CompareAndSwapInt32(&profiling, 0, 1) {
+						return
+					}
+					defer atomic.StoreInt32(&profiling, 0)
+					var buf bytes.Buffer
+					if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err == nil {
+						profile.Store(buf.Bytes())
+					}
+				})
+				defer timer.Stop()
+			}
+
+			sw := &statusResponseWriter{responseWriter: responseWriter{w}}
+			next.ServeHTTP(wrapResponseWriter(sw), r)
+
+			d := time.Since(start)
+			if d < cfg.Threshold || cfg.OnSlow == nil {
+				return
+			}
+			slow := SlowRequest{
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Route:    RouteTemplate(r),
+				Status:   sw.status(),
+				Duration: d,
+				Timings:  Timing(r.Context()).Metrics(),
+			}
+			slow.Goroutines, _ = profile.Load().([]byte)
+			cfg.OnSlow(r, slow)
+		})
+	}
+}
+
+// statusResponseWriter records the HTTP status code of the response.
+type statusResponseWriter struct {
+	responseWriter
+	code int
+}
+
+func (sw *statusResponseWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusResponseWriter) Write(p []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (sw *statusResponseWriter) Flush() {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	sw.responseWriter.Flush()
+}
+
+// status returns the HTTP status code, or 200 if nothing has been written.
+func (sw *statusResponseWriter) status() int {
+	if sw.code == 0 {
+		return http.StatusOK
+	}
+	return sw.code
+}
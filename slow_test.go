@@ -0,0 +1,67 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSlowRequests(t *testing.T) {
+	var reported []SlowRequest
+	router := mux.NewRouter()
+	router.Use(ServerTimingMiddleware(), SlowRequests(SlowRequestConfig{
+		Threshold: 20 * time.Millisecond,
+		Profile:   true,
+		OnSlow: func(r *http.Request, slow SlowRequest) {
+			reported = append(reported, slow)
+		},
+	}))
+	router.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if QueryBool(r, "slow", false) {
+			tm := Timing(r.Context())
+			span := tm.Start("db")
+			time.Sleep(50 * time.Millisecond)
+			span.Stop()
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, url := range []string{"/orders/1", "/orders/2?slow=1"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if want, have := http.StatusAccepted, w.Code; want != have {
+			t.Fatalf("want status %d, have %d", want, have)
+		}
+	}
+
+	if want, have := 1, len(reported); want != have {
+		t.Fatalf("want %d slow requests, have %d", want, have)
+	}
+	slow := reported[0]
+	if want, have := "/orders/2", slow.Path; want != have {
+		t.Errorf("want Path %q, have %q", want, have)
+	}
+	if want, have := "/orders/{id}", slow.Route; want != have {
+		t.Errorf("want Route %q, have %q", want, have)
+	}
+	if want, have := http.StatusAccepted, slow.Status; want != have {
+		t.Errorf("want Status %d, have %d", want, have)
+	}
+	if slow.Duration < 50*time.Millisecond {
+		t.Errorf("want Duration >= 50ms, have %v", slow.Duration)
+	}
+	if len(slow.Timings) != 1 || slow.Timings[0].Name != "db" {
+		t.Errorf("want db timing, have %+v", slow.Timings)
+	}
+	if !bytes.Contains(slow.Goroutines, []byte("goroutine profile")) {
+		t.Errorf("want goroutine profile, have %q", slow.Goroutines)
+	}
+}
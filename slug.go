@@ -0,0 +1,132 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// MinSlugLength is the minimum length of a slug as accepted by IsSlug.
+	MinSlugLength = 1
+	// MaxSlugLength is the maximum length of a slug as accepted by IsSlug.
+	MaxSlugLength = 128
+
+	// maxFilenameLength is the maximum length of a filename in bytes
+	// as returned by SanitizeFilename.
+	maxFilenameLength = 255
+)
+
+var slugHint = fmt.Sprintf("Expected %d to %d lowercase letters, digits, and single hyphens", MinSlugLength, MaxSlugLength)
+
+// IsSlug returns true if s is a slug, i.e. it consists of lowercase
+// letters a-z, digits, and hyphens, with no leading, trailing, or
+// consecutive hyphens, and a length between MinSlugLength and MaxSlugLength.
+func IsSlug(s string) bool {
+	if len(s) < MinSlugLength || len(s) > MaxSlugLength {
+		return false
+	}
+	if s[0] == '-' || s[len(s)-1] == '-' || strings.Contains(s, "--") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// MustParamsSlug checks if the request r has a routing component with
+// the specified key that is a slug as in IsSlug. If is doesn't, it will panic.
+func MustParamsSlug(r *http.Request, key string) string {
+	vars := mux.Vars(r)
+	v, found := vars[key]
+	if !found || v == "" {
+		panic(MissingParameterError(key))
+	}
+	if !IsSlug(v) {
+		panic(InvalidParameterHintError{Parameter: key, Hint: slugHint})
+	}
+	return v
+}
+
+// ParamsSlug checks if the request r has a routing component with
+// the specified key that is a slug as in IsSlug. If is doesn't,
+// it will return defaultValue.
+func ParamsSlug(r *http.Request, key string, defaultValue string) string {
+	vars := mux.Vars(r)
+	v, found := vars[key]
+	if !found || v == "" || !IsSlug(v) {
+		return defaultValue
+	}
+	return v
+}
+
+// SanitizeFilename returns a filename derived from s that is safe to use
+// on the local file system, e.g. for names of uploaded files. It removes
+// directory components (both "/" and "\"), control characters, and
+// characters reserved on common file systems, trims leading dots and
+// surrounding spaces, and limits the result to 255 bytes while
+// preserving the extension. If nothing is left, it returns "file".
+func SanitizeFilename(s string) string {
+	// Clients like old versions of Internet Explorer send full paths
+	if i := strings.LastIndexAny(s, `/\`); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimLeft(strings.TrimSpace(s), ".")
+	// Windows ignores trailing dots and spaces
+	s = strings.TrimRight(s, ". ")
+	if len(s) > maxFilenameLength {
+		ext := path.Ext(s)
+		if len(ext) > maxFilenameLength/2 {
+			ext = ""
+		}
+		base := s[:maxFilenameLength-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		s = base + ext
+	}
+	if isReservedFilename(s) {
+		s = "_" + s
+	}
+	if s == "" {
+		return "file"
+	}
+	return s
+}
+
+// isReservedFilename returns true if s is a device name reserved on Windows.
+func isReservedFilename(s string) bool {
+	name := strings.ToUpper(s)
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+	return false
+}
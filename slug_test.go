@@ -0,0 +1,112 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestIsSlug(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  bool
+	}{
+		{Input: "hello-world", Want: true},
+		{Input: "a", Want: true},
+		{Input: "2017-release-notes", Want: true},
+		{Input: strings.Repeat("a", MaxSlugLength), Want: true},
+		{Input: "", Want: false},
+		{Input: strings.Repeat("a", MaxSlugLength+1), Want: false},
+		{Input: "Hello", Want: false},
+		{Input: "-hello", Want: false},
+		{Input: "hello-", Want: false},
+		{Input: "hello--world", Want: false},
+		{Input: "hello_world", Want: false},
+		{Input: "../etc/passwd", Want: false},
+		{Input: "grüße", Want: false},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Want, IsSlug(tt.Input); want != have {
+			t.Errorf("#%d: IsSlug(%q): want %v, have %v", i, tt.Input, want, have)
+		}
+	}
+}
+
+func TestMustParamsSlug(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		defer RecoverJSON(w, r)
+		w.Write([]byte(MustParamsSlug(r, "slug")))
+	}
+
+	tests := []struct {
+		Vars map[string]string
+		Code int
+		Body string
+		Hint bool
+	}{
+		{Vars: map[string]string{"slug": "hello-world"}, Code: http.StatusOK, Body: "hello-world"},
+		{Vars: map[string]string{}, Code: http.StatusBadRequest},
+		{Vars: map[string]string{"slug": "Hello World"}, Code: http.StatusBadRequest, Hint: true},
+		{Vars: map[string]string{"slug": ".."}, Code: http.StatusBadRequest, Hint: true},
+	}
+	for i, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), tt.Vars)
+		w := httptest.NewRecorder()
+		h(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Code == http.StatusOK {
+			if want, have := tt.Body, w.Body.String(); want != have {
+				t.Errorf("#%d: want body %q, have %q", i, want, have)
+			}
+		} else if want, have := tt.Hint, strings.Contains(w.Body.String(), slugHint); want != have {
+			t.Errorf("#%d: want hint %v, have %s", i, want, w.Body.String())
+		}
+	}
+}
+
+func TestParamsSlug(t *testing.T) {
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"slug": "Bad Slug"})
+	if want, have := "default", ParamsSlug(req, "slug", "default"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"slug": "good-slug"})
+	if want, have := "good-slug", ParamsSlug(req, "slug", "default"); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "report.pdf", Want: "report.pdf"},
+		{Input: "  My Report (final).pdf  ", Want: "My Report (final).pdf"},
+		{Input: "../../etc/passwd", Want: "passwd"},
+		{Input: `C:\Users\oliver\Desktop\photo.jpg`, Want: "photo.jpg"},
+		{Input: "..", Want: "file"},
+		{Input: "", Want: "file"},
+		{Input: ".htaccess", Want: "htaccess"},
+		{Input: "a<b>c:d\"e|f?g*.txt", Want: "a_b_c_d_e_f_g_.txt"},
+		{Input: "line\nbreak\x00.txt", Want: "linebreak.txt"},
+		{Input: "trailing. . .", Want: "trailing"},
+		{Input: "CON.txt", Want: "_CON.txt"},
+		{Input: "grüße.txt", Want: "grüße.txt"},
+		{Input: strings.Repeat("a", 300) + ".txt", Want: strings.Repeat("a", 251) + ".txt"},
+		{Input: strings.Repeat("ü", 200) + ".txt", Want: strings.Repeat("ü", 125) + ".txt"},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Want, SanitizeFilename(tt.Input); want != have {
+			t.Errorf("#%d: SanitizeFilename(%q): want %q, have %q", i, tt.Input, want, have)
+		}
+	}
+}
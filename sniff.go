@@ -0,0 +1,113 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// SniffAndValidate detects the MIME type of file by its magic bytes and
+// checks it against the allowed types, e.g. "image/png" or "image/*".
+// It returns the detected type, without parameters, or
+// UnsupportedMediaTypeError if it is not allowed. File is rewound to
+// the start afterwards.
+//
+// Never trust the Content-Type or the extension of an uploaded file;
+// use SniffAndValidateFile for multipart uploads to also compare the
+// declared Content-Type.
+func SniffAndValidate(file io.ReadSeeker, allowed ...string) (string, error) {
+	return sniffAndValidate(file, "", allowed)
+}
+
+// SniffAndValidateFile opens the uploaded file fh and validates it like
+// SniffAndValidate. In addition, it returns UnsupportedMediaTypeError
+// if the declared Content-Type of the part doesn't match the detected
+// type, e.g. an HTML document uploaded as "image/png". On success, the
+// opened file is returned and must be closed by the caller.
+//
+// Example:
+//
+//	_, fh, err := r.FormFile("avatar")
+//	if err != nil {
+//	  panic(httputil.MissingParameterError("avatar"))
+//	}
+//	f, contentType, err := httputil.SniffAndValidateFile(fh, "image/png", "image/jpeg")
+//	if err != nil {
+//	  panic(err)
+//	}
+//	defer f.Close()
+func SniffAndValidateFile(fh *multipart.FileHeader, allowed ...string) (multipart.File, string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, err := sniffAndValidate(f, fh.Header.Get("Content-Type"), allowed)
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return f, contentType, nil
+}
+
+func sniffAndValidate(file io.ReadSeeker, declared string, allowed []string) (string, error) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sniffed := baseMediaType(http.DetectContentType(buf[:n]))
+
+	if declared != "" {
+		declared = baseMediaType(declared)
+		switch {
+		case declared == "application/octet-stream" || declared == sniffed:
+		case sniffed == "text/plain" && strings.HasPrefix(declared, "text/") && declared != "text/html":
+			// Magic bytes can't tell text formats apart, e.g. CSV
+			// and plain text, so we go with the declared type.
+			sniffed = declared
+		default:
+			return "", UnsupportedMediaTypeError{}
+		}
+	}
+
+	for _, pattern := range allowed {
+		if matchMediaType(pattern, sniffed) {
+			return sniffed, nil
+		}
+	}
+	return "", UnsupportedMediaTypeError{}
+}
+
+// baseMediaType returns the lowercase media type of s without parameters.
+func baseMediaType(s string) string {
+	mt, _, err := mime.ParseMediaType(s)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	return mt
+}
+
+// matchMediaType returns true if mt matches pattern, which may contain
+// wildcards like "image/*" or "*/*".
+func matchMediaType(pattern, mt string) bool {
+	pattern = baseMediaType(pattern)
+	if pattern == "*/*" || pattern == mt {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mt, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
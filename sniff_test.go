@@ -0,0 +1,110 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+var (
+	testPNG  = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	testJPEG = "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+	testHTML = "<!DOCTYPE html><html><script>alert(1)</script></html>"
+)
+
+func TestSniffAndValidate(t *testing.T) {
+	tests := []struct {
+		Data    string
+		Allowed []string
+		Want    string
+		Err     bool
+	}{
+		{Data: testPNG, Allowed: []string{"image/png"}, Want: "image/png"},
+		{Data: testJPEG, Allowed: []string{"image/*"}, Want: "image/jpeg"},
+		{Data: "%PDF-1.7\n", Allowed: []string{"*/*"}, Want: "application/pdf"},
+		{Data: testHTML, Allowed: []string{"image/*"}, Err: true},
+		{Data: testPNG, Allowed: []string{"image/jpeg", "application/pdf"}, Err: true},
+		{Data: testPNG, Err: true},
+	}
+	for i, tt := range tests {
+		r := strings.NewReader(tt.Data)
+		have, err := SniffAndValidate(r, tt.Allowed...)
+		if tt.Err {
+			if _, ok := err.(UnsupportedMediaTypeError); !ok {
+				t.Errorf("#%d: want UnsupportedMediaTypeError, have %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want := tt.Want; want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+		// File must be rewound
+		data, _ := ioutil.ReadAll(r)
+		if want, have := tt.Data, string(data); want != have {
+			t.Errorf("#%d: want file to be rewound, have %q", i, have)
+		}
+	}
+}
+
+func TestSniffAndValidateFile(t *testing.T) {
+	tests := []struct {
+		Data     string
+		Declared string
+		Allowed  []string
+		Want     string
+		Err      bool
+	}{
+		{Data: testPNG, Declared: "image/png", Allowed: []string{"image/*"}, Want: "image/png"},
+		{Data: testPNG, Declared: "application/octet-stream", Allowed: []string{"image/*"}, Want: "image/png"},
+		{Data: "id,name\n1,Oliver\n", Declared: "text/csv; charset=utf-8", Allowed: []string{"text/csv"}, Want: "text/csv"},
+		{Data: testPNG, Declared: "image/jpeg", Allowed: []string{"image/*"}, Err: true},
+		{Data: testHTML, Declared: "image/png", Allowed: []string{"image/*", "text/html"}, Err: true},
+		{Data: "hello", Declared: "text/html", Allowed: []string{"text/*"}, Err: true},
+	}
+	for i, tt := range tests {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {`form-data; name="file"; filename="upload"`},
+			"Content-Type":        {tt.Declared},
+		})
+		pw.Write([]byte(tt.Data))
+		mw.Close()
+		req := httptest.NewRequest("POST", "/", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		_, fh, err := req.FormFile("file")
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+
+		f, have, err := SniffAndValidateFile(fh, tt.Allowed...)
+		if tt.Err {
+			if _, ok := err.(UnsupportedMediaTypeError); !ok {
+				t.Errorf("#%d: want UnsupportedMediaTypeError, have %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want := tt.Want; want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		if want, have := tt.Data, string(data); want != have {
+			t.Errorf("#%d: want data %q, have %q", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ContentEncoding is a compression algorithm that can be used
+// as a Content-Encoding, e.g. gzip.
+type ContentEncoding struct {
+	// Name is the name of the encoding as used in Accept-Encoding.
+	Name string
+	// NewWriter returns a writer that compresses into w.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoding is the gzip ContentEncoding.
+var GzipEncoding = ContentEncoding{
+	Name: "gzip",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	},
+}
+
+// StaticJSONHandler serves a JSON payload that rarely changes, e.g.
+// configuration or catalogs. The payload is serialized and compressed
+// once, and served in the variant that best matches the Accept-Encoding
+// header of the request. Use StaticJSON to create one.
+type StaticJSONHandler struct {
+	encodings []ContentEncoding
+	payload   atomic.Value // *staticPayload
+}
+
+type staticPayload struct {
+	etag     string
+	variants map[string][]byte
+}
+
+// StaticJSON creates a StaticJSONHandler serving data as JSON. The payload
+// is pre-compressed with each of the given encodings, defaulting to gzip.
+// Brotli can be added via a third-party package, e.g.:
+//
+//	h, err := httputil.StaticJSON(catalog, httputil.ContentEncoding{
+//	  Name: "br",
+//	  NewWriter: func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+//	}, httputil.GzipEncoding)
+//
+// Encodings are preferred in the given order if the client accepts
+// several of them with the same quality.
+func StaticJSON(data interface{}, encodings ...ContentEncoding) (*StaticJSONHandler, error) {
+	if len(encodings) == 0 {
+		encodings = []ContentEncoding{GzipEncoding}
+	}
+	h := &StaticJSONHandler{encodings: encodings}
+	if err := h.Set(data); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Set atomically replaces the payload served by the handler with data.
+// Requests in flight are served with the previous payload.
+func (h *StaticJSONHandler) Set(data interface{}) error {
+	js, err := marshalJSON(data)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(js)
+	p := &staticPayload{
+		etag:     hex.EncodeToString(sum[:12]),
+		variants: map[string][]byte{"identity": js},
+	}
+	for _, enc := range h.encodings {
+		var buf bytes.Buffer
+		zw := enc.NewWriter(&buf)
+		if _, err := zw.Write(js); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		// Only keep variants that pay off
+		if buf.Len() < len(js) {
+			p.variants[enc.Name] = buf.Bytes()
+		}
+	}
+	h.payload.Store(p)
+	return nil
+}
+
+// ServeHTTP serves the payload.
+func (h *StaticJSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !IsGetOrHead(r) {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, r, InvalidMethodError{})
+		return
+	}
+	p := h.payload.Load().(*staticPayload)
+
+	encoding := "identity"
+	bestQ := 0.0
+	for _, enc := range h.encodings {
+		if _, found := p.variants[enc.Name]; !found {
+			continue
+		}
+		if q := acceptEncodingQuality(r, enc.Name); q > bestQ {
+			encoding, bestQ = enc.Name, q
+		}
+	}
+	body := p.variants[encoding]
+
+	etag := `"` + p.etag + `"`
+	if encoding != "identity" {
+		etag = `"` + p.etag + "-" + encoding + `"`
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		w.Write(body)
+	}
+}
+
+// acceptEncodingQuality returns the q-value for the encoding in the
+// Accept-Encoding header of r.
+func acceptEncodingQuality(r *http.Request, encoding string) float64 {
+	q := 0.0
+	specific := false
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+		if name == "*" && specific {
+			continue
+		}
+		pq := 1.0
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					pq = v
+				}
+			}
+		}
+		q = pq
+		specific = name == encoding
+	}
+	return q
+}
+
+// etagMatches returns true if the If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
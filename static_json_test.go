@@ -0,0 +1,99 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStaticJSON(t *testing.T) {
+	catalog := map[string]string{"items": strings.Repeat("abc", 100)}
+	h, err := StaticJSON(catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Uncompressed
+	req := httptest.NewRequest("GET", "/catalog", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected status = %d; got: %d", 200, w.Code)
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected no Content-Encoding; got: %q", w.Header().Get("Content-Encoding"))
+	}
+	plainETag := w.Header().Get("ETag")
+	if !EqualJSON(w.Body.Bytes(), []byte(`{"items":"`+catalog["items"]+`"}`)) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	// Gzip
+	req = httptest.NewRequest("GET", "/catalog", nil)
+	req.Header.Set("Accept-Encoding", "br;q=1.0, gzip;q=0.8, *;q=0.1")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if want, have := "gzip", w.Header().Get("Content-Encoding"); want != have {
+		t.Fatalf("want Content-Encoding %q, have %q", want, have)
+	}
+	if want, have := "Accept-Encoding", w.Header().Get("Vary"); want != have {
+		t.Fatalf("want Vary %q, have %q", want, have)
+	}
+	gzipETag := w.Header().Get("ETag")
+	if gzipETag == plainETag {
+		t.Fatalf("expected different ETags per encoding; got: %q", gzipETag)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(zr)
+	if !EqualJSON(body, []byte(`{"items":"`+catalog["items"]+`"}`)) {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// Gzip explicitly disabled
+	req = httptest.NewRequest("GET", "/catalog", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, *")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected no Content-Encoding; got: %q", w.Header().Get("Content-Encoding"))
+	}
+
+	// Not modified
+	req = httptest.NewRequest("GET", "/catalog", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", gzipETag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 304 {
+		t.Fatalf("expected status = %d; got: %d", 304, w.Code)
+	}
+
+	// Hot swap
+	if err := h.Set(map[string]string{"items": "none"}); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest("GET", "/catalog", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", gzipETag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected status = %d; got: %d", 200, w.Code)
+	}
+	// Small payloads are not compressed
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected no Content-Encoding; got: %q", w.Header().Get("Content-Encoding"))
+	}
+	if !EqualJSON(w.Body.Bytes(), []byte(`{"items":"none"}`)) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
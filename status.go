@@ -0,0 +1,34 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+)
+
+// WriteMultiStatus writes data as JSON into w with HTTP status code
+// 207 (Multi-Status), e.g. when relaying the result of a WebDAV upstream.
+// See WriteBulkResult for a standard format of batch results.
+func WriteMultiStatus(w http.ResponseWriter, data interface{}) {
+	WriteJSONCode(w, http.StatusMultiStatus, data)
+}
+
+// WriteAlreadyReported writes data as JSON into w with HTTP status
+// code 208 (Already Reported), used inside a WebDAV multistatus response
+// to avoid enumerating the members of a binding more than once.
+func WriteAlreadyReported(w http.ResponseWriter, data interface{}) {
+	WriteJSONCode(w, http.StatusAlreadyReported, data)
+}
+
+// WriteIMUsed writes data as JSON into w with HTTP status code 226
+// (IM Used) as in RFC 3229, i.e. the response is the result of the
+// instance-manipulations im (e.g. "diffe" or "vcdiff") applied to the
+// current instance. The manipulations are returned in the "IM" header.
+func WriteIMUsed(w http.ResponseWriter, im []string, data interface{}) {
+	for _, m := range im {
+		w.Header().Add("IM", m)
+	}
+	WriteJSONCode(w, http.StatusIMUsed, data)
+}
@@ -0,0 +1,46 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteStatusHelpers(t *testing.T) {
+	tests := []struct {
+		Write func(w http.ResponseWriter)
+		Code  int
+		IM    string
+	}{
+		{
+			Write: func(w http.ResponseWriter) { WriteMultiStatus(w, map[string]string{"a": "b"}) },
+			Code:  http.StatusMultiStatus,
+		},
+		{
+			Write: func(w http.ResponseWriter) { WriteAlreadyReported(w, nil) },
+			Code:  http.StatusAlreadyReported,
+		},
+		{
+			Write: func(w http.ResponseWriter) { WriteIMUsed(w, []string{"diffe"}, map[string]int{"n": 1}) },
+			Code:  http.StatusIMUsed,
+			IM:    "diffe",
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.Write(w)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := "application/json", w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if want, have := tt.IM, w.Header().Get("IM"); want != have {
+			t.Errorf("#%d: want IM %q, have %q", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StreamRegistry tracks long-lived connections like Server-Sent Events,
+// WebSockets, and long polls, so they can be terminated cleanly on
+// shutdown. http.Server.Shutdown doesn't close active connections: it
+// waits for SSE streams and long polls until its context expires, and
+// ignores hijacked WebSocket connections, so clients see an abrupt reset
+// instead of a message that tells them to reconnect elsewhere.
+//
+// Example:
+//
+//	streams := httputil.NewStreamRegistry()
+//	srv.RegisterOnShutdown(func() {
+//	  ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	  defer cancel()
+//	  streams.Shutdown(ctx)
+//	})
+//
+// RunServer does this for ServerConfig.Streams.
+//
+//	func events(w http.ResponseWriter, r *http.Request) {
+//	  stream, err := streams.Open("sse")
+//	  if err != nil {
+//	    httputil.ServeJSONError(w, r, err)
+//	    return
+//	  }
+//	  defer stream.Close()
+//	  // Lift ServerConfig.WriteTimeout for this response
+//	  http.NewResponseController(w).SetWriteDeadline(time.Time{})
+//	  for {
+//	    select {
+//	    case <-stream.Done():
+//	      fmt.Fprint(w, "event: shutdown\ndata: {}\n\n")
+//	      return
+//	    case <-r.Context().Done():
+//	      return
+//	    case e := <-updates:
+//	      ...
+//	    }
+//	  }
+//	}
+type StreamRegistry struct {
+	mu       sync.Mutex
+	streams  map[*Stream]struct{}
+	shutdown chan struct{}
+	closing  bool
+	wg       sync.WaitGroup
+}
+
+// NewStreamRegistry returns a new StreamRegistry.
+func NewStreamRegistry() *StreamRegistry {
+	return &StreamRegistry{
+		streams:  make(map[*Stream]struct{}),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Stream is a long-lived connection tracked by a StreamRegistry.
+type Stream struct {
+	// Kind is the kind of stream, e.g. "sse" or "ws".
+	Kind string
+	// Started is the time the stream was opened.
+	Started time.Time
+
+	reg  *StreamRegistry
+	once sync.Once
+}
+
+// Open registers a new stream of the given kind. It returns
+// ServiceUnavailableError if the registry is shutting down, so
+// clients reconnect to another instance.
+func (reg *StreamRegistry) Open(kind string) (*Stream, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.closing {
+		return nil, ServiceUnavailableError{RetryAfter: time.Second}
+	}
+	s := &Stream{Kind: kind, Started: time.Now(), reg: reg}
+	reg.streams[s] = struct{}{}
+	reg.wg.Add(1)
+	return s, nil
+}
+
+// Done returns a channel that is closed when the registry shuts down.
+// Handlers should then send a termination message, e.g. an SSE event
+// or a WebSocket close message (see WSConn.GoAway), and return.
+func (s *Stream) Done() <-chan struct{} {
+	return s.reg.shutdown
+}
+
+// Close unregisters the stream. It is safe to call Close more than once.
+func (s *Stream) Close() {
+	s.once.Do(func() {
+		s.reg.mu.Lock()
+		delete(s.reg.streams, s)
+		s.reg.mu.Unlock()
+		s.reg.wg.Done()
+	})
+}
+
+// Len returns the number of open streams by kind.
+func (reg *StreamRegistry) Len() map[string]int {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	n := make(map[string]int)
+	for s := range reg.streams {
+		n[s.Kind]++
+	}
+	return n
+}
+
+// Shutdown rejects new streams, closes the Done channel of all open
+// streams, and waits until they are closed or ctx is done, in which case
+// it returns the error of ctx. Subsequent calls only wait.
+func (reg *StreamRegistry) Shutdown(ctx context.Context) error {
+	reg.mu.Lock()
+	if !reg.closing {
+		reg.closing = true
+		close(reg.shutdown)
+	}
+	reg.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		reg.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamRegistry(t *testing.T) {
+	streams := NewStreamRegistry()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, err := streams.Open("sse")
+		if err != nil {
+			WriteJSONError(w, err)
+			return
+		}
+		defer stream.Close()
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-stream.Done():
+			fmt.Fprint(w, "event: shutdown\ndata: {}\n\n")
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	br := bufio.NewReader(res.Body)
+	if line, _ := br.ReadString('\n'); line != "data: hello\n" {
+		t.Fatalf("want first event, have %q", line)
+	}
+	if want, have := 1, streams.Len()["sse"]; want != have {
+		t.Errorf("want %d open stream, have %d", want, have)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := streams.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	br.ReadString('\n')
+	if line, _ := br.ReadString('\n'); line != "event: shutdown\n" {
+		t.Errorf("want shutdown event, have %q", line)
+	}
+	if want, have := 0, len(streams.Len()); want != have {
+		t.Errorf("want no open streams, have %d", have)
+	}
+
+	// New streams are rejected
+	res2, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrainAndClose(res2)
+	if want, have := http.StatusServiceUnavailable, res2.StatusCode; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
+
+func TestStreamRegistryShutdownTimeout(t *testing.T) {
+	streams := NewStreamRegistry()
+	stream, err := streams.Open("ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if want, have := context.DeadlineExceeded, streams.Shutdown(ctx); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	stream.Close()
+	stream.Close()
+	if err := streams.Shutdown(context.Background()); err != nil {
+		t.Errorf("want no error after streams are closed, have %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+)
+
+// StubOptions configures StubHandler.
+type StubOptions struct {
+	// Code is the HTTP status code of the example. It defaults to 200.
+	Code int
+	// NotImplemented responds with NotImplementedError and 501 Not
+	// Implemented instead, with the example in the "example" field of
+	// the error, so clients can test their error handling while still
+	// seeing the shape of the future response.
+	NotImplemented bool
+}
+
+// StubHandler returns a handler that serves example as JSON for an
+// endpoint that is not implemented yet, so clients can be developed
+// against it in the meantime. Responses have the header "X-Stub: true",
+// so stubs are easy to spot, e.g. in browser developer tools.
+//
+// Example:
+//
+//	router.Handle("/orders/{id}", httputil.StubHandler(Order{ID: 42, Status: "shipped"}, httputil.StubOptions{})).Methods("GET")
+//	router.Handle("/orders", httputil.StubHandler(Order{ID: 43}, httputil.StubOptions{Code: http.StatusCreated})).Methods("POST")
+func StubHandler(example interface{}, opts StubOptions) http.Handler {
+	code := opts.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Stub", "true")
+		if opts.NotImplemented {
+			err := NotImplementedError{}
+			writeJSONError(w, r, ErrorBody{
+				Code:       err.HTTPCode(),
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"example": example},
+			})
+			return
+		}
+		WriteJSONCode(w, code, example)
+	})
+}
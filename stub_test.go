@@ -0,0 +1,46 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStubHandler(t *testing.T) {
+	type order struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	example := order{ID: 42, Status: "shipped"}
+
+	tests := []struct {
+		Options StubOptions
+		Code    int
+		Body    string
+	}{
+		{Code: http.StatusOK, Body: `{"id":42,"status":"shipped"}`},
+		{Options: StubOptions{Code: http.StatusCreated}, Code: http.StatusCreated, Body: `{"id":42,"status":"shipped"}`},
+		{
+			Options: StubOptions{NotImplemented: true},
+			Code:    http.StatusNotImplemented,
+			Body:    `{"error":{"code":501,"example":{"id":42,"status":"shipped"},"message":"Not implemented"}}`,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		StubHandler(example, tt.Options).ServeHTTP(w, httptest.NewRequest("GET", "/orders/42", nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := "true", w.Header().Get("X-Stub"); want != have {
+			t.Errorf("#%d: want X-Stub %q, have %q", i, want, have)
+		}
+		if !EqualJSON([]byte(tt.Body), w.Body.Bytes()) {
+			t.Errorf("#%d: want %s, have %s", i, tt.Body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+)
+
+// TabularWriter serializes tabular data in a specific format, e.g. CSV.
+// See the xlsx subpackage for an implementation that writes Excel files.
+type TabularWriter interface {
+	// ContentType returns the content type of the format.
+	ContentType() string
+	// WriteTable writes the header followed by the records passed to
+	// yield by rows.
+	WriteTable(w io.Writer, header []string, rows func(yield func([]string))) error
+}
+
+// CSVWriter is a TabularWriter that writes CSV.
+type CSVWriter struct {
+	// BOM prefixes the output with a UTF-8 byte order mark for Excel.
+	BOM bool
+}
+
+// ContentType returns "text/csv; charset=utf-8".
+func (CSVWriter) ContentType() string { return "text/csv; charset=utf-8" }
+
+// WriteTable writes the header and rows as CSV.
+func (c CSVWriter) WriteTable(w io.Writer, header []string, rows func(yield func([]string))) error {
+	if c.BOM {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if len(header) > 0 {
+		cw.Write(header)
+	}
+	if rows != nil {
+		rows(func(record []string) {
+			cw.Write(record)
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTable writes tabular data into w with the given HTTP status code.
+// The format is picked from writers via content negotiation on the
+// Accept header of r. If none of the writers is acceptable, the first
+// one is used.
+//
+// Example:
+//
+//	httputil.WriteTable(w, r, http.StatusOK, header, rows,
+//	  httputil.CSVWriter{}, xlsx.Writer{SheetName: "Report"})
+func WriteTable(w http.ResponseWriter, r *http.Request, code int, header []string, rows func(yield func([]string)), writers ...TabularWriter) {
+	if len(writers) == 0 {
+		writers = []TabularWriter{CSVWriter{}}
+	}
+	offers := make([]string, len(writers))
+	for i, tw := range writers {
+		offers[i] = tw.ContentType()
+	}
+	tw := writers[0]
+	if ct := NegotiateContentType(r, offers...); ct != "" {
+		for i, offer := range offers {
+			if offer == ct {
+				tw = writers[i]
+				break
+			}
+		}
+	}
+	w.Header().Set("Content-Type", tw.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(code)
+	tw.WriteTable(w, header, rows)
+}
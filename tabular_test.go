@@ -0,0 +1,50 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+type tsvWriter struct{}
+
+func (tsvWriter) ContentType() string { return "text/tab-separated-values" }
+
+func (tsvWriter) WriteTable(w io.Writer, header []string, rows func(yield func([]string))) error {
+	io.WriteString(w, "tsv")
+	return nil
+}
+
+func TestWriteTable(t *testing.T) {
+	rows := func(yield func([]string)) {
+		yield([]string{"1", "Oliver"})
+	}
+	tests := []struct {
+		Accept      string
+		ContentType string
+		Body        string
+	}{
+		{Accept: "", ContentType: "text/csv; charset=utf-8", Body: "id,name\n1,Oliver\n"},
+		{Accept: "text/csv", ContentType: "text/csv; charset=utf-8", Body: "id,name\n1,Oliver\n"},
+		{Accept: "text/tab-separated-values", ContentType: "text/tab-separated-values", Body: "tsv"},
+		{Accept: "application/pdf", ContentType: "text/csv; charset=utf-8", Body: "id,name\n1,Oliver\n"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "/report", nil)
+		if tt.Accept != "" {
+			req.Header.Set("Accept", tt.Accept)
+		}
+		w := httptest.NewRecorder()
+		WriteTable(w, req, 200, []string{"id", "name"}, rows, CSVWriter{}, tsvWriter{})
+		if want, have := tt.ContentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if want, have := tt.Body, w.Body.String(); want != have {
+			t.Errorf("#%d: want body %q, have %q", i, want, have)
+		}
+	}
+}
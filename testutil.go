@@ -7,6 +7,8 @@ package httputil
 import (
 	"bytes"
 	"encoding/json"
+	"net/http/httptest"
+	"testing"
 )
 
 // EqualJSON compares the two serialized byte slices for equality.
@@ -37,3 +39,27 @@ func EqualJSON(a, b []byte) bool {
 	}
 	return bytes.Equal(dsta.Bytes(), dstb.Bytes())
 }
+
+// DecodeAs decodes the JSON body of the recorded response w into a value
+// of type T. It fails the test if the body cannot be decoded.
+//
+// Example:
+//
+//	w := httptest.NewRecorder()
+//	handler.ServeHTTP(w, req)
+//	user := httputil.DecodeAs[User](t, w)
+func DecodeAs[T any](t testing.TB, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("unable to decode response body %q: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+// ErrorEnvelopeOf decodes the recorded response w as written by
+// WriteJSONError. It fails the test if the body cannot be decoded.
+func ErrorEnvelopeOf(t testing.TB, w *httptest.ResponseRecorder) ErrorEnvelope {
+	t.Helper()
+	return DecodeAs[ErrorEnvelope](t, w)
+}
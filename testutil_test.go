@@ -4,7 +4,10 @@
 
 package httputil
 
-import "testing"
+import (
+	"net/http/httptest"
+	"testing"
+)
 
 func TestEqualJSON(t *testing.T) {
 	tests := []struct {
@@ -53,3 +56,34 @@ func TestEqualJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeAs(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+	w := httptest.NewRecorder()
+	WriteJSON(w, user{Name: "Oliver"})
+	if want, have := "Oliver", DecodeAs[user](t, w).Name; want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	w = httptest.NewRecorder()
+	WriteJSON(w, []int{1, 2, 3})
+	if want, have := 3, len(DecodeAs[[]int](t, w)); want != have {
+		t.Errorf("want %d, have %d", want, have)
+	}
+}
+
+func TestErrorEnvelopeOf(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteJSONError(w, UnprocessableEntityError{Errors: []string{"Name is blank"}})
+	env := ErrorEnvelopeOf(t, w)
+	if want, have := 422, env.Error.Code; want != have {
+		t.Errorf("want code %d, have %d", want, have)
+	}
+	if want, have := "Record has semantic errors", env.Error.Message; want != have {
+		t.Errorf("want message %q, have %q", want, have)
+	}
+	if want, have := 1, len(env.Error.Details); want != have {
+		t.Errorf("want %d details, have %d", want, have)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"time"
+)
+
+// ReasonResourceDeleted is the reason of errors written by WriteGone.
+const ReasonResourceDeleted = "RESOURCE_DELETED"
+
+// WriteGone writes a GoneError for a deleted resource, with the resource,
+// the time it was deleted, and the URL of its successor, if any, in the
+// error. A successor is also linked in the Link header with the relation
+// "successor-version". Use it for soft-deleted or deprecated resources,
+// so clients can tell them from resources that never existed. The
+// error is written with the Config in effect for r, like ServeJSONError.
+//
+// Example:
+//
+//	httputil.WriteGone(w, r, "/v1/orders/42", order.DeletedAt, "/v2/orders/42")
+//
+// writes:
+//
+//	{
+//	  "error": {
+//	    "alternative": "/v2/orders/42",
+//	    "code": 410,
+//	    "deleted_at": "2024-05-01T12:00:00Z",
+//	    "message": "Resource is gone",
+//	    "reason": "RESOURCE_DELETED",
+//	    "resource": "/v1/orders/42"
+//	  }
+//	}
+//
+// deleted_at is omitted if deletedAt is zero, and alternative if it is
+// blank. Times use the layout of SetJSONTimeLayout.
+func WriteGone(w http.ResponseWriter, r *http.Request, resource string, deletedAt time.Time, alternative string) {
+	body := ErrorBody{
+		Code:       http.StatusGone,
+		Message:    GoneError{}.Error(),
+		Reason:     ReasonResourceDeleted,
+		Extensions: map[string]interface{}{"resource": resource},
+	}
+	if !deletedAt.IsZero() {
+		body.Extensions["deleted_at"] = NewJSONTime(deletedAt)
+	}
+	if alternative != "" {
+		body.Extensions["alternative"] = alternative
+		SetLinkHeader(w, Link{URL: alternative, Rel: "successor-version"})
+	}
+	writeJSONError(w, r, body)
+}
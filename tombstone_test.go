@@ -0,0 +1,49 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteGone(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		DeletedAt   time.Time
+		Alternative string
+		Link        string
+		Body        string
+	}{
+		{
+			Body: `{"error":{"code":410,"message":"Resource is gone","reason":"RESOURCE_DELETED","resource":"/v1/orders/42"}}`,
+		},
+		{
+			DeletedAt:   deletedAt,
+			Alternative: "/v2/orders/42",
+			Link:        `</v2/orders/42>; rel="successor-version"`,
+			Body:        `{"error":{"alternative":"/v2/orders/42","code":410,"deleted_at":"2024-05-01T12:00:00Z","message":"Resource is gone","reason":"RESOURCE_DELETED","resource":"/v1/orders/42"}}`,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("DELETE", "/v1/orders/42", nil)
+		WriteGone(w, r, "/v1/orders/42", tt.DeletedAt, tt.Alternative)
+		if want, have := http.StatusGone, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := tt.Link, w.Header().Get("Link"); want != have {
+			t.Errorf("#%d: want Link %q, have %q", i, want, have)
+		}
+		if !EqualJSON([]byte(tt.Body), w.Body.Bytes()) {
+			t.Errorf("#%d: want %s, have %s", i, tt.Body, w.Body.String())
+		}
+		if want, have := ReasonResourceDeleted, ErrorEnvelopeOf(t, w).Error.Reason; want != have {
+			t.Errorf("#%d: want reason %q, have %q", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+type traceContextContextKey struct{}
+
+// TraceContext identifies the trace and span of a request, as propagated
+// by the W3C Trace Context headers traceparent and tracestate, and by
+// the B3 headers of Zipkin. It allows services that are not using a
+// tracing SDK yet to keep traces connected.
+type TraceContext struct {
+	// TraceID is the trace id as 32 lowercase hex characters.
+	TraceID string
+	// SpanID is the span id as 16 lowercase hex characters.
+	SpanID string
+	// Sampled is true if the caller records the trace.
+	Sampled bool
+	// State is the vendor-specific tracestate header, if any.
+	State string
+}
+
+// IsValid returns true if tc has a valid trace id and span id.
+func (tc TraceContext) IsValid() bool {
+	return isTraceHex(tc.TraceID, 32) && isTraceHex(tc.SpanID, 16)
+}
+
+// Traceparent returns tc in the format of the W3C traceparent header,
+// e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (tc TraceContext) Traceparent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + flags
+}
+
+// NewSpan returns a copy of tc with a new span id, i.e. a child span of
+// tc. If tc is not valid, it starts a new trace that is sampled.
+func (tc TraceContext) NewSpan() TraceContext {
+	if !isTraceHex(tc.TraceID, 32) {
+		tc = TraceContext{TraceID: newTraceHex(16), Sampled: true}
+	}
+	tc.SpanID = newTraceHex(8)
+	return tc
+}
+
+// ExtractTraceContext returns the trace context of the request headers.
+// It supports the W3C traceparent and tracestate headers, the B3 single
+// header, and the B3 multi headers (X-B3-TraceId etc.), in that order.
+// It returns false if r has no valid trace context.
+func ExtractTraceContext(r *http.Request) (TraceContext, bool) {
+	if tc, ok := parseTraceparent(r.Header.Get("Traceparent")); ok {
+		tc.State = strings.Join(r.Header.Values("Tracestate"), ",")
+		return tc, true
+	}
+	if tc, ok := parseB3(r.Header.Get("B3")); ok {
+		return tc, true
+	}
+	tc := TraceContext{
+		TraceID: padTraceID(strings.ToLower(r.Header.Get("X-B3-TraceId"))),
+		SpanID:  strings.ToLower(r.Header.Get("X-B3-SpanId")),
+		Sampled: r.Header.Get("X-B3-Sampled") == "1" || r.Header.Get("X-B3-Flags") == "1",
+	}
+	if !tc.IsValid() {
+		return TraceContext{}, false
+	}
+	return tc, true
+}
+
+// InjectTraceContext sets the trace context of ctx, if any, in the W3C
+// and B3 headers of the outbound request. Use it for requests made
+// without the transport of PropagateTraceContext.
+func InjectTraceContext(ctx context.Context, outbound *http.Request) {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return
+	}
+	h := outbound.Header
+	h.Set("Traceparent", tc.Traceparent())
+	if tc.State != "" {
+		h.Set("Tracestate", tc.State)
+	} else {
+		h.Del("Tracestate")
+	}
+	h.Del("B3")
+	h.Set("X-B3-TraceId", tc.TraceID)
+	h.Set("X-B3-SpanId", tc.SpanID)
+	h.Del("X-B3-ParentSpanId")
+	h.Del("X-B3-Flags")
+	if tc.Sampled {
+		h.Set("X-B3-Sampled", "1")
+	} else {
+		h.Set("X-B3-Sampled", "0")
+	}
+}
+
+// WithTraceContext returns a copy of ctx with the trace context tc.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context of ctx, and false
+// if there is none.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// TraceContextMiddleware attaches the trace context of each request to
+// its context, with a new span id for the request. Requests without a
+// trace context start a new trace. Use PropagateTraceContext or
+// InjectTraceContext to pass it on to outbound requests.
+func TraceContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, _ := ExtractTraceContext(r)
+		next.ServeHTTP(w, r.WithContext(WithTraceContext(r.Context(), tc.NewSpan())))
+	})
+}
+
+// PropagateTraceContext wraps a transport to set the trace context of
+// the request context in outbound requests, e.g. for use in
+// Profile.Transports. See InjectTraceContext.
+func PropagateTraceContext(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if _, ok := TraceContextFromContext(r.Context()); !ok {
+			return next.RoundTrip(r)
+		}
+		r2 := r.Clone(r.Context())
+		InjectTraceContext(r2.Context(), r2)
+		return next.RoundTrip(r2)
+	})
+}
+
+// parseTraceparent parses a W3C traceparent header.
+func parseTraceparent(s string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || !isTraceHex(parts[0], 2) || parts[0] == "ff" || !isTraceHex(parts[3], 2) {
+		return TraceContext{}, false
+	}
+	// Version 00 has exactly four parts, later versions may add more
+	if parts[0] == "00" && len(parts) != 4 {
+		return TraceContext{}, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	tc := TraceContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Sampled: flags[0]&1 == 1,
+	}
+	return tc, tc.IsValid()
+}
+
+// parseB3 parses a B3 single header, e.g. "{TraceId}-{SpanId}-1".
+func parseB3(s string) (TraceContext, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "-")
+	if len(parts) < 2 {
+		return TraceContext{}, false
+	}
+	tc := TraceContext{
+		TraceID: padTraceID(parts[0]),
+		SpanID:  parts[1],
+	}
+	if len(parts) > 2 {
+		tc.Sampled = parts[2] == "1" || parts[2] == "d"
+	}
+	return tc, tc.IsValid()
+}
+
+// padTraceID pads 64-bit B3 trace ids to 128 bits.
+func padTraceID(id string) string {
+	if len(id) == 16 {
+		return "0000000000000000" + id
+	}
+	return id
+}
+
+// isTraceHex returns true if s consists of n lowercase hex characters.
+// Trace and span ids must not be all zeros, while the version and flags
+// of traceparent (n == 2) may.
+func isTraceHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	return !zero || n == 2
+}
+
+// newTraceHex returns n random bytes in hex.
+func newTraceHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
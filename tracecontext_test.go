@@ -0,0 +1,126 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractTraceContext(t *testing.T) {
+	tests := []struct {
+		Header http.Header
+		OK     bool
+		Want   TraceContext
+	}{
+		{Header: http.Header{}},
+		{
+			Header: http.Header{
+				"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+				"Tracestate":  {"rojo=00f067aa0ba902b7", "congo=t61rcWkgMzE"},
+			},
+			OK:   true,
+			Want: TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, State: "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE"},
+		},
+		{
+			Header: http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}},
+			OK:     true,
+			Want:   TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"},
+		},
+		{Header: http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}},
+		{Header: http.Header{"Traceparent": {"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"}}},
+		{Header: http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}}},
+		{Header: http.Header{"Traceparent": {"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}},
+		{
+			Header: http.Header{"B3": {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"}},
+			OK:     true,
+			Want:   TraceContext{TraceID: "80f198ee56343ba864fe8b2a57d3eff7", SpanID: "e457b5a2e4d86bd1", Sampled: true},
+		},
+		{
+			Header: http.Header{
+				"X-B3-Traceid": {"A3CE929D0E0E4736"},
+				"X-B3-Spanid":  {"00f067aa0ba902b7"},
+				"X-B3-Sampled": {"1"},
+			},
+			OK:   true,
+			Want: TraceContext{TraceID: "0000000000000000a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true},
+		},
+		{Header: http.Header{"X-B3-Traceid": {"a3ce929d0e0e4736"}}},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header = tt.Header
+		tc, ok := ExtractTraceContext(r)
+		if want, have := tt.OK, ok; want != have {
+			t.Errorf("#%d: want ok=%v, have %v", i, want, have)
+			continue
+		}
+		if want, have := tt.Want, tc; want != have {
+			t.Errorf("#%d: want %+v, have %+v", i, want, have)
+		}
+	}
+}
+
+func TestPropagateTraceContext(t *testing.T) {
+	var outbound http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header
+	}))
+	defer backend.Close()
+	client := &http.Client{Transport: PropagateTraceContext(nil)}
+
+	var tc TraceContext
+	h := TraceContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, _ = TraceContextFromContext(r.Context())
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", backend.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("Tracestate", "rojo=00f067aa0ba902b7")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if want, have := "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID; want != have {
+		t.Errorf("want trace id %q, have %q", want, have)
+	}
+	if tc.SpanID == "00f067aa0ba902b7" || !tc.IsValid() {
+		t.Errorf("want a new valid span id, have %q", tc.SpanID)
+	}
+	if want, have := tc.Traceparent(), outbound.Get("Traceparent"); want != have {
+		t.Errorf("want traceparent %q, have %q", want, have)
+	}
+	if want, have := "rojo=00f067aa0ba902b7", outbound.Get("Tracestate"); want != have {
+		t.Errorf("want tracestate %q, have %q", want, have)
+	}
+	for name, want := range map[string]string{"X-B3-TraceId": tc.TraceID, "X-B3-SpanId": tc.SpanID, "X-B3-Sampled": "1"} {
+		if have := outbound.Get(name); want != have {
+			t.Errorf("want %s %q, have %q", name, want, have)
+		}
+	}
+}
+
+func TestTraceContextMiddlewareStartsTrace(t *testing.T) {
+	var tc TraceContext
+	var ok bool
+	h := TraceContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, ok = TraceContextFromContext(r.Context())
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !ok || !tc.IsValid() || !tc.Sampled {
+		t.Errorf("want a new sampled trace, have %+v", tc)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	InjectTraceContext(context.Background(), r)
+	if have := r.Header.Get("Traceparent"); have != "" {
+		t.Errorf("want no traceparent without trace context, have %q", have)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// ContentTypeOffsetOctetStream is the content type of the chunks
+	// sent to a ResumableUploadHandler.
+	ContentTypeOffsetOctetStream = "application/offset+octet-stream"
+
+	// DefaultMaxUploadSize is the default of ResumableUploadConfig.MaxSize.
+	DefaultMaxUploadSize = 1 << 30
+
+	// resumableVersion is the version of the resumable upload protocol.
+	resumableVersion = "1.0.0"
+
+	// maxChecksumChunkSize is the maximum size of a chunk with an
+	// Upload-Checksum header, as such chunks are buffered in memory.
+	maxChecksumChunkSize = 32 << 20
+
+	// statusChecksumMismatch is returned if a chunk doesn't match
+	// its Upload-Checksum header.
+	statusChecksumMismatch = 460
+)
+
+// BlobInfo describes an upload in a BlobStore.
+type BlobInfo struct {
+	// ID identifies the upload.
+	ID string
+	// Length is the total size of the upload in bytes.
+	Length int64
+	// Offset is the number of bytes received so far.
+	Offset int64
+	// Metadata are the key/value pairs passed on creation.
+	Metadata map[string]string
+}
+
+// Complete returns true if all bytes of the upload have been received.
+func (info BlobInfo) Complete() bool {
+	return info.Offset >= info.Length
+}
+
+// BlobStore stores the uploads of a ResumableUploadHandler. Methods should
+// return errors of this package, e.g. NotFoundError for an unknown upload,
+// which are passed on to the client.
+type BlobStore interface {
+	// Create starts a new upload with the total length in bytes.
+	Create(ctx context.Context, length int64, metadata map[string]string) (BlobInfo, error)
+	// Info returns the current state of an upload.
+	Info(ctx context.Context, id string) (BlobInfo, error)
+	// Append adds the bytes read from r to the upload, which must
+	// currently be at offset. It returns the new offset. If reading
+	// from r fails, e.g. because the client disconnected, Append should
+	// keep the bytes read so far, so the client can resume from there.
+	Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+}
+
+// ResumableUploadHandler returns a handler for offset-based resumable
+// uploads, compatible with the tus protocol 1.0.0 and its creation and
+// checksum extensions. Clients that lose their connection ask for the
+// offset of the upload and continue from there instead of starting over.
+//
+// The handler supports these requests:
+//
+//	OPTIONS /uploads       describes the supported protocol and extensions
+//	POST    /uploads       creates an upload with an Upload-Length header
+//	HEAD    /uploads/{id}  returns the current Upload-Offset
+//	PATCH   /uploads/{id}  appends a chunk at Upload-Offset
+//
+// The upload ID is the last element of the request path. Chunks with an
+// Upload-Checksum header (sha1, sha256, or md5) are buffered in memory and
+// verified before they are stored, so they are limited to 32 MB.
+// Uploads are limited to DefaultMaxUploadSize; see
+// ResumableUploadHandlerWithConfig for other limits.
+func ResumableUploadHandler(store BlobStore) http.Handler {
+	return ResumableUploadHandlerWithConfig(store, ResumableUploadConfig{})
+}
+
+// ResumableUploadConfig configures ResumableUploadHandlerWithConfig.
+type ResumableUploadConfig struct {
+	// MaxSize is the maximum size of an upload in bytes. Larger uploads
+	// are rejected with RequestEntityTooLargeError on creation. It
+	// defaults to DefaultMaxUploadSize.
+	MaxSize int64
+}
+
+// ResumableUploadHandlerWithConfig is like ResumableUploadHandler, with
+// more options.
+func ResumableUploadHandlerWithConfig(store BlobStore, cfg ResumableUploadConfig) http.Handler {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxUploadSize
+	}
+	return &resumableUploadHandler{store: store, cfg: cfg}
+}
+
+type resumableUploadHandler struct {
+	store BlobStore
+	cfg   ResumableUploadConfig
+}
+
+func (h *resumableUploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", resumableVersion)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", resumableVersion)
+		w.Header().Set("Tus-Extension", "creation,checksum")
+		w.Header().Set("Tus-Checksum-Algorithm", "md5,sha1,sha256")
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if v := r.Header.Get("Tus-Resumable"); v != "" && v != resumableVersion {
+		writeJSONError(w, r, HTTPError{
+			Code:    http.StatusPreconditionFailed,
+			Message: fmt.Sprintf("Unsupported protocol version %q", v),
+			Header:  http.Header{"Tus-Version": {resumableVersion}},
+		})
+		return
+	}
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		err = h.create(w, r)
+	case http.MethodHead:
+		err = h.head(w, r)
+	case http.MethodPatch:
+		err = h.patch(w, r)
+	default:
+		w.Header().Set("Allow", "OPTIONS, POST, HEAD, PATCH")
+		err = InvalidMethodError{}
+	}
+	if err != nil {
+		writeJSONError(w, r, err)
+	}
+}
+
+func (h *resumableUploadHandler) create(w http.ResponseWriter, r *http.Request) error {
+	length, err := uploadHeaderInt(r, "Upload-Length")
+	if err != nil {
+		return err
+	}
+	if length > h.cfg.MaxSize {
+		return RequestEntityTooLargeError{}
+	}
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return err
+	}
+	info, err := h.store.Create(r.Context(), length, metadata)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", path.Join(r.URL.Path, info.ID))
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func (h *resumableUploadHandler) head(w http.ResponseWriter, r *http.Request) error {
+	info, err := h.store.Info(r.Context(), path.Base(r.URL.Path))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	if len(info.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatUploadMetadata(info.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *resumableUploadHandler) patch(w http.ResponseWriter, r *http.Request) error {
+	if r.Header.Get("Content-Type") != ContentTypeOffsetOctetStream {
+		return UnsupportedMediaTypeError{}
+	}
+	offset, err := uploadHeaderInt(r, "Upload-Offset")
+	if err != nil {
+		return err
+	}
+	info, err := h.store.Info(r.Context(), path.Base(r.URL.Path))
+	if err != nil {
+		return err
+	}
+	if offset != info.Offset {
+		return HTTPError{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("Upload is at offset %d", info.Offset),
+		}
+	}
+	remaining := info.Length - info.Offset
+	if r.ContentLength > remaining {
+		return RequestEntityTooLargeError{}
+	}
+	var body io.Reader = io.LimitReader(r.Body, remaining)
+	if v := r.Header.Get("Upload-Checksum"); v != "" {
+		chunk, err := readVerifiedChunk(body, v)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(chunk)
+	}
+	newOffset, err := h.store.Append(r.Context(), info.ID, offset, body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// readVerifiedChunk reads the chunk from r and verifies it against the
+// Upload-Checksum header value, e.g. "sha1 Kq5sNclPz7QV2+lfQIuc6R7oRu0=".
+func readVerifiedChunk(r io.Reader, checksum string) ([]byte, error) {
+	algorithm, encoded, _ := strings.Cut(checksum, " ")
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, InvalidParameterHintError{
+			Parameter: "Upload-Checksum",
+			Hint:      fmt.Sprintf("Unsupported algorithm %q", algorithm),
+		}
+	}
+	want, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, InvalidParameterError("Upload-Checksum")
+	}
+	chunk, err := ioutil.ReadAll(io.LimitReader(r, maxChecksumChunkSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(chunk) > maxChecksumChunkSize {
+		return nil, RequestEntityTooLargeError{}
+	}
+	h.Write(chunk)
+	if !bytes.Equal(want, h.Sum(nil)) {
+		return nil, HTTPError{Code: statusChecksumMismatch, Message: "Checksum mismatch"}
+	}
+	return chunk, nil
+}
+
+// uploadHeaderInt returns the non-negative integer in header key.
+func uploadHeaderInt(r *http.Request, key string) (int64, error) {
+	v := r.Header.Get(key)
+	if v == "" {
+		return 0, MissingParameterError(key)
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, InvalidParameterError(key)
+	}
+	return n, nil
+}
+
+// parseUploadMetadata parses the Upload-Metadata header, a comma-separated
+// list of keys and base64-encoded values, e.g. "filename d29ybGQ=,secret".
+func parseUploadMetadata(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, InvalidParameterError("Upload-Metadata")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, InvalidParameterHintError{
+				Parameter: "Upload-Metadata",
+				Hint:      fmt.Sprintf("Value of %q must be base64-encoded", key),
+			}
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// formatUploadMetadata is the inverse of parseUploadMetadata.
+func formatUploadMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key
+		if v := metadata[key]; v != "" {
+			pairs[i] += " " + base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// MemoryBlobStore is an in-memory BlobStore, e.g. for tests. Its zero value
+// is ready to use. It is safe for concurrent use.
+type MemoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string]*memoryBlob
+}
+
+type memoryBlob struct {
+	info BlobInfo
+	data []byte
+}
+
+// Create starts a new upload.
+func (s *MemoryBlobStore) Create(ctx context.Context, length int64, metadata map[string]string) (BlobInfo, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return BlobInfo{}, err
+	}
+	info := BlobInfo{ID: hex.EncodeToString(id[:]), Length: length, Metadata: metadata}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blobs == nil {
+		s.blobs = make(map[string]*memoryBlob)
+	}
+	s.blobs[info.ID] = &memoryBlob{info: info}
+	return info, nil
+}
+
+// Info returns the current state of an upload.
+func (s *MemoryBlobStore) Info(ctx context.Context, id string) (BlobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, found := s.blobs[id]
+	if !found {
+		return BlobInfo{}, NotFoundError{}
+	}
+	return blob.info, nil
+}
+
+// Append adds the bytes read from r to the upload.
+func (s *MemoryBlobStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	// Read outside the lock; slow clients must not block other uploads.
+	data, readErr := ioutil.ReadAll(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, found := s.blobs[id]
+	if !found {
+		return 0, NotFoundError{}
+	}
+	if offset != blob.info.Offset {
+		return blob.info.Offset, ConflictError{}
+	}
+	if remaining := blob.info.Length - offset; int64(len(data)) > remaining {
+		data = data[:remaining]
+	}
+	blob.data = append(blob.data, data...)
+	blob.info.Offset += int64(len(data))
+	return blob.info.Offset, readErr
+}
+
+// Bytes returns the bytes received so far for an upload, or nil
+// if the upload doesn't exist.
+func (s *MemoryBlobStore) Bytes(id string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if blob, found := s.blobs[id]; found {
+		return append([]byte(nil), blob.data...)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestResumableUploadHandler(t *testing.T) {
+	store := &MemoryBlobStore{}
+	h := ResumableUploadHandler(store)
+
+	do := func(method, url string, header http.Header, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := resumableVersion, w.Header().Get("Tus-Resumable"); want != have {
+			t.Errorf("%s %s: want Tus-Resumable %q, have %q", method, url, want, have)
+		}
+		return w
+	}
+
+	// Discover
+	w := do("OPTIONS", "/uploads", nil, "")
+	if want, have := http.StatusNoContent, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "creation,checksum", w.Header().Get("Tus-Extension"); want != have {
+		t.Errorf("want Tus-Extension %q, have %q", want, have)
+	}
+	if want, have := strconv.Itoa(DefaultMaxUploadSize), w.Header().Get("Tus-Max-Size"); want != have {
+		t.Errorf("want Tus-Max-Size %q, have %q", want, have)
+	}
+
+	// Create
+	w = do("POST", "/uploads", http.Header{
+		"Upload-Length":   {"11"},
+		"Upload-Metadata": {"filename " + base64.StdEncoding.EncodeToString([]byte("hello.txt")) + ",private"},
+	}, "")
+	if want, have := http.StatusCreated, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if want, have := "/uploads", path.Dir(location); want != have {
+		t.Fatalf("want Location in %q, have %q", want, location)
+	}
+	info, err := store.Info(context.Background(), path.Base(location))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := map[string]string{"filename": "hello.txt", "private": ""}, info.Metadata; !reflect.DeepEqual(want, have) {
+		t.Errorf("want metadata %v, have %v", want, have)
+	}
+
+	chunk := func(offset string) http.Header {
+		return http.Header{
+			"Content-Type":  {ContentTypeOffsetOctetStream},
+			"Upload-Offset": {offset},
+		}
+	}
+
+	// Append the first chunk
+	w = do("PATCH", location, chunk("0"), "hello")
+	if want, have := http.StatusNoContent, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body)
+	}
+	if want, have := "5", w.Header().Get("Upload-Offset"); want != have {
+		t.Errorf("want Upload-Offset %q, have %q", want, have)
+	}
+
+	// Resume at a stale offset
+	w = do("PATCH", location, chunk("0"), "hello")
+	if want, have := http.StatusConflict, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+
+	// Ask for the offset
+	w = do("HEAD", location, nil, "")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "5", w.Header().Get("Upload-Offset"); want != have {
+		t.Errorf("want Upload-Offset %q, have %q", want, have)
+	}
+	if want, have := "11", w.Header().Get("Upload-Length"); want != have {
+		t.Errorf("want Upload-Length %q, have %q", want, have)
+	}
+	if want, have := "filename aGVsbG8udHh0,private", w.Header().Get("Upload-Metadata"); want != have {
+		t.Errorf("want Upload-Metadata %q, have %q", want, have)
+	}
+
+	// Append the rest with a wrong and then a correct checksum
+	header := chunk("5")
+	header.Set("Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(make([]byte, sha1.Size)))
+	w = do("PATCH", location, header, " world")
+	if want, have := statusChecksumMismatch, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+	sum := sha1.Sum([]byte(" world"))
+	header.Set("Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:]))
+	w = do("PATCH", location, header, " world")
+	if want, have := http.StatusNoContent, w.Code; want != have {
+		t.Fatalf("want status %d, have %d: %s", want, have, w.Body)
+	}
+	if want, have := "hello world", string(store.Bytes(path.Base(location))); want != have {
+		t.Errorf("want data %q, have %q", want, have)
+	}
+	if info, _ := store.Info(context.Background(), path.Base(location)); !info.Complete() {
+		t.Errorf("want upload to be complete, have %+v", info)
+	}
+}
+
+func TestResumableUploadHandlerFailures(t *testing.T) {
+	store := &MemoryBlobStore{}
+	info, _ := store.Create(context.Background(), 3, nil)
+	location := "/uploads/" + info.ID
+
+	tests := []struct {
+		Method string
+		URL    string
+		Header http.Header
+		Body   string
+		Code   int
+	}{
+		{Method: "POST", URL: "/uploads", Code: http.StatusBadRequest},
+		{Method: "POST", URL: "/uploads", Header: http.Header{"Upload-Length": {"-1"}}, Code: http.StatusBadRequest},
+		{Method: "POST", URL: "/uploads", Header: http.Header{"Upload-Length": {strconv.Itoa(DefaultMaxUploadSize + 1)}}, Code: http.StatusRequestEntityTooLarge},
+		{Method: "POST", URL: "/uploads", Header: http.Header{"Upload-Length": {"1"}, "Upload-Metadata": {"name !!"}}, Code: http.StatusBadRequest},
+		{Method: "POST", URL: "/uploads", Header: http.Header{"Upload-Length": {"1"}, "Tus-Resumable": {"0.2.2"}}, Code: http.StatusPreconditionFailed},
+		{Method: "HEAD", URL: "/uploads/unknown", Code: http.StatusNotFound},
+		{Method: "GET", URL: location, Code: http.StatusMethodNotAllowed},
+		{Method: "PATCH", URL: location, Header: http.Header{"Upload-Offset": {"0"}}, Body: "abc", Code: http.StatusUnsupportedMediaType},
+		{Method: "PATCH", URL: location, Header: http.Header{"Content-Type": {ContentTypeOffsetOctetStream}}, Body: "abc", Code: http.StatusBadRequest},
+		{Method: "PATCH", URL: location, Header: http.Header{"Content-Type": {ContentTypeOffsetOctetStream}, "Upload-Offset": {"0"}}, Body: "abcd", Code: http.StatusRequestEntityTooLarge},
+		{Method: "PATCH", URL: location, Header: http.Header{"Content-Type": {ContentTypeOffsetOctetStream}, "Upload-Offset": {"0"}, "Upload-Checksum": {"crc32 AAAA"}}, Body: "abc", Code: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.Method, tt.URL, strings.NewReader(tt.Body))
+		for k, v := range tt.Header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		ResumableUploadHandler(store).ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d: %s", i, want, have, w.Body)
+		}
+	}
+	if want, have := 0, len(store.Bytes(info.ID)); want != have {
+		t.Errorf("want %d bytes stored, have %d", want, have)
+	}
+}
+
+func TestResumableUploadHandlerMaxSize(t *testing.T) {
+	h := ResumableUploadHandlerWithConfig(&MemoryBlobStore{}, ResumableUploadConfig{MaxSize: 10})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/uploads", nil))
+	if want, have := "10", w.Header().Get("Tus-Max-Size"); want != have {
+		t.Errorf("want Tus-Max-Size %q, have %q", want, have)
+	}
+
+	tests := []struct {
+		Length string
+		Code   int
+	}{
+		{Length: "10", Code: http.StatusCreated},
+		{Length: "11", Code: http.StatusRequestEntityTooLarge},
+		{Length: "9223372036854775807", Code: http.StatusRequestEntityTooLarge},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", "/uploads", nil)
+		req.Header.Set("Upload-Length", tt.Length)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d: %s", i, want, have, w.Body)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Warmup runs tasks concurrently, e.g. to prime caches and connection
+// pools before the server receives traffic. It waits until all tasks
+// have finished or ctx is done, and returns the errors of the tasks,
+// or the error of ctx. Panics in tasks are returned as errors.
+//
+// Use Readiness.Warmup to keep a readiness endpoint reporting "not
+// ready" while the tasks are running.
+func Warmup(ctx context.Context, tasks ...func(context.Context) error) error {
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task func(context.Context) error) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					errs[i] = fmt.Errorf("warmup task %d panicked: %v", i, err)
+				}
+			}()
+			errs[i] = task(ctx)
+		}(i, task)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return errors.Join(errs...)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Readiness is a readiness endpoint for load balancers and orchestrators
+// like Kubernetes. It responds with 503 Service Unavailable until the
+// server is ready, and with 200 OK afterwards.
+//
+// Example:
+//
+//	ready := httputil.NewReadiness()
+//	mux.Handle("/readyz", ready)
+//	go srv.ListenAndServe()
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	if err := ready.Warmup(ctx, loadCache, pingDatabase); err != nil {
+//	  logger.Warn("Warmup incomplete", "error", err)
+//	}
+//
+//	srv.RegisterOnShutdown(func() { ready.SetReady(false) })
+type Readiness struct {
+	ready   atomic.Bool
+	started time.Time
+}
+
+// NewReadiness returns a Readiness that is not ready yet.
+func NewReadiness() *Readiness {
+	return &Readiness{started: time.Now()}
+}
+
+// Ready returns true if the server is ready to receive traffic.
+func (rd *Readiness) Ready() bool {
+	return rd.ready.Load()
+}
+
+// SetReady sets whether the server is ready to receive traffic, e.g.
+// to take it out of rotation when it shuts down.
+func (rd *Readiness) SetReady(ready bool) {
+	rd.ready.Store(ready)
+}
+
+// Warmup runs tasks like the package-level Warmup and marks the server
+// as ready when they are finished or ctx is done, even if tasks failed:
+// a cold cache is better than no traffic at all.
+func (rd *Readiness) Warmup(ctx context.Context, tasks ...func(context.Context) error) error {
+	defer rd.SetReady(true)
+	return Warmup(ctx, tasks...)
+}
+
+// ServeHTTP implements http.Handler.
+func (rd *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !rd.Ready() {
+		writeJSONError(w, r, ServiceUnavailableError{RetryAfter: time.Second})
+		return
+	}
+	WriteJSON(w, map[string]interface{}{
+		"status": "ready",
+		"uptime": time.Since(rd.started).Round(time.Second).String(),
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	var n atomic.Int32
+	task := func(ctx context.Context) error {
+		n.Add(1)
+		return nil
+	}
+	if err := Warmup(context.Background(), task, task); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int32(2), n.Load(); want != have {
+		t.Errorf("want %d tasks, have %d", want, have)
+	}
+
+	errFailed := errors.New("failed")
+	err := Warmup(context.Background(), task, func(ctx context.Context) error {
+		return errFailed
+	}, func(ctx context.Context) error {
+		panic("boom")
+	})
+	if !errors.Is(err, errFailed) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("want task errors, have %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = Warmup(ctx, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want deadline exceeded, have %v", err)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ready := NewReadiness()
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ready.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- ready.Warmup(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-release
+			return errors.New("cache unavailable")
+		})
+	}()
+
+	<-started
+	w := get()
+	if want, have := http.StatusServiceUnavailable, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "1", w.Header().Get("Retry-After"); want != have {
+		t.Errorf("want Retry-After %q, have %q", want, have)
+	}
+
+	close(release)
+	if err := <-done; err == nil {
+		t.Error("want warmup error")
+	}
+	if want, have := http.StatusOK, get().Code; want != have {
+		t.Fatalf("want status %d after failed warmup, have %d", want, have)
+	}
+
+	ready.SetReady(false)
+	if want, have := http.StatusServiceUnavailable, get().Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WebSocket message types as specified in RFC 6455, Section 11.8.
+// They match the constants of github.com/gorilla/websocket.
+const (
+	wsTextMessage  = 1
+	wsCloseMessage = 8
+	wsPingMessage  = 9
+)
+
+// WebSocketConn is an upgraded WebSocket connection. It is implemented
+// by *websocket.Conn of github.com/gorilla/websocket, so this package
+// doesn't depend on a specific WebSocket library.
+type WebSocketConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	Close() error
+}
+
+// WSConfig configures a WSConn.
+type WSConfig struct {
+	// PingInterval is the interval of pings sent to the peer.
+	// It defaults to 30 seconds.
+	PingInterval time.Duration
+	// PongTimeout is the time without a message or pong from the peer
+	// after which reads fail. It defaults to twice the PingInterval.
+	PongTimeout time.Duration
+	// WriteTimeout is the time limit of writing a message.
+	// It defaults to 10 seconds.
+	WriteTimeout time.Duration
+}
+
+// WSConn wraps an upgraded WebSocket connection to exchange JSON
+// messages, and keeps it alive with pings. Errors are sent as text
+// messages in the ErrorEnvelope format of WriteJSONError, so clients
+// can handle HTTP and WebSocket errors alike.
+//
+// Reads must be done by a single goroutine, as with the underlying
+// connection; writes are safe for concurrent use.
+//
+// Example:
+//
+//	if !httputil.IsWebsocketUpgrade(r) {
+//	  ...
+//	}
+//	conn, err := upgrader.Upgrade(w, r, nil)
+//	if err != nil {
+//	  return
+//	}
+//	ws := httputil.NewWSConn(conn, httputil.WSConfig{})
+//	defer ws.Close()
+//	for {
+//	  var msg Message
+//	  if err := ws.ReadJSON(&msg); err != nil {
+//	    if _, ok := err.(httputil.InvalidJSONError); ok {
+//	      ws.WriteError(err)
+//	      continue
+//	    }
+//	    return
+//	  }
+//	  ...
+//	}
+type WSConn struct {
+	conn WebSocketConn
+	cfg  WSConfig
+
+	mu        sync.Mutex // serializes writes
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWSConn returns a WSConn for conn and starts sending pings.
+// Call Close to stop them and close the connection.
+func NewWSConn(conn WebSocketConn, cfg WSConfig) *WSConn {
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = 30 * time.Second
+	}
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = 2 * cfg.PingInterval
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 10 * time.Second
+	}
+	c := &WSConn{conn: conn, cfg: cfg, done: make(chan struct{})}
+	conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+	})
+	go c.keepalive()
+	return c
+}
+
+// keepalive sends pings until the connection is closed.
+func (c *WSConn) keepalive() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			err := c.conn.WriteControl(wsPingMessage, nil, time.Now().Add(c.cfg.WriteTimeout))
+			c.mu.Unlock()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it into v. Any message
+// extends the read deadline. It returns InvalidJSONError if the message
+// can't be decoded, in which case the connection can still be used.
+func (c *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+	if err := json.Unmarshal(data, v); err != nil {
+		return InvalidJSONError{fmt.Errorf("invalid JSON data: %v", err)}
+	}
+	return nil
+}
+
+// WriteJSON sends v as a JSON text message.
+func (c *WSConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+	return c.conn.WriteMessage(wsTextMessage, data)
+}
+
+// WriteError sends err in the ErrorEnvelope format of WriteJSONError,
+// e.g. {"error":{"code":400,"message":"Invalid JSON"}}.
+func (c *WSConn) WriteError(err interface{}) error {
+	return c.WriteJSON(ErrorEnvelope{Error: NewErrorBody(err)})
+}
+
+// Close stops the pings, sends a close message with status 1000
+// (normal closure), and closes the connection.
+func (c *WSConn) Close() error {
+	return c.closeWithStatus(1000)
+}
+
+// GoAway stops the pings, sends a close message with status 1001
+// (going away), and closes the connection. Use it on shutdown, see
+// StreamRegistry, so clients reconnect to another instance.
+func (c *WSConn) GoAway() error {
+	return c.closeWithStatus(1001)
+}
+
+func (c *WSConn) closeWithStatus(code uint16) error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		// Status code in network byte order
+		c.conn.WriteControl(wsCloseMessage, []byte{byte(code >> 8), byte(code)}, time.Now().Add(c.cfg.WriteTimeout))
+		c.mu.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWebSocketConn records the messages written to it and returns
+// the messages of in on reads.
+type fakeWebSocketConn struct {
+	in chan []byte
+
+	mu       sync.Mutex
+	written  [][]byte
+	controls []int
+	data     []byte // of the last control message
+	closed   bool
+	pong     func(string) error
+}
+
+func (c *fakeWebSocketConn) ReadMessage() (int, []byte, error) {
+	data, ok := <-c.in
+	if !ok {
+		return 0, nil, io.EOF
+	}
+	return wsTextMessage, data, nil
+}
+
+func (c *fakeWebSocketConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, data)
+	return nil
+}
+
+func (c *fakeWebSocketConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.controls = append(c.controls, messageType)
+	c.data = data
+	return nil
+}
+
+func (c *fakeWebSocketConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakeWebSocketConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *fakeWebSocketConn) SetPongHandler(h func(string) error) {
+	c.pong = h
+}
+
+func (c *fakeWebSocketConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func TestWSConn(t *testing.T) {
+	fake := &fakeWebSocketConn{in: make(chan []byte, 2)}
+	ws := NewWSConn(fake, WSConfig{PingInterval: 10 * time.Millisecond})
+	if fake.pong == nil {
+		t.Fatal("want pong handler")
+	}
+
+	fake.in <- []byte(`{"name":"Oliver"}`)
+	fake.in <- []byte(`{"name":`)
+	var msg struct {
+		Name string `json:"name"`
+	}
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "Oliver", msg.Name; want != have {
+		t.Errorf("want name %q, have %q", want, have)
+	}
+	err := ws.ReadJSON(&msg)
+	if _, ok := err.(InvalidJSONError); !ok {
+		t.Fatalf("want InvalidJSONError, have %v", err)
+	}
+	if err := ws.WriteError(err); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteJSON(map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err := ws.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.written) != 2 {
+		t.Fatalf("want 2 messages, have %d", len(fake.written))
+	}
+	var env ErrorEnvelope
+	if err := json.Unmarshal(fake.written[0], &env); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 400, env.Error.Code; want != have {
+		t.Errorf("want error code %d, have %d", want, have)
+	}
+	if !EqualJSON([]byte(`{"n":1}`), fake.written[1]) {
+		t.Errorf("want %s, have %s", `{"n":1}`, fake.written[1])
+	}
+	if len(fake.controls) < 2 || fake.controls[0] != wsPingMessage || fake.controls[len(fake.controls)-1] != wsCloseMessage {
+		t.Errorf("want pings followed by a close message, have %v", fake.controls)
+	}
+	if !fake.closed {
+		t.Error("want connection to be closed")
+	}
+}
+
+func TestWSConnGoAway(t *testing.T) {
+	fake := &fakeWebSocketConn{in: make(chan []byte)}
+	ws := NewWSConn(fake, WSConfig{})
+	if err := ws.GoAway(); err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if want, have := []int{wsCloseMessage}, fake.controls; len(have) != 1 || have[0] != want[0] {
+		t.Fatalf("want controls %v, have %v", want, have)
+	}
+	if want, have := "\x03\xe9", string(fake.data); want != have {
+		t.Errorf("want status 1001, have %q", have)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotsRule is a group of rules in robots.txt.
+type RobotsRule struct {
+	// UserAgent is the crawler the rule applies to. It defaults to "*".
+	UserAgent string
+	// Allow and Disallow are path prefixes the crawler may or may not visit.
+	Allow    []string
+	Disallow []string
+}
+
+// RobotsConfig configures RobotsHandler.
+type RobotsConfig struct {
+	// Rules are the groups of rules. If empty, all crawlers are disallowed
+	// everywhere, which is what most APIs want.
+	Rules []RobotsRule
+	// Sitemaps are absolute URLs of sitemaps.
+	Sitemaps []string
+}
+
+// RobotsHandler returns a handler for /robots.txt.
+//
+// Example:
+//
+//	router.Handle("/robots.txt", httputil.RobotsHandler(httputil.RobotsConfig{
+//	  Rules: []httputil.RobotsRule{{Allow: []string{"/docs/"}, Disallow: []string{"/"}}},
+//	}))
+func RobotsHandler(cfg RobotsConfig) http.Handler {
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = []RobotsRule{{Disallow: []string{"/"}}}
+	}
+	var buf bytes.Buffer
+	for i, rule := range rules {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		ua := rule.UserAgent
+		if ua == "" {
+			ua = "*"
+		}
+		buf.WriteString("User-agent: " + ua + "\n")
+		for _, p := range rule.Allow {
+			buf.WriteString("Allow: " + p + "\n")
+		}
+		for _, p := range rule.Disallow {
+			buf.WriteString("Disallow: " + p + "\n")
+		}
+		if len(rule.Allow) == 0 && len(rule.Disallow) == 0 {
+			// An empty Disallow allows everything
+			buf.WriteString("Disallow:\n")
+		}
+	}
+	if len(cfg.Sitemaps) > 0 {
+		buf.WriteString("\n")
+		for _, u := range cfg.Sitemaps {
+			buf.WriteString("Sitemap: " + u + "\n")
+		}
+	}
+	return staticContentHandler("text/plain; charset=utf-8", buf.Bytes(), 24*time.Hour)
+}
+
+// FaviconHandler returns a handler for /favicon.ico serving icon, e.g.
+// embedded with go:embed. If icon is empty, it responds with
+// 204 No Content, so browsers stop asking and logs stay clean.
+func FaviconHandler(icon []byte) http.Handler {
+	if len(icon) == 0 {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	return staticContentHandler(http.DetectContentType(icon), icon, 7*24*time.Hour)
+}
+
+// staticContentHandler serves content with an ETag and caching headers.
+func staticContentHandler(contentType string, content []byte, maxAge time.Duration) http.Handler {
+	sum := sha256.Sum256(content)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGetOrHead(r) {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, r, InvalidMethodError{})
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	})
+}
+
+// SecurityTxt is the content of /.well-known/security.txt as
+// specified in RFC 9116.
+type SecurityTxt struct {
+	// Contact are URIs to report vulnerabilities, e.g.
+	// "mailto:security@example.com". At least one is required.
+	Contact []string
+	// Expires is the date after which the file is considered stale.
+	// It defaults to one year after the file is registered.
+	Expires time.Time
+	// Encryption, Acknowledgments, Policy, Hiring, and Canonical are
+	// optional URIs.
+	Encryption      []string
+	Acknowledgments []string
+	Policy          []string
+	Hiring          []string
+	Canonical       []string
+	// PreferredLanguages is a list of language tags, e.g. "en, de".
+	PreferredLanguages string
+}
+
+// String returns the content of security.txt.
+func (st SecurityTxt) String() string {
+	var sb strings.Builder
+	field := func(name string, values []string) {
+		for _, v := range values {
+			sb.WriteString(name + ": " + v + "\n")
+		}
+	}
+	field("Contact", st.Contact)
+	sb.WriteString("Expires: " + st.Expires.UTC().Format(time.RFC3339) + "\n")
+	field("Encryption", st.Encryption)
+	field("Acknowledgments", st.Acknowledgments)
+	field("Policy", st.Policy)
+	field("Hiring", st.Hiring)
+	field("Canonical", st.Canonical)
+	if st.PreferredLanguages != "" {
+		sb.WriteString("Preferred-Languages: " + st.PreferredLanguages + "\n")
+	}
+	return sb.String()
+}
+
+// WellKnown is a registry of the documents below /.well-known/, as
+// specified in RFC 8615. Mount it at that path; unknown documents
+// return NotFoundError. It is safe for concurrent use.
+//
+// Example:
+//
+//	wk := httputil.NewWellKnown()
+//	wk.SecurityTxt(httputil.SecurityTxt{Contact: []string{"mailto:security@example.com"}})
+//	wk.ChangePassword("https://example.com/account/password")
+//	wk.JSON("assetlinks.json", assetLinks)
+//	router.PathPrefix("/.well-known/").Handler(wk)
+type WellKnown struct {
+	mu       sync.RWMutex
+	handlers map[string]http.Handler
+}
+
+// NewWellKnown returns an empty registry.
+func NewWellKnown() *WellKnown {
+	return &WellKnown{handlers: make(map[string]http.Handler)}
+}
+
+// Handle registers h for the document name, e.g. "openid-configuration".
+// It also serves the sub-paths of name, e.g. "acme-challenge/{token}"
+// for "acme-challenge".
+func (wk *WellKnown) Handle(name string, h http.Handler) {
+	wk.mu.Lock()
+	wk.handlers[strings.Trim(name, "/")] = h
+	wk.mu.Unlock()
+}
+
+// SecurityTxt registers security.txt.
+func (wk *WellKnown) SecurityTxt(st SecurityTxt) {
+	if st.Expires.IsZero() {
+		st.Expires = time.Now().AddDate(1, 0, 0)
+	}
+	wk.Handle("security.txt", staticContentHandler("text/plain; charset=utf-8", []byte(st.String()), 24*time.Hour))
+}
+
+// ChangePassword registers change-password to redirect to url, the page
+// where users change their password, so password managers can find it.
+func (wk *WellKnown) ChangePassword(url string) {
+	wk.Handle("change-password", http.RedirectHandler(url, http.StatusFound))
+}
+
+// JSON registers the document name serving data as JSON, e.g.
+// "assetlinks.json" or "apple-app-site-association".
+func (wk *WellKnown) JSON(name string, data interface{}) error {
+	h, err := StaticJSON(data)
+	if err != nil {
+		return err
+	}
+	wk.Handle(name, h)
+	return nil
+}
+
+// ServeHTTP serves the registered document that the path of r refers to.
+func (wk *WellKnown) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path
+	if i := strings.Index(name, "/.well-known/"); i >= 0 {
+		name = name[i+len("/.well-known/"):]
+	}
+	name = strings.Trim(name, "/")
+	wk.mu.RLock()
+	h, found := wk.handlers[name]
+	if !found {
+		// Documents with sub-paths, e.g. "acme-challenge/{token}"
+		if i := strings.IndexByte(name, '/'); i > 0 {
+			h, found = wk.handlers[name[:i]]
+		}
+	}
+	wk.mu.RUnlock()
+	if !found {
+		writeJSONError(w, r, NotFoundError{})
+		return
+	}
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRobotsHandler(t *testing.T) {
+	tests := []struct {
+		Config RobotsConfig
+		Body   string
+	}{
+		{
+			Config: RobotsConfig{},
+			Body:   "User-agent: *\nDisallow: /\n",
+		},
+		{
+			Config: RobotsConfig{
+				Rules: []RobotsRule{
+					{UserAgent: "Googlebot", Allow: []string{"/docs/"}, Disallow: []string{"/"}},
+					{},
+				},
+				Sitemaps: []string{"https://example.com/sitemap.xml"},
+			},
+			Body: "User-agent: Googlebot\nAllow: /docs/\nDisallow: /\n\nUser-agent: *\nDisallow:\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		RobotsHandler(tt.Config).ServeHTTP(w, httptest.NewRequest("GET", "/robots.txt", nil))
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+		if want, have := "text/plain; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+			t.Errorf("#%d: want Content-Type %q, have %q", i, want, have)
+		}
+		if want, have := tt.Body, w.Body.String(); want != have {
+			t.Errorf("#%d: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestFaviconHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	FaviconHandler(nil).ServeHTTP(w, httptest.NewRequest("GET", "/favicon.ico", nil))
+	if want, have := http.StatusNoContent, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+
+	icon := []byte("\x00\x00\x01\x00\x01\x00\x10\x10")
+	h := FaviconHandler(icon)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/favicon.ico", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "image/x-icon", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("want Content-Type %q, have %q", want, have)
+	}
+	if want, have := string(icon), w.Body.String(); want != have {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	req := httptest.NewRequest("GET", "/favicon.ico", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if want, have := http.StatusNotModified, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/favicon.ico", nil))
+	if want, have := http.StatusMethodNotAllowed, w.Code; want != have {
+		t.Errorf("want status %d, have %d", want, have)
+	}
+}
+
+func TestWellKnown(t *testing.T) {
+	wk := NewWellKnown()
+	wk.SecurityTxt(SecurityTxt{
+		Contact:            []string{"mailto:security@example.com"},
+		Expires:            time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		PreferredLanguages: "en, de",
+	})
+	wk.ChangePassword("https://example.com/account/password")
+	if err := wk.JSON("assetlinks.json", []map[string]interface{}{{"relation": []string{"delegate_permission/common.handle_all_urls"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Path     string
+		Code     int
+		Body     string
+		Location string
+	}{
+		{
+			Path: "/.well-known/security.txt",
+			Code: http.StatusOK,
+			Body: "Contact: mailto:security@example.com\nExpires: 2030-01-01T00:00:00Z\nPreferred-Languages: en, de\n",
+		},
+		{
+			Path:     "/.well-known/change-password",
+			Code:     http.StatusFound,
+			Location: "https://example.com/account/password",
+		},
+		{
+			Path: "/.well-known/assetlinks.json",
+			Code: http.StatusOK,
+			Body: `[{"relation":["delegate_permission/common.handle_all_urls"]}]`,
+		},
+		{
+			Path: "/.well-known/openid-configuration",
+			Code: http.StatusNotFound,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		wk.ServeHTTP(w, httptest.NewRequest("GET", tt.Path, nil))
+		if want, have := tt.Code, w.Code; want != have {
+			t.Errorf("#%d: want status %d, have %d", i, want, have)
+		}
+		if tt.Body != "" {
+			if want, have := tt.Body, w.Body.String(); want != have && !EqualJSON([]byte(want), []byte(have)) {
+				t.Errorf("#%d: want %q, have %q", i, want, have)
+			}
+		}
+		if want, have := tt.Location, w.Header().Get("Location"); want != have {
+			t.Errorf("#%d: want Location %q, have %q", i, want, have)
+		}
+	}
+}
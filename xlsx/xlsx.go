@@ -0,0 +1,129 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+// Package xlsx implements a httputil.TabularWriter that streams
+// Office Open XML spreadsheets (.xlsx). It is kept in a separate
+// package so that services only exporting CSV don't depend on it.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentType is the content type of XLSX files.
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Writer writes tabular data as a workbook with a single worksheet.
+// Cells are written as inline strings, so the worksheet is streamed
+// without keeping the rows in memory.
+type Writer struct {
+	// SheetName is the name of the worksheet. It defaults to "Sheet1".
+	SheetName string
+}
+
+// ContentType returns the content type of XLSX files.
+func (Writer) ContentType() string { return ContentType }
+
+// WriteTable writes the header and the rows as XLSX.
+func (x Writer) WriteTable(w io.Writer, header []string, rows func(yield func([]string))) error {
+	name := x.SheetName
+	if name == "" {
+		name = "Sheet1"
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", relsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXML, escape(name))},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+	} {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	sw := &sheetWriter{w: fw}
+	sw.writeString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if len(header) > 0 {
+		sw.writeRow(header)
+	}
+	if rows != nil {
+		rows(sw.writeRow)
+	}
+	sw.writeString(`</sheetData></worksheet>`)
+	if sw.err != nil {
+		return sw.err
+	}
+	return zw.Close()
+}
+
+// sheetWriter writes the rows of a worksheet, remembering the first error.
+type sheetWriter struct {
+	w   io.Writer
+	row int
+	err error
+}
+
+func (sw *sheetWriter) writeString(s string) {
+	if sw.err == nil {
+		_, sw.err = io.WriteString(sw.w, s)
+	}
+}
+
+func (sw *sheetWriter) writeRow(record []string) {
+	sw.row++
+	sw.writeString(fmt.Sprintf(`<row r="%d">`, sw.row))
+	for col, value := range record {
+		sw.writeString(fmt.Sprintf(`<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+			columnName(col), sw.row, escape(value)))
+	}
+	sw.writeString(`</row>`)
+}
+
+// columnName returns the spreadsheet name of the zero-based column,
+// e.g. "A" for 0 and "AA" for 26.
+func columnName(col int) string {
+	var name []byte
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = append([]byte{byte('A' + (col-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const contentTypesXML = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const relsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbookXML = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const workbookRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
@@ -0,0 +1,80 @@
+// Copyright 2017 Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olivere/httputil"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		Col  int
+		Want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, columnName(tt.Col); want != have {
+			t.Errorf("columnName(%d): want %q, have %q", tt.Col, want, have)
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	req := httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("Accept", ContentType)
+	w := httptest.NewRecorder()
+	httputil.WriteTable(w, req, 200, []string{"id", "name"}, func(yield func([]string)) {
+		yield([]string{"1", "Tom & Jerry"})
+	}, httputil.CSVWriter{}, Writer{SheetName: "Report"})
+
+	if want, have := ContentType, w.Header().Get("Content-Type"); want != have {
+		t.Fatalf("want Content-Type %q, have %q", want, have)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, found := files[name]; !found {
+			t.Errorf("expected file %q in workbook", name)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `name="Report"`) {
+		t.Errorf("expected sheet name in workbook; got: %s", files["xl/workbook.xml"])
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">Tom &amp; Jerry</t></is></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("expected worksheet to contain %s; got: %s", want, sheet)
+		}
+	}
+}